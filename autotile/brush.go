@@ -0,0 +1,333 @@
+// Package autotile paints terrain onto tile layers, picking tiles whose corners join up with
+// those of their neighbours the way Tiled's terrain brush does.
+//
+// A Brush works on the corners of tiles, so it's built from a tileset's (legacy) Terrain or
+// from a corner (or mixed) WangSet. Terrain is painted onto the corners (vertices) of cells;
+// every cell touching a painted corner is given a tile whose corners match, chosen at random
+// from the matching tiles weighted by Tile.Probability.
+package autotile
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math/rand"
+	"sort"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+// Order of corners within a cell, as for common.Tile.Terrain()
+const (
+	topLeft = iota
+	topRight
+	bottomLeft
+	bottomRight
+)
+
+// Offset of each corner's vertex from the cell's own x,y
+var cornerOffsets = [4]image.Point{
+	topLeft:     {0, 0},
+	topRight:    {1, 0},
+	bottomLeft:  {0, 1},
+	bottomRight: {1, 1},
+}
+
+// Option configures a Brush
+type Option func(*Brush)
+
+// Choose between matching tiles with the given source of randomness (eg. a seeded one, so
+// results can be reproduced). By default the math/rand package functions are used.
+func WithRand(r *rand.Rand) Option {
+	return func(b *Brush) {
+		b.rng = r
+	}
+}
+
+// Brush paints terrain onto tile layers using the tiles of a single wang set
+type Brush struct {
+	set     *common.WangSet
+	terrain map[*common.Terrain]*common.WangColor
+	tiles   []candidate
+	corner  map[*common.Tile][4]int
+	rng     *rand.Rand
+}
+
+// A tile the brush can place, with the colours of it's corners (0 is no colour)
+type candidate struct {
+	tile    *common.Tile
+	corners [4]int
+	weight  float64
+}
+
+// NewBrush creates a brush placing the tiles of the given wang set. Only corner colours are
+// considered, so edge sets can't be used.
+func NewBrush(set *common.WangSet, opts ...Option) (*Brush, error) {
+	if set.Type == common.WangSetTypeEdge {
+		return nil, errors.New(fmt.Sprintf("Wang set %s has no corner colours, brushes need a %s or %s set", set.Name, common.WangSetTypeCorner, common.WangSetTypeMixed))
+	}
+
+	b := &Brush{
+		set:     set,
+		terrain: map[*common.Terrain]*common.WangColor{},
+		corner:  map[*common.Tile][4]int{},
+	}
+	for _, tile := range set.Tiles() {
+		id, _ := set.WangID(tile)
+		c := candidate{
+			tile:    tile,
+			corners: [4]int{id[common.WangTopLeft], id[common.WangTopRight], id[common.WangBottomLeft], id[common.WangBottomRight]},
+			weight:  tile.Probability,
+		}
+		for _, index := range c.corners {
+			if colour, ok := set.Colour(index); ok {
+				c.weight *= colour.Probability
+			}
+		}
+		b.tiles = append(b.tiles, c)
+		b.corner[tile] = c.corners
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b, nil
+}
+
+// NewTerrainBrush creates a brush placing the tiles of the given tileset that have terrain
+func NewTerrainBrush(tset *common.Tileset, opts ...Option) *Brush {
+	set := tset.TerrainWangSet(tset.Name)
+	b, _ := NewBrush(set, opts...) // always a corner set
+
+	for i, terr := range tset.Terrain() {
+		b.terrain[terr] = set.Colours()[i]
+	}
+	return b
+}
+
+// The wang set whose tiles this brush places. For a terrain brush this is built from the
+// tileset's terrain, with a colour per terrain.
+func (b *Brush) WangSet() *common.WangSet {
+	return b.set
+}
+
+// PaintTerrain paints the given terrain over the cells in area. All four corners of every cell
+// in the area are set to the terrain, cells around the area are given tiles that transition to it.
+func (b *Brush) PaintTerrain(layer *common.TileLayer, area image.Rectangle, terrain *common.Terrain) error {
+	colour, ok := b.terrain[terrain]
+	if !ok {
+		return errors.New(fmt.Sprintf("Terrain %s is not painted by brush %s", terrainName(terrain), b.set.Name))
+	}
+	return b.Paint(layer, area, colour)
+}
+
+// Paint paints the given colour over the cells in area, as PaintTerrain
+func (b *Brush) Paint(layer *common.TileLayer, area image.Rectangle, colour *common.WangColor) error {
+	index, err := b.colourIndex(colour)
+	if err != nil {
+		return err
+	}
+
+	vertices := map[image.Point]int{}
+	for y := area.Min.Y; y <= area.Max.Y && !area.Empty(); y++ {
+		for x := area.Min.X; x <= area.Max.X; x++ {
+			vertices[image.Pt(x, y)] = index
+		}
+	}
+	return b.apply(layer, vertices)
+}
+
+// FillTerrain sets the terrain of the corners of cells from the given mask, where corners[y][x]
+// is the top left corner of the cell at origin + x,y. A mask of w+1 by h+1 corners covers w by h
+// cells. A nil terrain leaves the corner as it is.
+//
+// This is intended for terrain generated elsewhere (eg. from noise), where setting corners
+// directly avoids the conflicts of painting neighbouring cells with different terrain.
+func (b *Brush) FillTerrain(layer *common.TileLayer, origin image.Point, corners [][]*common.Terrain) error {
+	mask := make([][]*common.WangColor, len(corners))
+	for y, row := range corners {
+		mask[y] = make([]*common.WangColor, len(row))
+		for x, terrain := range row {
+			if terrain == nil {
+				continue
+			}
+			colour, ok := b.terrain[terrain]
+			if !ok {
+				return errors.New(fmt.Sprintf("Terrain %s is not painted by brush %s", terrainName(terrain), b.set.Name))
+			}
+			mask[y][x] = colour
+		}
+	}
+	return b.Fill(layer, origin, mask)
+}
+
+// Fill sets the colour of the corners of cells from the given mask, as FillTerrain
+func (b *Brush) Fill(layer *common.TileLayer, origin image.Point, corners [][]*common.WangColor) error {
+	vertices := map[image.Point]int{}
+	for y, row := range corners {
+		for x, colour := range row {
+			if colour == nil {
+				continue
+			}
+			index, err := b.colourIndex(colour)
+			if err != nil {
+				return err
+			}
+			vertices[origin.Add(image.Pt(x, y))] = index
+		}
+	}
+	return b.apply(layer, vertices)
+}
+
+// Set the colours of the given vertices & give every cell touching one a matching tile.
+// Corners of those cells that aren't set keep the colour of the tiles around them.
+func (b *Brush) apply(layer *common.TileLayer, vertices map[image.Point]int) error {
+	cells := map[image.Point]bool{}
+	for v := range vertices {
+		for _, off := range cornerOffsets {
+			cell := v.Sub(off)
+			if layer.Infinite() || cell.In(layer.Bounds()) {
+				cells[cell] = true
+			}
+		}
+	}
+
+	// a fixed order, so a seeded brush always paints the same tiles
+	ordered := []image.Point{}
+	for cell := range cells {
+		ordered = append(ordered, cell)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].Y != ordered[j].Y {
+			return ordered[i].Y < ordered[j].Y
+		}
+		return ordered[i].X < ordered[j].X
+	})
+
+	placed := map[image.Point]*common.Tile{}
+	for _, cell := range ordered {
+		want := [4]int{}
+		fixed := [4]bool{}
+		for i, off := range cornerOffsets {
+			index, ok := vertices[cell.Add(off)]
+			if !ok {
+				index = b.vertex(layer, placed, cell.Add(off))
+			}
+			want[i] = index
+			fixed[i] = ok
+		}
+
+		tile := b.pick(want, fixed)
+		if tile == nil {
+			return errors.New(fmt.Sprintf("No tile in wang set %s has corners %s", b.set.Name, b.describe(want, fixed)))
+		}
+		placed[cell] = tile
+	}
+
+	// only change the layer once every cell has a tile
+	for cell, tile := range placed {
+		layer.Put(cell.X, cell.Y, tile)
+	}
+	return nil
+}
+
+// The colour of the given vertex from the tiles around it, preferring those placed by this paint
+// over those already in the layer. This is 0 (any colour) if none of the tiles are in the set.
+func (b *Brush) vertex(layer *common.TileLayer, placed map[image.Point]*common.Tile, v image.Point) int {
+	for i, off := range cornerOffsets {
+		if index := b.corner[placed[v.Sub(off)]][i]; index != 0 {
+			return index
+		}
+	}
+	for i, off := range cornerOffsets {
+		cell := v.Sub(off)
+		if index := b.corner[layer.Get(cell.X, cell.Y)][i]; index != 0 {
+			return index
+		}
+	}
+	return 0
+}
+
+// Pick a tile with the fixed corners, preferring those matching the most other corners (so
+// where the set lacks a transition the closest tile is used). Ties are broken at random,
+// weighted by the tiles' probability; tiles (or colours) with a probability of 0 are never picked.
+func (b *Brush) pick(want [4]int, fixed [4]bool) *common.Tile {
+	best := []candidate{}
+	bestScore := -1
+	for _, c := range b.tiles {
+		if c.weight <= 0 {
+			continue
+		}
+		score := 0
+		ok := true
+		for i := range want {
+			if want[i] == 0 || c.corners[i] == want[i] {
+				score++
+			} else if fixed[i] {
+				ok = false
+				break
+			}
+		}
+		if !ok || score < bestScore {
+			continue
+		}
+		if score > bestScore {
+			best, bestScore = []candidate{}, score
+		}
+		best = append(best, c)
+	}
+	if len(best) == 0 {
+		return nil
+	}
+
+	total := 0.0
+	for _, c := range best {
+		total += c.weight
+	}
+	r := b.float() * total
+	for _, c := range best {
+		r -= c.weight
+		if r < 0 {
+			return c.tile
+		}
+	}
+	return best[len(best)-1].tile
+}
+
+func (b *Brush) float() float64 {
+	if b.rng != nil {
+		return b.rng.Float64()
+	}
+	return rand.Float64()
+}
+
+func (b *Brush) colourIndex(colour *common.WangColor) (int, error) {
+	index := b.set.ColourIndex(colour)
+	if index == 0 {
+		name := "<nil>"
+		if colour != nil {
+			name = colour.Name
+		}
+		return 0, errors.New(fmt.Sprintf("Colour %s is not in wang set %s", name, b.set.Name))
+	}
+	return index, nil
+}
+
+// Names of the wanted colours of corners, in order top left, top right, bottom left, bottom right
+func (b *Brush) describe(want [4]int, fixed [4]bool) string {
+	names := [4]string{}
+	for i, index := range want {
+		names[i] = "*"
+		if colour, ok := b.set.Colour(index); ok && fixed[i] {
+			names[i] = colour.Name
+		}
+	}
+	return fmt.Sprintf("%v", names)
+}
+
+func terrainName(t *common.Terrain) string {
+	if t == nil {
+		return "<nil>"
+	}
+	return t.Name
+}
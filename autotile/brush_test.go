@@ -0,0 +1,236 @@
+package autotile
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+// A tileset with a tile for every combination of grass & sand corners, tile i has sand on the
+// corners whose bit is set (top left, top right, bottom left, bottom right from bit 0)
+func terrainTileset() (*common.Tileset, *common.Terrain, *common.Terrain) {
+	grass, sand := common.NewTerrain("grass"), common.NewTerrain("sand")
+	tset := common.NewTileset("ground")
+	tset.AddTerrain(grass, sand)
+
+	for i := 0; i < 16; i++ {
+		tile := common.NewTile("")
+		tile.Id = i
+		tset.AddTiles(tile)
+		for bit, set := range []func(*common.Terrain){tile.SetTopLeftTerrain, tile.SetTopRightTerrain, tile.SetBottomLeftTerrain, tile.SetBottomRightTerrain} {
+			if i&(1<<bit) != 0 {
+				set(sand)
+			} else {
+				set(grass)
+			}
+		}
+	}
+	return tset, grass, sand
+}
+
+func layer(w, h int) *common.TileLayer {
+	m := common.NewMap(common.Width(w), common.Height(h), common.TileWidth(16), common.TileHeight(16))
+	return m.NewTileLayer("ground")
+}
+
+// Check every pair of neighbouring tiles agree on the terrain of the corners they share
+func expectJoined(t *testing.T, l *common.TileLayer) {
+	t.Helper()
+	b := l.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			tile := l.Get(x, y)
+			if tile == nil {
+				continue
+			}
+			if right := l.Get(x+1, y); right != nil {
+				if tile.TopRightTerrain() != right.TopLeftTerrain() || tile.BottomRightTerrain() != right.BottomLeftTerrain() {
+					t.Errorf("tiles at %d,%d and %d,%d don't join", x, y, x+1, y)
+				}
+			}
+			if below := l.Get(x, y+1); below != nil {
+				if tile.BottomLeftTerrain() != below.TopLeftTerrain() || tile.BottomRightTerrain() != below.TopRightTerrain() {
+					t.Errorf("tiles at %d,%d and %d,%d don't join", x, y, x, y+1)
+				}
+			}
+		}
+	}
+}
+
+func TestPaintTerrain(t *testing.T) {
+	tset, grass, sand := terrainTileset()
+	brush := NewTerrainBrush(tset, WithRand(rand.New(rand.NewSource(1))))
+	l := layer(6, 6)
+
+	err := brush.PaintTerrain(l, image.Rect(0, 0, 6, 6), grass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = brush.PaintTerrain(l, image.Rect(2, 2, 4, 3), sand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectJoined(t, l)
+
+	for _, p := range []image.Point{{2, 2}, {3, 2}} {
+		if l.Get(p.X, p.Y) != tset.Tiles()[15] {
+			t.Errorf("expected all sand tile at %v got %v", p, l.Get(p.X, p.Y).Id)
+		}
+	}
+	expect := map[image.Point]int{
+		{1, 1}: 8, // sand bottom right only
+		{2, 1}: 12,
+		{4, 1}: 4,
+		{1, 2}: 10,
+		{4, 3}: 1,
+		{0, 0}: 0,
+		{5, 5}: 0,
+	}
+	for p, id := range expect {
+		if got := l.Get(p.X, p.Y); got.Id != id {
+			t.Errorf("expected tile %d at %v got %d", id, p, got.Id)
+		}
+	}
+
+	if err := brush.PaintTerrain(l, image.Rect(0, 0, 1, 1), common.NewTerrain("lava")); err == nil {
+		t.Error("expected an error painting terrain the brush doesn't have")
+	}
+}
+
+func TestFillTerrain(t *testing.T) {
+	tset, grass, sand := terrainTileset()
+	brush := NewTerrainBrush(tset)
+	l := layer(3, 2)
+
+	// corners of a 3x2 map, sand running diagonally
+	mask := [][]*common.Terrain{
+		{sand, grass, grass, grass},
+		{grass, sand, grass, grass},
+		{grass, grass, sand, sand},
+	}
+	err := brush.FillTerrain(l, image.Pt(0, 0), mask)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectJoined(t, l)
+
+	expect := [][]int{{9, 4, 0}, {2, 9, 12}}
+	for y, row := range expect {
+		for x, id := range row {
+			if got := l.Get(x, y); got.Id != id {
+				t.Errorf("expected tile %d at %d,%d got %d", id, x, y, got.Id)
+			}
+		}
+	}
+}
+
+func TestBrushProbability(t *testing.T) {
+	tset, grass, _ := terrainTileset()
+
+	// a second, rarer, all grass tile
+	rare := common.NewTile("")
+	rare.Id = 16
+	rare.Probability = 0.1
+	for _, set := range []func(*common.Terrain){rare.SetTopLeftTerrain, rare.SetTopRightTerrain, rare.SetBottomLeftTerrain, rare.SetBottomRightTerrain} {
+		set(grass)
+	}
+	tset.AddTiles(rare)
+	tset.Tiles()[0].Probability = 0.9
+
+	brush := NewTerrainBrush(tset, WithRand(rand.New(rand.NewSource(7))))
+	l := layer(40, 40)
+	err := brush.PaintTerrain(l, image.Rect(0, 0, 40, 40), grass)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			if l.Get(x, y) == rare {
+				count++
+			}
+		}
+	}
+	if count < 100 || count > 220 { // expect ~160 of 1600
+		t.Error("expected around a tenth of tiles to be the rare tile, got", count)
+	}
+}
+
+func TestBrushProbabilityZero(t *testing.T) {
+	tset, grass, _ := terrainTileset()
+
+	// a second all grass tile, that's never to be placed
+	never := common.NewTile("")
+	never.Probability = 0
+	for _, set := range []func(*common.Terrain){never.SetTopLeftTerrain, never.SetTopRightTerrain, never.SetBottomLeftTerrain, never.SetBottomRightTerrain} {
+		set(grass)
+	}
+	tset.AddTiles(never)
+
+	brush := NewTerrainBrush(tset, WithRand(rand.New(rand.NewSource(7))))
+	l := layer(20, 20)
+	err := brush.PaintTerrain(l, image.Rect(0, 0, 20, 20), grass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			if l.Get(x, y) != tset.Tiles()[0] {
+				t.Fatalf("expected only the all grass tile with a probability at %d,%d, got %v", x, y, l.Get(x, y))
+			}
+		}
+	}
+}
+
+func TestBrushMissingTransition(t *testing.T) {
+	tset, _, _ := terrainTileset()
+	set := tset.TerrainWangSet("ground")
+	for _, tile := range tset.Tiles()[8:] {
+		set.RemoveTile(tile) // no tiles with sand on the bottom right
+	}
+
+	brush, err := NewBrush(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := layer(4, 4)
+	err = brush.Paint(l, image.Rect(0, 0, 4, 4), set.Colours()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = brush.Paint(l, image.Rect(2, 2, 3, 3), set.Colours()[1])
+	if err == nil {
+		t.Fatal("expected an error when no tile makes the transition")
+	}
+	if l.Get(2, 2) != tset.Tiles()[0] || l.Get(1, 1) != tset.Tiles()[0] {
+		t.Error("expected the layer to be unchanged after a failed paint")
+	}
+
+	if _, err := NewBrush(common.NewWangSet("roads", common.WangSetTypeEdge)); err == nil {
+		t.Error("expected an error for a brush from an edge set")
+	}
+}
+
+func TestPaintInfinite(t *testing.T) {
+	tset, grass, sand := terrainTileset()
+	brush := NewTerrainBrush(tset)
+	m := common.NewMap(common.Width(4), common.Height(4), common.TileWidth(16), common.TileHeight(16), common.Infinite())
+	l := m.NewTileLayer("ground")
+
+	err := brush.PaintTerrain(l, image.Rect(-4, -4, 4, 4), grass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = brush.PaintTerrain(l, image.Rect(-2, -2, -1, -1), sand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectJoined(t, l)
+	if l.Get(-2, -2) != tset.Tiles()[15] || l.Get(-3, -3) != tset.Tiles()[8] {
+		t.Error("expected sand painted at negative coordinates")
+	}
+}
@@ -0,0 +1,180 @@
+// Package encode holds the encodings shared by Tiled's XML & JSON formats
+package encode
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/voidshard/libtmx/common"
+)
+
+// Parse a colour from hex #AARRGGBB or #RRGGBB
+//  - an empty string means no colour was set (nil)
+func DecodeHexColour(s string) (*color.RGBA, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	data, err := hex.DecodeString(strings.TrimLeft(s, "#"))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != 3 && len(data) != 4 {
+		return nil, errors.New(fmt.Sprintf("Expected #RRGGBB or #AARRGGBB colour, got %s", s))
+	}
+
+	dlen := len(data)
+	c := &color.RGBA{R: data[dlen-3], G: data[dlen-2], B: data[dlen-1]}
+	if dlen > 3 {
+		c.A = data[0]
+	}
+	return c, nil
+}
+
+// Parse a tint colour, as for DecodeHexColour but a colour without alpha is fully opaque
+func DecodeTintColour(s string) (*color.RGBA, error) {
+	c, err := DecodeHexColour(s)
+	if err != nil || c == nil {
+		return c, err
+	}
+	if len(strings.TrimLeft(s, "#")) == 6 {
+		c.A = 255
+	}
+	return c, nil
+}
+
+// Turn a RGBA colour back into #RRGGBB format, or #AARRGGBB if it's partly transparent.
+//  - colours read without an alpha have an alpha of 0 (or 255 for tints), so both are left out
+func EncodeHexColour(in *color.RGBA) string {
+	if in == nil {
+		return ""
+	}
+	data := []byte{in.R, in.G, in.B}
+	if in.A != 0 && in.A != 255 {
+		data = []byte{in.A, in.R, in.G, in.B}
+	}
+	return fmt.Sprintf("#%s", strings.ToUpper(hex.EncodeToString(data)))
+}
+
+// Decode tile data into [][]int - base64 encoded little-endian uint32 global tile ids,
+// optionally compressed with gzip, zlib or zstd. Rows are 'width' tiles long.
+func DecodeTileDataBase64(in, compression string, width int) ([][]int, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(in))
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader
+	switch compression {
+	case "":
+		reader = bytes.NewReader(raw)
+	case common.DataCompressionGzip:
+		reader, err = gzip.NewReader(bytes.NewReader(raw))
+	case common.DataCompressionZlib:
+		reader, err = zlib.NewReader(bytes.NewReader(raw))
+	case common.DataCompressionZstd:
+		var zreader *zstd.Decoder
+		zreader, err = zstd.NewReader(bytes.NewReader(raw))
+		if err == nil {
+			defer zreader.Close()
+		}
+		reader = zreader
+	default:
+		return nil, errors.New(fmt.Sprintf("Unknown tile data compression %s", compression))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) % 4 != 0 {
+		return nil, errors.New(fmt.Sprintf("Expected tile data to be a multiple of 4 bytes, got %d", len(data)))
+	}
+	if width < 1 {
+		return nil, errors.New(fmt.Sprintf("Expected tile data width > 0, got %d", width))
+	}
+
+	result := [][]int{}
+	row := []int{}
+	for i := 0; i < len(data); i += 4 {
+		row = append(row, int(binary.LittleEndian.Uint32(data[i:i+4])))
+		if len(row) == width {
+			result = append(result, row)
+			row = []int{}
+		}
+	}
+	if len(row) > 0 {
+		result = append(result, row)
+	}
+	return result, nil
+}
+
+// Encode tile id row/column data as base64 little-endian uint32s, compressed with the
+// given compression (if any)
+func EncodeTileDataBase64(tiles [][]int, compression string) (string, error) {
+	data := []byte{}
+	for _, row := range tiles {
+		for _, tileid := range row {
+			data = binary.LittleEndian.AppendUint32(data, uint32(tileid))
+		}
+	}
+
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	var err error
+	switch compression {
+	case "":
+		return base64.StdEncoding.EncodeToString(data), nil
+	case common.DataCompressionGzip:
+		writer = gzip.NewWriter(&buf)
+	case common.DataCompressionZlib:
+		writer = zlib.NewWriter(&buf)
+	case common.DataCompressionZstd:
+		writer, err = zstd.NewWriter(&buf)
+	default:
+		return "", errors.New(fmt.Sprintf("Unknown tile data compression %s", compression))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	_, err = writer.Write(data)
+	if err != nil {
+		return "", err
+	}
+	err = writer.Close()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// The map option for an orientation as Tiled names it (one of the common.MapOrientation*
+// constants, "" is orthogonal). Stagger settings are used by staggered & hexagonal maps only.
+func Orientation(orientation string, hexSideLength int, staggerAxis, staggerIndex string) (common.MapOption, error) {
+	switch orientation {
+	case common.MapOrientationOrthogonal, "":
+		return common.OrientationOrthogonal(), nil
+	case common.MapOrientationIsometric:
+		return common.OrientationIsometric(), nil
+	case common.MapOrientationStaggered:
+		return common.OrientationStaggered(staggerAxis, staggerIndex), nil
+	case common.MapOrientationHexagonal:
+		return common.OrientationHexagonal(hexSideLength, staggerAxis, staggerIndex), nil
+	}
+	return nil, errors.New(fmt.Sprintf("Unknown map orientation %s", orientation))
+}
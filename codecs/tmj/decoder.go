@@ -0,0 +1,33 @@
+package tmj
+
+import (
+	"github.com/voidshard/libtmx/common"
+)
+
+// Holds the state of a single Unmarshal call, so that parsing many maps at once
+// (or one after another) never shares tiles between them.
+//
+type decoder struct {
+	codec *CodecTMJ
+
+	// global tile id -> tile, filled in as tilesets are inflated
+	tiles map[int]*common.Tile
+
+	// template path -> template, filled in as objects using templates are inflated
+	templates map[string]*common.Template
+}
+
+func newDecoder(c *CodecTMJ) *decoder {
+	return &decoder{
+		codec: c,
+		tiles: make(map[int]*common.Tile),
+		templates: make(map[string]*common.Template),
+	}
+}
+
+// Look up an inflated tile by its global id (without flip flags)
+//
+func (d *decoder) tile(gid int) (*common.Tile, bool) {
+	t, ok := d.tiles[gid]
+	return t, ok
+}
@@ -0,0 +1,381 @@
+package tmj
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+// Something layers can be added to when inflating, that is a common.Map or common.GroupLayer
+//
+type layerContainer interface {
+	NewTileLayer(name string) *common.TileLayer
+	NewImageLayer(name, imageSource string) *common.ImageLayer
+	NewObjectLayer(name string) *common.ObjectLayer
+	NewGroupLayer(name string) *common.GroupLayer
+}
+
+// Any one of the layer kinds; which fields are used depends on Type
+//
+type layer struct {
+	Type    string  `json:"type"`
+	Name    string  `json:"name"`
+	Visible bool    `json:"visible"`
+	Opacity float64 `json:"opacity"`
+
+	// optional
+	OffsetX    float64    `json:"offsetx,omitempty"`
+	OffsetY    float64    `json:"offsety,omitempty"`
+	Tint       string     `json:"tintcolor,omitempty"`
+	Properties []property `json:"properties,omitempty"`
+
+	// tilelayer
+	Width       int             `json:"width,omitempty"`
+	Height      int             `json:"height,omitempty"`
+	StartX      int             `json:"startx,omitempty"` // infinite maps only
+	StartY      int             `json:"starty,omitempty"` // infinite maps only
+	Encoding    string          `json:"encoding,omitempty"`
+	Compression string          `json:"compression,omitempty"`
+	Data        json.RawMessage `json:"data,omitempty"`
+	Chunks      []chunk         `json:"chunks,omitempty"`
+
+	// objectgroup
+	Colour    string   `json:"color,omitempty"`
+	DrawOrder string   `json:"draworder,omitempty"`
+	Objects   []object `json:"objects,omitempty"`
+
+	// imagelayer
+	Image             string `json:"image,omitempty"`
+	ImageWidth        int    `json:"imagewidth,omitempty"`
+	ImageHeight       int    `json:"imageheight,omitempty"`
+	TransparentColour string `json:"transparentcolor,omitempty"`
+
+	// group
+	Layers []layer `json:"layers,omitempty"`
+}
+
+// Block of tile data covering part of a tilelayer in an infinite map
+//
+type chunk struct {
+	X      int             `json:"x"`
+	Y      int             `json:"y"`
+	Width  int             `json:"width"`
+	Height int             `json:"height"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// Tiled omits visible & opacity when they're set to their defaults, so we set them before decoding
+//
+func (l *layer) UnmarshalJSON(data []byte) error {
+	type plain layer
+	tmp := plain{Visible: true, Opacity: 1}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	*l = layer(tmp)
+	return nil
+}
+
+// Inflate whichever layer this is & add it to the given map or group
+//
+func (l *layer) inflate(d *decoder, parent layerContainer) error {
+	switch l.Type {
+	case typeTileLayer:
+		return l.inflateTileLayer(d, parent)
+	case typeObjectGroup:
+		return l.inflateObjectGroup(d, parent)
+	case typeImageLayer:
+		return l.inflateImageLayer(d, parent)
+	case typeGroup:
+		return l.inflateGroup(d, parent)
+	}
+	return errors.New(fmt.Sprintf("Unknown layer type %s", l.Type))
+}
+
+// Deflate the given common.Layer for writing to JSON
+//
+func deflateLayer(in common.Layer, encoding, compression string) (layer, error) {
+	switch l := in.(type) {
+	case *common.TileLayer:
+		return deflateTileLayer(l, encoding, compression)
+	case *common.ImageLayer:
+		return deflateImageLayer(l), nil
+	case *common.ObjectLayer:
+		return deflateObjectGroup(l), nil
+	case *common.GroupLayer:
+		return deflateGroup(l, encoding, compression)
+	}
+	return layer{}, errors.New(fmt.Sprintf("Unknown layer type %T", in))
+}
+
+// Settings shared by all layer kinds
+//
+func deflateLayerCommon(kind string, in common.Layer) layer {
+	return layer{
+		Type: kind,
+		Name: in.LayerName(),
+		Properties: deflateProperties(in.Properties()),
+	}
+}
+
+func (l *layer) inflateTileLayer(d *decoder, parent layerContainer) error {
+	out := parent.NewTileLayer(l.Name)
+
+	tint, err := decodeTintColour(l.Tint)
+	if err != nil {
+		return err
+	}
+	props, err := inflateProperties(d, l.Properties)
+	if err != nil {
+		return err
+	}
+
+	out.Opacity = l.Opacity
+	out.Visible = l.Visible
+	out.OffsetX = round(l.OffsetX)
+	out.OffsetY = round(l.OffsetY)
+	out.TintColour = tint
+	out.Encoding = l.Encoding
+	out.Compression = l.Compression
+	if out.Encoding == "" {
+		out.Encoding = common.DataEncodingCsv
+	}
+	out.UpdateProperties(props...)
+
+	if len(l.Chunks) == 0 {
+		tileIds, err := decodeTileData(l.Data, l.Encoding, l.Compression, l.Width)
+		if err != nil {
+			return err
+		}
+		putTileIds(d, out, 0, 0, tileIds)
+		return nil
+	}
+
+	for _, chk := range l.Chunks {
+		tileIds, err := decodeTileData(chk.Data, l.Encoding, l.Compression, chk.Width)
+		if err != nil {
+			return err
+		}
+		putTileIds(d, out, chk.X, chk.Y, tileIds)
+	}
+	return nil
+}
+
+// Place tiles by their global ids (rows of columns) into the layer, with the first at x,y
+//
+func putTileIds(d *decoder, out *common.TileLayer, x, y int, tileIds [][]int) {
+	for dy, row := range tileIds {
+		for dx, rawId := range row { // Nb: these are global tile ids, with flip flags in the high bits
+			tileId, cell := common.DecodeGID(uint32(rawId))
+			if tileId == 0 { // tile id of 0 means no tile is there
+				continue
+			}
+
+			tile, ok := d.tile(tileId)
+			if !ok {
+				continue
+			}
+			cell.Tile = tile
+			out.PutCell(x + dx, y + dy, cell)
+		}
+	}
+}
+
+// Deflate the given common.TileLayer for writing to JSON. Tile data is written with the given
+// encoding & compression, if no encoding is given the layer's own settings are used.
+//
+func deflateTileLayer(in *common.TileLayer, encoding, compression string) (layer, error) {
+	if encoding == "" {
+		encoding = in.Encoding
+		compression = in.Compression
+	}
+	if encoding == "" {
+		encoding = common.DataEncodingCsv
+	}
+
+	out := deflateLayerCommon(typeTileLayer, in)
+	out.Visible = in.Visible
+	out.Opacity = in.Opacity
+	out.OffsetX = float64(in.OffsetX)
+	out.OffsetY = float64(in.OffsetY)
+	out.Tint = encodeHexColour(in.TintColour)
+	out.Width = in.Width()
+	out.Height = in.Height()
+	out.Encoding = encoding
+	out.Compression = compression
+
+	if !in.Infinite() {
+		data, err := encodeTileData(in.TileIds(), encoding, compression)
+		out.Data = data
+		return out, err
+	}
+
+	out.StartX = in.Bounds().Min.X
+	out.StartY = in.Bounds().Min.Y
+	out.Chunks = []chunk{}
+	for _, rect := range in.Chunks() {
+		data, err := encodeTileData(in.TileIdsIn(rect), encoding, compression)
+		if err != nil {
+			return out, err
+		}
+		out.Chunks = append(out.Chunks, chunk{
+			X: rect.Min.X,
+			Y: rect.Min.Y,
+			Width: rect.Dx(),
+			Height: rect.Dy(),
+			Data: data,
+		})
+	}
+	return out, nil
+}
+
+func (l *layer) inflateObjectGroup(d *decoder, parent layerContainer) error {
+	out := parent.NewObjectLayer(l.Name)
+
+	col, err := decodeHexColour(l.Colour)
+	if err != nil {
+		return err
+	}
+	tint, err := decodeTintColour(l.Tint)
+	if err != nil {
+		return err
+	}
+	props, err := inflateProperties(d, l.Properties)
+	if err != nil {
+		return err
+	}
+
+	out.Colour = col
+	out.Opacity = l.Opacity
+	out.Visible = l.Visible
+	out.OffsetX = round(l.OffsetX)
+	out.OffsetY = round(l.OffsetY)
+	out.TintColour = tint
+	out.DrawOrder = l.DrawOrder
+	if out.DrawOrder == "" {
+		out.DrawOrder = common.DefaultDrawOrder
+	}
+	out.UpdateProperties(props...)
+
+	for _, obj := range l.Objects {
+		inflated, err := obj.inflate(d)
+		if err != nil {
+			return err
+		}
+		out.AddObjects(inflated)
+	}
+	return nil
+}
+
+// Deflate the given common.ObjectLayer for writing to JSON
+//
+func deflateObjectGroup(in *common.ObjectLayer) layer {
+	out := deflateLayerCommon(typeObjectGroup, in)
+	out.Visible = in.Visible
+	out.Opacity = in.Opacity
+	out.OffsetX = float64(in.OffsetX)
+	out.OffsetY = float64(in.OffsetY)
+	out.Tint = encodeHexColour(in.TintColour)
+	out.Colour = encodeHexColour(in.Colour)
+	out.DrawOrder = in.DrawOrder
+	out.Objects = []object{}
+	for _, obj := range in.Objects() {
+		out.Objects = append(out.Objects, deflateObject(obj))
+	}
+	return out
+}
+
+func (l *layer) inflateImageLayer(d *decoder, parent layerContainer) error {
+	out := parent.NewImageLayer(l.Name, l.Image)
+
+	tint, err := decodeTintColour(l.Tint)
+	if err != nil {
+		return err
+	}
+	trans, err := decodeHexColour(l.TransparentColour)
+	if err != nil {
+		return err
+	}
+	props, err := inflateProperties(d, l.Properties)
+	if err != nil {
+		return err
+	}
+
+	out.Opacity = l.Opacity
+	out.Visible = l.Visible
+	out.OffsetX = round(l.OffsetX)
+	out.OffsetY = round(l.OffsetY)
+	out.TintColour = tint
+	out.TransparentColour = trans
+	out.Width = l.ImageWidth
+	out.Height = l.ImageHeight
+	out.UpdateProperties(props...)
+	return nil
+}
+
+// Deflate the given common.ImageLayer for writing to JSON
+//
+func deflateImageLayer(in *common.ImageLayer) layer {
+	out := deflateLayerCommon(typeImageLayer, in)
+	out.Visible = in.Visible
+	out.Opacity = in.Opacity
+	out.OffsetX = float64(in.OffsetX)
+	out.OffsetY = float64(in.OffsetY)
+	out.Tint = encodeHexColour(in.TintColour)
+	out.Image = in.ImageSource
+	out.ImageWidth = in.Width
+	out.ImageHeight = in.Height
+	out.TransparentColour = encodeHexColour(in.TransparentColour)
+	return out
+}
+
+func (l *layer) inflateGroup(d *decoder, parent layerContainer) error {
+	out := parent.NewGroupLayer(l.Name)
+
+	tint, err := decodeTintColour(l.Tint)
+	if err != nil {
+		return err
+	}
+	props, err := inflateProperties(d, l.Properties)
+	if err != nil {
+		return err
+	}
+
+	out.Opacity = l.Opacity
+	out.Visible = l.Visible
+	out.OffsetX = round(l.OffsetX)
+	out.OffsetY = round(l.OffsetY)
+	out.TintColour = tint
+	out.UpdateProperties(props...)
+
+	for _, child := range l.Layers {
+		err := child.inflate(d, out)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deflate the given common.GroupLayer (and everything in it) for writing to JSON
+//
+func deflateGroup(in *common.GroupLayer, encoding, compression string) (layer, error) {
+	out := deflateLayerCommon(typeGroup, in)
+	out.Visible = in.Visible
+	out.Opacity = in.Opacity
+	out.OffsetX = float64(in.OffsetX)
+	out.OffsetY = float64(in.OffsetY)
+	out.Tint = encodeHexColour(in.TintColour)
+	out.Layers = []layer{}
+
+	for _, child := range in.Layers() {
+		jlayer, err := deflateLayer(child, encoding, compression)
+		if err != nil {
+			return out, err
+		}
+		out.Layers = append(out.Layers, jlayer)
+	}
+	return out, nil
+}
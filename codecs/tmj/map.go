@@ -0,0 +1,130 @@
+package tmj
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/voidshard/libtmx/codecs/internal/encode"
+	"github.com/voidshard/libtmx/common"
+)
+
+const (
+	// Values of the "type" field of maps, tilesets & layers
+	typeMap         = "map"
+	typeTileset     = "tileset"
+	typeTileLayer   = "tilelayer"
+	typeObjectGroup = "objectgroup"
+	typeImageLayer  = "imagelayer"
+	typeGroup       = "group"
+)
+
+type tileMap struct {
+	Type string `json:"type"`
+
+	Width      int `json:"width"`
+	Height     int `json:"height"`
+	TileWidth  int `json:"tilewidth"`
+	TileHeight int `json:"tileheight"`
+	Infinite   bool `json:"infinite"`
+
+	// optional
+	Version         version `json:"version,omitempty"`
+	TiledVersion    string  `json:"tiledversion,omitempty"`
+	Orientation     string  `json:"orientation,omitempty"`
+	RenderOrder     string  `json:"renderorder,omitempty"`
+	HexSideLength   int     `json:"hexsidelength,omitempty"`
+	StaggerAxis     string  `json:"staggeraxis,omitempty"`
+	StaggerIndex    string  `json:"staggerindex,omitempty"`
+	BackgroundColor string  `json:"backgroundcolor,omitempty"`
+	NextObjectId    int     `json:"nextobjectid,omitempty"`
+
+	Properties []property `json:"properties,omitempty"`
+	Tilesets   []tileset  `json:"tilesets"`
+
+	// tile, image & object layers and groups, in draw order
+	Layers []layer `json:"layers"`
+}
+
+// Map format version. Written as a string, though older versions of Tiled wrote a number.
+//
+type version string
+
+func (v *version) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = version(s)
+		return nil
+	}
+
+	var f float64
+	if err := json.Unmarshal(data, &f); err != nil {
+		return err
+	}
+	*v = version(strconv.FormatFloat(f, 'f', -1, 64))
+	return nil
+}
+
+// Inflate json encoding struct into our common.Map struct.
+//
+func (m *tileMap) inflate(d *decoder) (*common.Map, error) {
+	settings := []common.MapOption{}
+
+	bg, err := decodeHexColour(m.BackgroundColor)
+	if err != nil {
+		return nil, err
+	}
+	settings = append(settings, common.Background(bg))
+
+	orientation, err := encode.Orientation(m.Orientation, m.HexSideLength, m.StaggerAxis, m.StaggerIndex)
+	if err != nil {
+		return nil, err
+	}
+	settings = append(settings, orientation)
+
+	if m.Infinite {
+		settings = append(settings, common.Infinite())
+	}
+
+	if m.RenderOrder == common.MapRenderOrderRightDown {
+		settings = append(settings, common.RenderOrderRightDown())
+	} else if m.RenderOrder == common.MapRenderOrderLeftDown {
+		settings = append(settings, common.RenderOrderLeftDown())
+	} else if m.RenderOrder == common.MapRenderOrderRightUp {
+		settings = append(settings, common.RenderOrderRightUp())
+	} else if m.RenderOrder == common.MapRenderOrderLeftUp {
+		settings = append(settings, common.RenderOrderLeftUp())
+	}
+
+	out := common.NewMap(settings...)
+	out.Height = m.Height
+	out.Width = m.Width
+	out.TileWidth = m.TileWidth
+	out.TileHeight = m.TileHeight
+	out.HexSideLength = m.HexSideLength
+	out.Version = string(m.Version)
+	out.TiledVersion = m.TiledVersion
+	if m.NextObjectId > 0 {
+		out.SetNextObjectID(m.NextObjectId)
+	}
+
+	props, err := inflateProperties(d, m.Properties)
+	if err != nil {
+		return nil, err
+	}
+	out.UpdateProperties(props...)
+
+	for _, tset := range m.Tilesets {
+		inflated, err := tset.inflate(d)
+		if err != nil {
+			return nil, err
+		}
+		out.AddTileset(inflated)
+	}
+	for _, l := range m.Layers {
+		err := l.inflate(d, out)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
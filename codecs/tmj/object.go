@@ -0,0 +1,324 @@
+package tmj
+
+import (
+	"encoding/json"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+// Represents an object read in from JSON.
+// This can be a rectangle, ellipse, point, polygon, polyline, text or tile object ..
+//
+type object struct {
+	Id       int     `json:"id"`
+	Name     string  `json:"name"`
+	Type     string  `json:"type"`
+	Class    string  `json:"class,omitempty"` // Tiled 1.9 wrote 'type' as 'class', we only read this
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Width    float64 `json:"width"`
+	Height   float64 `json:"height"`
+	Rotation float64 `json:"rotation"`
+	Visible  bool    `json:"visible"`
+
+	// optional, at most one of these is set
+	Gid      uint32  `json:"gid,omitempty"`
+	Ellipse  bool    `json:"ellipse,omitempty"`
+	Point    bool    `json:"point,omitempty"`
+	Polygon  []point `json:"polygon,omitempty"`
+	Polyline []point `json:"polyline,omitempty"`
+	Text     *text   `json:"text,omitempty"`
+
+	Template   string     `json:"template,omitempty"`
+	Properties []property `json:"properties,omitempty"`
+
+	// fields set when read in, or to write out for objects based on a template
+	fields map[string]bool
+}
+
+type point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Objects are visible unless stated otherwise. Objects based on a template take whatever they
+// don't set from the template, so we note which fields are set.
+//
+func (o *object) UnmarshalJSON(data []byte) error {
+	type plain object
+	tmp := plain{Visible: true}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	*o = object(tmp)
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	o.fields = map[string]bool{}
+	for name := range fields {
+		o.fields[name] = true
+	}
+	return nil
+}
+
+// Objects based on a template are written with only the fields that differ from the template
+//
+func (o object) MarshalJSON() ([]byte, error) {
+	type plain object
+	data, err := json.Marshal(plain(o))
+	if err != nil || o.Template == "" {
+		return data, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for name := range fields {
+		if !o.fields[name] {
+			delete(fields, name)
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// Whether the given field was set (always true for objects without a template)
+//
+func (o *object) isSet(field string) bool {
+	return o.Template == "" || o.fields[field]
+}
+
+// Inflate this object into a common.Object. Objects based on a template start with the
+// template's values, then take those they set themselves.
+//
+func (o *object) inflate(d *decoder) (*common.Object, error) {
+	props, err := inflateProperties(d, o.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := common.NewObject("")
+	if o.Template != "" {
+		tmpl, err := d.template(o.Template)
+		if err != nil {
+			return nil, err
+		}
+		obj = common.NewObjectFromTemplate(tmpl)
+	}
+
+	obj.Id = o.Id
+	obj.X = o.X
+	obj.Y = o.Y
+	if o.isSet("name") {
+		obj.Name = o.Name
+	}
+	if o.isSet("type") || o.isSet("class") {
+		obj.Type = o.Type
+		if obj.Type == "" {
+			obj.Type = o.Class
+		}
+	}
+	if o.isSet("width") {
+		obj.Width = o.Width
+	}
+	if o.isSet("height") {
+		obj.Height = o.Height
+	}
+	if o.isSet("rotation") {
+		obj.Rotation = o.Rotation
+	}
+	if o.isSet("visible") {
+		obj.Visible = o.Visible
+	}
+	obj.UpdateProperties(props...)
+
+	if o.Gid > 0 {
+		tileId, cell := common.DecodeGID(o.Gid)
+		tile, ok := d.tile(tileId)
+		if ok {
+			cell.Tile = tile
+			obj.SetTileCell(cell)
+		}
+	} else if o.Ellipse {
+		obj.SetEllipse()
+	} else if o.Point {
+		obj.SetPoint()
+	} else if o.Polygon != nil {
+		obj.SetPolygon(inflatePoints(o.Polygon)...)
+	} else if o.Polyline != nil {
+		obj.SetPolyline(inflatePoints(o.Polyline)...)
+	} else if o.Text != nil {
+		txt, err := o.Text.inflate()
+		if err != nil {
+			return nil, err
+		}
+		obj.SetText(txt)
+	}
+
+	return obj, nil
+}
+
+// Deflate the given common.Object for writing to JSON. Objects based on a template keep only
+// the values that differ from the template.
+//
+func deflateObject(in *common.Object) object {
+	over := in.TemplateOverrides()
+	obj := object{
+		Id: in.Id,
+		Name: in.Name,
+		Type: in.Type,
+		X: in.X,
+		Y: in.Y,
+		Width: in.Width,
+		Height: in.Height,
+		Rotation: in.Rotation,
+		Visible: in.Visible,
+		Properties: deflateProperties(over.Properties),
+	}
+
+	if in.Template() != nil {
+		obj.Template = in.Template().Source
+		obj.fields = map[string]bool{
+			"id": true,
+			"template": true,
+			"x": true,
+			"y": true,
+			"name": over.Name,
+			"type": over.Type,
+			"width": over.Width,
+			"height": over.Height,
+			"rotation": over.Rotation,
+			"visible": over.Visible,
+			"properties": true, // only overridden properties are kept
+		}
+		if !over.Shape {
+			return obj
+		}
+		for _, name := range []string{"gid", "ellipse", "point", "polygon", "polyline", "text"} {
+			obj.fields[name] = true
+		}
+	}
+
+	switch in.Kind() {
+	case common.ObjectTypeEllipse:
+		obj.Ellipse = true
+	case common.ObjectTypePoint:
+		obj.Point = true
+	case common.ObjectTypePolygon:
+		obj.Polygon = deflatePoints(in.Points())
+	case common.ObjectTypePolyline:
+		obj.Polyline = deflatePoints(in.Points())
+	case common.ObjectTypeText:
+		obj.Text = deflateText(in.Text())
+	case common.ObjectTypeTile:
+		obj.Gid = in.TileCell().GlobalID()
+	}
+
+	return obj
+}
+
+func inflatePoints(in []point) []common.Point {
+	result := []common.Point{}
+	for _, p := range in {
+		result = append(result, common.Pt(p.X, p.Y))
+	}
+	return result
+}
+
+func deflatePoints(in []common.Point) []point {
+	result := []point{}
+	for _, p := range in {
+		result = append(result, point{X: p.X, Y: p.Y})
+	}
+	return result
+}
+
+type text struct {
+	Text       string `json:"text"`
+	FontFamily string `json:"fontfamily,omitempty"`
+	PixelSize  int    `json:"pixelsize,omitempty"`
+	Colour     string `json:"color,omitempty"`
+	AlignH     string `json:"halign,omitempty"`
+	AlignV     string `json:"valign,omitempty"`
+	Bold       bool   `json:"bold,omitempty"`
+	Italic     bool   `json:"italic,omitempty"`
+	Underline  bool   `json:"underline,omitempty"`
+	Strikeout  bool   `json:"strikeout,omitempty"`
+	Kerning    bool   `json:"kerning"`
+	Wrap       bool   `json:"wrap,omitempty"`
+}
+
+// Kerning is on unless stated otherwise
+//
+func (t *text) UnmarshalJSON(data []byte) error {
+	type plain text
+	tmp := plain{Kerning: true}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	*t = text(tmp)
+	return nil
+}
+
+// Inflate the given text object, unset fields take their defaults
+//
+func (t *text) inflate() (*common.Text, error) {
+	txt := common.NewText(t.Text)
+	if t.FontFamily != "" {
+		txt.FontFamily = t.FontFamily
+	}
+	if t.PixelSize != 0 {
+		txt.PixelSize = t.PixelSize
+	}
+	if t.AlignH != "" {
+		txt.HAlign = t.AlignH
+	}
+	if t.AlignV != "" {
+		txt.VAlign = t.AlignV
+	}
+	txt.Bold = t.Bold
+	txt.Italic = t.Italic
+	txt.Underline = t.Underline
+	txt.Strikeout = t.Strikeout
+	txt.Kerning = t.Kerning
+	txt.Wrap = t.Wrap
+
+	if t.Colour != "" {
+		col, err := decodeTintColour(t.Colour) // text without an alpha is opaque
+		if err != nil {
+			return nil, err
+		}
+		txt.Colour = col
+	}
+	return txt, nil
+}
+
+// Deflate the given common.Text for writing to JSON. The colour is left out when it's
+// the default (opaque black), as Tiled does.
+//
+func deflateText(in *common.Text) *text {
+	if in == nil {
+		in = common.NewText("")
+	}
+
+	def := common.NewText("")
+	out := &text{
+		Text: in.Value,
+		FontFamily: in.FontFamily,
+		PixelSize: in.PixelSize,
+		AlignH: in.HAlign,
+		AlignV: in.VAlign,
+		Bold: in.Bold,
+		Italic: in.Italic,
+		Underline: in.Underline,
+		Strikeout: in.Strikeout,
+		Kerning: in.Kerning,
+		Wrap: in.Wrap,
+	}
+	if in.Colour != nil && *in.Colour != *def.Colour {
+		out.Colour = encodeHexColour(in.Colour)
+	}
+	return out
+}
@@ -0,0 +1,189 @@
+// Package tmj reads & writes Tiled's JSON map (.tmj) and tileset (.tsj) formats.
+//  See JSON format docs: https://doc.mapeditor.org/en/stable/reference/json-map-format/
+package tmj
+
+import (
+	"encoding/json"
+
+	"github.com/voidshard/libtmx/codecs/v1"
+	"github.com/voidshard/libtmx/common"
+)
+
+type CodecTMJ struct {
+	// if set, all tile layer data is written with this encoding & compression
+	encoding    string
+	compression string
+
+	// loads external files referenced by maps
+	resolver Resolver
+
+	// if set, tilesets with a Source are written as references to their external file
+	externalTilesets bool
+
+	// custom classes, used to fill in default members of class properties
+	propertyTypes *common.PropertyTypes
+}
+
+// Resolver loads files that a map refers to, such as external tilesets.
+// Any v1.Resolver (eg. v1.FSResolver) will do.
+//
+type Resolver interface {
+	ReadFile(name string) ([]byte, error)
+}
+
+// Option alters how a CodecTMJ reads or writes maps
+type Option func(*CodecTMJ)
+
+// Write all tile layer data with the given encoding & compression (one of the
+// common.DataEncoding* & common.DataCompression* constants, compression may be "")
+// rather than the encoding set on each layer. Csv data is written as a JSON array.
+func DataEncoding(encoding, compression string) Option {
+	return func(c *CodecTMJ) {
+		c.encoding = encoding
+		c.compression = compression
+	}
+}
+
+// Load files referenced by maps (eg. external tilesets) with the given Resolver.
+// By default files are read from within the working directory (see v1.DefaultResolver), for a
+// trusted map read from a file use v1.NewFileResolver so they're found relative to the map.
+func WithResolver(r Resolver) Option {
+	return func(c *CodecTMJ) {
+		c.resolver = r
+	}
+}
+
+// Write tilesets that have a Source as references to their external file rather than
+// inlining them in the map. The tileset files themselves can be written with MarshalTileset.
+func ExternalTilesets() Option {
+	return func(c *CodecTMJ) {
+		c.externalTilesets = true
+	}
+}
+
+// Fill in the default values of members missing from class properties with the given custom
+// classes (eg. those of a Tiled project, loaded with UnmarshalPropertyTypes). These also give
+// the types of class members, which JSON maps don't store.
+func WithPropertyTypes(types *common.PropertyTypes) Option {
+	return func(c *CodecTMJ) {
+		c.propertyTypes = types
+	}
+}
+
+// NewCodecTMJ returns a codec with the given options applied
+func NewCodecTMJ(opts ...Option) *CodecTMJ {
+	c := &CodecTMJ{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// The resolver to load referenced files with
+func (c *CodecTMJ) getResolver() Resolver {
+	if c.resolver == nil {
+		return v1.DefaultResolver()
+	}
+	return c.resolver
+}
+
+// Unmarshal a standalone common.Tileset from the given data (that is, []byte read from a .tsj file)
+//
+func (c *CodecTMJ) UnmarshalTileset(data []byte) (*common.Tileset, error) {
+	var jtileset tileset
+	err := json.Unmarshal(data, &jtileset)
+	if err != nil {
+		return nil, err
+	}
+	return jtileset.inflate(newDecoder(c))
+}
+
+// Given a tileset, marshal it into the .tsj format, compatible with Tiled.
+//
+func (c *CodecTMJ) MarshalTileset(in *common.Tileset) ([]byte, error) {
+	in.FinalizeIDs()
+
+	tset := deflateTileset(in)
+	tset.FirstGID = 0 // external tilesets don't have a firstgid, that's set by each map using them
+	tset.Source = ""
+	tset.Type = typeTileset
+	return json.Marshal(tset)
+}
+
+// Unmarshal a standalone common.Template from the given data (that is, []byte read from a .tj file).
+// The template's tileset (if any) is loaded with the codec's Resolver.
+//
+func (c *CodecTMJ) UnmarshalTemplate(data []byte) (*common.Template, error) {
+	var jtemplate template
+	err := json.Unmarshal(data, &jtemplate)
+	if err != nil {
+		return nil, err
+	}
+	return jtemplate.inflate(newDecoder(c), ".", nil)
+}
+
+// Given a template, marshal it into the .tj format, compatible with Tiled.
+//
+func (c *CodecTMJ) MarshalTemplate(in *common.Template) ([]byte, error) {
+	tmpl, err := deflateTemplate(in)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(tmpl)
+}
+
+// Unmarshal a common.Map from the given data (that is, []byte read from a .tmj file)
+//
+func (c *CodecTMJ) Unmarshal(data []byte) (*common.Map, error) {
+	var jmap tileMap
+	err := json.Unmarshal(data, &jmap)
+	if err != nil {
+		return nil, err
+	}
+	return jmap.inflate(newDecoder(c))
+}
+
+// Given a map, marshal it into the .tmj format, compatible with Tiled.
+//
+func (c *CodecTMJ) Marshal(in *common.Map) ([]byte, error) {
+	in.FinalizeIDs()
+
+	jmap := &tileMap{
+		Type: typeMap,
+		Height: in.Height,
+		Width: in.Width,
+		TileHeight: in.TileHeight,
+		TileWidth: in.TileWidth,
+		Version: version(in.Version),
+		TiledVersion: in.TiledVersion,
+		Orientation: in.Orientation(),
+		RenderOrder: in.RenderOrder(),
+		HexSideLength: in.HexSideLength,
+		StaggerAxis: in.StaggerAxis(),
+		StaggerIndex: in.StaggerIndex(),
+		BackgroundColor: encodeHexColour(in.BackgroundColor),
+		NextObjectId: in.NextObjectID(),
+		Infinite: in.Infinite(),
+		Properties: deflateProperties(in.Properties()),
+		Tilesets: []tileset{},
+		Layers: []layer{},
+	}
+
+	for _, tset := range in.Tilesets() {
+		if c.externalTilesets && tset.Source != "" {
+			jmap.Tilesets = append(jmap.Tilesets, deflateTilesetReference(tset))
+		} else {
+			jmap.Tilesets = append(jmap.Tilesets, deflateTileset(tset))
+		}
+	}
+
+	for _, l := range in.Layers() {
+		jlayer, err := deflateLayer(l, c.encoding, c.compression)
+		if err != nil {
+			return nil, err
+		}
+		jmap.Layers = append(jmap.Layers, jlayer)
+	}
+
+	return json.Marshal(jmap)
+}
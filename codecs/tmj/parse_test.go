@@ -0,0 +1,391 @@
+package tmj
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+	"testing/fstest"
+
+	"github.com/voidshard/libtmx/codecs/v1"
+	"github.com/voidshard/libtmx/common"
+)
+
+// A map using most features, read with the XML codec
+const featureMap = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.4" tiledversion="1.4.3" orientation="staggered" renderorder="left-up" width="3" height="2" tilewidth="32" tileheight="16" staggeraxis="y" staggerindex="odd" backgroundcolor="#112233" nextobjectid="9">
+ <properties>
+  <property name="name" value="level one"/>
+  <property name="count" type="int" value="3"/>
+  <property name="speed" type="float" value="1.5"/>
+  <property name="dark" type="bool" value="true"/>
+  <property name="fog" type="color" value="#ff808080"/>
+  <property name="music" type="file" value="music/one.ogg"/>
+ </properties>
+ <tileset firstgid="1" name="things" tilewidth="32" tileheight="16" tilecount="2">
+  <tileoffset x="2" y="-4"/>
+  <terraintypes>
+   <terrain name="grass" tile="0"/>
+  </terraintypes>
+  <tile id="0" type="ground" terrain="0,0,,0" probability="0.5">
+   <properties>
+    <property name="solid" type="bool" value="false"/>
+   </properties>
+   <image width="32" height="16" source="a.png"/>
+   <animation>
+    <frame tileid="0" duration="100"/>
+    <frame tileid="1" duration="200"/>
+   </animation>
+  </tile>
+  <tile id="1">
+   <image width="32" height="16" source="b.png"/>
+   <objectgroup draworder="index">
+    <object id="1" x="0" y="8" width="32" height="8"/>
+    <object id="2" x="4" y="4">
+     <polygon points="0,0 8,0 4,4"/>
+    </object>
+   </objectgroup>
+  </tile>
+ </tileset>
+ <layer name="ground" width="3" height="2" opacity="0.75" offsetx="4" tintcolor="#ff0000">
+  <data encoding="base64" compression="zlib">eJxjZGBgYGKAAEYGhgYGKB8ABOwAhw==</data>
+ </layer>
+ <group name="things" offsety="8" visible="0">
+  <objectgroup name="spawns" color="#00ff00" draworder="index">
+   <object id="1" name="zone" type="trigger" x="10" y="20" width="30" height="40" rotation="45"/>
+   <object id="2" name="spawn" x="5" y="6">
+    <point/>
+   </object>
+   <object id="3" x="1" y="2" width="8" height="8" visible="0">
+    <ellipse/>
+   </object>
+   <object id="4" x="0" y="0">
+    <polygon points="0,0 10,0 10,10"/>
+   </object>
+   <object id="5" x="0" y="0">
+    <polyline points="0,0 -5,5"/>
+   </object>
+   <object id="6" x="0" y="0" width="64" height="16">
+    <text wrap="1" kerning="0" halign="center" bold="1">Hello</text>
+   </object>
+   <object id="7" x="0" y="0" width="64" height="16">
+    <text color="#00ff00">World</text>
+   </object>
+   <object id="8" gid="2147483650" x="32" y="32" width="32" height="16"/>
+  </objectgroup>
+  <imagelayer name="sky" offsetx="1" opacity="0.5">
+   <image source="sky.png" width="96" height="32" trans="#ff00ff"/>
+  </imagelayer>
+ </group>
+</map>
+`
+
+// The model compared by writing it out with the XML codec, which writes every field we keep
+func xmlOf(t *testing.T, m *common.Map) []byte {
+	data, err := v1.NewCodecV1().Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestRoundTrip(t *testing.T) {
+	in, err := v1.NewCodecV1().Unmarshal([]byte(featureMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		Encoding    string
+		Compression string
+	}{
+		{"", ""},
+		{common.DataEncodingCsv, ""},
+		{common.DataEncodingBase64, common.DataCompressionGzip},
+	}
+
+	for _, test := range cases {
+		codec := NewCodecTMJ(DataEncoding(test.Encoding, test.Compression))
+		if test.Encoding != "" { // the layer is expected to come back with the encoding it was written in
+			in.TileLayers()[0].Encoding = test.Encoding
+			in.TileLayers()[0].Compression = test.Compression
+		}
+		expect := xmlOf(t, in)
+
+		data, err := codec.Marshal(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := codec.Unmarshal(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result := xmlOf(t, out)
+		if !bytes.Equal(result, expect) {
+			t.Error("encoding", test.Encoding, "expected", string(expect), "got", string(result))
+		}
+
+		again, err := codec.Marshal(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, again) {
+			t.Error("expected JSON to be stable, got", string(data), "then", string(again))
+		}
+	}
+
+	// spot check the model itself, in case both codecs drop something
+	layer := in.TileLayers()[0]
+	if cell := layer.GetCell(0, 1); cell.Tile == nil || cell.Tile.Source != "a.png" || !cell.FlipH {
+		t.Error("expected flipped a.png at 0,1 got", cell)
+	}
+	if shapes := in.Tilesets()[0].Tiles()[1].CollisionShapes(); len(shapes) != 2 || shapes[1].Kind() != common.ObjectTypePolygon {
+		t.Error("expected 2 collision shapes, got", shapes)
+	}
+	if obj, ok := in.GroupLayers()[0].ObjectLayers()[0].Object(8); !ok || obj.Tile() == nil || !obj.TileCell().FlipH {
+		t.Error("expected flipped tile object")
+	}
+}
+
+const tiledJSON = `{
+ "compressionlevel": -1,
+ "height": 2,
+ "infinite": false,
+ "layers": [
+  {
+   "data": [1, 2, 0, 1073741825],
+   "height": 2,
+   "id": 1,
+   "name": "ground",
+   "opacity": 1,
+   "type": "tilelayer",
+   "visible": true,
+   "width": 2,
+   "x": 0,
+   "y": 0
+  },
+  {
+   "id": 2,
+   "name": "objects",
+   "type": "objectgroup",
+   "objects": [
+    {"id": 1, "name": "chest", "class": "loot", "x": 10.4, "y": 20.6, "width": 0, "height": 0, "rotation": 0, "point": true},
+    {"id": 2, "name": "", "type": "", "x": 1, "y": 2, "width": 3, "height": 4, "rotation": 0, "visible": false,
+     "polygon": [{"x": 0, "y": 0}, {"x": 5.5, "y": 0}, {"x": 0, "y": 5}],
+     "properties": [{"name": "hp", "type": "int", "value": 10}]}
+   ]
+  }
+ ],
+ "nextobjectid": 3,
+ "orientation": "orthogonal",
+ "renderorder": "right-down",
+ "tiledversion": "1.2.1",
+ "tileheight": 16,
+ "tilesets": [
+  {"firstgid": 1, "source": "../tilesets/shared.tsx"}
+ ],
+ "tilewidth": 16,
+ "type": "map",
+ "version": 1.2,
+ "width": 2
+}`
+
+const sharedTSX = `<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.2" tiledversion="1.2.1" name="shared" tilewidth="16" tileheight="16" tilecount="2" columns="0">
+ <tile id="0">
+  <image width="16" height="16" source="../images/grass.png"/>
+ </tile>
+ <tile id="1">
+  <image width="16" height="16" source="../images/dirt.png"/>
+ </tile>
+</tileset>
+`
+
+func TestUnmarshalTiledJSON(t *testing.T) {
+	fsys := fstest.MapFS{
+		"tilesets/shared.tsx": &fstest.MapFile{Data: []byte(sharedTSX)},
+	}
+	codec := NewCodecTMJ(WithResolver(v1.NewFSResolver(fsys, "maps/level.tmj")), ExternalTilesets())
+
+	m, err := codec.Unmarshal([]byte(tiledJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Version != "1.2" || m.Width != 2 || m.TileWidth != 16 || m.NextObjectID() != 3 {
+		t.Error("unexpected map settings", m.Version, m.Width, m.TileWidth, m.NextObjectID())
+	}
+
+	tset := m.Tilesets()[0]
+	if tset.Name != "shared" || tset.Source != "../tilesets/shared.tsx" || tset.TileCount() != 2 {
+		t.Fatal("unexpected tileset", tset)
+	}
+
+	layer := m.TileLayers()[0]
+	if layer.Get(0, 0) != tset.Tiles()[0] || layer.Get(1, 0) != tset.Tiles()[1] || layer.Get(0, 1) != nil {
+		t.Error("expected tiles to be resolved from the external tileset")
+	}
+	if cell := layer.GetCell(1, 1); cell.Tile != tset.Tiles()[0] || !cell.FlipV {
+		t.Error("expected vertically flipped tile at 1,1 got", cell)
+	}
+
+	objects := m.ObjectLayers()[0].Objects()
+	if len(objects) != 2 {
+		t.Fatal("expected 2 objects, got", len(objects))
+	}
+	chest := objects[0]
+	if chest.Kind() != common.ObjectTypePoint || chest.Type != "loot" || chest.X != 10.4 || chest.Y != 20.6 || !chest.Visible {
+		t.Error("unexpected point object", chest)
+	}
+	poly := objects[1]
+	expect := []common.Point{common.Pt(0, 0), common.Pt(5.5, 0), common.Pt(0, 5)}
+	if poly.Kind() != common.ObjectTypePolygon || poly.Visible || len(poly.Points()) != 3 || poly.Points()[1] != expect[1] {
+		t.Error("unexpected polygon object", poly)
+	}
+	if prop, ok := poly.Property("hp"); !ok || prop.AsInt() != 10 {
+		t.Error("expected hp=10 property")
+	}
+
+	// written back out as a reference to the external tileset
+	data, err := codec.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`{"firstgid":1,"source":"../tilesets/shared.tsx"}`)) {
+		t.Error("expected external tileset reference, got", string(data))
+	}
+}
+
+func TestTilesetRoundTrip(t *testing.T) {
+	in, err := v1.NewCodecV1().Unmarshal([]byte(featureMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codec := NewCodecTMJ()
+	data, err := codec.MarshalTileset(in.Tilesets()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := codec.UnmarshalTileset(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out.Name != "things" || out.TileCount() != 2 || out.OffsetX != 2 || out.OffsetY != -4 {
+		t.Fatal("unexpected tileset", out)
+	}
+	first := out.Tiles()[0]
+	if first.Type != "ground" || first.Probability != 0.5 || first.TopRightTerrain() == nil || first.BottomLeftTerrain() != nil {
+		t.Error("unexpected tile", first)
+	}
+	if first.Animation == nil || len(first.Animation.Frames) != 2 || first.Animation.Frames[1].Tile != out.Tiles()[1] {
+		t.Error("expected animation frames to refer to tiles in the set")
+	}
+	if out.Terrain()[0].Tile != first {
+		t.Error("expected terrain tile to be set")
+	}
+}
+
+func TestWangSetRoundTrip(t *testing.T) {
+	in := common.NewTileset("terrain", common.NewTile("grass.png"), common.NewTile("edge.png"), common.NewTile("sand.png"))
+	grass, sand := common.NewWangColor("grass"), common.NewWangColor("sand")
+	grass.Colour, grass.Tile = &color.RGBA{0, 255, 0, 255}, in.Tiles()[0]
+	sand.Colour, sand.Probability = &color.RGBA{255, 255, 0, 255}, 0.5
+	sand.UpdateProperties(common.NewProp("speed").SetFloat(0.75))
+	set := common.NewWangSet("ground", common.WangSetTypeCorner)
+	set.AddColours(grass, sand)
+	set.SetWangID(in.Tiles()[0], common.WangID{0, 1, 0, 1, 0, 1, 0, 1})
+	set.SetWangID(in.Tiles()[1], common.WangID{0, 2, 0, 1, 0, 1, 0, 2})
+	set.SetWangID(in.Tiles()[2], common.WangID{0, 2, 0, 2, 0, 2, 0, 2})
+	in.AddWangSets(set)
+
+	codec := NewCodecTMJ()
+	data, err := codec.MarshalTileset(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := codec.UnmarshalTileset(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, ok := out.WangSet("ground")
+	if !ok || len(result.Colours()) != 2 || len(result.Tiles()) != 3 {
+		t.Fatal("expected wang set with 2 colours & 3 tiles, got", result)
+	}
+	if id, _ := result.WangID(out.Tiles()[1]); id != (common.WangID{0, 2, 0, 1, 0, 1, 0, 2}) {
+		t.Error("unexpected wang id", id)
+	}
+	expect, _ := v1.NewCodecV1().MarshalTileset(in)
+	if got, _ := v1.NewCodecV1().MarshalTileset(out); !bytes.Equal(got, expect) {
+		t.Error("expected", string(expect), "got", string(got))
+	}
+}
+
+func TestUnmarshalBadProperty(t *testing.T) {
+	data := `{"width":1,"height":1,"tilewidth":1,"tileheight":1,"layers":[],"tilesets":[],
+	 "properties":[{"name":"count","type":"int","value":"three"}]}`
+
+	_, err := NewCodecTMJ().Unmarshal([]byte(data))
+	if err == nil {
+		t.Error("expected an error for an int property with a string value")
+	}
+}
+
+func TestAtlasTilesetRoundTrip(t *testing.T) {
+	in := common.NewMap(common.Width(2), common.Height(1), common.TileWidth(16), common.TileHeight(16))
+	tset := in.NewTileset("sheet")
+	tset.Margin = 1
+	tset.Spacing = 2
+	tset.SetImage("sheet.png", 54, 36)
+	tset.Tiles()[4].Type = "wall"
+	layer := in.NewTileLayer("ground")
+	layer.Put(0, 0, tset.Tiles()[4])
+	layer.Put(1, 0, tset.Tiles()[5])
+	expect := xmlOf(t, in)
+
+	codec := NewCodecTMJ()
+	data, err := codec.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := codec.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result := xmlOf(t, out); !bytes.Equal(result, expect) {
+		t.Error("expected", string(expect), "got", string(result))
+	}
+	if out.Tilesets()[0].TileCount() != 6 || out.TileLayers()[0].Get(1, 0).SourceRect() != image.Rect(37, 19, 53, 35) {
+		t.Error("expected all 6 atlas tiles to be addressable")
+	}
+}
+
+func TestEmptyInfiniteLayer(t *testing.T) {
+	in := common.NewMap(common.Infinite(), common.TileWidth(16), common.TileHeight(16))
+	in.NewTileLayer("empty")
+
+	for _, codec := range []*CodecTMJ{
+		NewCodecTMJ(),
+		NewCodecTMJ(DataEncoding(common.DataEncodingBase64, "")),
+		NewCodecTMJ(DataEncoding(common.DataEncodingBase64, common.DataCompressionGzip)),
+		NewCodecTMJ(DataEncoding(common.DataEncodingBase64, common.DataCompressionZlib)),
+		NewCodecTMJ(DataEncoding(common.DataEncodingBase64, common.DataCompressionZstd)),
+	} {
+		name := codec.encoding + codec.compression
+		data, err := codec.Marshal(in)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		out, err := codec.Unmarshal(data)
+		if err != nil {
+			t.Error(name, err)
+			continue
+		}
+		if len(out.TileLayers()) != 1 || len(out.TileLayers()[0].Chunks()) != 0 {
+			t.Error(name, "expected a single empty layer")
+		}
+	}
+}
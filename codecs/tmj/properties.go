@@ -0,0 +1,184 @@
+package tmj
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+// Represents a single Property, the JSON type of the value depends on the property type
+//
+type property struct {
+	Name         string          `json:"name"`
+	Type         string          `json:"type"`
+	PropertyType string          `json:"propertytype,omitempty"` // name of a custom class or enum
+	Value        json.RawMessage `json:"value"`
+}
+
+func inflateProperties(d *decoder, in []property) ([]*common.Property, error) {
+	result := []*common.Property{}
+	for _, prop := range in {
+		inflated, err := prop.inflate(d)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, inflated)
+	}
+	return result, nil
+}
+
+// Inflate the property, class properties are given any default members missing from the
+// data if the codec knows the class
+//
+func (p *property) inflate(d *decoder) (*common.Property, error) {
+	prop := common.NewProp(p.Name)
+
+	var err error
+	switch p.Type {
+	case common.PropertyTypeString, "": // type is optional, string is the default
+		var val string
+		err = json.Unmarshal(p.Value, &val)
+		prop.SetString(val)
+	case common.PropertyTypeFile:
+		var val string
+		err = json.Unmarshal(p.Value, &val)
+		prop.SetFilepath(val)
+	case common.PropertyTypeColour:
+		var val string
+		err = json.Unmarshal(p.Value, &val)
+		if err == nil {
+			col, cerr := decodeHexColour(val)
+			prop.SetColour(col)
+			err = cerr
+		}
+	case common.PropertyTypeFloat:
+		var val float64
+		err = json.Unmarshal(p.Value, &val)
+		prop.SetFloat(val)
+	case common.PropertyTypeInt:
+		var val int
+		err = json.Unmarshal(p.Value, &val)
+		prop.SetInt(val)
+	case common.PropertyTypeBool:
+		var val bool
+		err = json.Unmarshal(p.Value, &val)
+		prop.SetBool(val)
+	case common.PropertyTypeObject:
+		var val int
+		err = json.Unmarshal(p.Value, &val)
+		prop.SetObject(val)
+	case common.PropertyTypeClass:
+		members, merr := inflateMembers(d, p.PropertyType, p.Value)
+		if merr != nil {
+			return nil, merr
+		}
+		prop.SetClass(p.PropertyType, members...)
+		d.codec.propertyTypes.FillDefaults(prop)
+	default:
+		return nil, errors.New(fmt.Sprintf("Property %s has unknown type %s", p.Name, p.Type))
+	}
+
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Property %s has invalid %s value %s: %v", p.Name, p.Type, p.Value, err))
+	}
+	prop.SetPropertyType(p.PropertyType)
+	return prop, nil
+}
+
+// Inflate the members of a class property from it's value, a JSON object of member name -> value.
+// Member types are taken from the class if the codec knows it, otherwise from the JSON values.
+//
+func inflateMembers(d *decoder, class string, value json.RawMessage) ([]*common.Property, error) {
+	values := map[string]json.RawMessage{}
+	if len(value) > 0 {
+		err := json.Unmarshal(value, &values)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Class %s has invalid value %s: %v", class, value, err))
+		}
+	}
+	def, known := d.codec.propertyTypes.Class(class)
+
+	names := []string{}
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	members := []property{}
+	for _, name := range names {
+		member := property{Name: name, Type: jsonType(values[name]), Value: values[name]}
+		if known {
+			if m, ok := def.Member(name); ok {
+				member.Type = m.Type()
+				member.PropertyType = m.PropertyType()
+			}
+		}
+		members = append(members, member)
+	}
+	return inflateProperties(d, members)
+}
+
+// The property type best matching a JSON value, for members of classes we don't know
+func jsonType(value json.RawMessage) string {
+	trimmed := strings.TrimSpace(string(value))
+	if trimmed == "" {
+		return common.PropertyTypeString
+	}
+	switch trimmed[0] {
+	case '{':
+		return common.PropertyTypeClass
+	case '"':
+		return common.PropertyTypeString
+	case 't', 'f':
+		return common.PropertyTypeBool
+	}
+	if strings.ContainsAny(trimmed, ".eE") {
+		return common.PropertyTypeFloat
+	}
+	return common.PropertyTypeInt
+}
+
+func deflateProperty(in *common.Property) property {
+	var value interface{}
+	switch in.Type() {
+	case common.PropertyTypeFile:
+		value = in.AsFilepath()
+	case common.PropertyTypeColour:
+		value = encodeHexColour(in.AsColour())
+	case common.PropertyTypeFloat:
+		value = in.AsFloat()
+	case common.PropertyTypeInt:
+		value = in.AsInt()
+	case common.PropertyTypeBool:
+		value = in.AsBool()
+	case common.PropertyTypeObject:
+		value = in.AsObject()
+	case common.PropertyTypeClass:
+		members := map[string]json.RawMessage{}
+		for _, m := range in.ExplicitMembers() {
+			members[m.Name()] = deflateProperty(m).Value
+		}
+		value = members
+	default:
+		value = in.AsString()
+	}
+
+	data, _ := json.Marshal(value) // Nb. all of these types always marshal
+	return property{Name: in.Name(), Type: in.Type(), PropertyType: in.PropertyType(), Value: data}
+}
+
+// Deflate properties for writing to JSON, sorted by name so output is stable
+//
+func deflateProperties(in []*common.Property) []property {
+	sort.Slice(in, func(i, j int) bool { return in[i].Name() < in[j].Name() })
+
+	result := []property{}
+	for _, prop := range in {
+		result = append(result, deflateProperty(prop))
+	}
+	return result
+}
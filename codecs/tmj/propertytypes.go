@@ -0,0 +1,87 @@
+package tmj
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+const typeCustomClass = "class"
+
+// A custom type, as declared in a Tiled project file (.tiled-project) or exported from
+// Tiled's Custom Types Editor. Only classes are kept, enums are stored in maps as plain
+// string or int properties.
+//
+type propertyType struct {
+	Name    string               `json:"name"`
+	Type    string               `json:"type"`
+	UseAs   []string             `json:"useAs"`
+	Members []propertyTypeMember `json:"members"`
+}
+
+// A member of a custom class, like a property but with "propertyType" in camel case
+//
+type propertyTypeMember struct {
+	Name         string          `json:"name"`
+	Type         string          `json:"type"`
+	PropertyType string          `json:"propertyType"`
+	Value        json.RawMessage `json:"value"`
+}
+
+type project struct {
+	PropertyTypes []propertyType `json:"propertyTypes"`
+}
+
+// Unmarshal the custom classes of a Tiled project file (.tiled-project), or of a list of custom
+// types exported from Tiled. Pass these to WithPropertyTypes (of this or the v1 codec) so class
+// properties are given their default values.
+//
+func (c *CodecTMJ) UnmarshalPropertyTypes(data []byte) (*common.PropertyTypes, error) {
+	var types []propertyType
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		err = json.Unmarshal(data, &types)
+	} else {
+		var proj project
+		err = json.Unmarshal(data, &proj)
+		types = proj.PropertyTypes
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// all classes are known before members are inflated, as members may be of any class
+	result := common.NewPropertyTypes()
+	classes := []*common.CustomClass{}
+	for _, t := range types {
+		if t.Type != typeCustomClass {
+			continue
+		}
+		class := common.NewCustomClass(t.Name)
+		if t.UseAs != nil {
+			class.UseAs = t.UseAs
+		}
+		result.AddClasses(class)
+		classes = append(classes, class)
+	}
+
+	d := newDecoder(&CodecTMJ{propertyTypes: result})
+	i := 0
+	for _, t := range types {
+		if t.Type != typeCustomClass {
+			continue
+		}
+		members := []property{}
+		for _, m := range t.Members {
+			members = append(members, property{Name: m.Name, Type: m.Type, PropertyType: m.PropertyType, Value: m.Value})
+		}
+		props, err := inflateProperties(d, members)
+		if err != nil {
+			return nil, err
+		}
+		classes[i].UpdateMembers(props...)
+		i++
+	}
+	return result, nil
+}
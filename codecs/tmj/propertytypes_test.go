@@ -0,0 +1,170 @@
+package tmj
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+const (
+	tiledProject = `{
+ "automappingRulesFile": "",
+ "folders": ["."],
+ "propertyTypes": [
+  {"id": 1, "name": "Direction", "type": "enum", "storageType": "string", "values": ["north", "south"], "valuesAsFlags": false},
+  {"id": 2, "name": "Stats", "type": "class", "useAs": ["property", "object"], "color": "#ffa0a0a4", "drawFill": true,
+   "members": [
+    {"name": "hp", "type": "int", "value": 10},
+    {"name": "speed", "type": "float", "value": 1.5},
+    {"name": "tint", "type": "color", "value": "#ff0000"},
+    {"name": "facing", "type": "string", "propertyType": "Direction", "value": "south"},
+    {"name": "resist", "type": "class", "propertyType": "Resistances", "value": {}}
+   ]},
+  {"id": 3, "name": "Resistances", "type": "class", "useAs": ["property"],
+   "members": [
+    {"name": "fire", "type": "float", "value": 0},
+    {"name": "ice", "type": "float", "value": 0.25}
+   ]}
+ ]
+}`
+
+	classPropertiesMap = `{"type": "map", "orientation": "orthogonal", "width": 1, "height": 1, "tilewidth": 16, "tileheight": 16,
+ "properties": [
+  {"name": "boss", "type": "object", "value": 12},
+  {"name": "stats", "type": "class", "propertytype": "Stats", "value": {"hp": 50, "tint": "#00ff00", "resist": {"fire": 1}}}
+ ],
+ "layers": []}`
+)
+
+func TestClassProperties(t *testing.T) {
+	types, err := NewCodecTMJ().UnmarshalPropertyTypes([]byte(tiledProject))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, ok := types.Class("Stats")
+	if !ok || len(stats.Members()) != 5 || len(stats.UseAs) != 2 {
+		t.Fatal("unexpected Stats class", stats)
+	}
+	if _, ok := types.Class("Direction"); ok {
+		t.Error("expected enums not to be loaded as classes")
+	}
+	if facing, _ := stats.Member("facing"); facing.AsString() != "south" || facing.PropertyType() != "Direction" {
+		t.Error("unexpected facing member", facing)
+	}
+
+	codec := NewCodecTMJ(WithPropertyTypes(types))
+	check := func(m *common.Map) {
+		if boss, _ := m.Property("boss"); boss.Type() != common.PropertyTypeObject || boss.AsObject() != 12 {
+			t.Error("expected object property referring to 12, got", boss)
+		}
+
+		prop, _ := m.Property("stats")
+		if prop.Type() != common.PropertyTypeClass || prop.PropertyType() != "Stats" || len(prop.Members()) != 5 {
+			t.Fatal("expected Stats class property with 5 members, got", prop)
+		}
+		if hp, _ := prop.Member("hp"); hp.Type() != common.PropertyTypeInt || hp.AsInt() != 50 {
+			t.Error("expected hp of 50, got", hp)
+		}
+		if tint, _ := prop.Member("tint"); tint.Type() != common.PropertyTypeColour || tint.AsColour().G != 255 {
+			t.Error("expected tint colour member typed from the class, got", tint)
+		}
+		if speed, _ := prop.Member("speed"); speed.AsFloat() != 1.5 {
+			t.Error("expected default speed, got", speed)
+		}
+		resist, _ := prop.Member("resist")
+		if fire, _ := resist.Member("fire"); fire.Type() != common.PropertyTypeFloat || fire.AsFloat() != 1 {
+			t.Error("expected fire member typed as a float, got", fire)
+		}
+		if ice, ok := resist.Member("ice"); !ok || ice.AsFloat() != 0.25 {
+			t.Error("expected default ice member, got", ice)
+		}
+	}
+
+	in, err := codec.Unmarshal([]byte(classPropertiesMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(in)
+
+	data, err := codec.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := codec.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(out)
+
+	// without the classes, member types are guessed from the JSON
+	in, err = NewCodecTMJ().Unmarshal([]byte(classPropertiesMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prop, _ := in.Property("stats")
+	if tint, _ := prop.Member("tint"); tint.Type() != common.PropertyTypeString {
+		t.Error("expected untyped colour to be a string, got", tint.Type())
+	}
+	if _, ok := prop.Member("speed"); ok {
+		t.Error("expected no default members without the classes")
+	}
+}
+
+func TestClassDefaultsNotWritten(t *testing.T) {
+	types, err := NewCodecTMJ().UnmarshalPropertyTypes([]byte(tiledProject))
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec := NewCodecTMJ(WithPropertyTypes(types))
+
+	in, err := codec.Unmarshal([]byte(classPropertiesMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prop, _ := in.Property("stats")
+	speed, _ := prop.Member("speed")
+	if !speed.IsDefault() {
+		t.Error("expected speed to be filled in from the class default")
+	}
+	speed.SetFloat(2)
+
+	data, err := codec.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := NewCodecTMJ().Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prop, _ = out.Property("stats")
+	names := []string{}
+	for _, m := range prop.Members() {
+		names = append(names, m.Name())
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "hp,resist,speed,tint" {
+		t.Error("expected only the members set to be written, got", names)
+	}
+	resist, _ := prop.Member("resist")
+	if _, ok := resist.Member("ice"); ok || len(resist.Members()) != 1 {
+		t.Error("expected only the nested member set to be written, got", resist.Members())
+	}
+}
+
+func TestExportedPropertyTypes(t *testing.T) {
+	var proj struct {
+		PropertyTypes json.RawMessage `json:"propertyTypes"`
+	}
+	json.Unmarshal([]byte(tiledProject), &proj)
+
+	types, err := NewCodecTMJ().UnmarshalPropertyTypes(proj.PropertyTypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(types.Classes()) != 2 {
+		t.Error("expected 2 classes from exported types, got", len(types.Classes()))
+	}
+}
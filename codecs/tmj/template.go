@@ -0,0 +1,160 @@
+package tmj
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/voidshard/libtmx/codecs/v1"
+	"github.com/voidshard/libtmx/common"
+)
+
+const typeTemplate = "template"
+
+// Represents a template (.tj) file; a single object, with the tileset of it's tile if
+// it's a tile object
+//
+type template struct {
+	Type    string   `json:"type"`
+	Tileset *tileset `json:"tileset,omitempty"`
+	Object  object   `json:"object"`
+}
+
+// Inflate this template to a common.Template. The template's tileset is loaded relative to
+// dir (the directory of the template file).
+//
+// Global tile ids in a template refer only to it's own tileset, so it's read with a decoder of
+// it's own. The map decoder (if any) is used to swap the template's tile for the same tile of the
+// map's copy of the tileset, so that objects based on the template refer to tiles in the map.
+//
+func (t *template) inflate(d *decoder, dir string, mapDecoder *decoder) (*common.Template, error) {
+	td := newDecoder(d.codec)
+
+	if t.Tileset != nil {
+		if t.Tileset.Source == "" {
+			return nil, errors.New("Template tilesets must be external tilesets")
+		}
+		ref := *t.Tileset
+		ref.Source = path.Join(dir, ref.Source)
+		_, err := ref.inflate(td)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if t.Object.Template != "" {
+		return nil, errors.New(fmt.Sprintf("Template object cannot itself refer to template %s", t.Object.Template))
+	}
+	obj, err := t.Object.inflate(td)
+	if err != nil {
+		return nil, err
+	}
+	obj.Id = 0
+
+	if mapDecoder != nil {
+		mapDecoder.mapTemplateTile(obj, ".")
+	}
+	return common.NewTemplate("", obj), nil
+}
+
+// Deflate the given common.Template for writing to a template file. Tile objects must use a
+// tileset with a Source, as templates can only refer to external tilesets.
+//
+func deflateTemplate(in *common.Template) (*template, error) {
+	if in.Object == nil {
+		return nil, errors.New("Template has no object")
+	}
+
+	out := &template{Type: typeTemplate, Object: deflateObject(in.Object)}
+	out.Object.Id = 0
+	out.Object.X = 0
+	out.Object.Y = 0
+	out.Object.Template = ""
+
+	if in.Object.Kind() == common.ObjectTypeTile {
+		cell := in.Object.TileCell()
+		tset := cell.Tile.Tileset()
+		if tset == nil || tset.Source == "" {
+			return nil, errors.New("Template tile objects must use a tileset with a Source")
+		}
+
+		// the template's tileset is the only one in the file, so it starts at 1
+		out.Tileset = &tileset{FirstGID: 1, Source: tset.Source}
+		out.Object.Gid = cell.GlobalID() & common.GIDFlags | uint32(cell.Tile.Id + 1)
+	}
+	return out, nil
+}
+
+// Load (or fetch from those already loaded) the template at the given path. Templates may be
+// in Tiled's XML (.tx) format as well as it's JSON (.tj) format.
+//
+func (d *decoder) template(source string) (*common.Template, error) {
+	filename := path.Clean(source)
+	if tmpl, ok := d.templates[filename]; ok {
+		return tmpl, nil
+	}
+
+	data, err := d.codec.getResolver().ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var tmpl *common.Template
+	if strings.EqualFold(path.Ext(filename), ".tx") {
+		resolver := &dirResolver{Resolver: d.codec.getResolver(), Dir: path.Dir(filename)}
+		tmpl, err = v1.NewCodecV1(v1.WithResolver(resolver)).UnmarshalTemplate(data)
+		if err != nil {
+			return nil, err
+		}
+		d.mapTemplateTile(tmpl.Object, path.Dir(filename))
+	} else {
+		var jtemplate template
+		err = json.Unmarshal(data, &jtemplate)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err = jtemplate.inflate(d, path.Dir(filename), d)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tmpl.Source = source
+	d.templates[filename] = tmpl
+	return tmpl, nil
+}
+
+// Swap the tile of a template's tile object for the same tile in the map's copy of it's
+// tileset (if the map has it). The template's tileset source is relative to dir.
+//
+func (d *decoder) mapTemplateTile(obj *common.Object, dir string) {
+	if obj.Kind() != common.ObjectTypeTile || obj.Tile().Tileset() == nil {
+		return
+	}
+	source := path.Join(dir, obj.Tile().Tileset().Source)
+	for _, t := range d.tiles {
+		tset := t.Tileset()
+		if t.Id == obj.Tile().Id && tset != nil && tset.Source != "" && path.Clean(tset.Source) == source {
+			cell := obj.TileCell()
+			cell.Tile = t
+			obj.SetTileCell(cell)
+			return
+		}
+	}
+}
+
+// Reads files relative to a directory, for files referred to by files in that directory
+//
+type dirResolver struct {
+	Resolver Resolver
+	Dir      string
+}
+
+func (r *dirResolver) ReadFile(name string) ([]byte, error) {
+	if path.IsAbs(name) {
+		return r.Resolver.ReadFile(name)
+	}
+	return r.Resolver.ReadFile(path.Join(r.Dir, name))
+}
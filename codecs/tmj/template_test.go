@@ -0,0 +1,103 @@
+package tmj
+
+import (
+	"encoding/json"
+	"testing"
+	"testing/fstest"
+
+	"github.com/voidshard/libtmx/codecs/v1"
+	"github.com/voidshard/libtmx/common"
+)
+
+const (
+	templateTileset = `{"type": "tileset", "name": "things", "tilewidth": 16, "tileheight": 16, "tilecount": 1,
+ "tiles": [{"id": 0, "image": "chest.png", "imagewidth": 16, "imageheight": 16}]}`
+
+	chestTemplate = `{"type": "template",
+ "tileset": {"firstgid": 1, "source": "../tilesets/things.tsj"},
+ "object": {"name": "chest", "type": "loot", "gid": 1, "width": 16, "height": 16,
+  "properties": [{"name": "gold", "type": "int", "value": 10}]}}`
+
+	zoneTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<template>
+ <object name="zone" width="40" height="20" visible="0">
+  <ellipse/>
+ </object>
+</template>
+`
+
+	templateMap = `{"type": "map", "orientation": "orthogonal", "width": 2, "height": 2, "tilewidth": 16, "tileheight": 16,
+ "tilesets": [{"firstgid": 3, "source": "tilesets/things.tsj"}],
+ "layers": [{"type": "objectgroup", "name": "objects", "objects": [
+  {"id": 1, "template": "templates/chest.tj", "x": 16, "y": 32},
+  {"id": 2, "template": "templates/chest.tj", "name": "big chest", "x": 0, "y": 16, "visible": false,
+   "properties": [{"name": "gold", "type": "int", "value": 500}]},
+  {"id": 3, "template": "templates/zone.tx", "x": 8, "y": 8}
+ ]}]}`
+)
+
+func TestObjectTemplates(t *testing.T) {
+	fsys := fstest.MapFS{
+		"maps/tilesets/things.tsj": &fstest.MapFile{Data: []byte(templateTileset)},
+		"maps/templates/chest.tj":  &fstest.MapFile{Data: []byte(chestTemplate)},
+		"maps/templates/zone.tx":   &fstest.MapFile{Data: []byte(zoneTemplate)},
+	}
+	codec := NewCodecTMJ(WithResolver(v1.NewFSResolver(fsys, "maps/level.tmj")), ExternalTilesets())
+
+	check := func(m *common.Map) {
+		objs := m.ObjectLayers()[0].Objects()
+		if len(objs) != 3 {
+			t.Fatalf("expected 3 objects, got %d", len(objs))
+		}
+		chest, big, zone := objs[0], objs[1], objs[2]
+		thing, _ := m.Tilesets()[0].Tile(0)
+
+		if chest.Template() == nil || chest.Name != "chest" || chest.Type != "loot" || !chest.Visible || chest.Tile() != thing {
+			t.Error("unexpected chest values", chest)
+		}
+		if big.Name != "big chest" || big.Type != "loot" || big.Visible || big.Width != 16 || big.Tile() != thing {
+			t.Error("unexpected big chest values", big)
+		}
+		if gold, ok := big.Property("gold"); !ok || gold.AsInt() != 500 {
+			t.Error("expected overridden 500 gold, got", gold)
+		}
+		if zone.Kind() != common.ObjectTypeEllipse || zone.Visible || zone.Width != 40 || zone.X != 8 {
+			t.Error("unexpected zone values", zone)
+		}
+	}
+
+	in, err := codec.Unmarshal([]byte(templateMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(in)
+
+	data, err := codec.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the overridden values are written out
+	var written struct {
+		Layers []struct {
+			Objects []map[string]interface{} `json:"objects"`
+		} `json:"layers"`
+	}
+	err = json.Unmarshal(data, &written)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs := written.Layers[0].Objects
+	if len(objs[0]) != 4 {
+		t.Error("expected only the template, id & position of the chest to be written, got", objs[0])
+	}
+	if len(objs[1]) != 7 || objs[1]["name"] != "big chest" || objs[1]["visible"] != false {
+		t.Error("expected name, visibility & gold of the big chest to be written, got", objs[1])
+	}
+
+	out, err := codec.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(out)
+}
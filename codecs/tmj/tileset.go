@@ -0,0 +1,370 @@
+package tmj
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/voidshard/libtmx/codecs/v1"
+	"github.com/voidshard/libtmx/common"
+)
+
+type tileset struct {
+	// Nb. a tileset in a map may be just a reference to an external file (firstgid & source only)
+	FirstGID int    `json:"firstgid,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Type     string `json:"type,omitempty"` // set in .tsj files only
+
+	Name       string `json:"name,omitempty"`
+	TileWidth  int    `json:"tilewidth,omitempty"`
+	TileHeight int    `json:"tileheight,omitempty"`
+	Spacing    int    `json:"spacing,omitempty"`
+	Margin     int    `json:"margin,omitempty"`
+	TileCount  int    `json:"tilecount,omitempty"`
+	Columns    int    `json:"columns,omitempty"`
+
+	// atlas tilesets only
+	Image             string `json:"image,omitempty"`
+	ImageWidth        int    `json:"imagewidth,omitempty"`
+	ImageHeight       int    `json:"imageheight,omitempty"`
+	TransparentColour string `json:"transparentcolor,omitempty"`
+
+	Offset     *tileOffset `json:"tileoffset,omitempty"`
+	Properties []property  `json:"properties,omitempty"`
+	Terrains   []terrain   `json:"terrains,omitempty"`
+	WangSets   []wangSet   `json:"wangsets,omitempty"`
+	Tiles      []tile      `json:"tiles,omitempty"`
+}
+
+type tileOffset struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type tile struct {
+	Id          int        `json:"id"`
+	Type        string     `json:"type,omitempty"`
+	Class       string     `json:"class,omitempty"` // Tiled 1.9 wrote 'type' as 'class', we only read this
+	Image       string     `json:"image,omitempty"`
+	ImageWidth  int        `json:"imagewidth,omitempty"`
+	ImageHeight int        `json:"imageheight,omitempty"`
+	Probability *float64   `json:"probability,omitempty"` // nil unless set, Tiled's default is 1
+	Terrain     []int      `json:"terrain,omitempty"` // terrain ids of the top left, top right, bottom left & bottom right corners
+	Animation   []frame    `json:"animation,omitempty"`
+	ObjectGroup *layer     `json:"objectgroup,omitempty"` // collision shapes
+	Properties  []property `json:"properties,omitempty"`
+}
+
+type frame struct {
+	TileId   int `json:"tileid"`
+	Duration int `json:"duration"`
+}
+
+type terrain struct {
+	Name       string     `json:"name"`
+	Tile       int        `json:"tile"`
+	Properties []property `json:"properties,omitempty"`
+}
+
+// Deflate the given common.Tileset to be a reference to it's external file, for writing to JSON
+//
+func deflateTilesetReference(in *common.Tileset) tileset {
+	return tileset{
+		FirstGID: in.FirstGID,
+		Source: in.Source,
+	}
+}
+
+// Deflate the given common.Tileset for writing to JSON
+//
+func deflateTileset(in *common.Tileset) tileset {
+	tset := tileset{
+		Name: in.Name,
+		FirstGID: in.FirstGID,
+		TileWidth: in.TileWidth,
+		TileHeight: in.TileHeight,
+		Spacing: in.Spacing,
+		Margin: in.Margin,
+		TileCount: in.TileCount(),
+		Columns: in.Columns,
+		Properties: deflateProperties(in.Properties()),
+	}
+
+	if in.OffsetX != 0 || in.OffsetY != 0 {
+		tset.Offset = &tileOffset{X: in.OffsetX, Y: in.OffsetY}
+	}
+
+	for _, terr := range in.Terrain() {
+		if terr == nil {
+			continue
+		}
+		tileid := -1
+		if terr.Tile != nil {
+			tileid = terr.Tile.Id
+		}
+		tset.Terrains = append(tset.Terrains, terrain{
+			Name: terr.Name,
+			Tile: tileid,
+			Properties: deflateProperties(terr.Properties()),
+		})
+	}
+
+	if len(in.WangSets()) > 0 {
+		tset.WangSets = deflateWangSets(in.WangSets())
+	}
+
+	if in.IsAtlas() {
+		tset.Image = in.ImageSource
+		tset.ImageWidth = in.ImageWidth
+		tset.ImageHeight = in.ImageHeight
+		tset.TransparentColour = encodeHexColour(in.TransparentColour)
+	}
+
+	for _, t := range in.Tiles() {
+		if in.IsAtlas() && !t.HasData() {
+			continue // every tile in an atlas exists whether it's written out or not
+		}
+		tset.Tiles = append(tset.Tiles, deflateTile(t))
+	}
+
+	return tset
+}
+
+// Deflate the given common.Tile for writing to JSON
+//
+func deflateTile(in *common.Tile) tile {
+	out := tile{
+		Id: in.Id,
+		Type: in.Type,
+		Image: in.Source,
+		ImageWidth: in.Width,
+		ImageHeight: in.Height,
+		Properties: deflateProperties(in.Properties()),
+	}
+	if in.Probability != 1 {
+		probability := in.Probability
+		out.Probability = &probability
+	}
+
+	terrainIds := []int{-1, -1, -1, -1}
+	hasTerrain := false
+	for i, terr := range in.Terrain() {
+		if terr != nil {
+			terrainIds[i] = terr.Id
+			hasTerrain = true
+		}
+	}
+	if hasTerrain {
+		out.Terrain = terrainIds
+	}
+
+	if len(in.CollisionShapes()) > 0 {
+		out.ObjectGroup = &layer{
+			Type: typeObjectGroup,
+			Visible: true,
+			Opacity: 1,
+			DrawOrder: common.ObjectGroupDrawOrderIndex,
+			Objects: []object{},
+		}
+		for _, shape := range in.CollisionShapes() {
+			out.ObjectGroup.Objects = append(out.ObjectGroup.Objects, deflateObject(shape))
+		}
+	}
+
+	if in.Animation != nil {
+		for _, fr := range in.Animation.Frames {
+			out.Animation = append(out.Animation, frame{TileId: fr.Tile.Id, Duration: fr.Duration})
+		}
+	}
+	return out
+}
+
+// Inflate this tileset to a common.Tileset, registering it's tiles with the decoder.
+// If the tileset is a reference to an external file the file is loaded.
+//
+func (t *tileset) inflate(d *decoder) (*common.Tileset, error) {
+	if t.Source != "" {
+		return t.inflateExternal(d)
+	}
+
+	obj := common.NewTileset(t.Name)
+	obj.FirstGID = t.FirstGID
+	obj.TileWidth = t.TileWidth
+	obj.TileHeight = t.TileHeight
+	obj.Margin = t.Margin
+	obj.Spacing = t.Spacing
+
+	if t.Image != "" { // an atlas; every tile exists, whether or not it has an entry in 'tiles'
+		trans, err := decodeHexColour(t.TransparentColour)
+		if err != nil {
+			return nil, err
+		}
+		obj.SetImage(t.Image, t.ImageWidth, t.ImageHeight)
+		obj.TransparentColour = trans
+		if t.Columns > 0 {
+			obj.Columns = t.Columns
+		}
+		obj.FillTiles(t.TileCount)
+	}
+
+	// Tiles are created first as terrain & animations refer to other tiles by id
+	tiles := []*common.Tile{}
+	for _, jtile := range t.Tiles {
+		inflated, ok := obj.Tile(jtile.Id)
+		if !ok {
+			inflated = common.NewTile(jtile.Image)
+			inflated.Id = jtile.Id
+			obj.AddTiles(inflated)
+		}
+		tiles = append(tiles, inflated)
+	}
+	for _, tile := range obj.Tiles() {
+		d.tiles[tile.Id + t.FirstGID] = tile
+	}
+
+	if t.Offset != nil {
+		obj.OffsetX = t.Offset.X
+		obj.OffsetY = t.Offset.Y
+	}
+
+	props, err := inflateProperties(d, t.Properties)
+	if err != nil {
+		return nil, err
+	}
+	obj.UpdateProperties(props...)
+
+	for _, jterrain := range t.Terrains {
+		props, err := inflateProperties(d, jterrain.Properties)
+		if err != nil {
+			return nil, err
+		}
+		terr := common.NewTerrain(jterrain.Name)
+		terr.UpdateProperties(props...)
+		if tile, ok := d.tile(jterrain.Tile + t.FirstGID); ok && jterrain.Tile > -1 {
+			terr.Tile = tile
+		}
+		obj.AddTerrain(terr)
+	}
+
+	for i, jtile := range t.Tiles {
+		err := jtile.inflate(d, tiles[i], t.FirstGID, obj.Terrain())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, jset := range t.WangSets {
+		set, err := jset.inflate(d, t.FirstGID)
+		if err != nil {
+			return nil, err
+		}
+		obj.AddWangSets(set)
+	}
+
+	return obj, nil
+}
+
+// Load & inflate the external tileset file this tileset refers to. Maps may refer to tilesets
+// in Tiled's XML (.tsx) format as well as it's JSON (.tsj) format.
+//
+func (t *tileset) inflateExternal(d *decoder) (*common.Tileset, error) {
+	data, err := d.codec.getResolver().ReadFile(t.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	var obj *common.Tileset
+	if strings.EqualFold(path.Ext(t.Source), ".tsx") {
+		obj, err = v1.NewCodecV1(v1.WithResolver(d.codec.getResolver())).UnmarshalTileset(data)
+		if err != nil {
+			return nil, err
+		}
+		obj.FirstGID = t.FirstGID // the map sets the firstgid, the file doesn't know it
+		for _, tile := range obj.Tiles() {
+			d.tiles[tile.Id + t.FirstGID] = tile
+		}
+	} else {
+		var external tileset
+		err = json.Unmarshal(data, &external)
+		if err != nil {
+			return nil, err
+		}
+		if external.Source != "" {
+			return nil, errors.New(fmt.Sprintf("External tileset %s cannot itself refer to %s", t.Source, external.Source))
+		}
+
+		external.FirstGID = t.FirstGID // the map sets the firstgid, the file doesn't know it
+		obj, err = external.inflate(d)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	obj.Source = t.Source
+	return obj, nil
+}
+
+// Finish inflating the given tile, now that all tiles & terrain (given, in order of terrain id)
+// in the tileset are known
+//
+func (t *tile) inflate(d *decoder, out *common.Tile, firstGID int, terrain []*common.Terrain) error {
+	props, err := inflateProperties(d, t.Properties)
+	if err != nil {
+		return err
+	}
+
+	out.UpdateProperties(props...)
+	out.Type = t.Type
+	if out.Type == "" {
+		out.Type = t.Class
+	}
+	if t.Image != "" {
+		out.Source = t.Image
+		out.Width = t.ImageWidth
+		out.Height = t.ImageHeight
+	}
+	if t.Probability != nil {
+		out.Probability = *t.Probability
+	}
+
+	setters := []func(*common.Terrain){
+		out.SetTopLeftTerrain,
+		out.SetTopRightTerrain,
+		out.SetBottomLeftTerrain,
+		out.SetBottomRightTerrain,
+	}
+	for i, terrainId := range t.Terrain {
+		if i < len(setters) && terrainId > -1 && terrainId < len(terrain) {
+			setters[i](terrain[terrainId])
+		}
+	}
+
+	if t.ObjectGroup != nil {
+		shapes := []*common.Object{}
+		for _, obj := range t.ObjectGroup.Objects {
+			shape, err := obj.inflate(d)
+			if err != nil {
+				return err
+			}
+			shapes = append(shapes, shape)
+		}
+		out.SetCollisionShapes(shapes...)
+	}
+
+	if len(t.Animation) == 0 {
+		return nil
+	}
+
+	frames := []*common.Frame{}
+	for _, fr := range t.Animation {
+		tile, ok := d.tile(fr.TileId + firstGID) // Nb. frames use the local tile id
+		if !ok {
+			continue
+		}
+		frames = append(frames, &common.Frame{Duration: fr.Duration, Tile: tile})
+	}
+	out.SetAnimation(frames...)
+	return nil
+}
@@ -0,0 +1,94 @@
+package tmj
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/voidshard/libtmx/codecs/internal/encode"
+	"github.com/voidshard/libtmx/common"
+)
+
+// Tiled writes positions & sizes as floats, we keep whole pixels
+func round(in float64) int {
+	return int(math.Round(in))
+}
+
+// Parse a colour from hex #AARRGGBB or #RRGGBB
+//  - an empty string means no colour was set (nil)
+func decodeHexColour(s string) (*color.RGBA, error) {
+	return encode.DecodeHexColour(s)
+}
+
+// Parse a tint colour, as for decodeHexColour but a colour without alpha is fully opaque
+func decodeTintColour(s string) (*color.RGBA, error) {
+	return encode.DecodeTintColour(s)
+}
+
+// Turn a RGBA colour back into #RRGGBB format
+func encodeHexColour(in *color.RGBA) string {
+	return encode.EncodeHexColour(in)
+}
+
+// Decode tile data into [][]int, rows are 'width' tiles long.
+//  - csv encoded data is a JSON array of global tile ids
+//  - base64 encoded data is a JSON string, optionally compressed
+func decodeTileData(in json.RawMessage, encoding, compression string, width int) ([][]int, error) {
+	if len(in) == 0 {
+		return [][]int{}, nil
+	}
+
+	if encoding == common.DataEncodingBase64 {
+		var data string
+		err := json.Unmarshal(in, &data)
+		if err != nil {
+			return nil, err
+		}
+		return encode.DecodeTileDataBase64(data, compression, width)
+	}
+
+	var data []uint32
+	err := json.Unmarshal(in, &data)
+	if err != nil {
+		return nil, err
+	}
+	if width < 1 {
+		return nil, errors.New(fmt.Sprintf("Expected tile data width > 0, got %d", width))
+	}
+
+	result := [][]int{}
+	for i := 0; i < len(data); i += width {
+		row := []int{}
+		for j := i; j < i + width && j < len(data); j++ {
+			row = append(row, int(data[j]))
+		}
+		result = append(result, row)
+	}
+	return result, nil
+}
+
+// Encode tile id row/column data with the given encoding & compression
+func encodeTileData(tiles [][]int, encoding, compression string) (json.RawMessage, error) {
+	switch encoding {
+	case common.DataEncodingBase64:
+		data, err := encode.EncodeTileDataBase64(tiles, compression)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(data)
+	case common.DataEncodingCsv:
+		if compression != "" {
+			return nil, errors.New(fmt.Sprintf("Tile data compression %s requires %s encoding", compression, common.DataEncodingBase64))
+		}
+		data := []uint32{}
+		for _, row := range tiles {
+			for _, tileid := range row {
+				data = append(data, uint32(tileid))
+			}
+		}
+		return json.Marshal(data)
+	}
+	return nil, errors.New(fmt.Sprintf("Unknown tile data encoding %s", encoding))
+}
@@ -0,0 +1,141 @@
+package tmj
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+type wangSet struct {
+	Name       string      `json:"name"`
+	Type       string      `json:"type"`
+	Tile       int         `json:"tile"`
+	Properties []property  `json:"properties,omitempty"`
+	Colours    []wangColor `json:"colors"`
+	Tiles      []wangTile  `json:"wangtiles"`
+}
+
+type wangColor struct {
+	Name        string     `json:"name"`
+	Colour      string     `json:"color"`
+	Tile        int        `json:"tile"`
+	Probability *float64   `json:"probability"` // nil unless set, Tiled's default is 1
+	Properties  []property `json:"properties,omitempty"`
+}
+
+type wangTile struct {
+	TileId int   `json:"tileid"`
+	WangID []int `json:"wangid"` // colours of the 8 edges & corners, clockwise from the top edge
+}
+
+// Deflate the given wang sets for writing to JSON
+//
+func deflateWangSets(in []*common.WangSet) []wangSet {
+	result := []wangSet{}
+	for _, set := range in {
+		out := wangSet{
+			Name: set.Name,
+			Type: set.Type,
+			Tile: localTileId(set.Tile),
+			Properties: deflateProperties(set.Properties()),
+			Colours: []wangColor{},
+			Tiles: []wangTile{},
+		}
+		for _, colour := range set.Colours() {
+			out.Colours = append(out.Colours, wangColor{
+				Name: colour.Name,
+				Colour: encodeHexColour(colour.Colour),
+				Tile: localTileId(colour.Tile),
+				Probability: &colour.Probability,
+				Properties: deflateProperties(colour.Properties()),
+			})
+		}
+		for _, tile := range set.Tiles() {
+			id, _ := set.WangID(tile)
+			out.Tiles = append(out.Tiles, wangTile{TileId: tile.Id, WangID: id[:]})
+		}
+		result = append(result, out)
+	}
+	return result
+}
+
+// Inflate the wang set, tiles are referred to by their local id within the tileset
+//
+func (w *wangSet) inflate(d *decoder, firstGID int) (*common.WangSet, error) {
+	kind := w.Type
+	if kind == "" {
+		kind = common.WangSetTypeCorner
+	}
+	set := common.NewWangSet(w.Name, kind)
+	props, err := inflateProperties(d, w.Properties)
+	if err != nil {
+		return nil, err
+	}
+	set.UpdateProperties(props...)
+	set.Tile, err = localTile(d, firstGID, w.Tile)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, wc := range w.Colours {
+		colour := common.NewWangColor(wc.Name)
+		colour.Colour, err = decodeTintColour(wc.Colour)
+		if err != nil {
+			return nil, err
+		}
+		colour.Tile, err = localTile(d, firstGID, wc.Tile)
+		if err != nil {
+			return nil, err
+		}
+		if wc.Probability != nil {
+			colour.Probability = *wc.Probability
+		}
+		props, err := inflateProperties(d, wc.Properties)
+		if err != nil {
+			return nil, err
+		}
+		colour.UpdateProperties(props...)
+		set.AddColours(colour)
+	}
+
+	for _, wt := range w.Tiles {
+		tile, err := localTile(d, firstGID, wt.TileId)
+		if err != nil || tile == nil {
+			return nil, errors.New(fmt.Sprintf("Wang set %s refers to unknown tile %d", w.Name, wt.TileId))
+		}
+		id := common.WangID{}
+		if len(wt.WangID) != len(id) {
+			return nil, errors.New(fmt.Sprintf("Wang set %s expected 8 wang colours for tile %d, got %v", w.Name, wt.TileId, wt.WangID))
+		}
+		for i, colour := range wt.WangID {
+			if colour < 0 || colour > len(set.Colours()) {
+				return nil, errors.New(fmt.Sprintf("Wang set %s tile %d refers to unknown colour %d", w.Name, wt.TileId, colour))
+			}
+			id[i] = colour
+		}
+		set.SetWangID(tile, id)
+	}
+
+	return set, nil
+}
+
+// The tile with the given local id, where -1 means no tile
+func localTile(d *decoder, firstGID, id int) (*common.Tile, error) {
+	if id < 0 {
+		return nil, nil
+	}
+	tile, ok := d.tile(id + firstGID)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Refers to unknown tile %d", id))
+	}
+	return tile, nil
+}
+
+// Inverse of localTile, the local id of the given tile or -1 for no tile
+func localTileId(in *common.Tile) int {
+	if in == nil {
+		return -1
+	}
+	return in.Id
+}
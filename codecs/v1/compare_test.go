@@ -0,0 +1,419 @@
+package v1
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+// Walks two common.Maps (or tilesets, or objects) side by side, noting every difference along
+// with the path to where it is (in the form of DecodeError paths, eg. map/layer[ground])
+type comparer struct {
+	diffs []string
+}
+
+func compareMaps(a, b *common.Map) []string {
+	c := &comparer{}
+	c.compareMap("map", a, b)
+	return c.diffs
+}
+
+func compareTilesets(a, b *common.Tileset) []string {
+	c := &comparer{}
+	c.compareTileset(element("tileset", a.Name), a, b)
+	return c.diffs
+}
+
+func compareObjects(a, b *common.Object) []string {
+	c := &comparer{}
+	c.compareObject("object", a, b)
+	return c.diffs
+}
+
+// Note a difference if the given values print differently (so that nil & empty slices, or
+// colours at different addresses, are the same)
+func (c *comparer) field(path, name string, a, b interface{}) {
+	as, bs := fmt.Sprintf("%v", a), fmt.Sprintf("%v", b)
+	if as != bs {
+		c.diffs = append(c.diffs, fmt.Sprintf("%s: %s was %s, now %s", path, name, as, bs))
+	}
+}
+
+// Note a difference if the given counts differ, returning the smaller
+func (c *comparer) count(path, name string, a, b int) int {
+	c.field(path, name, a, b)
+	if b < a {
+		return b
+	}
+	return a
+}
+
+// A tile as it's tileset & id, so tiles of different (but equal) maps can be compared
+func tileRef(t *common.Tile) string {
+	if t == nil {
+		return "none"
+	}
+	if t.Tileset() == nil {
+		return fmt.Sprintf("tile %d of no tileset", t.Id)
+	}
+	return fmt.Sprintf("tile %d of %s", t.Id, t.Tileset().Name)
+}
+
+func cellRef(cell common.Cell) string {
+	return fmt.Sprintf("%s (flipped h:%v v:%v d:%v hex:%v)", tileRef(cell.Tile), cell.FlipH, cell.FlipV, cell.FlipD, cell.FlipHex)
+}
+
+// A text object's text & settings (the colour pointer would otherwise print as an address)
+func textRef(t *common.Text) string {
+	if t == nil {
+		return "none"
+	}
+	txt := *t
+	txt.Colour = nil
+	return fmt.Sprintf("%+v colour %v", txt, t.Colour)
+}
+
+func (c *comparer) compareMap(path string, a, b *common.Map) {
+	c.field(path, "Width", a.Width, b.Width)
+	c.field(path, "Height", a.Height, b.Height)
+	c.field(path, "TileWidth", a.TileWidth, b.TileWidth)
+	c.field(path, "TileHeight", a.TileHeight, b.TileHeight)
+	c.field(path, "HexSideLength", a.HexSideLength, b.HexSideLength)
+	c.field(path, "BackgroundColor", a.BackgroundColor, b.BackgroundColor)
+	c.field(path, "Version", a.Version, b.Version)
+	c.field(path, "TiledVersion", a.TiledVersion, b.TiledVersion)
+	c.field(path, "Orientation", a.Orientation(), b.Orientation())
+	c.field(path, "RenderOrder", a.RenderOrder(), b.RenderOrder())
+	c.field(path, "StaggerAxis", a.StaggerAxis(), b.StaggerAxis())
+	c.field(path, "StaggerIndex", a.StaggerIndex(), b.StaggerIndex())
+	c.field(path, "Infinite", a.Infinite(), b.Infinite())
+	c.field(path, "NextObjectID", a.NextObjectID(), b.NextObjectID())
+	c.compareProperties(path, a, b)
+
+	at, bt := a.Tilesets(), b.Tilesets()
+	for i := 0; i < c.count(path, "tilesets", len(at), len(bt)); i++ {
+		c.compareTileset(path+"/"+element("tileset", at[i].Name), at[i], bt[i])
+	}
+	c.compareLayers(path, a.Layers(), b.Layers())
+}
+
+func (c *comparer) compareTileset(path string, a, b *common.Tileset) {
+	c.field(path, "Name", a.Name, b.Name)
+	c.field(path, "FirstGID", a.FirstGID, b.FirstGID)
+	c.field(path, "Source", a.Source, b.Source)
+	c.field(path, "OffsetX", a.OffsetX, b.OffsetX)
+	c.field(path, "OffsetY", a.OffsetY, b.OffsetY)
+	c.field(path, "TileWidth", a.TileWidth, b.TileWidth)
+	c.field(path, "TileHeight", a.TileHeight, b.TileHeight)
+	c.field(path, "Spacing", a.Spacing, b.Spacing)
+	c.field(path, "Margin", a.Margin, b.Margin)
+	c.field(path, "ImageSource", a.ImageSource, b.ImageSource)
+	c.field(path, "ImageWidth", a.ImageWidth, b.ImageWidth)
+	c.field(path, "ImageHeight", a.ImageHeight, b.ImageHeight)
+	c.field(path, "TransparentColour", a.TransparentColour, b.TransparentColour)
+	c.field(path, "Columns", a.Columns, b.Columns)
+	c.compareProperties(path, a, b)
+
+	aTerrain, bTerrain := a.Terrain(), b.Terrain()
+	for i := 0; i < c.count(path, "terrain", len(aTerrain), len(bTerrain)); i++ {
+		at, bt := aTerrain[i], bTerrain[i]
+		terrainPath := path + "/" + element("terrain", at.Name)
+		c.field(terrainPath, "Id", at.Id, bt.Id)
+		c.field(terrainPath, "Name", at.Name, bt.Name)
+		c.field(terrainPath, "Tile", tileRef(at.Tile), tileRef(bt.Tile))
+		c.compareProperties(terrainPath, at, bt)
+	}
+
+	aSets, bSets := a.WangSets(), b.WangSets()
+	for i := 0; i < c.count(path, "wang sets", len(aSets), len(bSets)); i++ {
+		c.compareWangSet(path+"/"+element("wangset", aSets[i].Name), aSets[i], bSets[i])
+	}
+
+	aTiles, bTiles := a.Tiles(), b.Tiles()
+	for i := 0; i < c.count(path, "tiles", len(aTiles), len(bTiles)); i++ {
+		c.compareTile(path+"/"+element("tile", aTiles[i].Id), aTiles[i], bTiles[i])
+	}
+}
+
+func (c *comparer) compareWangSet(path string, a, b *common.WangSet) {
+	c.field(path, "Name", a.Name, b.Name)
+	c.field(path, "Type", a.Type, b.Type)
+	c.field(path, "Tile", tileRef(a.Tile), tileRef(b.Tile))
+	c.compareProperties(path, a, b)
+
+	ac, bc := a.Colours(), b.Colours()
+	for i := 0; i < c.count(path, "colours", len(ac), len(bc)); i++ {
+		colourPath := path + "/" + element("wangcolor", ac[i].Name)
+		c.field(colourPath, "Name", ac[i].Name, bc[i].Name)
+		c.field(colourPath, "Colour", ac[i].Colour, bc[i].Colour)
+		c.field(colourPath, "Tile", tileRef(ac[i].Tile), tileRef(bc[i].Tile))
+		c.field(colourPath, "Probability", ac[i].Probability, bc[i].Probability)
+		c.compareProperties(colourPath, ac[i], bc[i])
+	}
+
+	at, bt := a.Tiles(), b.Tiles()
+	for i := 0; i < c.count(path, "tiles", len(at), len(bt)); i++ {
+		aid, _ := a.WangID(at[i])
+		bid, _ := b.WangID(bt[i])
+		tilePath := path + "/" + element("wangtile", at[i].Id)
+		c.field(tilePath, "Tile", tileRef(at[i]), tileRef(bt[i]))
+		c.field(tilePath, "WangID", aid, bid)
+	}
+}
+
+func (c *comparer) compareTile(path string, a, b *common.Tile) {
+	c.field(path, "Id", a.Id, b.Id)
+	c.field(path, "Type", a.Type, b.Type)
+	c.field(path, "Source", a.Source, b.Source)
+	c.field(path, "Width", a.Width, b.Width)
+	c.field(path, "Height", a.Height, b.Height)
+	c.field(path, "Probability", a.Probability, b.Probability)
+	c.compareProperties(path, a, b)
+
+	for i, corner := range []string{"TopLeft", "TopRight", "BottomLeft", "BottomRight"} {
+		ater, bter := a.Terrain()[i], b.Terrain()[i]
+		aname, bname := "none", "none"
+		if ater != nil {
+			aname = fmt.Sprintf("%d %s", ater.Id, ater.Name)
+		}
+		if bter != nil {
+			bname = fmt.Sprintf("%d %s", bter.Id, bter.Name)
+		}
+		c.field(path, corner+"Terrain", aname, bname)
+	}
+
+	aFrames, bFrames := []*common.Frame{}, []*common.Frame{}
+	if a.Animation != nil {
+		aFrames = a.Animation.Frames
+	}
+	if b.Animation != nil {
+		bFrames = b.Animation.Frames
+	}
+	for i := 0; i < c.count(path, "animation frames", len(aFrames), len(bFrames)); i++ {
+		framePath := fmt.Sprintf("%s/frame[%d]", path, i)
+		c.field(framePath, "Tile", tileRef(aFrames[i].Tile), tileRef(bFrames[i].Tile))
+		c.field(framePath, "Duration", aFrames[i].Duration, bFrames[i].Duration)
+	}
+
+	as, bs := a.CollisionShapes(), b.CollisionShapes()
+	for i := 0; i < c.count(path, "collision shapes", len(as), len(bs)); i++ {
+		c.compareObject(path+"/"+element("object", as[i].Id), as[i], bs[i])
+	}
+}
+
+func (c *comparer) compareLayers(path string, a, b []common.Layer) {
+	for i := 0; i < c.count(path, "layers", len(a), len(b)); i++ {
+		switch al := a[i].(type) {
+		case *common.TileLayer:
+			layerPath := path + "/" + element("layer", al.Name)
+			bl, ok := b[i].(*common.TileLayer)
+			if !ok {
+				c.field(layerPath, "type", fmt.Sprintf("%T", a[i]), fmt.Sprintf("%T", b[i]))
+				continue
+			}
+			c.compareTileLayer(layerPath, al, bl)
+		case *common.ImageLayer:
+			layerPath := path + "/" + element("imagelayer", al.Name)
+			bl, ok := b[i].(*common.ImageLayer)
+			if !ok {
+				c.field(layerPath, "type", fmt.Sprintf("%T", a[i]), fmt.Sprintf("%T", b[i]))
+				continue
+			}
+			c.field(layerPath, "Name", al.Name, bl.Name)
+			c.field(layerPath, "Opacity", al.Opacity, bl.Opacity)
+			c.field(layerPath, "Visible", al.Visible, bl.Visible)
+			c.field(layerPath, "OffsetX", al.OffsetX, bl.OffsetX)
+			c.field(layerPath, "OffsetY", al.OffsetY, bl.OffsetY)
+			c.field(layerPath, "TintColour", al.TintColour, bl.TintColour)
+			c.field(layerPath, "ImageSource", al.ImageSource, bl.ImageSource)
+			c.field(layerPath, "ImageFormat", al.ImageFormat, bl.ImageFormat)
+			c.field(layerPath, "Width", al.Width, bl.Width)
+			c.field(layerPath, "Height", al.Height, bl.Height)
+			c.field(layerPath, "TransparentColour", al.TransparentColour, bl.TransparentColour)
+			c.compareProperties(layerPath, al, bl)
+		case *common.ObjectLayer:
+			layerPath := path + "/" + element("objectgroup", al.Name)
+			bl, ok := b[i].(*common.ObjectLayer)
+			if !ok {
+				c.field(layerPath, "type", fmt.Sprintf("%T", a[i]), fmt.Sprintf("%T", b[i]))
+				continue
+			}
+			c.field(layerPath, "Name", al.Name, bl.Name)
+			c.field(layerPath, "Colour", al.Colour, bl.Colour)
+			c.field(layerPath, "Opacity", al.Opacity, bl.Opacity)
+			c.field(layerPath, "Visible", al.Visible, bl.Visible)
+			c.field(layerPath, "OffsetX", al.OffsetX, bl.OffsetX)
+			c.field(layerPath, "OffsetY", al.OffsetY, bl.OffsetY)
+			c.field(layerPath, "DrawOrder", al.DrawOrder, bl.DrawOrder)
+			c.field(layerPath, "TintColour", al.TintColour, bl.TintColour)
+			c.compareProperties(layerPath, al, bl)
+			ao, bo := al.Objects(), bl.Objects()
+			for j := 0; j < c.count(layerPath, "objects", len(ao), len(bo)); j++ {
+				c.compareObject(layerPath+"/"+element("object", ao[j].Id), ao[j], bo[j])
+			}
+		case *common.GroupLayer:
+			layerPath := path + "/" + element("group", al.Name)
+			bl, ok := b[i].(*common.GroupLayer)
+			if !ok {
+				c.field(layerPath, "type", fmt.Sprintf("%T", a[i]), fmt.Sprintf("%T", b[i]))
+				continue
+			}
+			c.field(layerPath, "Name", al.Name, bl.Name)
+			c.field(layerPath, "Opacity", al.Opacity, bl.Opacity)
+			c.field(layerPath, "Visible", al.Visible, bl.Visible)
+			c.field(layerPath, "OffsetX", al.OffsetX, bl.OffsetX)
+			c.field(layerPath, "OffsetY", al.OffsetY, bl.OffsetY)
+			c.field(layerPath, "TintColour", al.TintColour, bl.TintColour)
+			c.compareProperties(layerPath, al, bl)
+			c.compareLayers(layerPath, al.Layers(), bl.Layers())
+		}
+	}
+}
+
+func (c *comparer) compareTileLayer(path string, a, b *common.TileLayer) {
+	c.field(path, "Name", a.Name, b.Name)
+	c.field(path, "Opacity", a.Opacity, b.Opacity)
+	c.field(path, "Visible", a.Visible, b.Visible)
+	c.field(path, "OffsetX", a.OffsetX, b.OffsetX)
+	c.field(path, "OffsetY", a.OffsetY, b.OffsetY)
+	c.field(path, "TintColour", a.TintColour, b.TintColour)
+	c.field(path, "Encoding", a.Encoding, b.Encoding)
+	c.field(path, "Compression", a.Compression, b.Compression)
+	c.field(path, "Width", a.Width(), b.Width())
+	c.field(path, "Height", a.Height(), b.Height())
+	c.field(path, "Infinite", a.Infinite(), b.Infinite())
+	c.field(path, "Chunks", a.Chunks(), b.Chunks())
+	c.compareProperties(path, a, b)
+
+	// every cell that's set in either layer
+	bounds := image.Rectangle{}
+	for _, rect := range append(a.Chunks(), b.Chunks()...) {
+		bounds = bounds.Union(rect)
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c.field(path, fmt.Sprintf("cell %d,%d", x, y), cellRef(a.GetCell(x, y)), cellRef(b.GetCell(x, y)))
+		}
+	}
+}
+
+func (c *comparer) compareObject(path string, a, b *common.Object) {
+	c.field(path, "Id", a.Id, b.Id)
+	c.field(path, "Name", a.Name, b.Name)
+	c.field(path, "Type", a.Type, b.Type)
+	c.field(path, "X", a.X, b.X)
+	c.field(path, "Y", a.Y, b.Y)
+	c.field(path, "Width", a.Width, b.Width)
+	c.field(path, "Height", a.Height, b.Height)
+	c.field(path, "Rotation", a.Rotation, b.Rotation)
+	c.field(path, "Visible", a.Visible, b.Visible)
+	c.field(path, "Kind", a.Kind(), b.Kind())
+	c.field(path, "Points", a.Points(), b.Points())
+	c.field(path, "Text", textRef(a.Text()), textRef(b.Text()))
+	c.field(path, "Tile", cellRef(a.TileCell()), cellRef(b.TileCell()))
+	c.compareProperties(path, a, b)
+
+	aSource, bSource := "none", "none"
+	if a.Template() != nil {
+		aSource = a.Template().Source
+	}
+	if b.Template() != nil {
+		bSource = b.Template().Source
+	}
+	c.field(path, "Template", aSource, bSource)
+}
+
+func (c *comparer) compareProperties(path string, a, b common.PropertyHolder) {
+	aProps, bProps := map[string]*common.Property{}, map[string]*common.Property{}
+	names := []string{}
+	for _, prop := range a.Properties() {
+		aProps[prop.Name()] = prop
+		names = append(names, prop.Name())
+	}
+	for _, prop := range b.Properties() {
+		bProps[prop.Name()] = prop
+		if aProps[prop.Name()] == nil {
+			names = append(names, prop.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !aProps[name].Equal(bProps[name]) {
+			c.field(path+"/"+element("property", name), "value", describeProperty(aProps[name]), describeProperty(bProps[name]))
+		}
+	}
+}
+
+// The type & value of a property (with it's members, for class properties)
+func describeProperty(p *common.Property) string {
+	if p == nil {
+		return "unset"
+	}
+	value := ""
+	switch p.Type() {
+	case common.PropertyTypeInt:
+		value = fmt.Sprint(p.AsInt())
+	case common.PropertyTypeObject:
+		value = fmt.Sprint(p.AsObject())
+	case common.PropertyTypeFloat:
+		value = fmt.Sprint(p.AsFloat())
+	case common.PropertyTypeBool:
+		value = fmt.Sprint(p.AsBool())
+	case common.PropertyTypeColour:
+		value = fmt.Sprint(p.AsColour())
+	case common.PropertyTypeFile:
+		value = p.AsFilepath()
+	case common.PropertyTypeClass:
+		members := []string{}
+		for _, m := range p.Members() {
+			members = append(members, m.Name()+"="+describeProperty(m))
+		}
+		sort.Strings(members)
+		value = "{" + strings.Join(members, " ") + "}"
+	default:
+		value = p.AsString()
+	}
+	return fmt.Sprintf("%s %s %q", p.Type(), p.PropertyType(), value)
+}
+
+func TestCompareMaps(t *testing.T) {
+	build := func() *common.Map {
+		m := common.NewMap(common.Width(2), common.Height(2), common.TileWidth(16), common.TileHeight(16))
+		tset := m.NewTileset("ground", common.NewTile("grass.png"), common.NewTile("sand.png"))
+		layer := m.NewTileLayer("ground")
+		layer.Put(0, 0, tset.Tiles()[0])
+		layer.UpdateProperties(common.NewProp("speed").SetFloat(1.5))
+		objects := m.NewObjectLayer("objects")
+		objects.AddObjects(common.NewObject("chest"))
+		m.FinalizeIDs()
+		return m
+	}
+
+	a, b := build(), build()
+	diffs := compareMaps(a, b)
+	if len(diffs) > 0 {
+		t.Error("expected no differences, got\n", strings.Join(diffs, "\n"))
+	}
+
+	layer := b.TileLayers()[0]
+	layer.PutCell(1, 1, common.Cell{Tile: b.Tilesets()[0].Tiles()[1], FlipH: true})
+	layer.UpdateProperties(common.NewProp("speed").SetFloat(2))
+	b.ObjectLayers()[0].Objects()[0].X = 10
+	b.Tilesets()[0].Spacing = 1
+
+	expect := []string{
+		"map/tileset[ground]: Spacing was 0, now 1",
+		"map/layer[ground]/property[speed]: value was float  \"1.5\", now float  \"2\"",
+		"map/layer[ground]: cell 1,1 was none (flipped h:false v:false d:false hex:false), now tile 1 of ground (flipped h:true v:false d:false hex:false)",
+		"map/objectgroup[objects]/object[1]: X was 0, now 10",
+	}
+	diffs = compareMaps(a, b)
+	if strings.Join(diffs, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expect, "\n"), strings.Join(diffs, "\n"))
+	}
+}
@@ -13,6 +13,22 @@ type dataBlock struct {
 
 	// subsections
 	//tile tile `xml:"tile,optional,omitempty"` // NB: Deliberately removed: creates circular struct
+	Chunks []chunk `xml:"chunk,optional,omitempty"` // infinite maps only
+
+	// value
+	Value string `xml:",chardata"`
+}
+
+// Block of tile data covering part of a tilelayer in an infinite map
+//
+type chunk struct {
+	XMLName xml.Name `xml:"chunk"`
+
+	// attrs
+	X      int `xml:"x,attr"`
+	Y      int `xml:"y,attr"`
+	Width  int `xml:"width,attr"`
+	Height int `xml:"height,attr"`
 
 	// value
 	Value string `xml:",chardata"`
@@ -0,0 +1,66 @@
+package v1
+
+import (
+	"path"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+// Holds the state of a single Unmarshal call, so that parsing many maps at once
+// (or one after another) never shares tiles between them.
+//
+type decoder struct {
+	codec *CodecV1
+
+	// global tile id -> tile, filled in as tilesets are inflated
+	tiles map[int]*common.Tile
+
+	// template path -> template, filled in as objects using templates are inflated
+	templates map[string]*common.Template
+
+	// elements we're currently inflating, outermost first
+	stack []pathElement
+
+	// problems worked around by a lenient codec, shared with decoders this one starts
+	warnings *[]*DecodeError
+}
+
+func newDecoder(c *CodecV1) *decoder {
+	return &decoder{
+		codec: c,
+		tiles: make(map[int]*common.Tile),
+		templates: make(map[string]*common.Template),
+		warnings: &[]*DecodeError{},
+	}
+}
+
+// A new decoder for another file read while decoding this one (eg. a template), which has
+// tiles of it's own but reports errors as part of this decoder
+//
+func (d *decoder) child() *decoder {
+	c := newDecoder(d.codec)
+	c.stack = append([]pathElement{}, d.stack...)
+	c.warnings = d.warnings
+	return c
+}
+
+// Look up an inflated tile by its global id (without flip flags)
+//
+func (d *decoder) tile(gid int) (*common.Tile, bool) {
+	t, ok := d.tiles[gid]
+	return t, ok
+}
+
+// Look up an inflated tile by it's id within the external tileset with the given source
+// (relative to the map)
+//
+func (d *decoder) tileFrom(source string, id int) (*common.Tile, bool) {
+	source = path.Clean(source)
+	for _, t := range d.tiles {
+		tset := t.Tileset()
+		if t.Id == id && tset != nil && tset.Source != "" && resolvePath(".", tset.Source) == source {
+			return t, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,200 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image/color"
+	"strings"
+	"sync"
+)
+
+// DecodeError is a problem found while decoding; what went wrong & where.
+//
+// Problems with the data that can be worked around (a property value that doesn't parse,
+// a tile id that's in no tileset ..) fail decoding, unless the codec is Lenient, in which
+// case they're collected as warnings (see UnmarshalWithWarnings) & decoding carries on.
+//
+type DecodeError struct {
+	// The file the problem is in, when not the data being unmarshalled (eg. an external tileset)
+	File string
+
+	// Path of elements down to the problem, eg. map/tileset[terrain]/tile[3]/property[speed]
+	Path string
+
+	// Where the innermost element on the path is (0 if not known)
+	Line   int
+	Column int
+
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	where := e.Path
+	if e.File != "" {
+		where = fmt.Sprintf("%s %s", e.File, where)
+	}
+	if e.Line > 0 {
+		where = fmt.Sprintf("%s (line %d, column %d)", where, e.Line, e.Column)
+	}
+	if where == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", strings.TrimSpace(where), e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Where an element starts in it's file, recorded as elements are read from XML
+//
+type position struct {
+	line   int
+	column int
+}
+
+// The data each decoder reading with unmarshalXML is reading, so positionOf can look back at it
+//
+var decoding sync.Map // *xml.Decoder -> []byte
+
+// Unmarshal XML data into v, as xml.Unmarshal, keeping the data for positionOf while decoding
+//
+func unmarshalXML(data []byte, v interface{}) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	decoding.Store(dec, data)
+	defer decoding.Delete(dec)
+	return dec.Decode(v)
+}
+
+// The position of the element whose start tag the given decoder has just read. The decoder
+// is at the end of the tag (which may be lines later), so we look back for where it begins.
+//
+func positionOf(d *xml.Decoder) position {
+	line, column := d.InputPos()
+	data, ok := decoding.Load(d)
+	if !ok {
+		return position{line: line, column: column}
+	}
+	src := data.([]byte)
+	end := int(d.InputOffset())
+	if end > len(src) {
+		return position{line: line, column: column}
+	}
+	begin := bytes.LastIndexByte(src[:end], '<')
+	if begin < 0 {
+		return position{line: line, column: column}
+	}
+	line -= bytes.Count(src[begin:end], []byte("\n"))
+	column = begin - bytes.LastIndexByte(src[:begin], '\n')
+	return position{line: line, column: column}
+}
+
+// An element we're inflating, for error reporting
+//
+type pathElement struct {
+	element string   // eg. "layer[ground]", empty for a frame that only changes file
+	file    string   // set when the element is the root of another file (eg. an external tileset)
+	pos     position
+}
+
+// Enter the given element (eg. layer[ground]), returning a func to call with the element's
+// error when leaving it, so that it's given the path to where it happened.
+//   eg. defer d.enter(element("layer", t.Name), t.pos)(&err)
+//
+func (d *decoder) enter(element string, pos position) func(*error) {
+	return d.push(pathElement{element: element, pos: pos})
+}
+
+// As enter, for the root element of another file
+//
+func (d *decoder) enterFile(file string, pos position) func(*error) {
+	return d.push(pathElement{file: file, pos: pos})
+}
+
+func (d *decoder) push(f pathElement) func(*error) {
+	d.stack = append(d.stack, f)
+	return func(err *error) {
+		if *err != nil {
+			*err = d.wrap(*err)
+		}
+		d.stack = d.stack[:len(d.stack) - 1]
+	}
+}
+
+// Wrap the given error as a DecodeError at the current element, errors that are already
+// DecodeErrors are left as they are
+//
+func (d *decoder) wrap(err error) error {
+	var derr *DecodeError
+	if errors.As(err, &derr) {
+		return err
+	}
+	return d.newError(err)
+}
+
+func (d *decoder) newError(err error) *DecodeError {
+	result := &DecodeError{Err: err}
+	path := []string{}
+	for _, f := range d.stack {
+		if f.file != "" {
+			result.File = f.file
+		}
+		if f.element != "" {
+			path = append(path, f.element)
+		}
+		result.Line = f.pos.line
+		result.Column = f.pos.column
+	}
+	result.Path = strings.Join(path, "/")
+	return result
+}
+
+// Report a problem with the data that we can work around. A lenient decoder notes it as a
+// warning & returns nil, so the caller carries on without the bad value, otherwise the problem
+// is returned as an error.
+//
+func (d *decoder) problem(format string, args ...interface{}) error {
+	err := d.newError(errors.New(fmt.Sprintf(format, args...)))
+	if d.codec.lenient {
+		*d.warnings = append(*d.warnings, err)
+		return nil
+	}
+	return err
+}
+
+// Wrap an error from reading XML from the given file ("" for the data being unmarshalled),
+// keeping the line of syntax errors
+//
+func (d *decoder) syntaxError(file string, err error) error {
+	result := d.newError(err)
+	result.File = file
+	var serr *xml.SyntaxError
+	if errors.As(err, &serr) {
+		result.Line = serr.Line
+		result.Column = 0
+	}
+	return result
+}
+
+// Decode a colour with the given decode func (eg. decodeHexColour), a colour that doesn't
+// parse is a problem that lenient decoders work around by leaving the colour unset
+//
+func (d *decoder) colour(s string, decode func(string) (*color.RGBA, error)) (*color.RGBA, error) {
+	col, err := decode(s)
+	if err != nil {
+		return nil, d.problem("Invalid colour %s: %v", s, err)
+	}
+	return col, nil
+}
+
+// Name an element for an error path, by it's kind & (if given) it's name or id
+//
+func element(kind string, id interface{}) string {
+	s := fmt.Sprintf("%v", id)
+	if s == "" {
+		return kind
+	}
+	return fmt.Sprintf("%s[%s]", kind, s)
+}
@@ -0,0 +1,177 @@
+package v1
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const problemMap = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.2" orientation="orthogonal" renderorder="right-down" width="3" height="1" tilewidth="16" tileheight="16">
+ <tileset firstgid="1" name="set" tilewidth="16" tileheight="16" tilecount="2">
+  <terraintypes>
+   <terrain name="grass" tile="0"/>
+  </terraintypes>
+  <tile id="0" terrain="0,0,x,0">
+   <image width="16" height="16" source="grass.png"/>
+   <animation>
+    <frame tileid="7" duration="100"/>
+   </animation>
+  </tile>
+  <tile id="1">
+   <image width="16" height="16" source="dirt.png"/>
+  </tile>
+ </tileset>
+ <layer name="ground" width="3" height="1">
+  <properties>
+   <property name="speed" type="int" value="fast"/>
+  </properties>
+  <data encoding="csv">1,9,2</data>
+ </layer>
+ <imagelayer name="sky">
+  <image source="sky.png" trans="#nope"/>
+ </imagelayer>
+</map>
+`
+
+func TestStrictDecodeError(t *testing.T) {
+	_, err := NewCodecV1().Unmarshal([]byte(problemMap))
+
+	var derr *DecodeError
+	if !errors.As(err, &derr) {
+		t.Fatal("expected a DecodeError, got", err)
+	}
+	// the first problem fails decoding
+	if derr.Path != "map/tileset[set]/tile[0]" || derr.Line != 7 {
+		t.Error("unexpected error location", derr.Path, derr.Line, derr.Column)
+	}
+	if !strings.Contains(err.Error(), "terrain 0,0,x,0") {
+		t.Error("unexpected error", err)
+	}
+}
+
+func TestLenientWarnings(t *testing.T) {
+	in, warnings, err := NewCodecV1(Lenient()).UnmarshalWithWarnings([]byte(problemMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []struct {
+		Path string
+		Line int
+		Text string
+	}{
+		{"map/tileset[set]/tile[0]", 7, "terrain"},
+		{"map/tileset[set]/tile[0]", 7, "unknown tile 7"},
+		{"map/layer[ground]/property[speed]", 19, "invalid int value fast"},
+		{"map/layer[ground]", 17, "global id 9"},
+		{"map/imagelayer[sky]", 23, "colour #nope"},
+	}
+	if len(warnings) != len(expect) {
+		t.Fatal("expected", len(expect), "warnings, got", warnings)
+	}
+	for i, e := range expect {
+		w := warnings[i]
+		if w.Path != e.Path || w.Line != e.Line || !strings.Contains(w.Error(), e.Text) {
+			t.Error("expected warning", e, "got", w.Path, w.Line, w.Error())
+		}
+	}
+
+	// the bad values are left out, everything else is there
+	tile := in.Tilesets()[0].Tiles()[0]
+	if tile.TopLeftTerrain() != nil || (tile.Animation != nil && len(tile.Animation.Frames) != 0) {
+		t.Error("expected no terrain or animation for tile with bad values")
+	}
+	layer := in.TileLayers()[0]
+	if layer.Get(0, 0) != tile || layer.Get(1, 0) != nil || layer.Get(2, 0) == nil {
+		t.Error("expected unknown tile to be left out of layer")
+	}
+	speed, ok := layer.Property("speed")
+	if !ok || speed.AsInt() != 0 {
+		t.Error("expected bad int property to be zero, got", speed)
+	}
+	if in.ImageLayers()[0].TransparentColour != nil {
+		t.Error("expected no transparent colour")
+	}
+}
+
+func TestExternalDecodeError(t *testing.T) {
+	bad := strings.Replace(externalTileset, `tileid="1"`, `tileid="4"`, 1)
+	fsys := fstest.MapFS{
+		"tilesets/shared.tsx": &fstest.MapFile{Data: []byte(bad)},
+	}
+	codec := NewCodecV1(WithResolver(NewFSResolver(fsys, "maps/level.tmx")))
+
+	_, err := codec.Unmarshal([]byte(externalTilesetMap))
+	var derr *DecodeError
+	if !errors.As(err, &derr) {
+		t.Fatal("expected a DecodeError, got", err)
+	}
+	if !strings.HasSuffix(derr.File, "shared.tsx") || derr.Path != "map/tileset[../tilesets/shared.tsx]/tile[0]" || derr.Line != 6 {
+		t.Error("unexpected error location", derr.File, derr.Path, derr.Line)
+	}
+
+	fsys["tilesets/shared.tsx"] = &fstest.MapFile{Data: []byte(externalTileset[:200])}
+	_, err = codec.Unmarshal([]byte(externalTilesetMap))
+	if !errors.As(err, &derr) || !strings.HasSuffix(derr.File, "shared.tsx") || derr.Line == 0 {
+		t.Error("expected syntax error in external tileset, got", err)
+	}
+}
+
+func TestDecodeErrorStartTagPosition(t *testing.T) {
+	in := `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.2" orientation="orthogonal" renderorder="right-down" width="1" height="1" tilewidth="16" tileheight="16">
+ <objectgroup name="things">
+  <object id="1"
+          x="4"
+          y="8">
+   <properties>
+      <property name="speed"
+                type="int"
+                value="fast"/>
+   </properties>
+  </object>
+ </objectgroup>
+</map>
+`
+	_, err := NewCodecV1().Unmarshal([]byte(in))
+
+	var derr *DecodeError
+	if !errors.As(err, &derr) {
+		t.Fatal("expected a DecodeError, got", err)
+	}
+	// where the property's start tag begins, not where it ends
+	if derr.Path != "map/objectgroup[things]/object[1]/property[speed]" || derr.Line != 8 || derr.Column != 7 {
+		t.Error("unexpected error location", derr.Path, derr.Line, derr.Column)
+	}
+}
+
+func TestTextColourProblem(t *testing.T) {
+	in := `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.2" orientation="orthogonal" renderorder="right-down" width="1" height="1" tilewidth="16" tileheight="16">
+ <objectgroup name="things">
+  <object id="1" x="4" y="8">
+   <text color="#nope">hello</text>
+  </object>
+ </objectgroup>
+</map>
+`
+	_, err := NewCodecV1().Unmarshal([]byte(in))
+	var derr *DecodeError
+	if !errors.As(err, &derr) || derr.Path != "map/objectgroup[things]/object[1]" || !strings.Contains(err.Error(), "colour #nope") {
+		t.Error("expected a DecodeError for the text colour, got", err)
+	}
+
+	m, warnings, err := NewCodecV1(Lenient()).UnmarshalWithWarnings([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Error(), "colour #nope") {
+		t.Error("expected a warning for the text colour, got", warnings)
+	}
+	txt := m.ObjectLayers()[0].Objects()[0].Text()
+	if txt == nil || txt.Value != "hello" || txt.Colour == nil {
+		t.Error("expected text with the default colour, got", txt)
+	}
+}
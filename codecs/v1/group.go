@@ -1,6 +1,20 @@
 package v1
 
-import "encoding/xml"
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/voidshard/libtmx/common"
+)
+
+// Something layers can be added to when inflating, that is a common.Map or common.GroupLayer
+//
+type layerContainer interface {
+	NewTileLayer(name string) *common.TileLayer
+	NewImageLayer(name, imageSource string) *common.ImageLayer
+	NewObjectLayer(name string) *common.ObjectLayer
+	NewGroupLayer(name string) *common.GroupLayer
+}
 
 // Represents an arbitrary named group of things
 //
@@ -8,18 +22,163 @@ type group struct {
 	XMLName xml.Name `xml:"group"`
 
 	// attrs
-	Name    string  `xml:"name"`
+	Name    string  `xml:"name,attr"`
 	X       int     `xml:"x,attr,optional,omitempty"`
 	Y       int     `xml:"y,attr,optional,omitempty"`
 	Visible int     `xml:"visible,attr"`
 	OffsetX int     `xml:"offsetx,attr"`
 	OffsetY int     `xml:"offsety,attr"`
-	Opacity float32 `xml:"opacity,attr"`
+	Opacity float64 `xml:"opacity,attr"`
+	Tint    string  `xml:"tintcolor,attr,optional,omitempty"`
 
 	// subsections
-	Properties   properties    `xml:"properties,optional,omitempty"`
-	TileLayers   []tileLayer   `xml:"layer,optional,omitempty"`
-	ImageLayers  []imageLayer  `xml:"imagelayer,optional,omitempty"`
-	ObjectGroups []objectGroup `xml:"objectgroup,optional,omitempty"`
-	Groups       []group       `xml:"group,optional,omitempty"`
+	Properties properties `xml:"properties,optional,omitempty"`
+
+	// tile, image & object layers and groups, in document (that is, draw) order
+	Layers []layerElement `xml:",any"`
+
+	pos position
+}
+
+// Any one of the layer elements (layer, imagelayer, objectgroup or group).
+// Other elements we don't know about are skipped when reading.
+//
+type layerElement struct {
+	TileLayer   *tileLayer
+	ImageLayer  *imageLayer
+	ObjectGroup *objectGroup
+	Group       *group
+}
+
+func (l *layerElement) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	switch start.Name.Local {
+	case "layer":
+		l.TileLayer = &tileLayer{}
+		return d.DecodeElement(l.TileLayer, &start)
+	case "imagelayer":
+		l.ImageLayer = &imageLayer{}
+		return d.DecodeElement(l.ImageLayer, &start)
+	case "objectgroup":
+		l.ObjectGroup = &objectGroup{}
+		return d.DecodeElement(l.ObjectGroup, &start)
+	case "group":
+		l.Group = &group{}
+		return d.DecodeElement(l.Group, &start)
+	}
+	return d.Skip()
+}
+
+func (l layerElement) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if l.TileLayer != nil {
+		return e.Encode(l.TileLayer)
+	} else if l.ImageLayer != nil {
+		return e.Encode(l.ImageLayer)
+	} else if l.ObjectGroup != nil {
+		return e.Encode(l.ObjectGroup)
+	} else if l.Group != nil {
+		return e.Encode(l.Group)
+	}
+	return nil
+}
+
+// Inflate whichever layer this is & add it to the given map or group
+//
+func (l *layerElement) inflate(d *decoder, parent layerContainer) error {
+	if l.TileLayer != nil {
+		return l.TileLayer.inflate(d, parent)
+	} else if l.ImageLayer != nil {
+		return l.ImageLayer.inflate(d, parent)
+	} else if l.ObjectGroup != nil {
+		return l.ObjectGroup.inflate(d, parent)
+	} else if l.Group != nil {
+		return l.Group.inflate(d, parent)
+	}
+	return nil
+}
+
+// Deflate the given common.Layer to be a layerElement for writing to XML
+//
+func deflateLayer(in common.Layer, encoding, compression string) (layerElement, error) {
+	switch layer := in.(type) {
+	case *common.TileLayer:
+		tlayer, err := deflateTileLayer(layer, encoding, compression)
+		return layerElement{TileLayer: &tlayer}, err
+	case *common.ImageLayer:
+		ilayer := deflateImageLayer(layer)
+		return layerElement{ImageLayer: &ilayer}, nil
+	case *common.ObjectLayer:
+		olayer := deflateObjectGroup(layer)
+		return layerElement{ObjectGroup: &olayer}, nil
+	case *common.GroupLayer:
+		glayer, err := deflateGroup(layer, encoding, compression)
+		return layerElement{Group: &glayer}, err
+	}
+	return layerElement{}, errors.New(fmt.Sprintf("Unknown layer type %T", in))
+}
+
+// Tiled omits visible & opacity when they're set to their defaults, so we set them before decoding
+//
+func (g *group) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain group
+	tmp := plain{Visible: 1, Opacity: 1, pos: positionOf(d)}
+	if err := d.DecodeElement(&tmp, &start); err != nil {
+		return err
+	}
+	*g = group(tmp)
+	return nil
+}
+
+// Inflate this group (and everything in it) to be a common.GroupLayer and add it to the
+// given map or group
+//
+func (g *group) inflate(d *decoder, parent layerContainer) (err error) {
+	defer d.enter(element("group", g.Name), g.pos)(&err)
+	layer := parent.NewGroupLayer(g.Name)
+
+	tint, err := d.colour(g.Tint, decodeTintColour)
+	if err != nil {
+		return err
+	}
+
+	layer.Opacity = g.Opacity
+	layer.Visible = g.Visible == 1
+	layer.OffsetX = g.OffsetX
+	layer.OffsetY = g.OffsetY
+	layer.TintColour = tint
+	props, err := g.Properties.inflate(d)
+	if err != nil {
+		return err
+	}
+	layer.UpdateProperties(props...)
+
+	for _, child := range g.Layers {
+		err := child.inflate(d, layer)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deflate the given common.GroupLayer (and everything in it) to be a group for writing to XML
+//
+func deflateGroup(in *common.GroupLayer, encoding, compression string) (group, error) {
+	grp := group{
+		Name: in.Name,
+		Visible: boolToInt(in.Visible),
+		OffsetX: in.OffsetX,
+		OffsetY: in.OffsetY,
+		Opacity: in.Opacity,
+		Tint: encodeHexColour(in.TintColour),
+		Properties: deflateProperties(in.Properties()),
+	}
+
+	for _, child := range in.Layers() {
+		layer, err := deflateLayer(child, encoding, compression)
+		if err != nil {
+			return grp, err
+		}
+		grp.Layers = append(grp.Layers, layer)
+	}
+	return grp, nil
 }
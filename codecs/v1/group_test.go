@@ -0,0 +1,171 @@
+package v1
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+const groupMap = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.4" orientation="orthogonal" renderorder="right-down" width="2" height="2" tilewidth="16" tileheight="16">
+ <group id="1" name="building" opacity="0.5" offsetx="10" tintcolor="#ff0000">
+  <properties>
+   <property name="floors" type="int" value="2"/>
+  </properties>
+  <group id="2" name="floor 1" offsety="4" tintcolor="#8080ff">
+   <layer id="3" name="walls" width="2" height="2" opacity="0.5">
+    <data encoding="csv">0,0,0,0</data>
+   </layer>
+   <objectgroup id="4" name="doors">
+    <object id="1" x="1" y="2"/>
+   </objectgroup>
+  </group>
+  <group id="5" name="floor 2" visible="0" opacity="0.7">
+   <imagelayer id="6" name="roof" offsetx="1">
+    <image source="roof.png" width="32" height="32"/>
+   </imagelayer>
+  </group>
+ </group>
+</map>
+`
+
+func TestGroupLayers(t *testing.T) {
+	codec := NewCodecV1()
+
+	in, err := codec.Unmarshal([]byte(groupMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := codec.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := codec.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, result := range []*common.Map{in, out} {
+		if len(result.GroupLayers()) != 1 || len(result.TileLayers()) != 0 {
+			t.Fatal("expected a single top level group")
+		}
+		building := result.GroupLayers()[0]
+		if prop, ok := building.Property("floors"); !ok || prop.AsInt() != 2 {
+			t.Error("expected group property floors=2")
+		}
+
+		groups := building.GroupLayers()
+		if len(groups) != 2 || groups[0].Name != "floor 1" || groups[1].Name != "floor 2" {
+			t.Fatal("expected two nested groups, got", groups)
+		}
+
+		walls := groups[0].TileLayers()[0]
+		if walls.Group() != groups[0] || groups[0].Group() != building {
+			t.Error("expected walls to be nested in floor 1 in building")
+		}
+		if walls.EffectiveOpacity() != 0.25 {
+			t.Error("expected inherited opacity 0.25, got", walls.EffectiveOpacity())
+		}
+		if x, y := walls.EffectiveOffset(); x != 10 || y != 4 {
+			t.Error("expected inherited offset 10,4, got", x, y)
+		}
+		if tint := walls.EffectiveTint(); tint == nil || tint.R != 128 || tint.G != 0 || tint.B != 0 || tint.A != 255 {
+			t.Error("expected inherited tint #800000, got", tint)
+		}
+		if !walls.EffectiveVisible() {
+			t.Error("expected walls to be visible")
+		}
+
+		doors := groups[0].ObjectLayers()[0]
+		if len(doors.Objects()) != 1 || doors.Objects()[0].X != 1 {
+			t.Error("expected nested object layer to keep its objects")
+		}
+
+		if groups[1].Opacity != 0.7 {
+			t.Error("expected opacity 0.7, got", groups[1].Opacity)
+		}
+
+		roof := groups[1].ImageLayers()[0]
+		if !roof.Visible || roof.EffectiveVisible() {
+			t.Error("expected roof to be hidden by its group")
+		}
+		if x, _ := roof.EffectiveOffset(); x != 11 {
+			t.Error("expected inherited offset x 11, got", x)
+		}
+	}
+}
+
+const orderedMap = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.4" orientation="orthogonal" renderorder="right-down" width="1" height="1" tilewidth="16" tileheight="16">
+ <editorsettings>
+  <export target="x.json" format="json"/>
+ </editorsettings>
+ <imagelayer id="1" name="background">
+  <image source="sky.png" width="16" height="16"/>
+ </imagelayer>
+ <layer id="2" name="ground" width="1" height="1">
+  <data encoding="csv">0</data>
+ </layer>
+ <objectgroup id="3" name="things"/>
+ <group id="4" name="upper">
+  <objectgroup id="5" name="nested things"/>
+  <imagelayer id="6" name="nested image"/>
+  <layer id="7" name="nested tiles" width="1" height="1">
+   <data encoding="csv">0</data>
+  </layer>
+ </group>
+ <layer id="8" name="roof" width="1" height="1">
+  <data encoding="csv">0</data>
+ </layer>
+</map>
+`
+
+func layerNames(in []common.Layer) []string {
+	names := []string{}
+	for _, layer := range in {
+		names = append(names, layer.LayerName())
+	}
+	return names
+}
+
+func TestLayerOrder(t *testing.T) {
+	codec := NewCodecV1()
+
+	in, err := codec.Unmarshal([]byte(orderedMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []string{"background", "ground", "things", "upper", "nested things", "nested image", "nested tiles", "roof"}
+	if !reflect.DeepEqual(layerNames(in.AllLayers()), expect) {
+		t.Fatal("expected", expect, "got", layerNames(in.AllLayers()))
+	}
+
+	// move the background above the ground & the roof into the group, then check it's written in that order
+	err = in.MoveLayer(in.Layers()[0], 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = in.GroupLayers()[0].InsertLayer(0, in.TileLayers()[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := codec.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := codec.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect = []string{"ground", "background", "things", "upper", "roof", "nested things", "nested image", "nested tiles"}
+	if !reflect.DeepEqual(layerNames(out.AllLayers()), expect) {
+		t.Error("expected", expect, "got", layerNames(out.AllLayers()))
+	}
+	if out.GroupLayers()[0].TileLayers()[0].Group() != out.GroupLayers()[0] {
+		t.Error("expected roof to be in the group")
+	}
+}
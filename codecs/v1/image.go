@@ -20,7 +20,7 @@ type imageData struct {
 	Height      int    `xml:"height,attr,optional,omitempty"`
 
 	// subsections
-	Data dataBlock `xml:"data,optional,omitempty"`
+	Data *dataBlock `xml:"data,optional,omitempty"`
 }
 
 // Get the color set to be transparent for this block
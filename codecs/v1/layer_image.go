@@ -11,37 +11,66 @@ type imageLayer struct {
 	XMLName xml.Name `xml:"imagelayer"`
 
 	// attrs
-	Name    string  `xml:"name"`
+	Name    string  `xml:"name,attr"`
 	X       int     `xml:"x,attr,optional,omitempty"`
 	Y       int     `xml:"y,attr,optional,omitempty"`
 	Visible int     `xml:"visible,attr"`
 	OffsetX int     `xml:"offsetx,attr"`
 	OffsetY int     `xml:"offsety,attr"`
 	Opacity float64 `xml:"opacity,attr"`
+	Tint    string  `xml:"tintcolor,attr,optional,omitempty"`
 
 	// subsections
 	Properties properties `xml:"properties,optional,omitempty"`
 	Image      imageData  `xml:"image,optional,omitempty"`
+
+	pos position
+}
+
+// Tiled omits visible & opacity when they're set to their defaults, so we set them before decoding
+//
+func (o *imageLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain imageLayer
+	tmp := plain{Visible: 1, Opacity: 1, pos: positionOf(d)}
+	if err := d.DecodeElement(&tmp, &start); err != nil {
+		return err
+	}
+	*o = imageLayer(tmp)
+	return nil
 }
 
-// Inflate this layer to be a common.ImageLayer and add it to the given map
+// Inflate this layer to be a common.ImageLayer and add it to the given map or group
 //
-func (o *imageLayer) inflate(parent *common.Map) {
+func (o *imageLayer) inflate(d *decoder, parent layerContainer) (err error) {
+	defer d.enter(element("imagelayer", o.Name), o.pos)(&err)
 	layer := parent.NewImageLayer(o.Name, o.Image.Source)
 
+	tint, err := d.colour(o.Tint, decodeTintColour)
+	if err != nil {
+		return err
+	}
+	trans, err := d.colour(o.Image.TransColour, decodeHexColour)
+	if err != nil {
+		return err
+	}
+
 	layer.OffsetX = o.OffsetX
 	layer.OffsetY = o.OffsetY
 	layer.Opacity = o.Opacity
+	layer.Visible = o.Visible == 1
+	layer.TintColour = tint
 	layer.Height = o.Image.Height
 	layer.Width = o.Image.Width
 	layer.ImageSource = o.Image.Source
 	layer.ImageFormat = o.Image.Format
+	layer.TransparentColour = trans
 
-	col, err := o.Image.TransparentColour()
-	if err == nil {
-		layer.TransparentColour = col
+	props, err := o.Properties.inflate(d)
+	if err != nil {
+		return err
 	}
-	layer.UpdateProperties(o.Properties.inflate()...)
+	layer.UpdateProperties(props...)
+	return nil
 }
 
 // Deflate the given common.ImageLayer to be an imageLayer for writing to XML
@@ -53,6 +82,7 @@ func deflateImageLayer(in *common.ImageLayer) imageLayer {
 		OffsetX: in.OffsetX,
 		OffsetY: in.OffsetY,
 		Opacity: in.Opacity,
+		Tint: encodeHexColour(in.TintColour),
 		Properties: deflateProperties(in.Properties()),
 		Image: imageData{
 			Source: in.ImageSource,
@@ -2,6 +2,7 @@ package v1
 
 import (
 	"encoding/xml"
+	"strings"
 	"github.com/voidshard/libtmx/common"
 )
 
@@ -21,44 +22,91 @@ type tileLayer struct {
 	// attrs optional
 	//X       int     `xml:"x,attr,optional,omitempty"` // defaults to 0, cannot be changed
 	//Y       int     `xml:"y,attr,optional,omitempty"` // defaults to 0, cannot be changed
-	Visible int     `xml:"visible,attr,optional,omitempty"`
+	Visible int     `xml:"visible,attr"`
 	OffsetX int     `xml:"offsetx,attr,optional,omitempty"`
 	OffsetY int     `xml:"offsety,attr,optional,omitempty"`
 	Width int `xml:"width,attr,optional,omitempty"`
 	Height int `xml:"height,attr,optional,omitempty"`
-	Opacity float64 `xml:"opacity,attr,optional,omitempty"`
+	Opacity float64 `xml:"opacity,attr"`
+	Tint    string  `xml:"tintcolor,attr,optional,omitempty"`
 
 	// subsections
 	Data       dataBlock  `xml:"data,optional,omitempty"`
 	Properties properties `xml:"properties,optional,omitempty"`
+
+	pos position
+}
+
+// Tiled omits visible & opacity when they're set to their defaults, so we set them before decoding
+//
+func (t *tileLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain tileLayer
+	tmp := plain{Visible: 1, Opacity: 1, pos: positionOf(d)}
+	if err := d.DecodeElement(&tmp, &start); err != nil {
+		return err
+	}
+	*t = tileLayer(tmp)
+	return nil
 }
 
-// Deflate the given common.TileLayer to be a codec v1 tileLayer for writing to xml
+// Deflate the given common.TileLayer to be a codec v1 tileLayer for writing to xml.
+// Tile data is written with the given encoding & compression, if no encoding is given
+// the layer's own settings are used.
 //
-func deflateTileLayer(in *common.TileLayer) tileLayer {
+func deflateTileLayer(in *common.TileLayer, encoding, compression string) (tileLayer, error) {
+	if encoding == "" {
+		encoding = in.Encoding
+		compression = in.Compression
+	}
+	if encoding == "" {
+		encoding = common.DataEncodingCsv
+	}
+
+	data := dataBlock{Encoding: encoding, Compression: compression}
+	if in.Infinite() {
+		for _, rect := range in.Chunks() {
+			value, err := encodeTileData(in.TileIdsIn(rect), encoding, compression)
+			if err != nil {
+				return tileLayer{}, err
+			}
+			data.Chunks = append(data.Chunks, chunk{
+				X: rect.Min.X,
+				Y: rect.Min.Y,
+				Width: rect.Dx(),
+				Height: rect.Dy(),
+				Value: value,
+			})
+		}
+	} else {
+		value, err := encodeTileData(in.TileIds(), encoding, compression)
+		if err != nil {
+			return tileLayer{}, err
+		}
+		data.Value = value
+	}
+
 	return tileLayer{
 		Name: in.Name,
 		Visible: boolToInt(in.Visible),
 		OffsetX: in.OffsetX,
 		OffsetY: in.OffsetY,
 		Opacity: in.Opacity,
+		Tint: encodeHexColour(in.TintColour),
 		Width: in.Width(),
 		Height: in.Height(),
-		Data: dataBlock{
-			Value: encodeTileDataCsv(in.TileIds()),
-			Encoding: common.DataEncodingCsv,
-		},
+		Data: data,
 		Properties: deflateProperties(in.Properties()),
-	}
+	}, nil
 }
 
 // Inflate this tileLayer from it's xml format to a common.TileLayer and add
-// it to the given map.
+// it to the given map or group.
 //
-func (t *tileLayer) inflate(parent *common.Map) error {
+func (t *tileLayer) inflate(d *decoder, parent layerContainer) (err error) {
+	defer d.enter(element("layer", t.Name), t.pos)(&err)
 	layer := parent.NewTileLayer(t.Name)
 
-	tileIds, err := t.TileIds()
+	layer.TintColour, err = d.colour(t.Tint, decodeTintColour)
 	if err != nil {
 		return err
 	}
@@ -66,41 +114,70 @@ func (t *tileLayer) inflate(parent *common.Map) error {
 	layer.Opacity = t.Opacity
 	layer.Visible = t.Visible == 1
 	layer.OffsetX = t.OffsetX
-	layer.OffsetX = t.OffsetY
-	layer.UpdateProperties(t.Properties.inflate()...)
+	layer.OffsetY = t.OffsetY
+	layer.Encoding = t.Data.Encoding
+	layer.Compression = t.Data.Compression
+	if layer.Encoding == "" {
+		layer.Encoding = common.DataEncodingCsv
+	}
+	props, err := t.Properties.inflate(d)
+	if err != nil {
+		return err
+	}
+	layer.UpdateProperties(props...)
+
+	if len(t.Data.Chunks) == 0 {
+		if layer.Infinite() || strings.TrimSpace(t.Data.Value) == "" {
+			return nil // an empty layer, Tiled writes no chunks (or data) for these
+		}
+		tileIds, err := t.TileIds()
+		if err != nil {
+			return err
+		}
+		return putTileIds(d, layer, 0, 0, tileIds)
+	}
+
+	for _, chk := range t.Data.Chunks {
+		tileIds, err := decodeTileData(chk.Value, t.Data.Encoding, t.Data.Compression, chk.Width)
+		if err != nil {
+			return err
+		}
+		err = putTileIds(d, layer, chk.X, chk.Y, tileIds)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	for y, row := range tileIds {
-		for x, tileId := range row { // Nb: these are global tile ids
+// Place tiles by their global ids (rows of columns) into the layer, with the first at x,y.
+// Ids that are in no tileset are a problem, lenient decoders leave those cells empty.
+//
+func putTileIds(d *decoder, layer *common.TileLayer, x, y int, tileIds [][]int) error {
+	for dy, row := range tileIds {
+		for dx, rawId := range row { // Nb: these are global tile ids, with flip flags in the high bits
+			tileId, cell := common.DecodeGID(uint32(rawId))
 			if tileId == 0 {  // tile id of 0 means no tile is there
 				continue
 			}
 
-			tile, ok := globalTilemap[tileId]
+			tile, ok := d.tile(tileId)
 			if !ok {
+				err := d.problem("Tile at %d,%d has global id %d, which is in no tileset", x + dx, y + dy, tileId)
+				if err != nil {
+					return err
+				}
 				continue
 			}
-			layer.Put(x, y, tile.inflatedTile)
+			cell.Tile = tile
+			layer.PutCell(x + dx, y + dy, cell)
 		}
 	}
-
 	return nil
 }
 
 // Return the tile data as a slice of int slices representing a TileId at a given x,y.
 //
 func (t *tileLayer) TileIds() ([][]int, error) {
-	var tiledata [][]int
-	var err error
-
-	if t.Data.Encoding == common.DataEncodingBase64 {
-		tiledata, err = decodeTileDataBase64(t.Data.Value)
-	} else {
-		tiledata, err = decodeTileDataCsv(t.Data.Value)
-	}
-
-	if err != nil {
-		return nil, err
-	}
-
-	return tiledata, err
+	return decodeTileData(t.Data.Value, t.Data.Encoding, t.Data.Compression, t.Width)
 }
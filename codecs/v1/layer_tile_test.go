@@ -0,0 +1,229 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+const flippedMap = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.2" orientation="orthogonal" renderorder="right-down" width="3" height="2" tilewidth="32" tileheight="32">
+ <tileset firstgid="1" name="things" tilewidth="32" tileheight="32" tilecount="2">
+  <tile id="0">
+   <image width="32" height="32" source="a.png"/>
+  </tile>
+  <tile id="1">
+   <image width="32" height="32" source="b.png"/>
+  </tile>
+ </tileset>
+ <layer name="ground" width="3" height="2">
+  <data encoding="csv">
+1,2147483649,1073741826,
+536870913,3221225474,0
+</data>
+ </layer>
+</map>
+`
+
+func TestTileLayerFlipFlags(t *testing.T) {
+	codec := &CodecV1{}
+
+	in, err := codec.Unmarshal([]byte(flippedMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := codec.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := codec.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		X, Y   int
+		Source string
+		Expect common.Cell
+	}{
+		{0, 0, "a.png", common.Cell{}},
+		{1, 0, "a.png", common.Cell{FlipH: true}},
+		{2, 0, "b.png", common.Cell{FlipV: true}},
+		{0, 1, "a.png", common.Cell{FlipD: true}},
+		{1, 1, "b.png", common.Cell{FlipH: true, FlipV: true}},
+	}
+
+	for _, result := range []*common.Map{in, out} {
+		layer := result.TileLayers()[0]
+
+		for _, test := range cases {
+			cell := layer.GetCell(test.X, test.Y)
+			if cell.Tile == nil || cell.Tile.Source != test.Source {
+				t.Error("At", test.X, test.Y, "expected tile", test.Source, "got", cell.Tile)
+				continue
+			}
+
+			test.Expect.Tile = cell.Tile
+			if cell != test.Expect {
+				t.Error("At", test.X, test.Y, "expected", test.Expect, "got", cell)
+			}
+			if layer.Get(test.X, test.Y) != cell.Tile {
+				t.Error("At", test.X, test.Y, "expected Get to match GetCell")
+			}
+		}
+
+		if !layer.GetCell(2, 1).IsEmpty() {
+			t.Error("expected 2,1 to be empty")
+		}
+	}
+}
+
+const infiniteMap = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.2" orientation="orthogonal" renderorder="right-down" width="10" height="10" tilewidth="32" tileheight="32" infinite="1">
+ <tileset firstgid="1" name="things" tilewidth="32" tileheight="32" tilecount="2">
+  <tile id="0">
+   <image width="32" height="32" source="a.png"/>
+  </tile>
+  <tile id="1">
+   <image width="32" height="32" source="b.png"/>
+  </tile>
+ </tileset>
+ <layer name="ground" width="32" height="16">
+  <data encoding="csv">
+   <chunk x="-16" y="0" width="16" height="16">
+1,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,2
+</chunk>
+   <chunk x="0" y="0" width="16" height="16">
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,2147483650,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,
+0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0
+</chunk>
+  </data>
+ </layer>
+</map>
+`
+
+func TestInfiniteMapChunks(t *testing.T) {
+	cases := []struct {
+		X, Y   int
+		Source string
+		Expect common.Cell
+	}{
+		{-16, 0, "a.png", common.Cell{}},
+		{-1, 15, "b.png", common.Cell{}},
+		{2, 2, "b.png", common.Cell{FlipH: true}},
+	}
+
+	check := func(name string, m *common.Map) {
+		if !m.Infinite() {
+			t.Error(name, "expected map to be infinite")
+		}
+		layer := m.TileLayers()[0]
+		if len(layer.Chunks()) != 2 {
+			t.Error(name, "expected 2 chunks, got", layer.Chunks())
+		}
+		for _, test := range cases {
+			cell := layer.GetCell(test.X, test.Y)
+			if cell.Tile == nil || cell.Tile.Source != test.Source {
+				t.Error(name, "at", test.X, test.Y, "expected tile", test.Source, "got", cell.Tile)
+				continue
+			}
+			test.Expect.Tile = cell.Tile
+			if cell != test.Expect {
+				t.Error(name, "at", test.X, test.Y, "expected", test.Expect, "got", cell)
+			}
+		}
+		if !layer.GetCell(0, 0).IsEmpty() || !layer.GetCell(-100, -100).IsEmpty() {
+			t.Error(name, "expected empty cells to be empty")
+		}
+	}
+
+	in, err := (&CodecV1{}).Unmarshal([]byte(infiniteMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("csv", in)
+
+	for _, codec := range []*CodecV1{
+		NewCodecV1(),
+		NewCodecV1(DataEncoding(common.DataEncodingBase64, common.DataCompressionZlib)),
+	} {
+		data, err := codec.Marshal(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := codec.Unmarshal(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(codec.encoding+codec.compression, out)
+	}
+
+	// converting to a fixed size map moves everything so the top left tile is 0,0
+	in.SetInfinite(false)
+	layer := in.TileLayers()[0]
+	if layer.Infinite() || layer.Width() != 19 || layer.Height() != 16 {
+		t.Error("expected fixed 19x16 layer, got", layer.Bounds())
+	}
+	if tile := layer.Get(0, 0); tile == nil || tile.Source != "a.png" {
+		t.Error("expected a.png at 0,0 got", tile)
+	}
+	if cell := layer.GetCell(18, 2); cell.Tile == nil || !cell.FlipH {
+		t.Error("expected flipped tile at 18,2 got", cell)
+	}
+}
+
+func TestEmptyInfiniteLayer(t *testing.T) {
+	in := common.NewMap(common.Infinite(), common.TileWidth(16), common.TileHeight(16))
+	in.NewTileLayer("empty")
+
+	for _, codec := range []*CodecV1{
+		NewCodecV1(),
+		NewCodecV1(DataEncoding(common.DataEncodingBase64, "")),
+		NewCodecV1(DataEncoding(common.DataEncodingBase64, common.DataCompressionGzip)),
+		NewCodecV1(DataEncoding(common.DataEncodingBase64, common.DataCompressionZlib)),
+		NewCodecV1(DataEncoding(common.DataEncodingBase64, common.DataCompressionZstd)),
+	} {
+		name := codec.encoding + codec.compression
+		data, err := codec.Marshal(in)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		out, err := codec.Unmarshal(data)
+		if err != nil {
+			t.Error(name, err)
+			continue
+		}
+		if len(out.TileLayers()) != 1 || len(out.TileLayers()[0].Chunks()) != 0 {
+			t.Error(name, "expected a single empty layer")
+		}
+	}
+}
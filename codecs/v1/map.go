@@ -3,7 +3,7 @@ package v1
 import (
 	"encoding/xml"
 	"image/color"
-	"os"
+	"github.com/voidshard/libtmx/codecs/internal/encode"
 	"github.com/voidshard/libtmx/common"
 	"io/ioutil"
 )
@@ -27,35 +27,52 @@ type tileMap struct {
 	StaggerIndex    string `xml:"staggerindex,attr,optional,omitempty"`
 	BackgroundColor string `xml:"backgroundcolor,attr,optional,omitempty"`
 	NextObjectId    int    `xml:"nextobjectid,attr,optional,omitempty"`
+	Infinite        int    `xml:"infinite,attr,optional,omitempty"`
+
+	// subsections optional
+	Properties properties `xml:"properties,optional,omitempty"`
 
 	// subsections
-	Tilesets   []tileset   `xml:"tileset"`
-	TileLayers []tileLayer `xml:"layer"`
+	Tilesets []tileset `xml:"tileset"`
 
-	// subsections optional
-	Properties   properties    `xml:"properties,optional,omitempty"`
-	ObjectGroups []objectGroup `xml:"objectgroup,optional,omitempty"`
-	ImageLayers  []imageLayer  `xml:"imagelayer,optional,omitempty"`
-	Groups       []group       `xml:"group,optional,omitempty"`
+	// tile, image & object layers and groups, in document (that is, draw) order
+	Layers []layerElement `xml:",any"`
+
+	pos position
+}
+
+// Record where the map starts, for error reporting
+//
+func (m *tileMap) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain tileMap
+	tmp := plain{pos: positionOf(d)}
+	if err := d.DecodeElement(&tmp, &start); err != nil {
+		return err
+	}
+	*m = tileMap(tmp)
+	return nil
 }
 
 // Inflate xml encoding struct into our common.Map struct.
 //
-func (m *tileMap) inflate() (*common.Map, error) {
+func (m *tileMap) inflate(d *decoder) (out *common.Map, err error) {
+	defer d.enter("map", m.pos)(&err)
 	settings := []common.MapOption{}
 
-	c, err := m.Background()
+	bg, err := d.colour(m.BackgroundColor, decodeHexColour)
 	if err != nil {
 		return nil, err
 	}
-	settings = append(settings, common.Background(c))
-
-	if m.Orientation == common.MapOrientationIsometric {
-		settings = append(settings, common.OrientationIsometric())
-	} else if m.Orientation == common.MapOrientationStaggered {
-		settings = append(settings, common.OrientationStaggered(m.StaggerAxis, m.StaggerIndex))
-	} else {
-		settings = append(settings, common.OrientationOrthogonal())
+	settings = append(settings, common.Background(bg))
+
+	orientation, err := encode.Orientation(m.Orientation, m.HexSideLength, m.StaggerAxis, m.StaggerIndex)
+	if err != nil {
+		return nil, err
+	}
+	settings = append(settings, orientation)
+
+	if m.Infinite == 1 {
+		settings = append(settings, common.Infinite())
 	}
 
 	if m.RenderOrder == common.MapRenderOrderRightDown {
@@ -68,22 +85,36 @@ func (m *tileMap) inflate() (*common.Map, error) {
 		settings = append(settings, common.RenderOrderLeftUp())
 	}
 
-	out := common.NewMap(settings...)
+	out = common.NewMap(settings...)
 	out.Height = m.Height
 	out.Width = m.Width
 	out.TileWidth = m.TileWidth
 	out.TileHeight = m.TileHeight
+	out.HexSideLength = m.HexSideLength
+	out.Version = m.Version
+	out.TiledVersion = m.TiledVersion
+	if m.NextObjectId > 0 {
+		out.SetNextObjectID(m.NextObjectId)
+	}
 
-	out.UpdateProperties(m.Properties.inflate()...)
+	props, err := m.Properties.inflate(d)
+	if err != nil {
+		return nil, err
+	}
+	out.UpdateProperties(props...)
 
 	for _, tileset := range m.Tilesets {
-		tileset.inflate(out)
-	}
-	for _, layer := range m.TileLayers {
-		layer.inflate(out)
+		tset, err := tileset.inflate(d, ".")
+		if err != nil {
+			return nil, err
+		}
+		out.AddTileset(tset)
 	}
-	for _, layer := range m.ImageLayers {
-		layer.inflate(out)
+	for _, layer := range m.Layers {
+		err := layer.inflate(d, out)
+		if err != nil {
+			return nil, err
+		}
 	}
 	return out, nil
 }
@@ -2,7 +2,6 @@ package v1
 
 import (
 	"encoding/xml"
-	"image"
 	"image/color"
 	"github.com/voidshard/libtmx/common"
 )
@@ -14,24 +13,100 @@ type objectGroup struct {
 
 	// attrs
 	Name   string `xml:"name,attr"`
-	Colour string `xml:"color,attr"`
 
 	// attrs optional
+	Colour    string  `xml:"color,attr,optional,omitempty"`
 	X         int     `xml:"x,attr,optional,omitempty"`
 	Y         int     `xml:"y,attr,optional,omitempty"`
 	Width     int     `xml:"width,attr,optional,omitempty"`
 	Height    int     `xml:"height,attr,optional,omitempty"`
-	Visible   int     `xml:"visible,attr,optional,omitempty"`
+	Visible   int     `xml:"visible,attr"`
 	OffsetX   int     `xml:"offsetx,attr,optional,omitempty"`
 	OffsetY   int     `xml:"offsety,attr,optional,omitempty"`
-	Opacity   float64 `xml:"opacity,attr,optional,omitempty"`
+	Opacity   float64 `xml:"opacity,attr"`
 	DrawOrder string  `xml:"draworder,attr,optional,omitempty"`
+	Tint      string  `xml:"tintcolor,attr,optional,omitempty"`
 
 	// subsections
 	Objects []object `xml:"object"`
 
 	// subsections optional
 	Properties properties `xml:"properties,optional,omitempty"`
+
+	pos position
+}
+
+// Tiled omits visible & opacity when they're set to their defaults, so we set them before decoding
+//
+func (o *objectGroup) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain objectGroup
+	tmp := plain{Visible: 1, Opacity: 1, pos: positionOf(d)}
+	if err := d.DecodeElement(&tmp, &start); err != nil {
+		return err
+	}
+	*o = objectGroup(tmp)
+	return nil
+}
+
+// Inflate this objectGroup to be a common.ObjectLayer and add it to the given map or group
+//
+func (o *objectGroup) inflate(d *decoder, parent layerContainer) (err error) {
+	defer d.enter(element("objectgroup", o.Name), o.pos)(&err)
+	layer := parent.NewObjectLayer(o.Name)
+
+	col, err := d.colour(o.Colour, decodeHexColour)
+	if err != nil {
+		return err
+	}
+	layer.TintColour, err = d.colour(o.Tint, decodeTintColour)
+	if err != nil {
+		return err
+	}
+
+	layer.Colour = col
+	layer.Opacity = o.Opacity
+	layer.Visible = o.Visible == 1
+	layer.OffsetX = o.OffsetX
+	layer.OffsetY = o.OffsetY
+	layer.DrawOrder = o.DrawOrder
+	if layer.DrawOrder == "" {
+		layer.DrawOrder = common.DefaultDrawOrder
+	}
+	props, err := o.Properties.inflate(d)
+	if err != nil {
+		return err
+	}
+	layer.UpdateProperties(props...)
+
+	for _, obj := range o.Objects {
+		inflated, err := obj.inflate(d)
+		if err != nil {
+			return err
+		}
+		layer.AddObjects(inflated)
+	}
+	return nil
+}
+
+// Deflate the given common.ObjectLayer to be an objectGroup for writing to XML
+//
+func deflateObjectGroup(in *common.ObjectLayer) objectGroup {
+	grp := objectGroup{
+		Name: in.Name,
+		Colour: encodeHexColour(in.Colour),
+		Visible: boolToInt(in.Visible),
+		OffsetX: in.OffsetX,
+		OffsetY: in.OffsetY,
+		Opacity: in.Opacity,
+		DrawOrder: in.DrawOrder,
+		Tint: encodeHexColour(in.TintColour),
+		Properties: deflateProperties(in.Properties()),
+		Objects: []object{},
+	}
+	for _, obj := range in.Objects() {
+		grp.Objects = append(grp.Objects, deflateObject(obj))
+	}
+	return grp
 }
 
 // Get the colour for this group
@@ -56,30 +131,223 @@ type object struct {
 	Name     string `xml:"name,attr,optional,omitempty"`
 	Type     string `xml:"type,attr,optional,omitempty"`
 	Id       int    `xml:"id,attr,optional,omitempty"`
-	Gid      int    `xml:"gid,attr,optional,omitempty"`
-	X        int    `xml:"x,attr,optional,omitempty"`
-	Y        int    `xml:"y,attr,optional,omitempty"`
-	Width    int    `xml:"width,attr,optional,omitempty"`
-	Height   int    `xml:"height,attr,optional,omitempty"`
-	Visible  int    `xml:"visible,attr,optional,omitempty"`
-	Rotation int    `xml:"rotation,attr,optional,omitempty"`
+	Gid      uint32 `xml:"gid,attr,optional,omitempty"`
+	X        float64 `xml:"x,attr,optional,omitempty"`
+	Y        float64 `xml:"y,attr,optional,omitempty"`
+	Width    float64 `xml:"width,attr,optional,omitempty"`
+	Height   float64 `xml:"height,attr,optional,omitempty"`
+	Visible  *int    `xml:"visible,attr,omitempty"` // nil unless set (visible, or from the template)
+	Rotation float64 `xml:"rotation,attr,optional,omitempty"`
+	Template string  `xml:"template,attr,optional,omitempty"`
 
 	// subsections
+	// Nb. at most one of these is set, they're pointers so that unset shapes aren't written out
 	Properties properties `xml:"properties,optional,omitempty"`
-	Ellipse    ellipse    `xml:"ellipse,optional,omitempty"`
-	Polygon    polygon    `xml:"polygon,optional,omitempty"`
-	Polyline   polyline   `xml:"polyline,optional,omitempty"`
-	Text       text       `xml:"text,optional,omitempty"`
+	Ellipse    *ellipse   `xml:"ellipse,optional,omitempty"`
+	Point      *point     `xml:"point,optional,omitempty"`
+	Polygon    *polygon   `xml:"polygon,optional,omitempty"`
+	Polyline   *polyline  `xml:"polyline,optional,omitempty"`
+	Text       *text      `xml:"text,optional,omitempty"`
+
+	attrs map[string]bool // attributes set when read in
+	pos   position
+}
+
+// Objects based on a template take whatever they don't set from the template, so we
+// note which attributes are set
+//
+func (o *object) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain object
+	tmp := plain{pos: positionOf(d)}
+	if err := d.DecodeElement(&tmp, &start); err != nil {
+		return err
+	}
+	*o = object(tmp)
+
+	o.attrs = map[string]bool{}
+	for _, attr := range start.Attr {
+		o.attrs[attr.Name.Local] = true
+	}
+	return nil
+}
+
+// Objects based on a template write out every value they override, including those that are
+// zero (& so would otherwise be omitted, leaving the template's value in place)
+//
+func (o object) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type plain object
+	if o.Template != "" {
+		zero := map[string]bool{"name": o.Name == "", "type": o.Type == "", "width": o.Width == 0, "height": o.Height == 0, "rotation": o.Rotation == 0}
+		for _, attr := range []string{"name", "type", "width", "height", "rotation"} {
+			if !o.attrs[attr] || !zero[attr] {
+				continue
+			}
+			value := "0"
+			if attr == "name" || attr == "type" {
+				value = ""
+			}
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: attr}, Value: value})
+		}
+	}
+	return e.EncodeElement(plain(o), start)
 }
 
+// Whether the given attribute was set (always true for objects without a template)
+//
+func (o *object) isSet(attr string) bool {
+	return o.Template == "" || o.attrs[attr]
+}
+
+// Inflate this object into a common.Object. Objects based on a template start with the
+// template's values, then take those they set themselves.
+//
+func (o *object) inflate(d *decoder) (out *common.Object, err error) {
+	defer d.enter(element("object", o.Id), o.pos)(&err)
+	obj := common.NewObject("")
+	if o.Template != "" {
+		tmpl, err := d.template(o.Template)
+		if err != nil {
+			return nil, err
+		}
+		obj = common.NewObjectFromTemplate(tmpl)
+	}
+
+	obj.Id = o.Id
+	obj.X = o.X
+	obj.Y = o.Y
+	if o.isSet("name") {
+		obj.Name = o.Name
+	}
+	if o.isSet("type") {
+		obj.Type = o.Type
+	}
+	if o.isSet("width") {
+		obj.Width = o.Width
+	}
+	if o.isSet("height") {
+		obj.Height = o.Height
+	}
+	if o.isSet("rotation") {
+		obj.Rotation = o.Rotation
+	}
+	if o.Visible != nil {
+		obj.Visible = *o.Visible == 1
+	}
+	props, err := o.Properties.inflate(d)
+	if err != nil {
+		return nil, err
+	}
+	obj.UpdateProperties(props...)
+
+	if o.Gid > 0 {
+		tileId, cell := common.DecodeGID(o.Gid)
+		tile, ok := d.tile(tileId)
+		if ok {
+			cell.Tile = tile
+			obj.SetTileCell(cell)
+		} else {
+			err := d.problem("Object has global tile id %d, which is in no tileset", tileId)
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else if o.Ellipse != nil {
+		obj.SetEllipse()
+	} else if o.Point != nil {
+		obj.SetPoint()
+	} else if o.Polygon != nil {
+		points, err := o.Polygon.Points()
+		if err != nil {
+			return nil, err
+		}
+		obj.SetPolygon(points...)
+	} else if o.Polyline != nil {
+		points, err := o.Polyline.Points()
+		if err != nil {
+			return nil, err
+		}
+		obj.SetPolyline(points...)
+	} else if o.Text != nil {
+		txt, err := o.Text.inflate(d)
+		if err != nil {
+			return nil, err
+		}
+		obj.SetText(txt)
+	}
+
+	return obj, nil
+}
+
+// Deflate the given common.Object into an object for writing to XML. Objects based on a
+// template keep only the values that differ from the template.
+//
+func deflateObject(in *common.Object) object {
+	over := in.TemplateOverrides()
+	obj := object{
+		Id: in.Id,
+		X: in.X,
+		Y: in.Y,
+		Properties: deflateProperties(over.Properties),
+	}
+	if in.Template() != nil {
+		obj.Template = in.Template().Source
+		obj.attrs = map[string]bool{"name": over.Name, "type": over.Type, "width": over.Width, "height": over.Height, "rotation": over.Rotation}
+	}
+
+	if over.Name {
+		obj.Name = in.Name
+	}
+	if over.Type {
+		obj.Type = in.Type
+	}
+	if over.Width {
+		obj.Width = in.Width
+	}
+	if over.Height {
+		obj.Height = in.Height
+	}
+	if over.Rotation {
+		obj.Rotation = in.Rotation
+	}
+	if over.Visible {
+		visible := boolToInt(in.Visible)
+		obj.Visible = &visible
+	}
+	if over.Shape {
+		deflateShape(in, &obj)
+	}
+	return obj
+}
+
+// Set the shape element (or gid) of the given object for the kind of common.Object
+//
+func deflateShape(in *common.Object, obj *object) {
+	switch in.Kind() {
+	case common.ObjectTypeEllipse:
+		obj.Ellipse = &ellipse{}
+	case common.ObjectTypePoint:
+		obj.Point = &point{}
+	case common.ObjectTypePolygon:
+		obj.Polygon = &polygon{}
+		obj.Polygon.SetPoints(in.Points())
+	case common.ObjectTypePolyline:
+		obj.Polyline = &polyline{}
+		obj.Polyline.SetPoints(in.Points())
+	case common.ObjectTypeText:
+		obj.Text = deflateText(in.Text())
+	case common.ObjectTypeTile:
+		obj.Gid = in.TileCell().GlobalID()
+	}
+}
+
+// Marker element, the object is an ellipse fitting in the object bounds
 type ellipse struct {
 	XMLName xml.Name `xml:"ellipse"`
+}
 
-	// attrs
-	X      int `xml:"x,attr"`
-	Y      int `xml:"y,attr"`
-	Width  int `xml:"width,attr"`
-	Height int `xml:"height,attr"`
+// Marker element, the object is a single point
+type point struct {
+	XMLName xml.Name `xml:"point"`
 }
 
 type polygon struct {
@@ -89,11 +357,11 @@ type polygon struct {
 	RawPoints string `xml:"points,attr"`
 }
 
-func (p *polygon) Points() ([]image.Point, error) {
+func (p *polygon) Points() ([]common.Point, error) {
 	return decodePoints(p.RawPoints)
 }
 
-func (p *polygon) SetPoints(in []image.Point) {
+func (p *polygon) SetPoints(in []common.Point) {
 	p.RawPoints = encodePoints(in)
 }
 
@@ -104,11 +372,11 @@ type polyline struct {
 	RawPoints string `xml:"points,attr"`
 }
 
-func (p *polyline) Points() ([]image.Point, error) {
+func (p *polyline) Points() ([]common.Point, error) {
 	return decodePoints(p.RawPoints)
 }
 
-func (p *polyline) SetPoints(in []image.Point) {
+func (p *polyline) SetPoints(in []common.Point) {
 	p.RawPoints = encodePoints(in)
 }
 
@@ -126,7 +394,7 @@ type text struct {
 	Bold       int    `xml:"bold,attr,optional,omitempty"`
 	Italic     int    `xml:"italic,attr,optional,omitempty"`
 	Underline  int    `xml:"underline,attr,optional,omitempty"`
-	Kerning    int    `xml:"kerning,attr,optional,omitempty"`
+	Kerning    int    `xml:"kerning,attr"`
 	Strikeout  int    `xml:"strikeout,attr,optional,omitempty"`
 	Wrap       int    `xml:"wrap,attr,optional,omitempty"`
 
@@ -134,9 +402,21 @@ type text struct {
 	Value string `xml:",chardata"`
 }
 
+// Kerning is on unless stated otherwise
+//
+func (t *text) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain text
+	tmp := plain{Kerning: 1}
+	if err := d.DecodeElement(&tmp, &start); err != nil {
+		return err
+	}
+	*t = text(tmp)
+	return nil
+}
+
 // Inflate the given text object, setting it's internal values
 //
-func (t *text) inflate() {
+func (t *text) inflate(d *decoder) (*common.Text, error) {
 	if t.FontFamily == "" {
 		t.FontFamily = common.DefaultFontFamily
 	}
@@ -149,12 +429,61 @@ func (t *text) inflate() {
 	if t.AlignV == "" {
 		t.AlignV = common.DefaultVAlign
 	}
+
+	txt := common.NewText(t.Value)
+	txt.FontFamily = t.FontFamily
+	txt.PixelSize = t.PixelSize
+	txt.HAlign = t.AlignH
+	txt.VAlign = t.AlignV
+	txt.Bold = t.Bold == 1
+	txt.Italic = t.Italic == 1
+	txt.Underline = t.Underline == 1
+	txt.Strikeout = t.Strikeout == 1
+	txt.Kerning = t.Kerning == 1
+	txt.Wrap = t.Wrap == 1
+
+	if t.Colour != "" {
+		col, err := d.colour(t.Colour, decodeTintColour)
+		if err != nil {
+			return nil, err
+		}
+		if col != nil {
+			txt.Colour = col
+		}
+	}
+	return txt, nil
+}
+
+// Deflate the given common.Text into a text block for writing to XML
+//
+func deflateText(in *common.Text) *text {
+	if in == nil {
+		in = common.NewText("")
+	}
+	return &text{
+		FontFamily: in.FontFamily,
+		PixelSize: in.PixelSize,
+		Colour: encodeHexColour(in.Colour),
+		AlignH: in.HAlign,
+		AlignV: in.VAlign,
+		Bold: boolToInt(in.Bold),
+		Italic: boolToInt(in.Italic),
+		Underline: boolToInt(in.Underline),
+		Kerning: boolToInt(in.Kerning),
+		Strikeout: boolToInt(in.Strikeout),
+		Wrap: boolToInt(in.Wrap),
+		Value: in.Value,
+	}
 }
 
+// Get the colour of the text, a colour without alpha is fully opaque
+//
 func (t *text) TextColour() (*color.RGBA, error) {
-	return decodeHexColour(t.Colour)
+	return decodeTintColour(t.Colour)
 }
 
+// Set the colour of the text
+//
 func (t *text) SetTextColour(rgba *color.RGBA) {
 	t.Colour = encodeHexColour(rgba)
 }
@@ -0,0 +1,103 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+const objectMap = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.2" tiledversion="1.2.1" orientation="orthogonal" renderorder="right-down" width="4" height="4" tilewidth="32" tileheight="32" nextobjectid="8">
+ <objectgroup id="2" name="spawns" color="#ff0000" opacity="0.5" offsetx="3">
+  <properties>
+   <property name="team" value="red"/>
+  </properties>
+  <object id="1" name="zone" type="trigger" x="10" y="20" width="30" height="40"/>
+  <object id="2" name="spawn" x="5" y="6">
+   <point/>
+  </object>
+  <object id="3" x="1" y="2" width="8" height="8" visible="0">
+   <ellipse/>
+  </object>
+  <object id="4" x="0" y="0">
+   <polygon points="0,0 10,0 10,10"/>
+  </object>
+  <object id="5" x="0" y="0" rotation="90">
+   <polyline points="0,0 -5,5"/>
+  </object>
+  <object id="6" x="0" y="0" width="64" height="16">
+   <text wrap="1" kerning="0" halign="center" color="#00ff00">Hello</text>
+  </object>
+ </objectgroup>
+</map>
+`
+
+func TestObjectLayerRoundTrip(t *testing.T) {
+	codec := &CodecV1{}
+
+	in, err := codec.Unmarshal([]byte(objectMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := codec.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := codec.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, result := range []*common.Map{in, out} {
+		if len(result.ObjectLayers()) != 1 {
+			t.Fatal("expected 1 object layer, got", len(result.ObjectLayers()))
+		}
+		layer := result.ObjectLayers()[0]
+		if layer.Name != "spawns" || layer.Opacity != 0.5 || !layer.Visible || layer.OffsetX != 3 {
+			t.Error("unexpected layer attributes", layer)
+		}
+		if prop, ok := layer.Property("team"); !ok || prop.AsString() != "red" {
+			t.Error("expected layer property team=red")
+		}
+		if result.NextObjectID() != 8 {
+			t.Error("expected next object id 8, got", result.NextObjectID())
+		}
+
+		objs := layer.Objects()
+		if len(objs) != 6 {
+			t.Fatal("expected 6 objects, got", len(objs))
+		}
+
+		kinds := []string{
+			common.ObjectTypeRectangle,
+			common.ObjectTypePoint,
+			common.ObjectTypeEllipse,
+			common.ObjectTypePolygon,
+			common.ObjectTypePolyline,
+			common.ObjectTypeText,
+		}
+		for i, kind := range kinds {
+			if objs[i].Kind() != kind {
+				t.Error("object", objs[i].Id, "expected kind", kind, "got", objs[i].Kind())
+			}
+		}
+
+		if objs[0].Type != "trigger" || objs[0].X != 10 || objs[0].Height != 40 {
+			t.Error("unexpected rectangle", objs[0])
+		}
+		if objs[2].Visible {
+			t.Error("expected ellipse to be hidden")
+		}
+		if len(objs[3].Points()) != 3 || objs[3].Points()[2] != common.Pt(10, 10) {
+			t.Error("unexpected polygon points", objs[3].Points())
+		}
+		if objs[4].Rotation != 90 || objs[4].Points()[1] != common.Pt(-5, 5) {
+			t.Error("unexpected polyline", objs[4])
+		}
+
+		txt := objs[5].Text()
+		if txt.Value != "Hello" || !txt.Wrap || txt.Kerning || txt.HAlign != common.TextHAlignCentre || txt.Colour.G != 255 {
+			t.Error("unexpected text", txt)
+		}
+	}
+}
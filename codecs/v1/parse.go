@@ -5,17 +5,169 @@ import (
 	"encoding/xml"
 )
 
-type CodecV1 struct {}
+type CodecV1 struct {
+	// if set, all tile layer data is written with this encoding & compression
+	encoding    string
+	compression string
 
-// Unmarshal a common.Map from the given data (that is, []byte read from a tmx .xml file)
+	// loads external files referenced by maps
+	resolver Resolver
+
+	// if set, tilesets with a Source are written as references to their external file
+	externalTilesets bool
+
+	// custom classes, used to fill in default members of class properties
+	propertyTypes *common.PropertyTypes
+
+	// if set, problems with the data that can be worked around are warnings rather than errors
+	lenient bool
+}
+
+// Option alters how a CodecV1 reads or writes maps
+type Option func(*CodecV1)
+
+// Write all tile layer data with the given encoding & compression (one of the
+// common.DataEncoding* & common.DataCompression* constants, compression may be "")
+// rather than the encoding set on each layer.
+func DataEncoding(encoding, compression string) Option {
+	return func(c *CodecV1) {
+		c.encoding = encoding
+		c.compression = compression
+	}
+}
+
+// Load files referenced by maps (eg. external tilesets) with the given Resolver.
+// By default files are read from within the working directory (see DefaultResolver), for a
+// trusted map read from a file use NewFileResolver so they're found relative to the map.
+func WithResolver(r Resolver) Option {
+	return func(c *CodecV1) {
+		c.resolver = r
+	}
+}
+
+// Write tilesets that have a Source as references to their external file rather than
+// inlining them in the map. The tileset files themselves can be written with MarshalTileset.
+func ExternalTilesets() Option {
+	return func(c *CodecV1) {
+		c.externalTilesets = true
+	}
+}
+
+// Fill in the default values of members missing from class properties with the given custom
+// classes (eg. those of a Tiled project, loaded with tmj.CodecTMJ.UnmarshalPropertyTypes).
+func WithPropertyTypes(types *common.PropertyTypes) Option {
+	return func(c *CodecV1) {
+		c.propertyTypes = types
+	}
+}
+
+// Work around problems with the data (eg. property values that don't parse, tiles that are in no
+// tileset) by leaving out what's wrong, rather than failing on the first problem. The problems are
+// returned as warnings by UnmarshalWithWarnings.
+func Lenient() Option {
+	return func(c *CodecV1) {
+		c.lenient = true
+	}
+}
+
+// NewCodecV1 returns a codec with the given options applied
+func NewCodecV1(opts ...Option) *CodecV1 {
+	c := &CodecV1{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// The resolver to load referenced files with
+func (c *CodecV1) getResolver() Resolver {
+	if c.resolver == nil {
+		return DefaultResolver()
+	}
+	return c.resolver
+}
+
+// Unmarshal a standalone common.Tileset from the given data (that is, []byte read from a .tsx file)
+//
+func (c *CodecV1) UnmarshalTileset(data []byte) (*common.Tileset, error) {
+	tset, _, err := c.UnmarshalTilesetWithWarnings(data)
+	return tset, err
+}
+
+// As UnmarshalTileset, also returning the problems that were worked around if the codec is
+// Lenient (see UnmarshalWithWarnings).
+//
+func (c *CodecV1) UnmarshalTilesetWithWarnings(data []byte) (*common.Tileset, []*DecodeError, error) {
+	d := newDecoder(c)
+	var xmltileset tileset
+	err := unmarshalXML(data, &xmltileset)
+	if err != nil {
+		return nil, nil, d.syntaxError("", err)
+	}
+	tset, err := xmltileset.inflate(d, ".")
+	if err != nil {
+		return nil, *d.warnings, err
+	}
+	return tset, *d.warnings, nil
+}
+
+// Given a tileset, marshal it into the .tsx format, compatible with Tiled.
+//
+func (c *CodecV1) MarshalTileset(in *common.Tileset) ([]byte, error) {
+	in.FinalizeIDs()
+
+	tset := deflateTileset(in)
+	tset.FirstGID = 0 // external tilesets don't have a firstgid, that's set by each map using them
+	tset.Source = ""
+	return xml.Marshal(tset)
+}
+
+// Unmarshal a standalone common.Template from the given data (that is, []byte read from a .tx file).
+// The template's tileset (if any) is loaded with the codec's Resolver.
+//
+func (c *CodecV1) UnmarshalTemplate(data []byte) (*common.Template, error) {
+	d := newDecoder(c)
+	var xmltemplate template
+	err := unmarshalXML(data, &xmltemplate)
+	if err != nil {
+		return nil, d.syntaxError("", err)
+	}
+	return xmltemplate.inflate(d, ".", nil)
+}
+
+// Given a template, marshal it into the .tx format, compatible with Tiled.
+//
+func (c *CodecV1) MarshalTemplate(in *common.Template) ([]byte, error) {
+	tmpl, err := deflateTemplate(in)
+	if err != nil {
+		return nil, err
+	}
+	return xml.Marshal(tmpl)
+}
+
+// Unmarshal a common.Map from the given data (that is, []byte read from a tmx .xml file).
+// Errors are *DecodeError, saying where in the data (or the files it refers to) they are.
 //
 func (c *CodecV1) Unmarshal(data []byte) (*common.Map, error) {
+	m, _, err := c.UnmarshalWithWarnings(data)
+	return m, err
+}
+
+// As Unmarshal, also returning the problems that were worked around if the codec is Lenient
+// (without Lenient the first problem is returned as the error, so there are never warnings).
+//
+func (c *CodecV1) UnmarshalWithWarnings(data []byte) (*common.Map, []*DecodeError, error) {
+	d := newDecoder(c)
 	var xmlmap tileMap
-	err := xml.Unmarshal(data, &xmlmap)
+	err := unmarshalXML(data, &xmlmap)
 	if err != nil {
-		return nil, err
+		return nil, nil, d.syntaxError("", err)
 	}
-	return xmlmap.inflate()
+	m, err := xmlmap.inflate(d)
+	if err != nil {
+		return nil, *d.warnings, err
+	}
+	return m, *d.warnings, nil
 }
 
 // Given a map, marshal it back into it's tmx .xml format, compatible with Tiled.
@@ -36,23 +188,27 @@ func (c *CodecV1) Marshal(in *common.Map) ([]byte, error) {
 		StaggerAxis: in.StaggerAxis(),
 		StaggerIndex: in.StaggerIndex(),
 		BackgroundColor: encodeHexColour(in.BackgroundColor),
-		NextObjectId: 0,
+		NextObjectId: in.NextObjectID(),
+		Infinite: boolToInt(in.Infinite()),
 		Properties: deflateProperties(in.Properties()),
 		Tilesets:   []tileset{},
-		TileLayers: []tileLayer{},
-		ImageLayers:  []imageLayer{},
+		Layers: []layerElement{},
 	}
 
 	for _, tset := range in.Tilesets() {
-		tmap.Tilesets = append(tmap.Tilesets, deflateTileset(tset))
-	}
-
-	for _, layer := range in.TileLayers() {
-		tmap.TileLayers = append(tmap.TileLayers, deflateTileLayer(layer))
+		if c.externalTilesets && tset.Source != "" {
+			tmap.Tilesets = append(tmap.Tilesets, deflateTilesetReference(tset))
+		} else {
+			tmap.Tilesets = append(tmap.Tilesets, deflateTileset(tset))
+		}
 	}
 
-	for _, layer := range in.ImageLayers() {
-		tmap.ImageLayers = append(tmap.ImageLayers, deflateImageLayer(layer))
+	for _, layer := range in.Layers() {
+		element, err := deflateLayer(layer, c.encoding, c.compression)
+		if err != nil {
+			return nil, err
+		}
+		tmap.Layers = append(tmap.Layers, element)
 	}
 
 	return xml.Marshal(tmap)
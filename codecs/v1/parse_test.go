@@ -0,0 +1,137 @@
+package v1
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+// A 2x1 map with a single tile per tileset, each map using a different tile image
+// but the same global tile ids.
+func concurrentMap(n int) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.2" orientation="orthogonal" renderorder="right-down" width="2" height="1" tilewidth="16" tileheight="16">
+ <tileset firstgid="1" name="set%d" tilewidth="16" tileheight="16" tilecount="1">
+  <terraintypes>
+   <terrain name="terrain%d" tile="0"/>
+  </terraintypes>
+  <tile id="0" terrain="0,0,0,0">
+   <image width="16" height="16" source="tile%d.png"/>
+  </tile>
+ </tileset>
+ <layer name="ground" width="2" height="1">
+  <data encoding="csv">1,2</data>
+ </layer>
+</map>
+`, n, n, n)
+}
+
+func TestUnmarshalConcurrent(t *testing.T) {
+	codec := NewCodecV1(Lenient()) // gid 2 is in no tileset
+
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+
+			for j := 0; j < 10; j++ {
+				result, err := codec.Unmarshal([]byte(concurrentMap(n)))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				layer := result.TileLayers()[0]
+				expect := fmt.Sprintf("tile%d.png", n)
+				if layer.Get(0, 0) == nil || layer.Get(0, 0).Source != expect {
+					t.Error("map", n, "expected tile", expect, "got", layer.Get(0, 0))
+				}
+				if layer.Get(1, 0) != nil {
+					t.Error("map", n, "expected no tile for unknown gid 2, got", layer.Get(1, 0))
+				}
+
+				terrain := layer.Get(0, 0).TopLeftTerrain()
+				if terrain == nil || terrain.Name != fmt.Sprintf("terrain%d", n) {
+					t.Error("map", n, "expected terrain from its own tileset, got", terrain)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestUnmarshalDoesNotLeakTiles(t *testing.T) {
+	codec := NewCodecV1(Lenient())
+
+	// The first map defines gid 2, the second doesn't but refers to it
+	first := `<map width="1" height="1" tilewidth="16" tileheight="16">
+ <tileset firstgid="1" name="a" tilewidth="16" tileheight="16">
+  <tile id="0"><image source="a.png"/></tile>
+  <tile id="1"><image source="b.png"/></tile>
+ </tileset>
+</map>`
+
+	_, err := codec.Unmarshal([]byte(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := codec.Unmarshal([]byte(concurrentMap(0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.TileLayers()[0].Get(1, 0) != nil {
+		t.Error("expected no tile for gid 2, got", result.TileLayers()[0].Get(1, 0))
+	}
+}
+
+func TestHexagonalMapRoundTrip(t *testing.T) {
+	in := `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.2" orientation="hexagonal" renderorder="right-down" width="3" height="2" tilewidth="14" tileheight="12" hexsidelength="6" staggeraxis="y" staggerindex="even">
+ <layer name="ground" width="3" height="2">
+  <data encoding="csv">0,0,0,0,0,0</data>
+ </layer>
+</map>
+`
+	codec := NewCodecV1()
+
+	result, err := codec.Unmarshal([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(m *common.Map) {
+		if m.Orientation() != common.MapOrientationHexagonal {
+			t.Error("expected hexagonal orientation, got", m.Orientation())
+		}
+		if m.HexSideLength != 6 {
+			t.Error("expected hex side length 6, got", m.HexSideLength)
+		}
+		if m.StaggerAxis() != common.MapStaggerAxisY || m.StaggerIndex() != common.MapStaggerIndexEven {
+			t.Error("expected stagger y/even, got", m.StaggerAxis(), m.StaggerIndex())
+		}
+	}
+	check(result)
+
+	data, err := codec.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := codec.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(again)
+}
+
+func TestUnknownOrientation(t *testing.T) {
+	in := `<map version="1.2" orientation="triangular" width="1" height="1" tilewidth="16" tileheight="16"></map>`
+
+	_, err := NewCodecV1().Unmarshal([]byte(in))
+	if err == nil {
+		t.Error("expected an error for an unknown orientation")
+	}
+}
@@ -2,6 +2,8 @@ package v1
 
 import (
 	"encoding/xml"
+	"errors"
+	"sort"
 	"strconv"
 	"github.com/voidshard/libtmx/common"
 )
@@ -15,12 +17,26 @@ type properties struct {
 	Properties []property `xml:"property,optional,omitempty"`
 }
 
-func (p *properties) inflate() []*common.Property {
+// Properties are optional, so we don't write out an empty block
+//
+func (p properties) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if len(p.Properties) == 0 {
+		return nil
+	}
+	type plain properties
+	return e.EncodeElement(plain(p), start)
+}
+
+func (p *properties) inflate(d *decoder) ([]*common.Property, error) {
 	result := []*common.Property{}
 	for _, prop := range p.Properties {
-		result = append(result, prop.inflate())
+		inflated, err := prop.inflate(d)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, inflated)
 	}
-	return result
+	return result, nil
 }
 
 type property struct {
@@ -30,34 +46,101 @@ type property struct {
 	Name      string `xml:"name,attr"`
 	ValueType string `xml:"type,attr"`
 	Value     string `xml:"value,attr"`
+
+	// attrs optional
+	PropertyType string `xml:"propertytype,attr,optional,omitempty"` // name of a custom class or enum
+
+	// subsections optional
+	Properties properties `xml:"properties,optional,omitempty"` // members of class properties
+
+	pos position
+}
+
+// Record where the property starts, for error reporting
+//
+func (p *property) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain property
+	tmp := plain{pos: positionOf(d)}
+	if err := d.DecodeElement(&tmp, &start); err != nil {
+		return err
+	}
+	*p = property(tmp)
+	return nil
 }
 
-func (p *property) inflate() *common.Property {
+// Inflate the property, class properties are given any default members missing from the
+// data if the codec knows the class. Values that don't parse are a problem, lenient decoders
+// give the property the zero value of it's type (or make it a string, if the type is unknown).
+//
+func (p *property) inflate(d *decoder) (out *common.Property, err error) {
+	defer d.enter(element("property", p.Name), p.pos)(&err)
 	prop := common.NewProp(p.Name)
 
-	if p.ValueType == common.PropertyTypeString {
+	var invalid error // set if the value doesn't parse
+	if p.ValueType == common.PropertyTypeString || p.ValueType == "" { // type is optional, string is the default
 		prop.SetString(p.Value)
 	} else if p.ValueType == common.PropertyTypeFile {
 		prop.SetFilepath(p.Value)
 	} else if p.ValueType == common.PropertyTypeColour {
-		col, _ := decodeHexColour(p.Value)
+		col, err := decodeHexColour(p.Value)
+		invalid = err
 		prop.SetColour(col)
 	} else if p.ValueType == common.PropertyTypeFloat {
-		val, _ := strconv.ParseFloat(p.Value, 64)
+		val, err := strconv.ParseFloat(p.Value, 64)
+		if err != nil {
+			invalid, val = err, 0
+		}
 		prop.SetFloat(val)
 	} else if p.ValueType == common.PropertyTypeInt {
-		val, _ := strconv.Atoi(p.Value)
+		val, err := strconv.Atoi(p.Value)
+		if err != nil {
+			invalid, val = err, 0
+		}
 		prop.SetInt(val)
 	} else if p.ValueType == common.PropertyTypeBool {
+		if p.Value != "true" && p.Value != "false" {
+			invalid = errors.New("expected true or false")
+		}
 		prop.SetBool(p.Value == "true")
+	} else if p.ValueType == common.PropertyTypeObject {
+		val := 0 // no object
+		if p.Value != "" {
+			var err error
+			val, err = strconv.Atoi(p.Value)
+			if err != nil {
+				invalid, val = err, 0
+			}
+		}
+		prop.SetObject(val)
+	} else if p.ValueType == common.PropertyTypeClass {
+		members, err := p.Properties.inflate(d)
+		if err != nil {
+			return nil, err
+		}
+		prop.SetClass(p.PropertyType, members...)
+		d.codec.propertyTypes.FillDefaults(prop)
+	} else {
+		err := d.problem("Property %s has unknown type %s", p.Name, p.ValueType)
+		if err != nil {
+			return nil, err
+		}
+		prop.SetString(p.Value)
 	}
 
-	return prop
+	if invalid != nil {
+		err := d.problem("Property %s has invalid %s value %s: %v", p.Name, p.ValueType, p.Value, invalid)
+		if err != nil {
+			return nil, err
+		}
+	}
+	prop.SetPropertyType(p.PropertyType)
+	return prop, nil
 }
 
 func deflateProperty(in *common.Property) (out property) {
 	out.Name = in.Name()
 	out.ValueType = in.Type()
+	out.PropertyType = in.PropertyType()
 
 	if out.ValueType == common.PropertyTypeString {
 		out.Value = in.AsString()
@@ -74,12 +157,19 @@ func deflateProperty(in *common.Property) (out property) {
 		if in.AsBool() {
 			out.Value = "true"
 		}
+	} else if out.ValueType == common.PropertyTypeObject {
+		out.Value = strconv.Itoa(in.AsObject())
+	} else if out.ValueType == common.PropertyTypeClass {
+		out.Properties = deflateProperties(in.ExplicitMembers())
 	}
 
 	return
 }
 
+// Deflate properties for writing to XML, sorted by name so output is stable
+//
 func deflateProperties(in []*common.Property) (out properties) {
+	sort.Slice(in, func(i, j int) bool { return in[i].Name() < in[j].Name() })
 	for _, prop := range in {
 		out.Properties = append(out.Properties, deflateProperty(prop))
 	}
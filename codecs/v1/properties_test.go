@@ -0,0 +1,176 @@
+package v1
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+const classPropertiesMap = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="orthogonal" renderorder="right-down" width="1" height="1" tilewidth="16" tileheight="16">
+ <properties>
+  <property name="boss" type="object" value="12"/>
+  <property name="nobody" type="object" value=""/>
+  <property name="facing" type="string" propertytype="Direction" value="north"/>
+  <property name="stats" type="class" propertytype="Stats">
+   <properties>
+    <property name="hp" type="int" value="50"/>
+    <property name="resist" type="class" propertytype="Resistances">
+     <properties>
+      <property name="fire" type="float" value="0.5"/>
+     </properties>
+    </property>
+   </properties>
+  </property>
+ </properties>
+</map>
+`
+
+func TestClassProperties(t *testing.T) {
+	types := common.NewPropertyTypes(
+		common.NewCustomClass("Stats",
+			common.NewProp("hp").SetInt(10),
+			common.NewProp("mp").SetInt(5),
+			common.NewProp("resist").SetClass("Resistances"),
+		),
+		common.NewCustomClass("Resistances",
+			common.NewProp("fire").SetFloat(0),
+			common.NewProp("ice").SetFloat(0.25),
+		),
+	)
+
+	check := func(m *common.Map, defaults bool) {
+		boss, _ := m.Property("boss")
+		if boss.Type() != common.PropertyTypeObject || boss.AsObject() != 12 {
+			t.Error("expected object property referring to 12, got", boss)
+		}
+		if nobody, _ := m.Property("nobody"); nobody.AsObject() != 0 {
+			t.Error("expected object property referring to no object, got", nobody)
+		}
+		if facing, _ := m.Property("facing"); facing.AsString() != "north" || facing.PropertyType() != "Direction" {
+			t.Error("expected enum property, got", facing)
+		}
+
+		stats, _ := m.Property("stats")
+		if stats.Type() != common.PropertyTypeClass || stats.PropertyType() != "Stats" {
+			t.Fatal("expected class property, got", stats)
+		}
+		if hp, ok := stats.Member("hp"); !ok || hp.AsInt() != 50 {
+			t.Error("expected hp member of 50, got", hp)
+		}
+		resist, _ := stats.Member("resist")
+		if fire, ok := resist.Member("fire"); !ok || fire.AsFloat() != 0.5 {
+			t.Error("expected nested fire member of 0.5, got", fire)
+		}
+
+		mp, hasMp := stats.Member("mp")
+		ice, hasIce := resist.Member("ice")
+		if !defaults && (hasMp || hasIce) {
+			t.Error("expected no default members without property types")
+		}
+		if defaults && (!hasMp || mp.AsInt() != 5 || !hasIce || ice.AsFloat() != 0.25) {
+			t.Error("expected default members mp & ice, got", mp, ice)
+		}
+	}
+
+	for _, defaults := range []bool{false, true} {
+		opts := []Option{}
+		if defaults {
+			opts = append(opts, WithPropertyTypes(types))
+		}
+		codec := NewCodecV1(opts...)
+
+		in, err := codec.Unmarshal([]byte(classPropertiesMap))
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(in, defaults)
+
+		data, err := codec.Marshal(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := codec.Unmarshal(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(out, defaults)
+	}
+
+	// default members are copies, changing one doesn't change the class
+	in, _ := NewCodecV1(WithPropertyTypes(types)).Unmarshal([]byte(classPropertiesMap))
+	stats, _ := in.Property("stats")
+	mp, _ := stats.Member("mp")
+	mp.SetInt(99)
+	if def, _ := types.Class("Stats"); def != nil {
+		if mp, _ := def.Member("mp"); mp.AsInt() != 5 {
+			t.Error("expected class default to be unchanged, got", mp.AsInt())
+		}
+	}
+}
+
+func TestUnknownPropertyType(t *testing.T) {
+	data := strings.Replace(classPropertiesMap, `type="object" value="12"`, `type="vector" value="1,2"`, 1)
+	_, err := NewCodecV1().Unmarshal([]byte(data))
+	if err == nil || !strings.Contains(err.Error(), "vector") {
+		t.Error("expected an error for an unknown property type, got", err)
+	}
+
+	data = strings.Replace(classPropertiesMap, `value="12"`, `value="twelve"`, 1)
+	_, err = NewCodecV1().Unmarshal([]byte(data))
+	if err == nil {
+		t.Error("expected an error for an invalid object id")
+	}
+}
+
+func TestClassDefaultsNotWritten(t *testing.T) {
+	types := common.NewPropertyTypes(
+		common.NewCustomClass("Stats",
+			common.NewProp("hp").SetInt(10),
+			common.NewProp("mp").SetInt(5),
+			common.NewProp("armour").SetInt(0),
+			common.NewProp("resist").SetClass("Resistances"),
+		),
+		common.NewCustomClass("Resistances",
+			common.NewProp("fire").SetFloat(0),
+			common.NewProp("ice").SetFloat(0.25),
+		),
+	)
+	codec := NewCodecV1(WithPropertyTypes(types))
+
+	in, err := codec.Unmarshal([]byte(classPropertiesMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, _ := in.Property("stats")
+	if mp, _ := stats.Member("mp"); !mp.IsDefault() {
+		t.Error("expected mp to be filled in from the class default")
+	}
+	armour, _ := stats.Member("armour")
+	armour.SetInt(3)
+
+	data, err := codec.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, member := range []string{`name="mp"`, `name="ice"`} {
+		if strings.Contains(string(data), member) {
+			t.Error("expected default member not to be written", member)
+		}
+	}
+	for _, member := range []string{`name="hp" type="int" value="50"`, `name="fire" type="float" value="0.5"`, `name="armour" type="int" value="3"`} {
+		if !strings.Contains(string(data), member) {
+			t.Error("expected member to be written", member)
+		}
+	}
+
+	out, err := NewCodecV1().Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, _ = out.Property("stats")
+	if len(stats.Members()) != 3 {
+		t.Error("expected only the members set to be written, got", len(stats.Members()))
+	}
+}
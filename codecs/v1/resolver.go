@@ -0,0 +1,99 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Resolver loads files that a map refers to, such as external tilesets.
+//
+// Names are slash separated paths relative to the directory of the map being read
+// (references from within referenced files are joined onto the referencing file's directory
+// before being handed to the Resolver).
+//
+type Resolver interface {
+	ReadFile(name string) ([]byte, error)
+}
+
+// FSResolver resolves files from an fs.FS, relative to Dir within it. Files outside of the FS
+// (eg. ../ past it's root) are refused, as are absolute paths unless AbsolutePaths is set.
+//
+type FSResolver struct {
+	FS  fs.FS
+	Dir string
+
+	// if set, absolute paths are read from the root of FS (as for a FS of the whole disk, see
+	// NewDirResolver)
+	AbsolutePaths bool
+}
+
+// Create a new resolver for a map at mapPath within the given file system
+//
+func NewFSResolver(fsys fs.FS, mapPath string) *FSResolver {
+	return &FSResolver{FS: fsys, Dir: path.Dir(mapPath)}
+}
+
+// Create a new resolver for a map file on disk. Referenced files may be anywhere on disk (see
+// NewDirResolver).
+//
+func NewFileResolver(mapFile string) (*FSResolver, error) {
+	return NewDirResolver(filepath.Dir(mapFile))
+}
+
+// Create a new resolver for maps in the given directory on disk. Referenced files may be anywhere
+// on disk (eg. in a parent directory, or by absolute path), so this should only be used for maps
+// that are trusted.
+//
+func NewDirResolver(dir string) (*FSResolver, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	root := filepath.VolumeName(abs) + string(filepath.Separator)
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return nil, err
+	}
+	return &FSResolver{FS: os.DirFS(root), Dir: filepath.ToSlash(rel), AbsolutePaths: true}, nil
+}
+
+// The resolver codecs use if none is given. Maps read from bytes have no file of their own, so
+// files are read from disk relative to the working directory, which they must be within (maps
+// from elsewhere may refer to any file, use NewFileResolver for maps that are trusted).
+//
+func DefaultResolver() Resolver {
+	return NewFSResolver(os.DirFS("."), ".")
+}
+
+// Read the named file, relative to Dir
+//
+func (r *FSResolver) ReadFile(name string) ([]byte, error) {
+	resolved := resolvePath(r.Dir, name)
+	if path.IsAbs(resolved) {
+		if !r.AbsolutePaths {
+			return nil, errors.New(fmt.Sprintf("Refusing to read %s, absolute paths aren't allowed", name))
+		}
+		resolved = strings.TrimPrefix(resolved, "/")
+	}
+	if !fs.ValidPath(resolved) {
+		return nil, errors.New(fmt.Sprintf("Refusing to read %s, it's outside of the resolver's files", name))
+	}
+	return fs.ReadFile(r.FS, resolved)
+}
+
+// Join a referenced file name onto the directory it was referenced from (absolute names are
+// left absolute)
+//
+func resolvePath(dir, name string) string {
+	name = filepath.ToSlash(name)
+	if path.IsAbs(name) {
+		return path.Clean(name)
+	}
+	return path.Join(dir, name)
+}
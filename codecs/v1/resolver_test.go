@@ -0,0 +1,101 @@
+package v1
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// Write a map in maps/ referring to a tileset in a sibling directory (../tilesets)
+func writeMapDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"maps/level.tmx":      externalTilesetMap,
+		"tilesets/shared.tsx": externalTileset,
+	}
+	for name, data := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFileResolverParentDir(t *testing.T) {
+	dir := writeMapDir(t)
+	resolver, err := NewFileResolver(filepath.Join(dir, "maps", "level.tmx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := NewCodecV1(WithResolver(resolver)).Unmarshal([]byte(externalTilesetMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(in.Tilesets()) != 1 || in.Tilesets()[0].Name != "shared" {
+		t.Error("expected tileset from ../tilesets/shared.tsx, got", in.Tilesets())
+	}
+}
+
+func TestDefaultResolver(t *testing.T) {
+	dir := writeMapDir(t)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	// files within the working directory are read
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	within := strings.Replace(externalTilesetMap, "../tilesets/", "tilesets/", 1)
+	if _, err := NewCodecV1().Unmarshal([]byte(within)); err != nil {
+		t.Error("expected tileset within the working directory to be read, got", err)
+	}
+
+	// those outside it aren't, whether by a parent directory or an absolute path
+	if err := os.Chdir(filepath.Join(dir, "maps")); err != nil {
+		t.Fatal(err)
+	}
+	absolute := strings.Replace(externalTilesetMap, "../tilesets/shared.tsx", filepath.ToSlash(filepath.Join(dir, "tilesets", "shared.tsx")), 1)
+	for name, data := range map[string]string{"parent": externalTilesetMap, "absolute": absolute} {
+		_, err := NewCodecV1().Unmarshal([]byte(data))
+		if err == nil || !strings.Contains(err.Error(), "Refusing to read") {
+			t.Error(name, "expected file outside the working directory to be refused, got", err)
+		}
+	}
+
+	// unless the resolver allows them
+	resolver, err := NewFileResolver("level.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCodecV1(WithResolver(resolver)).Unmarshal([]byte(absolute)); err != nil {
+		t.Error("expected file resolver to read absolute paths, got", err)
+	}
+}
+
+func TestFSResolverRefuses(t *testing.T) {
+	fsys := fstest.MapFS{
+		"maps/level.tmx": &fstest.MapFile{Data: []byte(externalTilesetMap)},
+		"secret.txt":     &fstest.MapFile{Data: []byte("secret")},
+	}
+	resolver := NewFSResolver(fsys, "maps/level.tmx")
+
+	if data, err := resolver.ReadFile("../secret.txt"); err != nil || string(data) != "secret" {
+		t.Error("expected file within the FS to be read, got", err)
+	}
+	for _, name := range []string{"../../secret.txt", "/secret.txt"} {
+		if _, err := resolver.ReadFile(name); err == nil || !strings.Contains(err.Error(), "Refusing to read") {
+			t.Error(name, "expected to be refused, got", err)
+		}
+	}
+}
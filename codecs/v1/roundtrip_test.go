@@ -0,0 +1,121 @@
+package v1
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// Maps, tilesets & templates covering everything the common model supports, written as Tiled
+// writes them. Each is read in, written out & read in again; both the XML written & the
+// common.Map read back must match the original.
+const roundTripCorpus = "testdata/roundtrip"
+
+func TestRoundTrip(t *testing.T) {
+	corpus := os.DirFS(roundTripCorpus)
+	files := []string{}
+	err := fs.WalkDir(corpus, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch path.Ext(name) {
+		case ".tmx", ".tsx", ".tx":
+			files = append(files, name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no files in", roundTripCorpus)
+	}
+
+	for _, name := range files {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			original, err := fs.ReadFile(corpus, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			codec := NewCodecV1(WithResolver(NewFSResolver(corpus, name)), ExternalTilesets())
+
+			var written []byte
+			var diffs []string
+			switch path.Ext(name) {
+			case ".tmx":
+				written, diffs = roundTripMap(t, codec, original)
+			case ".tsx":
+				written, diffs = roundTripTileset(t, codec, original)
+			case ".tx":
+				written, diffs = roundTripTemplate(t, codec, original)
+			}
+			if len(diffs) > 0 {
+				t.Errorf("read back differently:\n%s", strings.Join(diffs, "\n"))
+			}
+
+			diffs, err = diffXML(original, written)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(diffs) > 0 {
+				t.Errorf("written differently:\n%s", strings.Join(diffs, "\n"))
+			}
+		})
+	}
+}
+
+// Read, write & read again the given map, returning what was written & how what was read back
+// differs from what was first read
+func roundTripMap(t *testing.T, codec *CodecV1, original []byte) ([]byte, []string) {
+	t.Helper()
+	first, err := codec.Unmarshal(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := codec.Marshal(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := codec.Unmarshal(written)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return written, compareMaps(first, again)
+}
+
+func roundTripTileset(t *testing.T, codec *CodecV1, original []byte) ([]byte, []string) {
+	t.Helper()
+	first, err := codec.UnmarshalTileset(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := codec.MarshalTileset(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := codec.UnmarshalTileset(written)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return written, compareTilesets(first, again)
+}
+
+func roundTripTemplate(t *testing.T, codec *CodecV1, original []byte) ([]byte, []string) {
+	t.Helper()
+	first, err := codec.UnmarshalTemplate(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := codec.MarshalTemplate(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := codec.UnmarshalTemplate(written)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return written, compareObjects(first.Object, again.Object)
+}
@@ -0,0 +1,143 @@
+package v1
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+// Represents a template (.tx) file; a single object, with the tileset of it's tile if
+// it's a tile object
+//
+type template struct {
+	XMLName xml.Name `xml:"template"`
+
+	// subsections optional
+	Tileset *tileset `xml:"tileset,optional,omitempty"`
+
+	// subsections
+	Object object `xml:"object"`
+
+	pos position
+}
+
+// Record where the template starts, for error reporting
+//
+func (t *template) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain template
+	tmp := plain{pos: positionOf(d)}
+	if err := d.DecodeElement(&tmp, &start); err != nil {
+		return err
+	}
+	*t = template(tmp)
+	return nil
+}
+
+// Inflate this template to a common.Template. The template's tileset is loaded relative to
+// dir (the directory of the template file).
+//
+// Global tile ids in a template refer only to it's own tileset, so it's read with a decoder of
+// it's own. The map decoder (if any) is used to swap the template's tile for the same tile of the
+// map's copy of the tileset, so that objects based on the template refer to tiles in the map.
+//
+func (t *template) inflate(d *decoder, dir string, mapDecoder *decoder) (out *common.Template, err error) {
+	td := d.child()
+	defer td.enter("template", t.pos)(&err)
+
+	tilesetSource := ""
+	if t.Tileset != nil {
+		if t.Tileset.Source == "" {
+			return nil, errors.New("Template tilesets must be external tilesets")
+		}
+		_, err := t.Tileset.inflate(td, dir)
+		if err != nil {
+			return nil, err
+		}
+		tilesetSource = resolvePath(dir, t.Tileset.Source)
+	}
+
+	if t.Object.Template != "" {
+		return nil, errors.New(fmt.Sprintf("Template object cannot itself refer to template %s", t.Object.Template))
+	}
+	obj, err := t.Object.inflate(td)
+	if err != nil {
+		return nil, err
+	}
+	obj.Id = 0
+
+	if mapDecoder != nil && obj.Kind() == common.ObjectTypeTile {
+		if tile, ok := mapDecoder.tileFrom(tilesetSource, obj.Tile().Id); ok {
+			cell := obj.TileCell()
+			cell.Tile = tile
+			obj.SetTileCell(cell)
+		}
+	}
+
+	return common.NewTemplate("", obj), nil
+}
+
+// Deflate the given common.Template for writing to a template file. Tile objects must use a
+// tileset with a Source, as templates can only refer to external tilesets.
+//
+func deflateTemplate(in *common.Template) (*template, error) {
+	if in.Object == nil {
+		return nil, errors.New("Template has no object")
+	}
+
+	out := &template{Object: deflateObject(in.Object)}
+	out.Object.Id = 0
+	out.Object.X = 0
+	out.Object.Y = 0
+	out.Object.Template = ""
+
+	if in.Object.Kind() == common.ObjectTypeTile {
+		cell := in.Object.TileCell()
+		tset := cell.Tile.Tileset()
+		if tset == nil || tset.Source == "" {
+			return nil, errors.New("Template tile objects must use a tileset with a Source")
+		}
+
+		// the template's tileset is the only one in the file, so it starts at 1
+		out.Tileset = &tileset{FirstGID: 1, Source: tset.Source}
+		out.Object.Gid = cell.GlobalID() & common.GIDFlags | uint32(cell.Tile.Id + 1)
+	}
+	return out, nil
+}
+
+// Load (or fetch from those already loaded) the template at the given path
+//
+func (d *decoder) template(source string) (*common.Template, error) {
+	filename := resolvePath(".", source)
+	if tmpl, ok := d.templates[filename]; ok {
+		return tmpl, nil
+	}
+
+	data, err := d.codec.getResolver().ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var xmltemplate template
+	err = unmarshalXML(data, &xmltemplate)
+	if err != nil {
+		return nil, d.syntaxError(filename, err)
+	}
+
+	tmpl, err := d.inflateTemplate(&xmltemplate, filename)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.Source = source
+	d.templates[filename] = tmpl
+	return tmpl, nil
+}
+
+// Inflate the given template read from the given file, as part of this decoder's file
+//
+func (d *decoder) inflateTemplate(xmltemplate *template, filename string) (out *common.Template, err error) {
+	defer d.enterFile(filename, position{})(&err)
+	return xmltemplate.inflate(d, path.Dir(filename), d)
+}
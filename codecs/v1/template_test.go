@@ -0,0 +1,178 @@
+package v1
+
+import (
+	"encoding/xml"
+	"testing"
+	"testing/fstest"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+const (
+	templateTileset = `<?xml version="1.0" encoding="UTF-8"?>
+<tileset name="things" tilewidth="16" tileheight="16" tilecount="2" columns="0">
+ <tile id="0"><image width="16" height="16" source="chest.png"/></tile>
+ <tile id="1"><image width="16" height="16" source="barrel.png"/></tile>
+</tileset>
+`
+
+	chestTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<template>
+ <tileset firstgid="1" source="../tilesets/things.tsx"/>
+ <object name="chest" type="loot" gid="1" width="16" height="16">
+  <properties>
+   <property name="gold" type="int" value="10"/>
+   <property name="locked" type="bool" value="true"/>
+  </properties>
+ </object>
+</template>
+`
+
+	zoneTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<template>
+ <object name="zone" width="40" height="20" visible="0">
+  <ellipse/>
+ </object>
+</template>
+`
+
+	templateMap = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.2" orientation="orthogonal" renderorder="right-down" width="2" height="2" tilewidth="16" tileheight="16">
+ <tileset firstgid="3" source="tilesets/things.tsx"/>
+ <objectgroup name="objects">
+  <object id="1" template="templates/chest.tx" x="16" y="32"/>
+  <object id="2" template="templates/chest.tx" name="big chest" x="0" y="16" visible="0">
+   <properties>
+    <property name="gold" type="int" value="500"/>
+   </properties>
+  </object>
+  <object id="3" template="templates/zone.tx" x="4" y="4" visible="1">
+   <polygon points="0,0 10,0 10,10"/>
+  </object>
+  <object id="4" template="templates/zone.tx" x="8" y="8"/>
+ </objectgroup>
+</map>
+`
+)
+
+func templateFS() fstest.MapFS {
+	return fstest.MapFS{
+		"maps/level.tmx":             &fstest.MapFile{Data: []byte(templateMap)},
+		"maps/tilesets/things.tsx":   &fstest.MapFile{Data: []byte(templateTileset)},
+		"maps/templates/chest.tx":    &fstest.MapFile{Data: []byte(chestTemplate)},
+		"maps/templates/zone.tx":     &fstest.MapFile{Data: []byte(zoneTemplate)},
+	}
+}
+
+func TestObjectTemplates(t *testing.T) {
+	codec := NewCodecV1(WithResolver(NewFSResolver(templateFS(), "maps/level.tmx")), ExternalTilesets())
+
+	check := func(m *common.Map) {
+		objs := m.ObjectLayers()[0].Objects()
+		if len(objs) != 4 {
+			t.Fatalf("expected 4 objects, got %d", len(objs))
+		}
+		chest, big, poly, zone := objs[0], objs[1], objs[2], objs[3]
+
+		// plain instance, everything from the template
+		if chest.Template() == nil || chest.Template().Source != "templates/chest.tx" {
+			t.Fatal("expected chest to be linked to it's template, got", chest.Template())
+		}
+		if chest.Name != "chest" || chest.Type != "loot" || chest.Width != 16 || !chest.Visible || chest.X != 16 || chest.Y != 32 {
+			t.Error("unexpected chest values", chest)
+		}
+		thing, _ := m.Tilesets()[0].Tile(0)
+		if chest.Kind() != common.ObjectTypeTile || chest.Tile() != thing {
+			t.Error("expected chest to use the map's chest tile, got", chest.Tile())
+		}
+		if gold, ok := chest.Property("gold"); !ok || gold.AsInt() != 10 {
+			t.Error("expected 10 gold from the template, got", gold)
+		}
+
+		// overrides
+		if big.Name != "big chest" || big.Type != "loot" || big.Visible || big.Tile() != thing {
+			t.Error("unexpected big chest values", big)
+		}
+		if gold, ok := big.Property("gold"); !ok || gold.AsInt() != 500 {
+			t.Error("expected overridden 500 gold, got", gold)
+		}
+		if locked, ok := big.Property("locked"); !ok || !locked.AsBool() {
+			t.Error("expected locked from the template, got", locked)
+		}
+
+		if poly.Kind() != common.ObjectTypePolygon || len(poly.Points()) != 3 || !poly.Visible || poly.Width != 40 {
+			t.Error("unexpected polygon values", poly)
+		}
+		if zone.Kind() != common.ObjectTypeEllipse || zone.Visible || zone.Height != 20 {
+			t.Error("unexpected zone values", zone)
+		}
+		if zone.Template() != poly.Template() {
+			t.Error("expected objects to share a template")
+		}
+	}
+
+	in, err := codec.Unmarshal([]byte(templateMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(in)
+
+	data, err := codec.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the overridden values are written out
+	var written tileMap
+	err = xml.Unmarshal(data, &written)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs := written.Layers[0].ObjectGroup.Objects
+	if objs[0].Template != "templates/chest.tx" || objs[0].Name != "" || objs[0].Gid != 0 || objs[0].Width != 0 || objs[0].Visible != nil || len(objs[0].Properties.Properties) != 0 {
+		t.Error("expected only the template, id & position of the chest to be written, got", objs[0])
+	}
+	if objs[1].Name != "big chest" || objs[1].Type != "" || objs[1].Visible == nil || *objs[1].Visible != 0 || len(objs[1].Properties.Properties) != 1 {
+		t.Error("expected name, visibility & gold of the big chest to be written, got", objs[1])
+	}
+	if objs[2].Polygon == nil || objs[2].Visible == nil || objs[2].Ellipse != nil {
+		t.Error("expected polygon & visibility of the polygon to be written, got", objs[2])
+	}
+
+	out, err := codec.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(out)
+}
+
+func TestTemplateRoundTrip(t *testing.T) {
+	codec := NewCodecV1(WithResolver(NewFSResolver(templateFS(), "maps/templates/chest.tx")))
+
+	tmpl, err := codec.UnmarshalTemplate([]byte(chestTemplate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Object.Name != "chest" || tmpl.Object.Kind() != common.ObjectTypeTile || tmpl.Object.Tile().Source != "chest.png" {
+		t.Fatal("unexpected template object", tmpl.Object)
+	}
+
+	data, err := codec.MarshalTemplate(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := codec.UnmarshalTemplate(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Object.Name != "chest" || again.Object.Type != "loot" || again.Object.Tile().Id != 0 || len(again.Object.Properties()) != 2 {
+		t.Error("unexpected template object after round trip", again.Object)
+	}
+
+	// tile objects need an external tileset to refer to
+	again.Object.Tile().Tileset().Source = ""
+	_, err = codec.MarshalTemplate(again)
+	if err == nil {
+		t.Error("expected an error for a template tile from an embedded tileset")
+	}
+}
@@ -12,23 +12,16 @@ type terrainTypes struct {
 	Terrain []terrain `xml:"terrain"`
 }
 
-func (t *terrainTypes) inflate() []*common.Terrain {
+func (t *terrainTypes) inflate(d *decoder, firstGID int) ([]*common.Terrain, error) {
 	result := []*common.Terrain{}
-	for terrainId, ter := range t.Terrain {
-		terrain := common.NewTerrain(ter.Name)
-		globalTerrain[terrainId] = terrain
-
-		if ter.Tile > 0 {
-			tile, ok := globalTilemap[ter.Tile]
-			if ok {
-				terrain.Tile = tile.inflatedTile
-			}
+	for _, ter := range t.Terrain {
+		terrain, err := ter.inflate(d, firstGID)
+		if err != nil {
+			return nil, err
 		}
-
-		terrain.UpdateProperties(ter.Properties.inflate()...)
 		result = append(result, terrain)
 	}
-	return result
+	return result, nil
 }
 
 type terrain struct {
@@ -40,4 +33,35 @@ type terrain struct {
 
 	// subsections
 	Properties properties `xml:"properties,optional,omitempty"`
+
+	pos position
+}
+
+// Record where the terrain starts, for error reporting
+//
+func (t *terrain) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain terrain
+	tmp := plain{pos: positionOf(d)}
+	if err := d.DecodeElement(&tmp, &start); err != nil {
+		return err
+	}
+	*t = terrain(tmp)
+	return nil
+}
+
+func (t *terrain) inflate(d *decoder, firstGID int) (out *common.Terrain, err error) {
+	defer d.enter(element("terrain", t.Name), t.pos)(&err)
+	out = common.NewTerrain(t.Name)
+
+	out.Tile, err = localTile(d, firstGID, t.Tile) // Nb. the tile here is a local id within the tileset
+	if err != nil {
+		return nil, err
+	}
+
+	props, err := t.Properties.inflate(d)
+	if err != nil {
+		return nil, err
+	}
+	out.UpdateProperties(props...)
+	return out, nil
 }
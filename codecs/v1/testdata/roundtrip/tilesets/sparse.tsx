@@ -0,0 +1,12 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset name="sparse" tilewidth="16" tileheight="16" tilecount="3" columns="0">
+ <tile id="0">
+  <image width="16" height="16" source="rock.png"/>
+ </tile>
+ <tile id="2">
+  <image width="16" height="16" source="bush.png"/>
+ </tile>
+ <tile id="5">
+  <image width="16" height="16" source="tree.png"/>
+ </tile>
+</tileset>
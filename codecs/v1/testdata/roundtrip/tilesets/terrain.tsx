@@ -0,0 +1,55 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset name="terrain" tilewidth="16" tileheight="16" spacing="1" margin="2" tilecount="16" columns="4">
+ <image source="terrain.png" trans="ff00ff" width="70" height="70"/>
+ <properties>
+  <property name="biome" value="forest"/>
+ </properties>
+ <terraintypes>
+  <terrain name="grass" tile="0">
+   <properties>
+    <property name="speed" type="float" value="1"/>
+   </properties>
+  </terrain>
+  <terrain name="water" tile="5"/>
+ </terraintypes>
+ <tile id="0" terrain="0,0,0,0"/>
+ <tile id="1" terrain="0,0,0,1"/>
+ <tile id="2" terrain=",1,,1" type="shore"/>
+ <tile id="5" terrain="1,1,1,1" probability="0.25">
+  <properties>
+   <property name="swim" type="bool" value="true"/>
+  </properties>
+  <animation>
+   <frame tileid="5" duration="200"/>
+   <frame tileid="6" duration="200"/>
+  </animation>
+ </tile>
+ <tile id="7">
+  <objectgroup draworder="index">
+   <object id="1" x="0" y="8" width="16" height="8"/>
+   <object id="2" x="0" y="0">
+    <polygon points="0,0 16,0 8,8"/>
+   </object>
+  </objectgroup>
+ </tile>
+ <wangsets>
+  <wangset name="ground" type="corner" tile="0">
+   <properties>
+    <property name="auto" type="bool" value="true"/>
+   </properties>
+   <wangcolor name="grass" color="#00ff00" tile="0" probability="1"/>
+   <wangcolor name="water" color="#0000ff" tile="5" probability="0.5">
+    <properties>
+     <property name="deep" type="bool" value="false"/>
+    </properties>
+   </wangcolor>
+   <wangtile tileid="0" wangid="0,1,0,1,0,1,0,1"/>
+   <wangtile tileid="1" wangid="0,1,0,2,0,1,0,1"/>
+   <wangtile tileid="5" wangid="0,2,0,2,0,2,0,2"/>
+  </wangset>
+  <wangset name="paths" type="edge" tile="-1">
+   <wangcolor name="path" color="#806040" tile="-1" probability="1"/>
+   <wangtile tileid="8" wangid="1,0,0,0,1,0,0,0"/>
+  </wangset>
+ </wangsets>
+</tileset>
@@ -0,0 +1,9 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset name="things" tilewidth="16" tileheight="16" tilecount="2" columns="0">
+ <tile id="0">
+  <image width="16" height="16" source="chest.png"/>
+ </tile>
+ <tile id="1">
+  <image width="16" height="16" source="barrel.png"/>
+ </tile>
+</tileset>
@@ -12,72 +12,127 @@ type tile struct {
 	Id          int     `xml:"id,attr"`
 	Type        string  `xml:"type,attr,optional,omitempty"`
 	RawTerrain  string  `xml:"terrain,attr,optional,omitempty"`
-	Probability float64 `xml:"probability,attr,optional,omitempty"`
+	Probability *float64 `xml:"probability,attr,optional,omitempty"` // nil unless set, Tiled's default is 1
 
 	// subsections
 	Properties  properties  `xml:"properties,optional,omitempty"`
-	Image       imageData   `xml:"image,optional,omitempty"`
-	Animation   animation   `xml:"animation,optional,omitempty"`
-	ObjectGroup objectGroup `xml:"objectgroup,optional,omitempty"`
+	Image       *imageData  `xml:"image,optional,omitempty"` // tiles in image collection tilesets only
+	Animation   *animation  `xml:"animation,optional,omitempty"`
+	ObjectGroup *objectGroup `xml:"objectgroup,optional,omitempty"` // collision shapes
 
 	// Wrapped common.Tile that represents this xml parsed Tile
 	// (we have to create this in bits as Terrain & other tiles are loaded)
 	inflatedTile *common.Tile
+
+	pos position
 }
 
-func (t *tile) inflate() {
+// Record where the tile starts, for error reporting
+//
+func (t *tile) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain tile
+	tmp := plain{pos: positionOf(d)}
+	if err := d.DecodeElement(&tmp, &start); err != nil {
+		return err
+	}
+	*t = tile(tmp)
+	return nil
+}
+
+// Finish inflating this tile, now that all tiles & terrain (given, in order of terrain id)
+// in the tileset are known
+//
+func (t *tile) inflate(d *decoder, firstGID int, terrain []*common.Terrain) (err error) {
+	defer d.enter(element("tile", t.Id), t.pos)(&err)
+
 	// Continue to setup Tile obj
-	t.inflatedTile.UpdateProperties(t.Properties.inflate()...)
-	t.inflatedTile.Source = t.Image.Source
+	props, err := t.Properties.inflate(d)
+	if err != nil {
+		return err
+	}
+	t.inflatedTile.UpdateProperties(props...)
+	t.inflatedTile.Id = t.Id
 	t.inflatedTile.Type = t.Type
-	t.inflatedTile.Probability = t.Probability
-	t.inflatedTile.Width = t.Image.Width
-	t.inflatedTile.Height = t.Image.Height
-
-	terrains, err := t.Terrain()
-	if err == nil {
-		if terrains[0] > -1 {
-			ter, ok := globalTerrain[terrains[0]]
-			if ok {
-				t.inflatedTile.SetTopLeftTerrain(ter)
-			}
-		}
+	if t.Probability != nil {
+		t.inflatedTile.Probability = *t.Probability
+	}
+	if t.Image != nil {
+		t.inflatedTile.Source = t.Image.Source
+		t.inflatedTile.Width = t.Image.Width
+		t.inflatedTile.Height = t.Image.Height
+	}
 
-		if terrains[1] > -1 {
-			ter, ok := globalTerrain[terrains[1]]
-			if ok {
-				t.inflatedTile.SetTopRightTerrain(ter)
-			}
-		}
+	err = t.inflateTerrain(d, terrain)
+	if err != nil {
+		return err
+	}
 
-		if terrains[2] > -1 {
-			ter, ok := globalTerrain[terrains[2]]
-			if ok {
-				t.inflatedTile.SetBottomLeftTerrain(ter)
+	if t.ObjectGroup != nil {
+		shapes := []*common.Object{}
+		for _, obj := range t.ObjectGroup.Objects {
+			shape, err := obj.inflate(d)
+			if err != nil {
+				return err
 			}
+			shapes = append(shapes, shape)
 		}
+		t.inflatedTile.SetCollisionShapes(shapes...)
+	}
 
-		if terrains[3] > -1 {
-			ter, ok := globalTerrain[terrains[3]]
-			if ok {
-				t.inflatedTile.SetBottomRightTerrain(ter)
-			}
-		}
+	if t.Animation == nil {
+		return nil
 	}
 
 	frames := []*common.Frame{}
 	for _, fr := range t.Animation.Frames {
-		tile, ok := globalTilemap[fr.TileId]
+		tile, ok := d.tile(fr.TileId + firstGID) // Nb. frames use the local tile id
 		if !ok {
+			err := d.problem("Animation frame refers to unknown tile %d", fr.TileId)
+			if err != nil {
+				return err
+			}
 			continue
 		}
 
 		frames = append(frames, &common.Frame{
 			Duration: fr.Duration,
-			Tile: tile.inflatedTile,
+			Tile: tile,
 		})
 	}
 	t.inflatedTile.SetAnimation(frames...)
+	return nil
+}
+
+// Set the tile's terrain (corners) from the given terrain of the tileset, in order of
+// terrain id. Terrain that doesn't parse or refers to unknown terrain is a problem, lenient
+// decoders leave those corners without terrain.
+//
+func (t *tile) inflateTerrain(d *decoder, terrain []*common.Terrain) error {
+	terrains, err := t.Terrain()
+	if err != nil {
+		return d.problem("Invalid terrain %s: %v", t.RawTerrain, err)
+	}
+
+	setters := []func(*common.Terrain){
+		t.inflatedTile.SetTopLeftTerrain,
+		t.inflatedTile.SetTopRightTerrain,
+		t.inflatedTile.SetBottomLeftTerrain,
+		t.inflatedTile.SetBottomRightTerrain,
+	}
+	for i, terrainId := range terrains {
+		if terrainId < 0 {
+			continue
+		}
+		if terrainId >= len(terrain) {
+			err := d.problem("Tile refers to unknown terrain %d", terrainId)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		setters[i](terrain[terrainId])
+	}
+	return nil
 }
 
 func deflateTile(in *common.Tile) tile {
@@ -90,19 +145,36 @@ func deflateTile(in *common.Tile) tile {
 		}
 	}
 
-	return tile{
+	out := tile{
 		Id: in.Id,
 		Type: in.Type,
 		RawTerrain: encodeTerrain(rawter),
 		Properties: deflateProperties(in.Properties()),
 		Animation: deflateAnimation(in.Animation),
-		Probability: in.Probability,
-		Image: imageData{
+	}
+	if in.Probability != 1 {
+		probability := in.Probability
+		out.Probability = &probability
+	}
+	if len(in.CollisionShapes()) > 0 {
+		out.ObjectGroup = &objectGroup{
+			Visible: 1,
+			Opacity: 1,
+			DrawOrder: common.ObjectGroupDrawOrderIndex,
+			Objects: []object{},
+		}
+		for _, shape := range in.CollisionShapes() {
+			out.ObjectGroup.Objects = append(out.ObjectGroup.Objects, deflateObject(shape))
+		}
+	}
+	if in.Source != "" {
+		out.Image = &imageData{
 			Source: in.Source,
 			Width: in.Width,
 			Height: in.Height,
-		},
+		}
 	}
+	return out
 }
 
 func (t *tile) Terrain() ([4]int, error) {
@@ -126,8 +198,12 @@ type frame struct {
 	Duration int `xml:"duration,attr"`
 }
 
-func deflateAnimation(in *common.Animation) animation {
-	ani := animation{
+func deflateAnimation(in *common.Animation) *animation {
+	if in == nil || len(in.Frames) == 0 {
+		return nil
+	}
+
+	ani := &animation{
 		Frames: []frame{},
 	}
 	for _, fr := range in.Frames {
@@ -142,7 +218,7 @@ func deflateAnimation(in *common.Animation) animation {
 func deflateTerrain(in *common.Terrain) terrain {
 	tileid := -1
 	if in.Tile != nil {
-		tileid = in.Tile.GlobalID()
+		tileid = in.Tile.Id
 	}
 
 	return terrain{
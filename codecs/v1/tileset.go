@@ -2,21 +2,17 @@ package v1
 
 import (
 	"encoding/xml"
+	"errors"
+	"fmt"
 	"github.com/voidshard/libtmx/common"
 )
 
-var globalTilemap map[int]*tile
-var globalTerrain map[int]*common.Terrain
-func init () {
-	globalTilemap = make(map[int]*tile)
-	globalTerrain = make(map[int]*common.Terrain)
-}
-
 type tileset struct {
 	XMLName xml.Name `xml:"tileset"`
 
 	// attrs
-	Name string `xml:"name,attr"`
+	// Nb. a tileset in a map may be just a reference to an external file (firstgid & source only)
+	Name string `xml:"name,attr,omitempty"`
 
 	// attrs optional
 	FirstGID   int    `xml:"firstgid,attr,optional,omitempty"`
@@ -32,9 +28,34 @@ type tileset struct {
 	Tiles []tile `xml:"tile"`
 
 	// subsections optional
-	Offset     tileOffset   `xml:"tileoffset,optional,omitempty"`
-	Properties properties   `xml:"properties,optional,omitempty"`
-	Terrain    terrainTypes `xml:"terraintypes,optional,omitempty"`
+	Image      *imageData    `xml:"image,optional,omitempty"` // atlas tilesets only
+	Offset     *tileOffset   `xml:"tileoffset,optional,omitempty"`
+	Properties properties    `xml:"properties,optional,omitempty"`
+	Terrain    *terrainTypes `xml:"terraintypes,optional,omitempty"`
+	WangSets   *wangSets     `xml:"wangsets,optional,omitempty"`
+
+	pos position
+}
+
+// Record where the tileset starts, for error reporting
+//
+func (t *tileset) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain tileset
+	tmp := plain{pos: positionOf(d)}
+	if err := d.DecodeElement(&tmp, &start); err != nil {
+		return err
+	}
+	*t = tileset(tmp)
+	return nil
+}
+
+// Deflate the given common.Tileset to be a reference to it's external file, for writing to XML
+//
+func deflateTilesetReference(in *common.Tileset) tileset {
+	return tileset{
+		FirstGID: in.FirstGID,
+		Source: in.Source,
+	}
 }
 
 func deflateTileset(in *common.Tileset) tileset {
@@ -45,71 +66,162 @@ func deflateTileset(in *common.Tileset) tileset {
 		TileHeight: in.TileHeight,
 		Spacing: in.Spacing,
 		Margin: in.Margin,
-		Tilecount: len(in.Tiles()),
-		Offset: tileOffset{
-			X: in.OffsetX,
-			Y: in.OffsetY,
-		},
+		Tilecount: in.TileCount(),
+		Columns: in.Columns,
 		Properties: deflateProperties(in.Properties()),
 		Tiles: []tile{},
-		Terrain: terrainTypes{
-			Terrain: []terrain{},
-		},
 	}
 
-	for _, terr := range in.Terrain() {
-		if terr != nil {
-			tset.Terrain.Terrain = append(tset.Terrain.Terrain, deflateTerrain(terr))
+	if in.OffsetX != 0 || in.OffsetY != 0 {
+		tset.Offset = &tileOffset{
+			X: in.OffsetX,
+			Y: in.OffsetY,
+		}
+	}
+
+	if len(in.Terrain()) > 0 {
+		tset.Terrain = &terrainTypes{Terrain: []terrain{}}
+		for _, terr := range in.Terrain() {
+			if terr != nil {
+				tset.Terrain.Terrain = append(tset.Terrain.Terrain, deflateTerrain(terr))
+			}
+		}
+	}
+
+	tset.WangSets = deflateWangSets(in.WangSets())
+
+	if in.IsAtlas() {
+		tset.Image = &imageData{
+			Source: in.ImageSource,
+			TransColour: encodeHexColour(in.TransparentColour),
+			Width: in.ImageWidth,
+			Height: in.ImageHeight,
 		}
 	}
 
 	for _, tile := range in.Tiles() {
+		if in.IsAtlas() && !tile.HasData() {
+			continue // every tile in an atlas exists whether it's written out or not
+		}
 		tset.Tiles = append(tset.Tiles, deflateTile(tile))
 	}
 
 	return tset
 }
 
-func (t *tileset) inflate(parent *common.Map) *common.Tileset {
+// Inflate this tileset to a common.Tileset. If the tileset is a reference to an external file
+// the file is loaded (relative to dir, the directory of the file containing this tileset).
+//
+func (t *tileset) inflate(d *decoder, dir string) (out *common.Tileset, err error) {
+	if t.Source != "" {
+		defer d.enter(element("tileset", t.Source), t.pos)(&err)
+		return t.inflateExternal(d, dir)
+	}
+	defer d.enter(element("tileset", t.Name), t.pos)(&err)
+	return t.inflateTileset(d)
+}
+
+// Inflate the contents of this tileset, which isn't a reference to an external file
+//
+func (t *tileset) inflateTileset(d *decoder) (*common.Tileset, error) {
 	// Build a map Id->Tile as we're going to need to match TileId(s) to Tiles
 	// even when fully inflating Tiles & Terrain ..
 	// That is, Tile and Terrain can reference other Tile(s)
-	tiles := []*common.Tile{}
-	tilewrappers := []*tile{}
+	obj := common.NewTileset(t.Name)
+	obj.TileWidth = t.TileWidth
+	obj.TileHeight = t.TileHeight
+	obj.Margin = t.Margin
+	obj.Spacing = t.Spacing
 
-	for _, tmp := range t.Tiles {
-		tilecopy := &tile{
-			Id: tmp.Id,
-			Type: tmp.Type,
-			RawTerrain: tmp.RawTerrain,
-			Probability: tmp.Probability,
-			Properties: tmp.Properties,
-			Image: tmp.Image,
-			Animation: tmp.Animation,
-			ObjectGroup: tmp.ObjectGroup,
+	if t.Image != nil { // an atlas; every tile exists, whether or not it has a <tile> entry
+		trans, err := d.colour(t.Image.TransColour, decodeHexColour)
+		if err != nil {
+			return nil, err
+		}
+		obj.SetImage(t.Image.Source, t.Image.Width, t.Image.Height)
+		obj.TransparentColour = trans
+		if t.Columns > 0 {
+			obj.Columns = t.Columns
 		}
+		obj.FillTiles(t.Tilecount)
+	}
 
-		tileId := tilecopy.Id + t.FirstGID
-		globalTilemap[tileId] = tilecopy
-		tilecopy.inflatedTile = common.NewTile(tilecopy.Image.Source)
-		tiles = append(tiles, tilecopy.inflatedTile)
-		tilewrappers = append(tilewrappers, tilecopy)
+	tilewrappers := []*tile{}
+	for i := range t.Tiles {
+		tw := &t.Tiles[i]
+		inflated, ok := obj.Tile(tw.Id)
+		if !ok {
+			inflated = common.NewTile("")
+			inflated.Id = tw.Id
+			obj.AddTiles(inflated)
+		}
+		tw.inflatedTile = inflated
+		tilewrappers = append(tilewrappers, tw)
+	}
+	for _, tile := range obj.Tiles() {
+		d.tiles[tile.Id + t.FirstGID] = tile
 	}
 
-	obj := parent.NewTileset(t.Name, tiles...)
-	obj.UpdateProperties(t.Properties.inflate()...)
-	obj.AddTerrain(t.Terrain.inflate()...)
+	obj.FirstGID = t.FirstGID
+	props, err := t.Properties.inflate(d)
+	if err != nil {
+		return nil, err
+	}
+	obj.UpdateProperties(props...)
+	if t.Terrain != nil {
+		terrain, err := t.Terrain.inflate(d, t.FirstGID)
+		if err != nil {
+			return nil, err
+		}
+		obj.AddTerrain(terrain...)
+	}
+	if t.WangSets != nil {
+		sets, err := t.WangSets.inflate(d, t.FirstGID)
+		if err != nil {
+			return nil, err
+		}
+		obj.AddWangSets(sets...)
+	}
 
-	obj.TileWidth = t.TileWidth
-	obj.TileHeight = t.TileHeight
-	obj.Margin = t.Margin
-	obj.Spacing = t.Spacing
-	obj.OffsetX = t.Offset.X
-	obj.OffsetY = t.Offset.Y
+	if t.Offset != nil {
+		obj.OffsetX = t.Offset.X
+		obj.OffsetY = t.Offset.Y
+	}
 
 	for _, tw := range tilewrappers {
-		tw.inflate()
+		err := tw.inflate(d, t.FirstGID, obj.Terrain())
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return obj
+	return obj, nil
+}
+
+// Load & inflate the external tileset file this tileset refers to
+//
+func (t *tileset) inflateExternal(d *decoder, dir string) (out *common.Tileset, err error) {
+	filename := resolvePath(dir, t.Source)
+	data, err := d.codec.getResolver().ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var external tileset
+	err = unmarshalXML(data, &external)
+	if err != nil {
+		return nil, d.syntaxError(filename, err)
+	}
+	defer d.enterFile(filename, external.pos)(&err)
+	if external.Source != "" {
+		return nil, errors.New(fmt.Sprintf("External tileset %s cannot itself refer to %s", t.Source, external.Source))
+	}
+
+	external.FirstGID = t.FirstGID // the map sets the firstgid, the file doesn't know it
+	obj, err := external.inflateTileset(d)
+	if err != nil {
+		return nil, err
+	}
+	obj.Source = t.Source
+	return obj, nil
 }
@@ -0,0 +1,354 @@
+package v1
+
+import (
+	"image"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+const (
+	externalTileset = `<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.2" tiledversion="1.2.1" name="shared" tilewidth="16" tileheight="16" tilecount="2" columns="0">
+ <terraintypes>
+  <terrain name="grass" tile="1"/>
+ </terraintypes>
+ <tile id="0" terrain="0,0,0,0">
+  <image width="16" height="16" source="../images/grass.png"/>
+  <animation>
+   <frame tileid="1" duration="100"/>
+  </animation>
+ </tile>
+ <tile id="1">
+  <image width="16" height="16" source="../images/dirt.png"/>
+ </tile>
+</tileset>
+`
+
+	externalTilesetMap = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.2" orientation="orthogonal" renderorder="right-down" width="2" height="1" tilewidth="16" tileheight="16">
+ <tileset firstgid="5" source="../tilesets/shared.tsx"/>
+ <layer name="ground" width="2" height="1">
+  <data encoding="csv">6,5</data>
+ </layer>
+</map>
+`
+)
+
+func TestExternalTileset(t *testing.T) {
+	fsys := fstest.MapFS{
+		"tilesets/shared.tsx": &fstest.MapFile{Data: []byte(externalTileset)},
+		"maps/level.tmx":      &fstest.MapFile{Data: []byte(externalTilesetMap)},
+	}
+	codec := NewCodecV1(WithResolver(NewFSResolver(fsys, "maps/level.tmx")), ExternalTilesets())
+
+	in, err := codec.Unmarshal([]byte(externalTilesetMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tset := in.Tilesets()[0]
+	if tset.Name != "shared" || tset.Source != "../tilesets/shared.tsx" || tset.FirstGID != 5 || tset.TileCount() != 2 {
+		t.Fatal("unexpected tileset", tset)
+	}
+	layer := in.TileLayers()[0]
+	if layer.Get(0, 0) != tset.Tiles()[1] || layer.Get(1, 0) != tset.Tiles()[0] {
+		t.Error("expected layer tiles to be resolved from the external tileset")
+	}
+
+	grass := tset.Tiles()[0]
+	if grass.TopLeftTerrain() == nil || grass.TopLeftTerrain().Name != "grass" {
+		t.Error("expected terrain to be set, got", grass.TopLeftTerrain())
+	}
+	if tset.Terrain()[0].Tile != tset.Tiles()[1] {
+		t.Error("expected terrain tile to be the local tile id 1")
+	}
+	if len(grass.Animation.Frames) != 1 || grass.Animation.Frames[0].Tile != tset.Tiles()[1] {
+		t.Error("expected animation frame to be the local tile id 1")
+	}
+
+	data, err := codec.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `<tileset firstgid="1" source="../tilesets/shared.tsx"></tileset>`) {
+		t.Error("expected tileset to be written as a reference, got", string(data))
+	}
+
+	tsx, err := codec.MarshalTileset(tset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := codec.UnmarshalTileset(tsx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "shared" || out.TileCount() != 2 || out.Tiles()[1].Source != "../images/dirt.png" {
+		t.Error("unexpected tileset after round trip", out)
+	}
+	if out.Terrain()[0].Tile != out.Tiles()[1] || out.Tiles()[0].BottomRightTerrain() != out.Terrain()[0] {
+		t.Error("expected terrain to survive round trip")
+	}
+}
+
+const atlasMap = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.2" orientation="orthogonal" renderorder="right-down" width="2" height="1" tilewidth="16" tileheight="16">
+ <tileset firstgid="1" name="sheet" tilewidth="16" tileheight="16" spacing="2" margin="1" tilecount="6" columns="3">
+  <image source="sheet.png" trans="ff00ff" width="54" height="36"/>
+  <tile id="4" type="wall">
+   <properties>
+    <property name="solid" type="bool" value="true"/>
+   </properties>
+  </tile>
+ </tileset>
+ <layer name="ground" width="2" height="1">
+  <data encoding="csv">5,6</data>
+ </layer>
+</map>
+`
+
+func TestAtlasTileset(t *testing.T) {
+	codec := NewCodecV1()
+
+	in, err := codec.Unmarshal([]byte(atlasMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := codec.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := codec.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Count(string(data), "<tile ") != 1 {
+		t.Error("expected only the tile with data to be written, got", string(data))
+	}
+
+	for _, result := range []*common.Map{in, out} {
+		tset := result.Tilesets()[0]
+		if !tset.IsAtlas() || tset.ImageSource != "sheet.png" || tset.Columns != 3 || tset.TileCount() != 6 {
+			t.Fatal("unexpected tileset", tset)
+		}
+		if tset.TransparentColour == nil || tset.TransparentColour.R != 255 || tset.TransparentColour.G != 0 {
+			t.Error("expected transparent colour, got", tset.TransparentColour)
+		}
+
+		layer := result.TileLayers()[0]
+		wall, plain := layer.Get(0, 0), layer.Get(1, 0)
+		if wall == nil || wall.Type != "wall" || wall.ImageSource() != "sheet.png" {
+			t.Fatal("expected wall tile at 0,0 got", wall)
+		}
+		if prop, ok := wall.Property("solid"); !ok || !prop.AsBool() {
+			t.Error("expected wall to be solid")
+		}
+		if plain == nil || plain.Id != 5 || plain.HasData() {
+			t.Fatal("expected tile without a <tile> entry at 1,0 got", plain)
+		}
+
+		if rect := wall.SourceRect(); rect != image.Rect(19, 19, 35, 35) {
+			t.Error("expected tile 4 at 19,19-35,35 got", rect)
+		}
+		if rect := plain.SourceRect(); rect != image.Rect(37, 19, 53, 35) {
+			t.Error("expected tile 5 at 37,19-53,35 got", rect)
+		}
+	}
+}
+
+const collisionTileset = `<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.2" name="walls" tilewidth="16" tileheight="16" tilecount="2" columns="2">
+ <image source="walls.png" width="32" height="16"/>
+ <tile id="1">
+  <objectgroup draworder="index" id="2">
+   <object id="1" x="0" y="8" width="16" height="8"/>
+   <object id="2" x="2" y="2" width="4" height="4">
+    <ellipse/>
+   </object>
+   <object id="3" x="8" y="0">
+    <polygon points="0,0 8,0 8,8"/>
+   </object>
+  </objectgroup>
+ </tile>
+</tileset>
+`
+
+func TestTileCollisionShapes(t *testing.T) {
+	codec := NewCodecV1()
+
+	in, err := codec.UnmarshalTileset([]byte(collisionTileset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := codec.MarshalTileset(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := codec.UnmarshalTileset(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, result := range []*common.Tileset{in, out} {
+		if len(result.Tiles()[0].CollisionShapes()) != 0 {
+			t.Error("expected no collision shapes on tile 0")
+		}
+
+		shapes := result.Tiles()[1].CollisionShapes()
+		if len(shapes) != 3 {
+			t.Fatal("expected 3 collision shapes, got", len(shapes))
+		}
+		if shapes[0].Kind() != common.ObjectTypeRectangle || shapes[0].Y != 8 || shapes[0].Width != 16 || shapes[0].Height != 8 {
+			t.Error("unexpected rectangle", shapes[0])
+		}
+		if shapes[1].Kind() != common.ObjectTypeEllipse || shapes[1].X != 2 || shapes[1].Width != 4 {
+			t.Error("unexpected ellipse", shapes[1])
+		}
+		if shapes[2].Kind() != common.ObjectTypePolygon || shapes[2].X != 8 || len(shapes[2].Points()) != 3 || shapes[2].Points()[2] != common.Pt(8, 8) {
+			t.Error("unexpected polygon", shapes[2])
+		}
+	}
+}
+
+const wangTileset = `<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" tiledversion="1.10.2" name="ground" tilewidth="16" tileheight="16" tilecount="4" columns="2">
+ <image source="ground.png" width="32" height="32"/>
+ <wangsets>
+  <wangset name="terrain" type="corner" tile="0">
+   <properties>
+    <property name="biome" value="temperate"/>
+   </properties>
+   <wangcolor name="grass" color="#00ff00" tile="0" probability="1"/>
+   <wangcolor name="sand" color="#ffff00" tile="3" probability="0.5">
+    <properties>
+     <property name="slow" type="bool" value="true"/>
+    </properties>
+   </wangcolor>
+   <wangtile tileid="0" wangid="0,1,0,1,0,1,0,1"/>
+   <wangtile tileid="1" wangid="0,2,0,1,0,1,0,2"/>
+   <wangtile tileid="3" wangid="0,2,0,2,0,2,0,2"/>
+  </wangset>
+  <wangset name="roads" type="edge" tile="-1">
+   <wangcolor name="road" color="#808080" tile="-1" probability="1"/>
+   <wangtile tileid="2" wangid="1,0,0,0,1,0,0,0"/>
+  </wangset>
+ </wangsets>
+</tileset>
+`
+
+func TestWangSets(t *testing.T) {
+	codec := NewCodecV1()
+
+	in, err := codec.UnmarshalTileset([]byte(wangTileset))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := codec.MarshalTileset(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := codec.UnmarshalTileset(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, result := range []*common.Tileset{in, out} {
+		if len(result.WangSets()) != 2 {
+			t.Fatal("expected 2 wang sets, got", len(result.WangSets()))
+		}
+
+		terrain, ok := result.WangSet("terrain")
+		if !ok || terrain.Type != common.WangSetTypeCorner || terrain.Tile != result.Tiles()[0] || terrain.Tileset() != result {
+			t.Fatal("unexpected terrain wang set", terrain)
+		}
+		if biome, ok := terrain.Property("biome"); !ok || biome.AsString() != "temperate" {
+			t.Error("expected wang set property, got", biome)
+		}
+
+		sand, ok := terrain.Colour(2)
+		if !ok || sand.Name != "sand" || sand.Tile != result.Tiles()[3] || sand.Probability != 0.5 || sand.Colour.R != 255 || sand.Colour.A != 255 {
+			t.Fatal("unexpected sand colour", sand)
+		}
+		if slow, ok := sand.Property("slow"); !ok || !slow.AsBool() {
+			t.Error("expected wang colour property, got", slow)
+		}
+
+		id, ok := terrain.WangID(result.Tiles()[1])
+		if !ok || id != (common.WangID{0, 2, 0, 1, 0, 1, 0, 2}) {
+			t.Error("unexpected wang id for tile 1", id)
+		}
+		if id.Corners() != [4]int{2, 1, 1, 2} {
+			t.Error("unexpected corners for tile 1", id.Corners())
+		}
+		if _, ok := terrain.WangID(result.Tiles()[2]); ok {
+			t.Error("expected tile 2 not to be in the terrain set")
+		}
+
+		roads, _ := result.WangSet("roads")
+		if roads.Type != common.WangSetTypeEdge || roads.Tile != nil || roads.Colours()[0].Tile != nil {
+			t.Error("unexpected roads wang set", roads)
+		}
+		if id, _ := roads.WangID(result.Tiles()[2]); id.Edges() != [4]int{1, 0, 1, 0} {
+			t.Error("unexpected edges for tile 2", id.Edges())
+		}
+	}
+}
+
+func TestWangSetErrors(t *testing.T) {
+	codec := NewCodecV1()
+
+	for name, wangid := range map[string]string{
+		"short": "0,1,0,1",
+		"unknown-colour": "0,3,0,1,0,1,0,1",
+		"not-a-number": "0,x,0,1,0,1,0,1",
+	} {
+		tsx := strings.Replace(wangTileset, "0,2,0,1,0,1,0,2", wangid, 1)
+		_, err := codec.UnmarshalTileset([]byte(tsx))
+		if err == nil {
+			t.Error("expected an error for wang id", name)
+		}
+	}
+}
+
+func TestTileProbability(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" name="weighted" tilewidth="16" tileheight="16" tilecount="3" columns="0">
+ <tile id="0">
+  <image width="16" height="16" source="plain.png"/>
+ </tile>
+ <tile id="1" probability="0">
+  <image width="16" height="16" source="never.png"/>
+ </tile>
+ <tile id="2" probability="0.5">
+  <image width="16" height="16" source="rare.png"/>
+ </tile>
+</tileset>
+`
+	codec := NewCodecV1()
+	in, err := codec.UnmarshalTileset([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := codec.MarshalTileset(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(out), `probability="1"`) || !strings.Contains(string(out), `probability="0"`) {
+		t.Error("expected only probabilities other than the default to be written, got", string(out))
+	}
+	result, err := codec.UnmarshalTileset(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tset := range []*common.Tileset{in, result} {
+		for i, expect := range []float64{1, 0, 0.5} {
+			if tset.Tiles()[i].Probability != expect {
+				t.Errorf("expected tile %d probability %v, got %v", i, expect, tset.Tiles()[i].Probability)
+			}
+		}
+	}
+}
@@ -1,14 +1,14 @@
 package v1
 
 import (
-	"encoding/hex"
 	"errors"
 	"fmt"
-	"image"
 	"image/color"
 	"strconv"
 	"strings"
-	"encoding/base64"
+
+	"github.com/voidshard/libtmx/codecs/internal/encode"
+	"github.com/voidshard/libtmx/common"
 )
 
 func boolToInt(b bool) int {
@@ -18,56 +18,51 @@ func boolToInt(b bool) int {
 	return 0
 }
 
-// Turn space separated string of x,y coords to []image.Point
-func decodePoints(s string) ([]image.Point, error) {
-	points := []image.Point{}
+// Turn space separated string of x,y coords to []common.Point
+func decodePoints(s string) ([]common.Point, error) {
+	points := []common.Point{}
 	for _, bit := range strings.Split(s, " ") {
 		coords := strings.Split(bit, ",")
 		if len(coords) != 2 {
 			return nil, errors.New(fmt.Sprintf("Expected x,y coord from %s got %s", bit, coords))
 		}
 
-		x, err := strconv.Atoi(coords[0])
+		x, err := strconv.ParseFloat(coords[0], 64)
 		if err != nil {
 			return nil, err
 		}
-		y, err := strconv.Atoi(coords[1])
+		y, err := strconv.ParseFloat(coords[1], 64)
 		if err != nil {
 			return nil, err
 		}
-		points = append(points, image.Pt(x, y))
+		points = append(points, common.Pt(x, y))
 	}
 	return points, nil
 }
 
 // Turn list of points to space separated string of x,y coords
-func encodePoints(in []image.Point) string {
+func encodePoints(in []common.Point) string {
 	bits := []string{}
 	for _, p := range in {
-		bits = append(bits, fmt.Sprintf("%d,%d", p.X, p.Y))
+		bits = append(bits, strconv.FormatFloat(p.X, 'f', -1, 64)+","+strconv.FormatFloat(p.Y, 'f', -1, 64))
 	}
 	return strings.Join(bits, " ")
 }
 
 // Parse a colour from hex #AARRGGBB or #RRGGBB
+//  - an empty string means no colour was set (nil)
 func decodeHexColour(s string) (*color.RGBA, error) {
-	data, err := hex.DecodeString(strings.TrimLeft(s, "#"))
-	if err != nil {
-		return nil, err
-	}
+	return encode.DecodeHexColour(s)
+}
 
-	dlen := len(data)
-	c := &color.RGBA{R: data[dlen-3], G: data[dlen-2], B: data[dlen-1]}
-	if dlen > 3 {
-		c.A = data[0]
-	}
-	return c, nil
+// Parse a tint colour, as for decodeHexColour but a colour without alpha is fully opaque
+func decodeTintColour(s string) (*color.RGBA, error) {
+	return encode.DecodeTintColour(s)
 }
 
-// Turn a RGBA colour back into #RRGGBB format (Experimentation finds that #AARRGGBB doesn't work?)
+// Turn a RGBA colour back into #RRGGBB format
 func encodeHexColour(in *color.RGBA) string {
-	chex := hex.EncodeToString([]byte{in.R, in.G, in.B})
-	return fmt.Sprintf("#%s", strings.ToUpper(chex))
+	return encode.EncodeHexColour(in)
 }
 
 // Turn terrain Id csv to []int
@@ -84,7 +79,10 @@ func decodeTerrain(in string) ([4]int, error) {
 		return res, nil
 	}
 
-	bits := strings.SplitN(in, ",", 4)
+	bits := strings.Split(in, ",")
+	if len(bits) != 4 {
+		return res, errors.New(fmt.Sprintf("Expected 4 terrain ids, got %d", len(bits)))
+	}
 	for i := 0; i < 4; i++ {
 		if bits[i] == "" {
 			continue
@@ -92,7 +90,7 @@ func decodeTerrain(in string) ([4]int, error) {
 
 		x, err := strconv.Atoi(bits[i])
 		if err != nil {
-			return res, nil
+			return [4]int{-1, -1, -1, -1}, err
 		}
 		res[i] = x
 	}
@@ -116,13 +114,38 @@ func encodeTerrain(in []int) string {
 	return strings.Join(bits, ",")
 }
 
-// Decode tile data into [][]int - assuming it's base64 encoded csv data
-func decodeTileDataBase64(in string) ([][]int, error) {
-	tmp, err := base64.StdEncoding.DecodeString(in)
-	if err != nil {
-		return nil, err
+// Decode tile data into [][]int with the given encoding & compression, rows are 'width' tiles long
+func decodeTileData(in, encoding, compression string, width int) ([][]int, error) {
+	if encoding == common.DataEncodingBase64 {
+		return decodeTileDataBase64(in, compression, width)
 	}
-	return decodeTileDataCsv(string(tmp))
+	return decodeTileDataCsv(in)
+}
+
+// Encode tile id row/column data with the given encoding & compression
+func encodeTileData(tiles [][]int, encoding, compression string) (string, error) {
+	switch encoding {
+	case common.DataEncodingBase64:
+		return encodeTileDataBase64(tiles, compression)
+	case common.DataEncodingCsv:
+		if compression != "" {
+			return "", errors.New(fmt.Sprintf("Tile data compression %s requires %s encoding", compression, common.DataEncodingBase64))
+		}
+		return encodeTileDataCsv(tiles), nil
+	}
+	return "", errors.New(fmt.Sprintf("Unknown tile data encoding %s", encoding))
+}
+
+// Decode tile data into [][]int - base64 encoded little-endian uint32 global tile ids,
+// optionally compressed with gzip, zlib or zstd. Rows are 'width' tiles long.
+func decodeTileDataBase64(in, compression string, width int) ([][]int, error) {
+	return encode.DecodeTileDataBase64(in, compression, width)
+}
+
+// Encode tile id row/column data as base64 little-endian uint32s, compressed with the
+// given compression (if any)
+func encodeTileDataBase64(tiles [][]int, compression string) (string, error) {
+	return encode.EncodeTileDataBase64(tiles, compression)
 }
 
 // Encode tile id row/column data back into csv / newline delimited format
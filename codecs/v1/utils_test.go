@@ -1,11 +1,11 @@
 package v1
 
 import (
-	"encoding/base64"
-	"image"
 	"image/color"
 	"reflect"
 	"testing"
+
+	"github.com/voidshard/libtmx/common"
 )
 
 const (
@@ -40,18 +40,18 @@ var (
 func TestDecodePoints(t *testing.T) {
 	cases := []struct {
 		In     string
-		Expect []image.Point
+		Expect []common.Point
 	}{
 		{
 			"0,0 146,-13 164,165 58,193 -80,152 117,80 1,2",
-			[]image.Point{
-				image.Pt(0, 0),
-				image.Pt(146, -13),
-				image.Pt(164, 165),
-				image.Pt(58, 193),
-				image.Pt(-80, 152),
-				image.Pt(117, 80),
-				image.Pt(1, 2),
+			[]common.Point{
+				common.Pt(0, 0),
+				common.Pt(146, -13),
+				common.Pt(164, 165),
+				common.Pt(58, 193),
+				common.Pt(-80, 152),
+				common.Pt(117, 80),
+				common.Pt(1, 2),
 			},
 		},
 	}
@@ -71,18 +71,18 @@ func TestDecodePoints(t *testing.T) {
 func TestEncodePoints(t *testing.T) {
 	cases := []struct {
 		Expect string
-		In     []image.Point
+		In     []common.Point
 	}{
 		{
 			"0,0 146,-13 164,165 58,193 -80,152 117,80 1,2",
-			[]image.Point{
-				image.Pt(0, 0),
-				image.Pt(146, -13),
-				image.Pt(164, 165),
-				image.Pt(58, 193),
-				image.Pt(-80, 152),
-				image.Pt(117, 80),
-				image.Pt(1, 2),
+			[]common.Point{
+				common.Pt(0, 0),
+				common.Pt(146, -13),
+				common.Pt(164, 165),
+				common.Pt(58, 193),
+				common.Pt(-80, 152),
+				common.Pt(117, 80),
+				common.Pt(1, 2),
 			},
 		},
 	}
@@ -117,6 +117,13 @@ func TestDecodeTerrain(t *testing.T) {
 			t.Error("Given", test.In, "expected", test.Expect, "got", result)
 		}
 	}
+
+	for _, in := range []string{"1,2", "0,1,2,3,4", "0,x,2,3"} {
+		_, err := decodeTerrain(in)
+		if err == nil {
+			t.Error("Given", in, "expected an error")
+		}
+	}
 }
 
 func TestEncodeTerrain(t *testing.T) {
@@ -172,6 +179,7 @@ func TestEncodeHexColour(t *testing.T) {
 		{"#00FF00", &color.RGBA{0, 255, 0, 255}},
 		{"#FF00FF", &color.RGBA{255, 0, 255, 0}},
 		{"#00FF00", &color.RGBA{0, 255, 0, 0}},
+		{"#8000FF00", &color.RGBA{0, 255, 0, 128}},
 	}
 
 	for _, test := range cases {
@@ -184,14 +192,20 @@ func TestEncodeHexColour(t *testing.T) {
 
 func TestDecodeTileDataBase64(t *testing.T) {
 	cases := []struct {
-		In     string
-		Expect [][]int
+		In          string
+		Compression string
+		Width       int
+		Expect      [][]int
 	}{
-		{base64.StdEncoding.EncodeToString([]byte(encodedcsv)), decodedcsv},
+		// 2x2 layer, gids 1, 2, 3 & 3221225474 (gid 2 with flip flags set)
+		{"AQAAAAIAAAADAAAAAgAAwA==", "", 2, [][]int{{1, 2}, {3, 3221225474}}},
+		{"H4sIAAAAAAAA/wAQAO//AQAAAAIAAAADAAAAAgAAwAMAg0kKERAAAAA=", common.DataCompressionGzip, 2, [][]int{{1, 2}, {3, 3221225474}}},
+		{"eJwAEADv/wEAAAACAAAAAwAAAAIAAMADAAEYAMk=", common.DataCompressionZlib, 2, [][]int{{1, 2}, {3, 3221225474}}},
+		{"\n   AQAAAAIAAAADAAAAAgAAwA==\n  ", "", 2, [][]int{{1, 2}, {3, 3221225474}}},
 	}
 
 	for _, test := range cases {
-		result, err := decodeTileDataBase64(test.In)
+		result, err := decodeTileDataBase64(test.In, test.Compression, test.Width)
 		if err != nil {
 			t.Error(err)
 		}
@@ -202,6 +216,31 @@ func TestDecodeTileDataBase64(t *testing.T) {
 	}
 }
 
+func TestEncodeTileDataBase64(t *testing.T) {
+	cases := []string{
+		"",
+		common.DataCompressionGzip,
+		common.DataCompressionZlib,
+		common.DataCompressionZstd,
+	}
+
+	for _, compression := range cases {
+		encoded, err := encodeTileDataBase64(decodedcsv, compression)
+		if err != nil {
+			t.Error(err)
+		}
+
+		result, err := decodeTileDataBase64(encoded, compression, len(decodedcsv[0]))
+		if err != nil {
+			t.Error(err)
+		}
+
+		if !reflect.DeepEqual(result, decodedcsv) {
+			t.Error("Given compression", compression, "expected", decodedcsv, "got", result)
+		}
+	}
+}
+
 func TestDecodeTileDataCsv(t *testing.T) {
 	cases := []struct {
 		In     string
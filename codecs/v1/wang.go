@@ -0,0 +1,243 @@
+package v1
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+type wangSets struct {
+	XMLName xml.Name `xml:"wangsets"`
+
+	// subsections
+	WangSets []wangSet `xml:"wangset"`
+}
+
+func (w *wangSets) inflate(d *decoder, firstGID int) ([]*common.WangSet, error) {
+	result := []*common.WangSet{}
+	for _, ws := range w.WangSets {
+		set, err := ws.inflate(d, firstGID)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, set)
+	}
+	return result, nil
+}
+
+func deflateWangSets(in []*common.WangSet) *wangSets {
+	if len(in) == 0 {
+		return nil
+	}
+	out := &wangSets{WangSets: []wangSet{}}
+	for _, set := range in {
+		out.WangSets = append(out.WangSets, deflateWangSet(set))
+	}
+	return out
+}
+
+type wangSet struct {
+	XMLName xml.Name `xml:"wangset"`
+
+	// attrs
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+	Tile int    `xml:"tile,attr"`
+
+	// subsections
+	Properties properties  `xml:"properties,optional,omitempty"`
+	Colours    []wangColor `xml:"wangcolor"`
+	Tiles      []wangTile  `xml:"wangtile"`
+
+	pos position
+}
+
+// Record where the wang set starts, for error reporting
+//
+func (w *wangSet) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plain wangSet
+	tmp := plain{pos: positionOf(d)}
+	if err := d.DecodeElement(&tmp, &start); err != nil {
+		return err
+	}
+	*w = wangSet(tmp)
+	return nil
+}
+
+// Inflate the wang set, tiles are referred to by their local id within the tileset
+//
+func (w *wangSet) inflate(d *decoder, firstGID int) (out *common.WangSet, err error) {
+	defer d.enter(element("wangset", w.Name), w.pos)(&err)
+	kind := w.Type
+	if kind == "" {
+		kind = common.WangSetTypeCorner
+	}
+	set := common.NewWangSet(w.Name, kind)
+	props, err := w.Properties.inflate(d)
+	if err != nil {
+		return nil, err
+	}
+	set.UpdateProperties(props...)
+	set.Tile, err = localTile(d, firstGID, w.Tile)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, wc := range w.Colours {
+		colour, err := wc.inflate(d, firstGID)
+		if err != nil {
+			return nil, err
+		}
+		set.AddColours(colour)
+	}
+
+	for _, wt := range w.Tiles {
+		tile, ok := d.tile(wt.TileId + firstGID)
+		if !ok {
+			err := d.problem("Wang set %s refers to unknown tile %d", w.Name, wt.TileId)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		id, err := decodeWangID(wt.WangID, len(set.Colours()))
+		if err != nil {
+			return nil, err
+		}
+		set.SetWangID(tile, id)
+	}
+
+	return set, nil
+}
+
+func deflateWangSet(in *common.WangSet) wangSet {
+	out := wangSet{
+		Name: in.Name,
+		Type: in.Type,
+		Tile: localTileId(in.Tile),
+		Properties: deflateProperties(in.Properties()),
+		Colours: []wangColor{},
+		Tiles: []wangTile{},
+	}
+	for _, colour := range in.Colours() {
+		out.Colours = append(out.Colours, deflateWangColor(colour))
+	}
+	for _, tile := range in.Tiles() {
+		id, _ := in.WangID(tile)
+		out.Tiles = append(out.Tiles, wangTile{TileId: tile.Id, WangID: encodeWangID(id)})
+	}
+	return out
+}
+
+type wangColor struct {
+	XMLName xml.Name `xml:"wangcolor"`
+
+	// attrs
+	Name        string   `xml:"name,attr"`
+	Colour      string   `xml:"color,attr"`
+	Tile        int      `xml:"tile,attr"`
+	Probability *float64 `xml:"probability,attr,omitempty"`
+
+	// subsections
+	Properties properties `xml:"properties,optional,omitempty"`
+}
+
+func (w *wangColor) inflate(d *decoder, firstGID int) (*common.WangColor, error) {
+	colour := common.NewWangColor(w.Name)
+	col, err := d.colour(w.Colour, decodeTintColour)
+	if err != nil {
+		return nil, err
+	}
+	colour.Colour = col
+	colour.Tile, err = localTile(d, firstGID, w.Tile)
+	if err != nil {
+		return nil, err
+	}
+	if w.Probability != nil {
+		colour.Probability = *w.Probability
+	}
+	props, err := w.Properties.inflate(d)
+	if err != nil {
+		return nil, err
+	}
+	colour.UpdateProperties(props...)
+	return colour, nil
+}
+
+func deflateWangColor(in *common.WangColor) wangColor {
+	probability := in.Probability
+	return wangColor{
+		Name: in.Name,
+		Colour: encodeHexColour(in.Colour),
+		Tile: localTileId(in.Tile),
+		Probability: &probability,
+		Properties: deflateProperties(in.Properties()),
+	}
+}
+
+type wangTile struct {
+	XMLName xml.Name `xml:"wangtile"`
+
+	// attrs
+	TileId int    `xml:"tileid,attr"`
+	WangID string `xml:"wangid,attr"`
+}
+
+// The tile with the given local id, where -1 means no tile. A tile that doesn't exist is a
+// problem, lenient decoders take it to mean no tile.
+func localTile(d *decoder, firstGID, id int) (*common.Tile, error) {
+	if id < 0 {
+		return nil, nil
+	}
+	tile, ok := d.tile(id + firstGID)
+	if !ok {
+		return nil, d.problem("Refers to unknown tile %d", id)
+	}
+	return tile, nil
+}
+
+// Inverse of localTile, the local id of the given tile or -1 for no tile
+func localTileId(in *common.Tile) int {
+	if in == nil {
+		return -1
+	}
+	return in.Id
+}
+
+// Turn a wang id csv of 8 colours (clockwise from the top edge) into a WangID, checking
+// each colour is in a set of the given number of colours.
+//   - this is the format used by Tiled 1.5+, older files used a single hex value
+//
+//  Example: "0,1,0,2,0,2,0,1"
+//
+func decodeWangID(in string, colours int) (common.WangID, error) {
+	id := common.WangID{}
+	bits := strings.Split(in, ",")
+	if len(bits) != len(id) {
+		return id, errors.New(fmt.Sprintf("Expected 8 comma separated wang colours, got %s", in))
+	}
+	for i, bit := range bits {
+		x, err := strconv.Atoi(strings.TrimSpace(bit))
+		if err != nil {
+			return id, err
+		}
+		if x < 0 || x > colours {
+			return id, errors.New(fmt.Sprintf("Wang id %s refers to unknown colour %d", in, x))
+		}
+		id[i] = x
+	}
+	return id, nil
+}
+
+// Inverse of decodeWangID
+func encodeWangID(in common.WangID) string {
+	bits := []string{}
+	for _, x := range in {
+		bits = append(bits, strconv.Itoa(x))
+	}
+	return strings.Join(bits, ",")
+}
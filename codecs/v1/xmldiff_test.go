@@ -0,0 +1,357 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// An XML element, reduced to what matters when comparing documents
+type xmlNode struct {
+	name     string
+	attrs    map[string]string
+	text     string
+	children []*xmlNode
+}
+
+// Attribute values that are the same as leaving the attribute out, by element
+var xmlDefaults = map[string]map[string]string{
+	"map":         {"orientation": "orthogonal", "infinite": "0", "hexsidelength": "0"},
+	"layer":       {"visible": "1", "opacity": "1", "offsetx": "0", "offsety": "0", "x": "0", "y": "0"},
+	"imagelayer":  {"visible": "1", "opacity": "1", "offsetx": "0", "offsety": "0"},
+	"objectgroup": {"name": "", "visible": "1", "opacity": "1", "offsetx": "0", "offsety": "0", "draworder": "topdown"},
+	"group":       {"visible": "1", "opacity": "1", "offsetx": "0", "offsety": "0"},
+	"object":      {"x": "0", "y": "0", "width": "0", "height": "0", "rotation": "0", "visible": "1"},
+	"tileset":     {"spacing": "0", "margin": "0", "columns": "0"},
+	"tileoffset":  {"x": "0", "y": "0"},
+	"terrain":     {"tile": "0"},
+	"wangcolor":   {"probability": "1"},
+	"property":    {"type": "string", "value": ""},
+	"text": {
+		"fontfamily": "sans-serif", "pixelsize": "16", "halign": "left", "valign": "top", "kerning": "1", "color": "000000",
+		"bold": "0", "italic": "0", "underline": "0", "strikeout": "0", "wrap": "0",
+	},
+}
+
+// Attributes holding colours, which may be written with or without a '#' & in either case
+var xmlColours = map[string]bool{"color": true, "backgroundcolor": true, "tintcolor": true, "trans": true}
+
+// Attributes whose values are compared as they are, others that are numbers are normalised
+// (so 0.50 & 0.5 are the same)
+var xmlVerbatim = map[string]bool{"name": true, "type": true, "value": true, "source": true, "template": true}
+
+// Elements whose order within their parent matters; layers (which are drawn in order) & objects
+var xmlOrdered = map[string]bool{"layer": true, "imagelayer": true, "objectgroup": true, "group": true, "object": true}
+
+// Parse an XML document into a tree of xmlNodes, dropping the prolog, comments & whitespace
+// between elements
+func parseXML(data []byte) (*xmlNode, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var root *xmlNode
+	stack := []*xmlNode{}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{name: t.Name.Local, attrs: map[string]string{}}
+			for _, attr := range t.Attr {
+				n.attrs[attr.Name.Local] = attr.Value
+			}
+			if len(stack) == 0 {
+				root = n
+			} else {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		}
+	}
+	if root == nil {
+		return nil, errors.New("No root element")
+	}
+	return root, nil
+}
+
+// Rewrite the tree so that documents meaning the same thing are the same; attributes set to
+// their defaults are dropped, numbers & colours are normalised and tile data is decoded to
+// global ids (keeping the encoding & compression, as layers remember these).
+func canonicalise(n *xmlNode, parent *xmlNode) error {
+	if n.name != "text" {
+		n.text = strings.TrimSpace(n.text)
+	}
+
+	defaults := xmlDefaults[n.name]
+	if n.name == "object" && n.attrs["template"] != "" {
+		defaults = map[string]string{"x": "0", "y": "0"} // the template decides the others
+	}
+	for key, value := range n.attrs {
+		if xmlColours[key] || (n.name == "property" && n.attrs["type"] == "color" && key == "value") {
+			value = canonicalColour(value, key == "tintcolor")
+		} else if !xmlVerbatim[key] || (n.name == "property" && n.attrs["type"] == "float" && key == "value") {
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				value = strconv.FormatFloat(f, 'f', -1, 64)
+			}
+		}
+		n.attrs[key] = value
+		if def, ok := defaults[key]; ok && def == value {
+			delete(n.attrs, key)
+		}
+	}
+
+	switch n.name {
+	case "data":
+		width, _ := strconv.Atoi(parent.attrs["width"])
+		ids, err := canonicalTileData(n.text, n.attrs["encoding"], n.attrs["compression"], width)
+		if err != nil {
+			return err
+		}
+		n.text = ids
+	case "chunk":
+		width, _ := strconv.Atoi(n.attrs["width"])
+		ids, err := canonicalTileData(n.text, parent.attrs["encoding"], parent.attrs["compression"], width)
+		if err != nil {
+			return err
+		}
+		n.text = ids
+	}
+
+	for _, child := range n.children {
+		err := canonicalise(child, n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A colour as lower case hex without the '#', tints without an alpha are fully opaque
+func canonicalColour(in string, tint bool) string {
+	out := strings.ToLower(strings.TrimPrefix(in, "#"))
+	if tint && len(out) == 8 && strings.HasPrefix(out, "ff") {
+		out = out[2:]
+	}
+	return out
+}
+
+// Tile data as a single comma separated list of global ids
+func canonicalTileData(in, encoding, compression string, width int) (string, error) {
+	if in == "" {
+		return "", nil
+	}
+	rows, err := decodeTileData(in, encoding, compression, width)
+	if err != nil {
+		return "", err
+	}
+	ids := []string{}
+	for _, row := range rows {
+		for _, id := range row {
+			ids = append(ids, strconv.Itoa(id))
+		}
+	}
+	return strings.Join(ids, ","), nil
+}
+
+// The name a child is known by within it's parent, that is it's element name & (if it has one)
+// it's name or id, eg. layer[ground], tile[3] or chunk[0,16]. Frames have no id, so they're
+// numbered, as are any other children that would share a name (eg. property[x]#1).
+func xmlKeys(children []*xmlNode) []string {
+	keys := []string{}
+	count := map[string]int{}
+	frames := 0
+	for _, child := range children {
+		id := ""
+		switch child.name {
+		case "tile", "object":
+			id = child.attrs["id"]
+		case "wangtile":
+			id = child.attrs["tileid"]
+		case "chunk":
+			id = child.attrs["x"] + "," + child.attrs["y"]
+		case "frame":
+			id = strconv.Itoa(frames)
+			frames++
+		default:
+			id = child.attrs["name"]
+		}
+		key := child.name
+		if id != "" {
+			key = fmt.Sprintf("%s[%s]", child.name, id)
+		}
+		if n := count[key]; n > 0 {
+			count[key]++
+			key = fmt.Sprintf("%s#%d", key, n)
+		} else {
+			count[key]++
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Canonicalise the given documents & report every attribute or element of the first that is
+// lost or changed in the second (or added to it), with the path to where it is.
+func diffXML(before, after []byte) ([]string, error) {
+	a, err := parseXML(before)
+	if err != nil {
+		return nil, err
+	}
+	b, err := parseXML(after)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range []*xmlNode{a, b} {
+		err = canonicalise(n, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if a.name != b.name {
+		return []string{fmt.Sprintf("root element changed from %s to %s", a.name, b.name)}, nil
+	}
+	return diffNodes(a.name, a, b), nil
+}
+
+func diffNodes(path string, a, b *xmlNode) []string {
+	diffs := []string{}
+
+	names := []string{}
+	for key := range a.attrs {
+		names = append(names, key)
+	}
+	for key := range b.attrs {
+		if _, ok := a.attrs[key]; !ok {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+	for _, key := range names {
+		av, inA := a.attrs[key]
+		bv, inB := b.attrs[key]
+		if !inB {
+			diffs = append(diffs, fmt.Sprintf("%s: lost attribute %s=%q", path, key, av))
+		} else if !inA {
+			diffs = append(diffs, fmt.Sprintf("%s: added attribute %s=%q", path, key, bv))
+		} else if av != bv {
+			diffs = append(diffs, fmt.Sprintf("%s: attribute %s changed from %q to %q", path, key, av, bv))
+		}
+	}
+
+	if a.text != b.text {
+		diffs = append(diffs, fmt.Sprintf("%s: text changed from %q to %q", path, a.text, b.text))
+	}
+
+	aKeys, bKeys := xmlKeys(a.children), xmlKeys(b.children)
+	bChildren := map[string]*xmlNode{}
+	for i, key := range bKeys {
+		bChildren[key] = b.children[i]
+	}
+	aChildren := map[string]bool{}
+	aOrder, bOrder := []string{}, []string{}
+	for i, key := range aKeys {
+		aChildren[key] = true
+		child, ok := bChildren[key]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("%s: lost element %s", path, key))
+			continue
+		}
+		diffs = append(diffs, diffNodes(path+"/"+key, a.children[i], child)...)
+		if xmlOrdered[a.children[i].name] {
+			aOrder = append(aOrder, key)
+		}
+	}
+	for i, key := range bKeys {
+		if !aChildren[key] {
+			diffs = append(diffs, fmt.Sprintf("%s: added element %s", path, key))
+		} else if xmlOrdered[b.children[i].name] {
+			bOrder = append(bOrder, key)
+		}
+	}
+	if strings.Join(aOrder, " ") != strings.Join(bOrder, " ") {
+		diffs = append(diffs, fmt.Sprintf("%s: order changed from %s to %s", path, strings.Join(aOrder, ", "), strings.Join(bOrder, ", ")))
+	}
+	return diffs
+}
+
+func TestDiffXML(t *testing.T) {
+	before := `<?xml version="1.0" encoding="UTF-8"?>
+<map orientation="orthogonal" width="2" height="1" tilewidth="16" tileheight="16" backgroundcolor="#ff00ff">
+ <!-- a comment -->
+ <properties>
+  <property name="speed" type="float" value="1.50"/>
+  <property name="title" value="Level"/>
+ </properties>
+ <layer name="ground" width="2" height="1" opacity="0.5">
+  <data encoding="csv">
+1,2
+</data>
+ </layer>
+ <layer name="top" width="2" height="1" tintcolor="#ff0000">
+  <data encoding="csv">0,0</data>
+ </layer>
+ <objectgroup name="objects"/>
+</map>
+`
+	// the same map written differently
+	same := `<map width="2" height="1" tilewidth="16" tileheight="16" backgroundcolor="FF00FF" infinite="0">
+ <properties>
+  <property name="title" type="string" value="Level"/>
+  <property name="speed" type="float" value="1.5"/>
+ </properties>
+ <layer name="ground" width="2" height="1" opacity="0.50" visible="1"><data encoding="csv">1,2</data></layer>
+ <layer name="top" width="2" height="1" tintcolor="#FFFF0000"><data encoding="csv">0,0</data></layer>
+ <objectgroup name="objects" draworder="topdown" opacity="1"></objectgroup>
+</map>`
+
+	diffs, err := diffXML([]byte(before), []byte(same))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) > 0 {
+		t.Error("expected no differences, got\n", strings.Join(diffs, "\n"))
+	}
+
+	changed := `<map width="2" height="1" tilewidth="16" tileheight="16" backgroundcolor="#ff00ff">
+ <properties>
+  <property name="speed" type="int" value="1"/>
+ </properties>
+ <layer name="top" width="2" height="1"><data encoding="csv">0,0</data></layer>
+ <layer name="ground" width="2" height="1" opacity="0.5"><data encoding="csv">1,3</data></layer>
+ <imagelayer name="sky"/>
+</map>`
+
+	diffs, err = diffXML([]byte(before), []byte(changed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{
+		`map/properties/property[speed]: attribute type changed from "float" to "int"`,
+		`map/properties/property[speed]: attribute value changed from "1.5" to "1"`,
+		`map/properties: lost element property[title]`,
+		`map/layer[ground]/data: text changed from "1,2" to "1,3"`,
+		`map/layer[top]: lost attribute tintcolor="ff0000"`,
+		`map: lost element objectgroup[objects]`,
+		`map: added element imagelayer[sky]`,
+		`map: order changed from layer[ground], layer[top] to layer[top], layer[ground]`,
+	}
+	if strings.Join(diffs, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expect, "\n"), strings.Join(diffs, "\n"))
+	}
+}
@@ -0,0 +1,51 @@
+package common
+
+// Cell is a single placed tile (eg. in a TileLayer) along with how it's flipped.
+// A Cell with no Tile is empty.
+type Cell struct {
+	Tile *Tile
+
+	FlipH   bool // flipped horizontally
+	FlipV   bool // flipped vertically
+	FlipD   bool // flipped anti-diagonally (combined with H & V this gives 90 degree rotations)
+	FlipHex bool // rotated by 120 degrees (hexagonal maps only)
+}
+
+func (c Cell) IsEmpty() bool {
+	return c.Tile == nil
+}
+
+// The global tile id of this cell's tile, with the flip flags set.
+// An empty cell has the global id 0.
+func (c Cell) GlobalID() uint32 {
+	if c.Tile == nil {
+		return 0
+	}
+
+	gid := uint32(c.Tile.GlobalID())
+	if c.FlipH {
+		gid |= GIDFlagFlippedHorizontally
+	}
+	if c.FlipV {
+		gid |= GIDFlagFlippedVertically
+	}
+	if c.FlipD {
+		gid |= GIDFlagFlippedDiagonally
+	}
+	if c.FlipHex {
+		gid |= GIDFlagRotatedHexagonal120
+	}
+	return gid
+}
+
+// Split a raw global tile id (as stored in a map) into the global id of the tile and
+// a Cell with the flip flags set (but no Tile).
+func DecodeGID(raw uint32) (int, Cell) {
+	cell := Cell{
+		FlipH:   raw&GIDFlagFlippedHorizontally != 0,
+		FlipV:   raw&GIDFlagFlippedVertically != 0,
+		FlipD:   raw&GIDFlagFlippedDiagonally != 0,
+		FlipHex: raw&GIDFlagRotatedHexagonal120 != 0,
+	}
+	return int(raw &^ GIDFlags), cell
+}
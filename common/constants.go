@@ -6,6 +6,7 @@ const (
 	MapOrientationOrthogonal = "orthogonal"
 	MapOrientationIsometric  = "isometric"
 	MapOrientationStaggered  = "staggered"
+	MapOrientationHexagonal  = "hexagonal"
 
 	MapRenderOrderRightDown = "right-down"
 	MapRenderOrderRightUp   = "right-up"
@@ -23,6 +24,7 @@ const (
 
 	DataCompressionGzip = "gzip"
 	DataCompressionZlib = "zlib"
+	DataCompressionZstd = "zstd"
 
 	ObjectGroupDrawOrderIndex   = "index"
 	ObjectGroupDrawOrderTopDown = "topdown"
@@ -41,21 +43,43 @@ const (
 	PropertyTypeBool   = "bool"
 	PropertyTypeColour = "color"
 	PropertyTypeFile   = "file"
+	PropertyTypeObject = "object"
+	PropertyTypeClass  = "class"
 
+	WangSetTypeCorner = "corner"
+	WangSetTypeEdge   = "edge"
+	WangSetTypeMixed  = "mixed"
+
+	ObjectTypeRectangle = "r"
+	ObjectTypePoint = "o"
 	ObjectTypeEllipse = "e"
 	ObjectTypePolygon = "p"
 	ObjectTypePolyline = "l"
 	ObjectTypeText = "t"
+	ObjectTypeTile = "g"
 
 	// Defaults that shall be enforced on object creation or parsing
 	DefaultPropertyType = PropertyTypeString
+	DefaultDataEncoding = DataEncodingCsv
 	DefaultDrawOrder    = ObjectGroupDrawOrderTopDown
 	DefaultRenderOrder  = MapRenderOrderRightDown
 	DefaultVAlign       = TextVAlignTop
 	DefaultHAlign       = TextHAlignLeft
 	DefaultStaggerAxis  = MapStaggerAxisX
 	DefaultStaggerIndex = MapStaggerIndexEven
-	DefaultFontFamily   = "sand-serif"
+	DefaultFontFamily   = "sans-serif"
 	DefaultPixelSize    = 16
+	DefaultChunkSize    = 16
 	DefaultTmxVersion   = "1.0"
 )
+
+const (
+	// Flags stored in the top bits of a global tile id (GID) wherever a tile is placed.
+	//  See TMX format docs: https://doc.mapeditor.org/en/stable/reference/global-tile-ids/
+	GIDFlagFlippedHorizontally uint32 = 0x80000000
+	GIDFlagFlippedVertically   uint32 = 0x40000000
+	GIDFlagFlippedDiagonally   uint32 = 0x20000000
+	GIDFlagRotatedHexagonal120 uint32 = 0x10000000
+
+	GIDFlags = GIDFlagFlippedHorizontally | GIDFlagFlippedVertically | GIDFlagFlippedDiagonally | GIDFlagRotatedHexagonal120
+)
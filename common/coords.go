@@ -0,0 +1,106 @@
+package common
+
+import (
+	"image"
+	"math"
+)
+
+// Pixel coordinates here are those of the map as it's drawn (as Tiled draws it), with the top
+// left of the map's bounding box at 0,0. For orthogonal maps these are the same as object
+// coordinates, but isometric maps place objects in tile height units along the tile axes.
+
+// Pixel position of the top left corner of the bounding box of tile x,y
+func (m *Map) TileToPixel(x, y int) image.Point {
+	switch m.orientation {
+	case MapOrientationIsometric:
+		originX := m.Height * m.TileWidth / 2
+		return image.Pt((x - y) * m.TileWidth / 2 + originX - m.TileWidth / 2, (x + y) * m.TileHeight / 2)
+	case MapOrientationStaggered, MapOrientationHexagonal:
+		return m.staggerGrid().TileToPixel(x, y)
+	}
+	return image.Pt(x * m.TileWidth, y * m.TileHeight)
+}
+
+// The tile holding the given pixel. Pixels outside of the map give tiles outside of the map.
+func (m *Map) PixelToTile(px, py int) image.Point {
+	switch m.orientation {
+	case MapOrientationIsometric:
+		fx := float64(px - m.Height * m.TileWidth / 2) / float64(m.TileWidth)
+		fy := float64(py) / float64(m.TileHeight)
+		return image.Pt(int(math.Floor(fy + fx)), int(math.Floor(fy - fx)))
+	case MapOrientationStaggered:
+		return m.staggeredPixelToTile(px, py)
+	case MapOrientationHexagonal:
+		return m.HexGrid().PixelToTile(px, py)
+	}
+	return image.Pt(floorDiv(px, m.TileWidth), floorDiv(py, m.TileHeight))
+}
+
+// The bounding box in pixels of tile x,y (tiles taller than the map's tiles extend above this)
+func (m *Map) TileBounds(x, y int) image.Rectangle {
+	p := m.TileToPixel(x, y)
+	return image.Rect(p.X, p.Y, p.X + m.TileWidth, p.Y + m.TileHeight)
+}
+
+// The tiles sharing an edge with tile x,y. These are the four tiles above, right, below & left
+// of the tile for orthogonal & isometric maps, the four diagonally adjacent tiles for staggered
+// maps & the six surrounding tiles for hexagonal maps.
+func (m *Map) Neighbours(x, y int) []image.Point {
+	switch m.orientation {
+	case MapOrientationStaggered:
+		grid := m.staggerGrid()
+		if m.staggerAxis == MapStaggerAxisX {
+			// columns x-1 and x+1 are shifted down relative to this column, or up
+			up, down := y - 1, y
+			if grid.shifted(x) {
+				up, down = y, y + 1
+			}
+			return []image.Point{{x - 1, up}, {x + 1, up}, {x + 1, down}, {x - 1, down}}
+		}
+		left, right := x - 1, x
+		if grid.shifted(y) {
+			left, right = x, x + 1
+		}
+		return []image.Point{{left, y - 1}, {right, y - 1}, {right, y + 1}, {left, y + 1}}
+	case MapOrientationHexagonal:
+		return m.HexGrid().Neighbours(x, y)
+	}
+	return []image.Point{{x, y - 1}, {x + 1, y}, {x, y + 1}, {x - 1, y}}
+}
+
+// Staggered maps are laid out as hexagonal maps with no flat sides
+func (m *Map) staggerGrid() *HexGrid {
+	grid := m.HexGrid()
+	if m.orientation == MapOrientationStaggered {
+		grid.SideLength = 0
+	}
+	return grid
+}
+
+// The staggered tile holding the given pixel, that is the tile whose diamond holds it
+func (m *Map) staggeredPixelToTile(px, py int) image.Point {
+	grid := m.staggerGrid()
+	halfW, halfH := float64(m.TileWidth) / 2, float64(m.TileHeight) / 2
+
+	// a rough guess from the rows & columns of bounding boxes, then the tile around it
+	// whose centre is nearest (diamonds are the shapes of equal distance in this measure)
+	var guess image.Point
+	if m.staggerAxis == MapStaggerAxisX {
+		guess = image.Pt(floorDiv(px, m.TileWidth / 2), floorDiv(py, m.TileHeight))
+	} else {
+		guess = image.Pt(floorDiv(px, m.TileWidth), floorDiv(py, m.TileHeight / 2))
+	}
+
+	best := guess
+	bestDist := math.Inf(1)
+	for y := guess.Y - 1; y <= guess.Y + 1; y++ {
+		for x := guess.X - 1; x <= guess.X + 1; x++ {
+			c := grid.TileCentre(x, y)
+			dist := math.Abs(float64(px) + 0.5 - float64(c.X)) / halfW + math.Abs(float64(py) + 0.5 - float64(c.Y)) / halfH
+			if dist < bestDist {
+				best, bestDist = image.Pt(x, y), dist
+			}
+		}
+	}
+	return best
+}
@@ -0,0 +1,137 @@
+package common
+
+import (
+	"image"
+	"testing"
+)
+
+func TestMapCoordinates(t *testing.T) {
+	cases := []struct {
+		Name       string
+		Options    []MapOption
+		Tile       image.Point
+		Pixel      image.Point            // TileToPixel(Tile)
+		Neighbours []image.Point          // Neighbours(Tile), in order
+		Lookups    map[image.Point]image.Point // pixel -> tile
+	}{
+		{
+			Name: "orthogonal",
+			Options: []MapOption{TileWidth(32), TileHeight(32), OrientationOrthogonal()},
+			Tile: image.Pt(2, 1),
+			Pixel: image.Pt(64, 32),
+			Neighbours: []image.Point{{2, 0}, {3, 1}, {2, 2}, {1, 1}},
+			Lookups: map[image.Point]image.Point{
+				{65, 33}: {2, 1},
+				{0, 0}: {0, 0},
+				{31, 31}: {0, 0},
+				{-1, -1}: {-1, -1},
+			},
+		},
+		{
+			Name: "isometric",
+			Options: []MapOption{TileWidth(32), TileHeight(16), OrientationIsometric()},
+			Tile: image.Pt(1, 0),
+			Pixel: image.Pt(64, 8),
+			Neighbours: []image.Point{{1, -1}, {2, 0}, {1, 1}, {0, 0}},
+			Lookups: map[image.Point]image.Point{
+				{80, 16}: {1, 0},
+				{64, 0}: {0, 0},
+				{63, 1}: {0, 0},
+				{64, 56}: {3, 3},
+				{10, 2}: {-2, 1},
+			},
+		},
+		{
+			Name: "staggered-y-odd",
+			Options: []MapOption{TileWidth(32), TileHeight(16), OrientationStaggered(MapStaggerAxisY, MapStaggerIndexOdd)},
+			Tile: image.Pt(1, 1),
+			Pixel: image.Pt(48, 8),
+			Neighbours: []image.Point{{1, 0}, {2, 0}, {2, 2}, {1, 2}},
+			Lookups: map[image.Point]image.Point{
+				{64, 16}: {1, 1},
+				{16, 8}: {0, 0},
+				{1, 1}: {-1, -1},
+				{34, 24}: {1, 2},
+			},
+		},
+		{
+			Name: "staggered-y-odd-even-row",
+			Options: []MapOption{TileWidth(32), TileHeight(16), OrientationStaggered(MapStaggerAxisY, MapStaggerIndexOdd)},
+			Tile: image.Pt(1, 2),
+			Pixel: image.Pt(32, 16),
+			Neighbours: []image.Point{{0, 1}, {1, 1}, {1, 3}, {0, 3}},
+		},
+		{
+			Name: "staggered-x-even",
+			Options: []MapOption{TileWidth(32), TileHeight(16), OrientationStaggered(MapStaggerAxisX, MapStaggerIndexEven)},
+			Tile: image.Pt(1, 0),
+			Pixel: image.Pt(16, 0),
+			Neighbours: []image.Point{{0, -1}, {2, -1}, {2, 0}, {0, 0}},
+			Lookups: map[image.Point]image.Point{
+				{32, 8}: {1, 0},
+				{16, 16}: {0, 0},
+				{48, 16}: {2, 0},
+				{40, 24}: {1, 1},
+			},
+		},
+		{
+			Name: "hexagonal-y-odd",
+			Options: []MapOption{TileWidth(14), TileHeight(12), OrientationHexagonal(6, MapStaggerAxisY, MapStaggerIndexOdd)},
+			Tile: image.Pt(1, 1),
+			Pixel: image.Pt(21, 9),
+			Neighbours: []image.Point{{2, 1}, {2, 0}, {1, 0}, {0, 1}, {1, 2}, {2, 2}},
+			Lookups: map[image.Point]image.Point{
+				{28, 15}: {1, 1},
+				{7, 6}: {0, 0},
+			},
+		},
+		{
+			Name: "hexagonal-x-even",
+			Options: []MapOption{TileWidth(14), TileHeight(12), OrientationHexagonal(6, MapStaggerAxisX, MapStaggerIndexEven)},
+			Tile: image.Pt(0, 0),
+			Pixel: image.Pt(0, 6),
+			Neighbours: []image.Point{{1, 1}, {1, 0}, {0, -1}, {-1, 0}, {-1, 1}, {0, 1}},
+			Lookups: map[image.Point]image.Point{
+				{7, 12}: {0, 0},
+				{17, 6}: {1, 0},
+			},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			m := NewMap(append([]MapOption{Width(4), Height(4)}, tt.Options...)...)
+
+			if got := m.TileToPixel(tt.Tile.X, tt.Tile.Y); got != tt.Pixel {
+				t.Errorf("expected tile %v at pixel %v got %v", tt.Tile, tt.Pixel, got)
+			}
+
+			bounds := m.TileBounds(tt.Tile.X, tt.Tile.Y)
+			if bounds.Min != tt.Pixel || bounds.Dx() != m.TileWidth || bounds.Dy() != m.TileHeight {
+				t.Errorf("expected tile %v bounds at %v, got %v", tt.Tile, tt.Pixel, bounds)
+			}
+
+			// the middle of a tile's bounding box is always in the tile
+			mid := bounds.Min.Add(image.Pt(m.TileWidth / 2, m.TileHeight / 2))
+			if got := m.PixelToTile(mid.X, mid.Y); got != tt.Tile {
+				t.Errorf("expected middle %v of tile %v to be in the tile, got %v", mid, tt.Tile, got)
+			}
+
+			got := m.Neighbours(tt.Tile.X, tt.Tile.Y)
+			if len(got) != len(tt.Neighbours) {
+				t.Fatalf("expected neighbours %v got %v", tt.Neighbours, got)
+			}
+			for i := range got {
+				if got[i] != tt.Neighbours[i] {
+					t.Fatalf("expected neighbours %v got %v", tt.Neighbours, got)
+				}
+			}
+
+			for pixel, expect := range tt.Lookups {
+				if got := m.PixelToTile(pixel.X, pixel.Y); got != expect {
+					t.Errorf("expected pixel %v in tile %v got %v", pixel, expect, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+)
+
+// GroupLayer holds other layers (including other groups). Opacity, visibility, offset and
+// tint of a group apply to everything in it, see the Effective* functions on each layer.
+type GroupLayer struct {
+	parent     *Map
+	group      *GroupLayer
+	properties map[string]*Property
+
+	layers []Layer // layers in this group, in draw order
+
+	Name       string
+	Opacity    float64
+	Visible    bool
+	OffsetX    int
+	OffsetY    int
+	TintColour *color.RGBA
+}
+
+func newGroupLayer(m *Map, name string) *GroupLayer {
+	return &GroupLayer{
+		parent:     m,
+		Name:       name,
+		Opacity:    1,
+		Visible:    true,
+		properties: make(map[string]*Property),
+		layers:     []Layer{},
+	}
+}
+
+func (g *GroupLayer) UpdateProperties(props ...*Property) {
+	for _, prop := range props {
+		g.properties[prop.Name()] = prop
+	}
+}
+
+// Remove the named properties (if present)
+func (g *GroupLayer) RemoveProperties(names ...string) {
+	for _, name := range names {
+		delete(g.properties, name)
+	}
+}
+
+func (g *GroupLayer) Property(name string) (*Property, bool) {
+	prop, ok := g.properties[name]
+	return prop, ok
+}
+
+func (g *GroupLayer) Properties() []*Property {
+	results := []*Property{}
+	for _, p := range g.properties {
+		results = append(results, p)
+	}
+	return results
+}
+
+// The group this group is in (nil if it's at the top level of the map)
+func (g *GroupLayer) Group() *GroupLayer {
+	return g.group
+}
+
+// Opacity of this group, combined with that of all groups it's in.
+// Nb. this is safe to call on a nil group (giving 1)
+func (g *GroupLayer) EffectiveOpacity() float64 {
+	if g == nil {
+		return 1
+	}
+	return g.Opacity * g.group.EffectiveOpacity()
+}
+
+// Whether this group, and all groups it's in, are visible.
+// Nb. this is safe to call on a nil group (giving true)
+func (g *GroupLayer) EffectiveVisible() bool {
+	if g == nil {
+		return true
+	}
+	return g.Visible && g.group.EffectiveVisible()
+}
+
+// Offset of this group, added to that of all groups it's in.
+// Nb. this is safe to call on a nil group (giving 0,0)
+func (g *GroupLayer) EffectiveOffset() (int, int) {
+	if g == nil {
+		return 0, 0
+	}
+	x, y := g.group.EffectiveOffset()
+	return g.OffsetX + x, g.OffsetY + y
+}
+
+// Tint of this group, multiplied by that of all groups it's in (nil if there's no tint).
+// Nb. this is safe to call on a nil group (giving nil)
+func (g *GroupLayer) EffectiveTint() *color.RGBA {
+	if g == nil {
+		return nil
+	}
+	return multiplyTint(g.TintColour, g.group.EffectiveTint())
+}
+
+// Tile layers directly in this group, in draw order
+func (g *GroupLayer) TileLayers() []*TileLayer {
+	result := []*TileLayer{}
+	for _, layer := range g.layers {
+		if l, ok := layer.(*TileLayer); ok {
+			result = append(result, l)
+		}
+	}
+	return result
+}
+
+// Image layers directly in this group, in draw order
+func (g *GroupLayer) ImageLayers() []*ImageLayer {
+	result := []*ImageLayer{}
+	for _, layer := range g.layers {
+		if l, ok := layer.(*ImageLayer); ok {
+			result = append(result, l)
+		}
+	}
+	return result
+}
+
+// Object layers directly in this group, in draw order
+func (g *GroupLayer) ObjectLayers() []*ObjectLayer {
+	result := []*ObjectLayer{}
+	for _, layer := range g.layers {
+		if l, ok := layer.(*ObjectLayer); ok {
+			result = append(result, l)
+		}
+	}
+	return result
+}
+
+// Groups directly in this group, in draw order
+func (g *GroupLayer) GroupLayers() []*GroupLayer {
+	result := []*GroupLayer{}
+	for _, layer := range g.layers {
+		if l, ok := layer.(*GroupLayer); ok {
+			result = append(result, l)
+		}
+	}
+	return result
+}
+
+// Layers of any kind directly in this group, in draw order (first is drawn first). The slice is
+// a copy, use AddLayer, RemoveLayer etc. to change the layers.
+func (g *GroupLayer) Layers() []Layer {
+	return append([]Layer{}, g.layers...)
+}
+
+// Every layer in this group in draw order, including those nested in child groups.
+// Each group is followed by it's contents.
+func (g *GroupLayer) AllLayers() []Layer {
+	return flattenLayers(g.layers)
+}
+
+// Position of the given layer in this group, -1 if it isn't there
+func (g *GroupLayer) IndexOfLayer(l Layer) int {
+	return indexOfLayer(g.layers, l)
+}
+
+// Insert a layer at the given position in this group (moving it out of wherever it currently is).
+// The layer must have been created for the same map as this group.
+func (g *GroupLayer) InsertLayer(index int, l Layer) error {
+	err := detachLayer(g.parent, g, l)
+	if err != nil {
+		return err
+	}
+	l.setGroup(g)
+	g.layers = insertLayer(g.layers, index, l)
+	return nil
+}
+
+// Add a layer to the top of this group, see InsertLayer
+func (g *GroupLayer) AddLayer(l Layer) error {
+	return g.InsertLayer(len(g.layers), l)
+}
+
+// Remove the layer from this group, returns false if it wasn't there
+func (g *GroupLayer) RemoveLayer(l Layer) bool {
+	var ok bool
+	g.layers, ok = removeLayer(g.layers, l)
+	if ok {
+		l.setGroup(nil)
+	}
+	return ok
+}
+
+// Move one of the layers in this group to the given position
+func (g *GroupLayer) MoveLayer(l Layer, index int) error {
+	layers, ok := removeLayer(g.layers, l)
+	if !ok {
+		return errors.New(fmt.Sprintf("Layer %s is not in group %s", l.LayerName(), g.Name))
+	}
+	g.layers = insertLayer(layers, index, l)
+	return nil
+}
+
+// Set the order of layers in this group, 'order' must hold exactly the layers currently in the group
+func (g *GroupLayer) ReorderLayers(order ...Layer) error {
+	err := checkLayerOrder(g.layers, order)
+	if err != nil {
+		return err
+	}
+	g.layers = append([]Layer{}, order...)
+	return nil
+}
+
+// Add a new tile layer to the top of this group
+func (g *GroupLayer) NewTileLayer(name string) *TileLayer {
+	layer := newTileLayer(g.parent, name)
+	layer.group = g
+	g.layers = append(g.layers, layer)
+	return layer
+}
+
+// Add a new image layer to the top of this group
+func (g *GroupLayer) NewImageLayer(name, imageSource string) *ImageLayer {
+	layer := newImageLayer(g.parent, name, imageSource)
+	layer.group = g
+	g.layers = append(g.layers, layer)
+	return layer
+}
+
+// Add a new object layer to the top of this group
+func (g *GroupLayer) NewObjectLayer(name string) *ObjectLayer {
+	layer := newObjectLayer(g.parent, name)
+	layer.group = g
+	g.layers = append(g.layers, layer)
+	return layer
+}
+
+// Add a new group to the top of this group
+func (g *GroupLayer) NewGroupLayer(name string) *GroupLayer {
+	layer := newGroupLayer(g.parent, name)
+	layer.group = g
+	g.layers = append(g.layers, layer)
+	return layer
+}
+
+// Multiply two tints together, where nil is no tint (that is, white)
+func multiplyTint(a, b *color.RGBA) *color.RGBA {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	return &color.RGBA{
+		R: uint8(uint16(a.R) * uint16(b.R) / 255),
+		G: uint8(uint16(a.G) * uint16(b.G) / 255),
+		B: uint8(uint16(a.B) * uint16(b.B) / 255),
+		A: uint8(uint16(a.A) * uint16(b.A) / 255),
+	}
+}
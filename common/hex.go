@@ -0,0 +1,210 @@
+package common
+
+import (
+	"image"
+	"math"
+)
+
+// HexGrid converts between tile & pixel coordinates of a hexagonal map, and finds neighbouring
+// tiles & distances between tiles. It follows Tiled's layout: tiles are in rows (stagger axis y)
+// or columns (stagger axis x) where every other row (or column) is shifted by half a tile, as
+// picked by the stagger index.
+type HexGrid struct {
+	TileWidth    int
+	TileHeight   int
+	SideLength   int // length of the flat side of each hexagon along the stagger axis
+	StaggerAxis  string
+	StaggerIndex string
+}
+
+// The hex grid of this map (which should be hexagonal)
+func (m *Map) HexGrid() *HexGrid {
+	return &HexGrid{
+		TileWidth: m.TileWidth,
+		TileHeight: m.TileHeight,
+		SideLength: m.HexSideLength,
+		StaggerAxis: m.staggerAxis,
+		StaggerIndex: m.staggerIndex,
+	}
+}
+
+// Neighbouring tiles of a hexagon in cube coordinates
+var hexDirections = [6][2]int{{1, 0}, {1, -1}, {0, -1}, {-1, 0}, {-1, 1}, {0, 1}}
+
+func (h *HexGrid) staggerX() bool {
+	return h.StaggerAxis == MapStaggerAxisX
+}
+
+func (h *HexGrid) staggerEven() bool {
+	return h.StaggerIndex == MapStaggerIndexEven
+}
+
+// Whether the given row (or column) is shifted by half a tile
+func (h *HexGrid) shifted(index int) bool {
+	return (index & 1 == 1) != h.staggerEven()
+}
+
+// Sizes of the parts of a hexagon (as Tiled works them out, tile sizes are rounded down to even)
+func (h *HexGrid) params() (tw, th, sideX, sideY, offsetX, offsetY, columnWidth, rowHeight int) {
+	tw, th = h.TileWidth &^ 1, h.TileHeight &^ 1
+	if h.staggerX() {
+		sideX = h.SideLength
+	} else {
+		sideY = h.SideLength
+	}
+	offsetX = (tw - sideX) / 2
+	offsetY = (th - sideY) / 2
+	return tw, th, sideX, sideY, offsetX, offsetY, offsetX + sideX, offsetY + sideY
+}
+
+// Size in pixels of a map of the given number of tiles
+func (h *HexGrid) Size(width, height int) image.Point {
+	tw, th, sideX, sideY, offsetX, offsetY, columnWidth, rowHeight := h.params()
+	if h.staggerX() {
+		size := image.Pt(width * columnWidth + offsetX, height * (th + sideY))
+		if width > 1 {
+			size.Y += rowHeight
+		}
+		return size
+	}
+	size := image.Pt(width * (tw + sideX), height * rowHeight + offsetY)
+	if height > 1 {
+		size.X += columnWidth
+	}
+	return size
+}
+
+// Pixel position of the top left corner of the bounding box of tile x,y
+func (h *HexGrid) TileToPixel(x, y int) image.Point {
+	tw, th, sideX, sideY, _, _, columnWidth, rowHeight := h.params()
+	if h.staggerX() {
+		p := image.Pt(x * columnWidth, y * (th + sideY))
+		if h.shifted(x) {
+			p.Y += rowHeight
+		}
+		return p
+	}
+	p := image.Pt(x * (tw + sideX), y * rowHeight)
+	if h.shifted(y) {
+		p.X += columnWidth
+	}
+	return p
+}
+
+// Pixel position of the centre of tile x,y
+func (h *HexGrid) TileCentre(x, y int) image.Point {
+	return h.TileToPixel(x, y).Add(image.Pt((h.TileWidth &^ 1) / 2, (h.TileHeight &^ 1) / 2))
+}
+
+// The tile holding the given pixel, that is the tile with the nearest centre
+func (h *HexGrid) PixelToTile(px, py int) image.Point {
+	tw, th, sideX, sideY, offsetX, offsetY, columnWidth, rowHeight := h.params()
+	x, y := float64(px), float64(py)
+
+	if h.staggerX() {
+		if h.staggerEven() {
+			x -= float64(tw)
+		} else {
+			x -= float64(offsetX)
+		}
+	} else {
+		if h.staggerEven() {
+			y -= float64(th)
+		} else {
+			y -= float64(offsetY)
+		}
+	}
+
+	// start with a grid aligned tile two rows & columns wide, then pick whichever of the
+	// hexagons overlapping it has it's centre nearest the pixel
+	refX := int(math.Floor(x / float64(columnWidth * 2)))
+	refY := int(math.Floor(y / float64(rowHeight * 2)))
+	relX := x - float64(refX * columnWidth * 2)
+	relY := y - float64(refY * rowHeight * 2)
+
+	var centres [4][2]float64
+	var offsets [4][2]int
+	if h.staggerX() {
+		refX *= 2
+		if h.staggerEven() {
+			refX++
+		}
+		left := float64(sideX) / 2
+		cx, cy := left + float64(columnWidth), float64(th) / 2
+		centres = [4][2]float64{{left, cy}, {cx, cy - float64(rowHeight)}, {cx, cy + float64(rowHeight)}, {cx + float64(columnWidth), cy}}
+		offsets = [4][2]int{{0, 0}, {1, -1}, {1, 0}, {2, 0}}
+	} else {
+		refY *= 2
+		if h.staggerEven() {
+			refY++
+		}
+		top := float64(sideY) / 2
+		cx, cy := float64(tw) / 2, top + float64(rowHeight)
+		centres = [4][2]float64{{cx, top}, {cx - float64(columnWidth), cy}, {cx + float64(columnWidth), cy}, {cx, cy + float64(rowHeight)}}
+		offsets = [4][2]int{{0, 0}, {-1, 1}, {0, 1}, {0, 2}}
+	}
+
+	nearest := 0
+	best := math.Inf(1)
+	for i, c := range centres {
+		dist := (c[0] - relX) * (c[0] - relX) + (c[1] - relY) * (c[1] - relY)
+		if dist < best {
+			best = dist
+			nearest = i
+		}
+	}
+	return image.Pt(refX + offsets[nearest][0], refY + offsets[nearest][1])
+}
+
+// The six tiles next to tile x,y
+func (h *HexGrid) Neighbours(x, y int) []image.Point {
+	q, r := h.toCube(x, y)
+	result := make([]image.Point, 0, len(hexDirections))
+	for _, d := range hexDirections {
+		result = append(result, h.fromCube(q + d[0], r + d[1]))
+	}
+	return result
+}
+
+// Number of steps between two tiles, moving between neighbouring tiles
+func (h *HexGrid) Distance(a, b image.Point) int {
+	aq, ar := h.toCube(a.X, a.Y)
+	bq, br := h.toCube(b.X, b.Y)
+	dq, dr := aq - bq, ar - br
+	return (abs(dq) + abs(dr) + abs(dq + dr)) / 2
+}
+
+// Convert tile x,y to (axial) cube coordinates q,r (the third coordinate is -q-r)
+func (h *HexGrid) toCube(x, y int) (int, int) {
+	if h.staggerX() {
+		if h.staggerEven() {
+			return x, y - (x + (x & 1)) / 2
+		}
+		return x, y - (x - (x & 1)) / 2
+	}
+	if h.staggerEven() {
+		return x - (y + (y & 1)) / 2, y
+	}
+	return x - (y - (y & 1)) / 2, y
+}
+
+// Convert (axial) cube coordinates q,r to tile x,y
+func (h *HexGrid) fromCube(q, r int) image.Point {
+	if h.staggerX() {
+		if h.staggerEven() {
+			return image.Pt(q, r + (q + (q & 1)) / 2)
+		}
+		return image.Pt(q, r + (q - (q & 1)) / 2)
+	}
+	if h.staggerEven() {
+		return image.Pt(q + (r + (r & 1)) / 2, r)
+	}
+	return image.Pt(q + (r - (r & 1)) / 2, r)
+}
+
+func abs(in int) int {
+	if in < 0 {
+		return -in
+	}
+	return in
+}
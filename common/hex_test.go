@@ -0,0 +1,126 @@
+package common
+
+import (
+	"image"
+	"sort"
+	"testing"
+)
+
+func sortedPoints(in []image.Point) []image.Point {
+	out := append([]image.Point{}, in...)
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Y != out[j].Y {
+			return out[i].Y < out[j].Y
+		}
+		return out[i].X < out[j].X
+	})
+	return out
+}
+
+func TestHexGrid(t *testing.T) {
+	cases := []struct {
+		Name       string
+		Axis       string
+		Index      string
+		Size       image.Point    // of a 3x3 map
+		Pixel      image.Point    // top left of tile 1,1
+		Neighbours []image.Point // of tile 1,1
+	}{
+		{
+			Name: "y-odd",
+			Axis: MapStaggerAxisY, Index: MapStaggerIndexOdd,
+			Size: image.Pt(3*14+7, 3*9+3),
+			Pixel: image.Pt(14+7, 9),
+			Neighbours: []image.Point{{1, 0}, {2, 0}, {0, 1}, {2, 1}, {1, 2}, {2, 2}},
+		},
+		{
+			Name: "y-even",
+			Axis: MapStaggerAxisY, Index: MapStaggerIndexEven,
+			Size: image.Pt(3*14+7, 3*9+3),
+			Pixel: image.Pt(14, 9),
+			Neighbours: []image.Point{{0, 0}, {1, 0}, {0, 1}, {2, 1}, {0, 2}, {1, 2}},
+		},
+		{
+			Name: "x-odd",
+			Axis: MapStaggerAxisX, Index: MapStaggerIndexOdd,
+			Size: image.Pt(3*10+4, 3*12+6),
+			Pixel: image.Pt(10, 12+6),
+			Neighbours: []image.Point{{1, 0}, {0, 1}, {2, 1}, {1, 2}, {0, 2}, {2, 2}},
+		},
+		{
+			Name: "x-even",
+			Axis: MapStaggerAxisX, Index: MapStaggerIndexEven,
+			Size: image.Pt(3*10+4, 3*12+6),
+			Pixel: image.Pt(10, 12),
+			Neighbours: []image.Point{{0, 0}, {1, 0}, {2, 0}, {0, 1}, {2, 1}, {1, 2}},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			// 14x12 tiles with 6 pixel sides, as Tiled's default hexagonal map
+			m := NewMap(Width(3), Height(3), TileWidth(14), TileHeight(12), OrientationHexagonal(6, tt.Axis, tt.Index))
+			grid := m.HexGrid()
+
+			if got := grid.Size(3, 3); got != tt.Size {
+				t.Errorf("expected size %v got %v", tt.Size, got)
+			}
+			if got := grid.TileToPixel(1, 1); got != tt.Pixel {
+				t.Errorf("expected tile 1,1 at %v got %v", tt.Pixel, got)
+			}
+
+			got := sortedPoints(grid.Neighbours(1, 1))
+			expect := sortedPoints(tt.Neighbours)
+			if len(got) != len(expect) {
+				t.Fatalf("expected neighbours %v got %v", expect, got)
+			}
+			for i := range got {
+				if got[i] != expect[i] {
+					t.Fatalf("expected neighbours %v got %v", expect, got)
+				}
+			}
+			for _, n := range got {
+				if d := grid.Distance(image.Pt(1, 1), n); d != 1 {
+					t.Errorf("expected neighbour %v at distance 1, got %d", n, d)
+				}
+			}
+
+			// every tile centre (including negative tiles) maps back to it's tile
+			for y := -3; y < 4; y++ {
+				for x := -3; x < 4; x++ {
+					c := grid.TileCentre(x, y)
+					if got := grid.PixelToTile(c.X, c.Y); got != image.Pt(x, y) {
+						t.Errorf("centre %v of tile %d,%d maps to tile %v", c, x, y, got)
+					}
+					if d := grid.Distance(image.Pt(x, y), image.Pt(x, y)); d != 0 {
+						t.Errorf("expected distance 0 from tile to itself, got %d", d)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestHexGridDistance(t *testing.T) {
+	grid := NewMap(OrientationHexagonal(6, MapStaggerAxisY, MapStaggerIndexOdd)).HexGrid()
+
+	cases := []struct {
+		A, B   image.Point
+		Expect int
+	}{
+		{image.Pt(0, 0), image.Pt(3, 0), 3},
+		{image.Pt(0, 0), image.Pt(0, 2), 2},
+		{image.Pt(0, 0), image.Pt(1, 2), 2},
+		{image.Pt(0, 0), image.Pt(2, 4), 4},
+		{image.Pt(2, 1), image.Pt(-1, -1), 4},
+	}
+
+	for _, tt := range cases {
+		if got := grid.Distance(tt.A, tt.B); got != tt.Expect {
+			t.Errorf("distance %v to %v: expected %d got %d", tt.A, tt.B, tt.Expect, got)
+		}
+		if got := grid.Distance(tt.B, tt.A); got != tt.Expect {
+			t.Errorf("distance %v to %v: expected %d got %d", tt.B, tt.A, tt.Expect, got)
+		}
+	}
+}
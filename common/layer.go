@@ -0,0 +1,161 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+)
+
+// Layer is any of a TileLayer, ImageLayer, ObjectLayer or GroupLayer.
+//
+// Layers are kept in a Map (or GroupLayer) in draw order, that is the first layer is drawn
+// first (bottom) and the last is drawn last (top).
+type Layer interface {
+	LayerName() string
+
+	// The map this layer belongs to & the group it's in (nil if it's at the top level)
+	Map() *Map
+	Group() *GroupLayer
+
+	EffectiveOpacity() float64
+	EffectiveVisible() bool
+	EffectiveOffset() (int, int)
+	EffectiveTint() *color.RGBA
+
+	UpdateProperties(props ...*Property)
+	Property(name string) (*Property, bool)
+	Properties() []*Property
+
+	setGroup(*GroupLayer)
+}
+
+func (t *TileLayer) LayerName() string {
+	return t.Name
+}
+
+func (t *TileLayer) Map() *Map {
+	return t.parent
+}
+
+func (t *TileLayer) setGroup(g *GroupLayer) {
+	t.group = g
+}
+
+func (m *ImageLayer) LayerName() string {
+	return m.Name
+}
+
+func (m *ImageLayer) Map() *Map {
+	return m.parent
+}
+
+func (m *ImageLayer) setGroup(g *GroupLayer) {
+	m.group = g
+}
+
+func (o *ObjectLayer) LayerName() string {
+	return o.Name
+}
+
+func (o *ObjectLayer) Map() *Map {
+	return o.parent
+}
+
+func (o *ObjectLayer) setGroup(g *GroupLayer) {
+	o.group = g
+}
+
+func (g *GroupLayer) LayerName() string {
+	return g.Name
+}
+
+func (g *GroupLayer) Map() *Map {
+	return g.parent
+}
+
+func (g *GroupLayer) setGroup(grp *GroupLayer) {
+	g.group = grp
+}
+
+// All layers in the given list, with the contents of each group following the group itself
+func flattenLayers(in []Layer) []Layer {
+	result := []Layer{}
+	for _, layer := range in {
+		result = append(result, layer)
+		if grp, ok := layer.(*GroupLayer); ok {
+			result = append(result, flattenLayers(grp.layers)...)
+		}
+	}
+	return result
+}
+
+// Position of the given layer in the list, or -1 if it isn't present
+func indexOfLayer(in []Layer, l Layer) int {
+	for i, layer := range in {
+		if layer == l {
+			return i
+		}
+	}
+	return -1
+}
+
+// Insert the layer into the list at index (clamped to the list bounds)
+func insertLayer(in []Layer, index int, l Layer) []Layer {
+	if index < 0 {
+		index = 0
+	}
+	if index > len(in) {
+		index = len(in)
+	}
+	result := append([]Layer{}, in[:index]...)
+	result = append(result, l)
+	return append(result, in[index:]...)
+}
+
+// Remove the layer from the list, if present. The list given is left as it is (it may be one
+// returned by Layers).
+func removeLayer(in []Layer, l Layer) ([]Layer, bool) {
+	i := indexOfLayer(in, l)
+	if i < 0 {
+		return in, false
+	}
+	result := append([]Layer{}, in[:i]...)
+	return append(result, in[i+1:]...), true
+}
+
+// Check the given order holds exactly the layers in the list, in any order
+func checkLayerOrder(in []Layer, order []Layer) error {
+	if len(in) != len(order) {
+		return errors.New(fmt.Sprintf("Expected %d layers in new order, got %d", len(in), len(order)))
+	}
+	seen := map[Layer]bool{}
+	for _, layer := range order {
+		if seen[layer] || indexOfLayer(in, layer) < 0 {
+			return errors.New(fmt.Sprintf("Layer %s appears more than once or isn't in the list", layer.LayerName()))
+		}
+		seen[layer] = true
+	}
+	return nil
+}
+
+// Check the layer can be placed in the given map & group, and take it out of wherever
+// it currently is.
+func detachLayer(m *Map, g *GroupLayer, l Layer) error {
+	if l.Map() != m {
+		return errors.New(fmt.Sprintf("Layer %s belongs to a different map", l.LayerName()))
+	}
+	if grp, ok := l.(*GroupLayer); ok {
+		for parent := g; parent != nil; parent = parent.group {
+			if parent == grp {
+				return errors.New(fmt.Sprintf("Group %s cannot be placed inside itself", grp.Name))
+			}
+		}
+	}
+
+	if l.Group() != nil {
+		l.Group().RemoveLayer(l)
+	} else {
+		m.RemoveLayer(l)
+	}
+	return nil
+}
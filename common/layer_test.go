@@ -0,0 +1,134 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func layerNames(in []Layer) []string {
+	names := []string{}
+	for _, layer := range in {
+		names = append(names, layer.LayerName())
+	}
+	return names
+}
+
+func TestLayerOperations(t *testing.T) {
+	m := NewMap()
+	a := m.NewTileLayer("a")
+	b := m.NewImageLayer("b", "b.png")
+	c := m.NewObjectLayer("c")
+	g := m.NewGroupLayer("g")
+	d := g.NewTileLayer("d")
+
+	cases := []struct {
+		Name      string
+		Operation func() error
+		Expect    []string
+	}{
+		{"initial", func() error { return nil }, []string{"a", "b", "c", "g", "d"}},
+		{"move to bottom", func() error { return m.MoveLayer(c, 0) }, []string{"c", "a", "b", "g", "d"}},
+		{"move past end", func() error { return m.MoveLayer(c, 100) }, []string{"a", "b", "g", "d", "c"}},
+		{"into group", func() error { return g.InsertLayer(0, a) }, []string{"b", "g", "a", "d", "c"}},
+		{"out of group", func() error { return m.InsertLayer(0, d) }, []string{"d", "b", "g", "a", "c"}},
+		{"reorder", func() error { return m.ReorderLayers(g, c, b, d) }, []string{"g", "a", "c", "b", "d"}},
+		{"remove", func() error { m.RemoveLayer(b); return nil }, []string{"g", "a", "c", "d"}},
+	}
+
+	for _, test := range cases {
+		err := test.Operation()
+		if err != nil {
+			t.Error(test.Name, err)
+		}
+
+		result := layerNames(m.AllLayers())
+		if !reflect.DeepEqual(result, test.Expect) {
+			t.Error(test.Name, "expected", test.Expect, "got", result)
+		}
+	}
+
+	if a.Group() != g || d.Group() != nil {
+		t.Error("expected layer groups to follow the layers")
+	}
+	if err := m.ReorderLayers(g, c); err == nil {
+		t.Error("expected error reordering with missing layers")
+	}
+	if err := g.InsertLayer(0, g); err == nil {
+		t.Error("expected error putting a group inside itself")
+	}
+	if err := m.AddLayer(NewMap().NewTileLayer("other")); err == nil {
+		t.Error("expected error adding a layer from another map")
+	}
+}
+
+func TestLayersCopied(t *testing.T) {
+	m := NewMap()
+	a := m.NewTileLayer("a")
+	m.NewTileLayer("b")
+	m.NewTileLayer("c")
+
+	layers := m.Layers()
+	for _, layer := range layers {
+		if layer.LayerName() == "a" {
+			m.RemoveLayer(layer) // removing while ranging over Layers() is fine
+		}
+	}
+	if names := layerNames(layers); !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Error("expected layers returned earlier to be unchanged, got", names)
+	}
+	if names := layerNames(m.Layers()); !reflect.DeepEqual(names, []string{"b", "c"}) {
+		t.Error("expected a to be removed, got", names)
+	}
+
+	m.Layers()[0] = a
+	if names := layerNames(m.Layers()); !reflect.DeepEqual(names, []string{"b", "c"}) {
+		t.Error("expected changing the slice returned not to change the map, got", names)
+	}
+
+	g := m.NewGroupLayer("g")
+	d := g.NewTileLayer("d")
+	g.NewTileLayer("e")
+	g.NewTileLayer("f")
+
+	layers = g.Layers()
+	if err := g.MoveLayer(d, 2); err != nil {
+		t.Fatal(err)
+	}
+	if names := layerNames(layers); !reflect.DeepEqual(names, []string{"d", "e", "f"}) {
+		t.Error("expected group layers returned earlier to be unchanged by a move, got", names)
+	}
+	g.RemoveLayer(d)
+	if names := layerNames(layers); !reflect.DeepEqual(names, []string{"d", "e", "f"}) {
+		t.Error("expected group layers returned earlier to be unchanged by a removal, got", names)
+	}
+	if names := layerNames(g.Layers()); !reflect.DeepEqual(names, []string{"e", "f"}) {
+		t.Error("expected d to be removed, got", names)
+	}
+}
+
+func TestObjectsCopied(t *testing.T) {
+	m := NewMap()
+	layer := m.NewObjectLayer("things")
+	layer.AddObjects(NewObject("a"), NewObject("b"), NewObject("c"), NewObject("d"))
+
+	objects := layer.Objects()
+	for _, obj := range layer.Objects() {
+		if obj.Name == "a" || obj.Name == "b" {
+			layer.RemoveObject(obj) // removing while ranging over Objects() is fine
+		}
+	}
+
+	names := func(in []*Object) []string {
+		result := []string{}
+		for _, obj := range in {
+			result = append(result, obj.Name)
+		}
+		return result
+	}
+	if got := names(objects); !reflect.DeepEqual(got, []string{"a", "b", "c", "d"}) {
+		t.Error("expected objects returned earlier to be unchanged, got", got)
+	}
+	if got := names(layer.Objects()); !reflect.DeepEqual(got, []string{"c", "d"}) {
+		t.Error("expected a & b to be removed, got", got)
+	}
+}
@@ -1,11 +1,13 @@
 package common
 
 import (
+	"image"
 	"image/color"
 )
 
 type ImageLayer struct {
 	parent  *Map
+	group   *GroupLayer
 	Name    string
 	Opacity float64
 	Visible bool
@@ -17,15 +19,49 @@ type ImageLayer struct {
 	Height int
 	Format string
 	TransparentColour *color.RGBA
+	TintColour *color.RGBA
 	properties map[string]*Property
 }
 
+// The group this layer is in (nil if it's at the top level of the map)
+func (m *ImageLayer) Group() *GroupLayer {
+	return m.group
+}
+
+// Opacity of this layer, combined with that of all groups it's in
+func (m *ImageLayer) EffectiveOpacity() float64 {
+	return m.Opacity * m.group.EffectiveOpacity()
+}
+
+// Whether this layer, and all groups it's in, are visible
+func (m *ImageLayer) EffectiveVisible() bool {
+	return m.Visible && m.group.EffectiveVisible()
+}
+
+// Offset of this layer, added to that of all groups it's in
+func (m *ImageLayer) EffectiveOffset() (int, int) {
+	x, y := m.group.EffectiveOffset()
+	return m.OffsetX + x, m.OffsetY + y
+}
+
+// Tint of this layer, multiplied by that of all groups it's in (nil if there's no tint)
+func (m *ImageLayer) EffectiveTint() *color.RGBA {
+	return multiplyTint(m.TintColour, m.group.EffectiveTint())
+}
+
 func (m *ImageLayer) UpdateProperties(props ...*Property) {
 	for _, prop := range props {
 		m.properties[prop.Name()] = prop
 	}
 }
 
+// Remove the named properties (if present)
+func (m *ImageLayer) RemoveProperties(names ...string) {
+	for _, name := range names {
+		delete(m.properties, name)
+	}
+}
+
 func (m *ImageLayer) Property(name string) (*Property, bool) {
 	prop, ok := m.properties[name]
 	return prop, ok
@@ -41,21 +77,56 @@ func (m *ImageLayer) Properties() []*Property {
 
 type TileLayer struct {
 	parent     *Map
-	tiles      []*Tile
+	group      *GroupLayer
+	cells      cellStore
 	Name       string
 	Opacity    float64
 	Visible    bool
 	OffsetX    int
 	OffsetY    int
+	TintColour *color.RGBA
 	properties map[string]*Property
+
+	// How tile data is written out, one of the DataEncoding* and (optionally) one of the
+	// DataCompression* constants. Set to how the layer was encoded when it was read in.
+	Encoding    string
+	Compression string
 }
 
+// The group this layer is in (nil if it's at the top level of the map)
+func (t *TileLayer) Group() *GroupLayer {
+	return t.group
+}
+
+// Opacity of this layer, combined with that of all groups it's in
+func (t *TileLayer) EffectiveOpacity() float64 {
+	return t.Opacity * t.group.EffectiveOpacity()
+}
+
+// Whether this layer, and all groups it's in, are visible
+func (t *TileLayer) EffectiveVisible() bool {
+	return t.Visible && t.group.EffectiveVisible()
+}
+
+// Offset of this layer, added to that of all groups it's in
+func (t *TileLayer) EffectiveOffset() (int, int) {
+	x, y := t.group.EffectiveOffset()
+	return t.OffsetX + x, t.OffsetY + y
+}
+
+// Tint of this layer, multiplied by that of all groups it's in (nil if there's no tint)
+func (t *TileLayer) EffectiveTint() *color.RGBA {
+	return multiplyTint(t.TintColour, t.group.EffectiveTint())
+}
+
+// Width of the layer's Bounds() in tiles
 func (t *TileLayer) Width() int {
-	return t.parent.Width
+	return t.Bounds().Dx()
 }
 
+// Height of the layer's Bounds() in tiles
 func (t *TileLayer) Height() int {
-	return t.parent.Height
+	return t.Bounds().Dy()
 }
 
 func (t *TileLayer) UpdateProperties(props ...*Property) {
@@ -64,6 +135,13 @@ func (t *TileLayer) UpdateProperties(props ...*Property) {
 	}
 }
 
+// Remove the named properties (if present)
+func (t *TileLayer) RemoveProperties(names ...string) {
+	for _, name := range names {
+		delete(t.properties, name)
+	}
+}
+
 func (t *TileLayer) Property(name string) (*Property, bool) {
 	prop, ok := t.properties[name]
 	return prop, ok
@@ -77,39 +155,92 @@ func (t *TileLayer) Properties() []*Property {
 	return results
 }
 
+// Global tile ids for each x,y in the layer's Bounds(), including any flip flags.
+// Empty cells have the id 0.
 func (t *TileLayer) TileIds() [][]int {
-	result := make([][]int, t.parent.Height)
-	for y := 0; y < t.parent.Height; y++ {
-		result[y] = make([]int, t.parent.Width)
-
-		for x := 0; x < t.parent.Width; x++ {
-			tile := t.Get(x, y)
-			if tile == nil {
-				result[y][x] = 0
-				continue
-			}
-			result[y][x] = tile.GlobalID()
+	return t.TileIdsIn(t.Bounds())
+}
+
+// Global tile ids for each x,y in the given region, including any flip flags.
+// Empty cells have the id 0.
+func (t *TileLayer) TileIdsIn(region image.Rectangle) [][]int {
+	result := make([][]int, region.Dy())
+	for y := 0; y < region.Dy(); y++ {
+		result[y] = make([]int, region.Dx())
+
+		for x := 0; x < region.Dx(); x++ {
+			result[y][x] = int(t.GetCell(region.Min.X + x, region.Min.Y + y).GlobalID())
 		}
 	}
 	return result
 }
 
+// Whether this layer is part of an infinite map, in which case tiles may be placed at any
+// x,y (including negative values).
+func (t *TileLayer) Infinite() bool {
+	_, ok := t.cells.(*chunkedStore)
+	return ok
+}
+
+// The region of the layer holding tiles. For a fixed size map this is the whole map, for an
+// infinite map this covers every chunk holding at least one tile.
+func (t *TileLayer) Bounds() image.Rectangle {
+	return t.cells.bounds()
+}
+
+// Regions of the layer that together hold all of it's tiles. For a fixed size map this is
+// the whole map, for an infinite map each is a chunk holding at least one tile.
+func (t *TileLayer) Chunks() []image.Rectangle {
+	return t.cells.chunks()
+}
+
+// Get the tile at x,y (nil if there is no tile or x,y is out of bounds)
 func (t *TileLayer) Get(x, y int) *Tile {
-	if x < 0 || x >= t.parent.Width {
-		return nil
-	}
-	if y < 0 || y >= t.parent.Height {
-		return nil
-	}
-	return t.tiles[y * t.parent.Height + x]
+	return t.GetCell(x, y).Tile
+}
+
+// Get the cell at x,y, that is the tile along with how it's flipped
+func (t *TileLayer) GetCell(x, y int) Cell {
+	return t.cells.get(x, y)
 }
 
+// Put a tile at x,y (unflipped)
 func (t *TileLayer) Put(x, y int, tile *Tile) {
-	if x < 0 || x >= t.parent.Width {
-		return
+	t.PutCell(x, y, Cell{Tile: tile})
+}
+
+// Put a cell at x,y, that is a tile along with how it's flipped.
+// For a fixed size map, cells outside of the map are ignored.
+func (t *TileLayer) PutCell(x, y int, cell Cell) {
+	t.cells.put(x, y, cell)
+}
+
+// Move all cells into the given store, shifted by dx,dy
+func (t *TileLayer) moveCells(to cellStore, dx, dy int) {
+	for _, rect := range t.cells.chunks() {
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				cell := t.cells.get(x, y)
+				if !cell.IsEmpty() {
+					to.put(x + dx, y + dy, cell)
+				}
+			}
+		}
 	}
-	if y < 0 || y >= t.parent.Width {
-		return
+	t.cells = to
+}
+
+// The smallest region holding every tile in the layer (empty if there are no tiles)
+func (t *TileLayer) usedBounds() image.Rectangle {
+	result := image.Rectangle{}
+	for _, rect := range t.cells.chunks() {
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				if !t.cells.get(x, y).IsEmpty() {
+					result = result.Union(image.Rect(x, y, x + 1, y + 1))
+				}
+			}
+		}
 	}
-	t.tiles[y * t.parent.Height + x] = tile
-}
\ No newline at end of file
+	return result
+}
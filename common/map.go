@@ -1,6 +1,9 @@
 package common
 
 import (
+	"errors"
+	"fmt"
+	"image"
 	"image/color"
 )
 
@@ -25,14 +28,14 @@ type Map struct {
 	TiledVersion string
 
 	nextObjectId int
+	infinite     bool
 	orientation  string
 	renderOrder  string
 	staggerAxis  string
 	staggerIndex string
 
 	tilesets    []*Tileset
-	tileLayers  []*TileLayer
-	imageLayers []*ImageLayer
+	layers      []Layer // top level layers, in draw order
 	properties  map[string]*Property
 }
 
@@ -42,20 +45,105 @@ func (m *Map) FinalizeIDs() {
 
 	for _, tileset := range m.tilesets {
 		tileset.FirstGID = globaltilecount + 1 // Note that global tile id 0 is reserved for 'no tile'
+		tileset.FinalizeIDs()
+		globaltilecount += tileset.NextTileID()
+	}
 
-		terrainCount := 0
-		for _, terrain := range tileset.terrain {
-			terrain.Id = terrainCount
-			terrainCount += 1
+	// Objects keep any Id they already have, new objects are given the next free Id
+	maxObjectId := 0
+	for _, layer := range m.allObjectLayers() {
+		for _, obj := range layer.objects {
+			if obj.Id > maxObjectId {
+				maxObjectId = obj.Id
+			}
+		}
+	}
+	if m.nextObjectId <= maxObjectId {
+		m.nextObjectId = maxObjectId + 1
+	}
+	for _, layer := range m.allObjectLayers() {
+		for _, obj := range layer.objects {
+			if obj.Id < 1 {
+				obj.Id = m.nextObjectId
+				m.nextObjectId += 1
+			}
 		}
+	}
+}
+
+// The Id that will be given to the next new object
+func (m *Map) NextObjectID() int {
+	return m.nextObjectId
+}
+
+// Set the Id that will be given to the next new object
+func (m *Map) SetNextObjectID(in int) {
+	m.nextObjectId = in
+}
+
+// Whether the map is infinite, that is it's tile layers can hold tiles at any x,y
+// (including negative values) rather than being limited to Width x Height.
+func (m *Map) Infinite() bool {
+	return m.infinite
+}
 
-		localtilecount := 0
-		for _, tile := range tileset.tiles {
-			tile.Id = localtilecount
-			localtilecount += 1
+// Convert the map to or from an infinite map.
+//
+// Converting to a fixed size map resizes the map to fit all of the tiles in it's tile layers;
+// everything is moved so that the top left tile is at 0,0 (including objects, which are shifted
+// by the same number of tiles).
+func (m *Map) SetInfinite(infinite bool) {
+	if infinite == m.infinite {
+		return
+	}
+
+	tileLayers := []*TileLayer{}
+	for _, layer := range m.AllLayers() {
+		if l, ok := layer.(*TileLayer); ok {
+			tileLayers = append(tileLayers, l)
+		}
+	}
+
+	m.infinite = infinite
+	if infinite {
+		for _, layer := range tileLayers {
+			layer.moveCells(m.newCellStore(), 0, 0)
 		}
-		globaltilecount += localtilecount
+		return
 	}
+
+	bounds := image.Rectangle{}
+	for _, layer := range tileLayers {
+		bounds = bounds.Union(layer.usedBounds())
+	}
+	if !bounds.Empty() {
+		m.Width = bounds.Dx()
+		m.Height = bounds.Dy()
+	}
+
+	for _, layer := range tileLayers {
+		layer.moveCells(m.newCellStore(), -bounds.Min.X, -bounds.Min.Y)
+	}
+
+	// Objects are positioned in pixels, so we move them by the same number of tiles
+	dx, dy := -bounds.Min.X * m.TileWidth, -bounds.Min.Y * m.TileHeight
+	if m.orientation == MapOrientationIsometric { // isometric objects are in tile height units on both axes
+		dx = -bounds.Min.X * m.TileHeight
+	}
+	for _, layer := range m.allObjectLayers() {
+		for _, obj := range layer.objects {
+			obj.X += float64(dx)
+			obj.Y += float64(dy)
+		}
+	}
+}
+
+// Storage for a new tile layer in this map
+func (m *Map) newCellStore() cellStore {
+	if m.infinite {
+		return newChunkedStore(DefaultChunkSize)
+	}
+	return newFixedStore(m.Width, m.Height)
 }
 
 func (m *Map) Orientation() string {
@@ -91,12 +179,37 @@ func (m *Map) Properties() []*Property {
 	return results
 }
 
+// Top level tile layers, in draw order
 func (m *Map) TileLayers() []*TileLayer {
-	return m.tileLayers
+	result := []*TileLayer{}
+	for _, layer := range m.layers {
+		if l, ok := layer.(*TileLayer); ok {
+			result = append(result, l)
+		}
+	}
+	return result
 }
 
+// Top level image layers, in draw order
 func (m *Map) ImageLayers() []*ImageLayer {
-	return m.imageLayers
+	result := []*ImageLayer{}
+	for _, layer := range m.layers {
+		if l, ok := layer.(*ImageLayer); ok {
+			result = append(result, l)
+		}
+	}
+	return result
+}
+
+// Top level object layers, in draw order
+func (m *Map) ObjectLayers() []*ObjectLayer {
+	result := []*ObjectLayer{}
+	for _, layer := range m.layers {
+		if l, ok := layer.(*ObjectLayer); ok {
+			result = append(result, l)
+		}
+	}
+	return result
 }
 
 func (m *Map) UpdateProperties(props ...*Property) {
@@ -105,36 +218,162 @@ func (m *Map) UpdateProperties(props ...*Property) {
 	}
 }
 
+// Remove the named properties (if present)
+func (m *Map) RemoveProperties(names ...string) {
+	for _, name := range names {
+		delete(m.properties, name)
+	}
+}
+
+// Top level group layers, in draw order
+func (m *Map) GroupLayers() []*GroupLayer {
+	result := []*GroupLayer{}
+	for _, layer := range m.layers {
+		if l, ok := layer.(*GroupLayer); ok {
+			result = append(result, l)
+		}
+	}
+	return result
+}
+
+// Top level layers of any kind, in draw order (first is drawn first). The slice is a copy,
+// use AddLayer, RemoveLayer etc. to change the layers.
+func (m *Map) Layers() []Layer {
+	return append([]Layer{}, m.layers...)
+}
+
+// Every layer in the map in draw order, including those nested in groups.
+// Each group is followed by it's contents.
+func (m *Map) AllLayers() []Layer {
+	return flattenLayers(m.layers)
+}
+
+// Position of the given layer in the top level layers, -1 if it isn't there
+func (m *Map) IndexOfLayer(l Layer) int {
+	return indexOfLayer(m.layers, l)
+}
+
+// Insert a layer at the given position in the top level layers (moving it out of any group
+// it's currently in). The layer must have been created for this map.
+func (m *Map) InsertLayer(index int, l Layer) error {
+	err := detachLayer(m, nil, l)
+	if err != nil {
+		return err
+	}
+	l.setGroup(nil)
+	m.layers = insertLayer(m.layers, index, l)
+	return nil
+}
+
+// Add a layer to the top of the top level layers, see InsertLayer
+func (m *Map) AddLayer(l Layer) error {
+	return m.InsertLayer(len(m.layers), l)
+}
+
+// Remove the layer from the top level layers, returns false if it wasn't there
+func (m *Map) RemoveLayer(l Layer) bool {
+	var ok bool
+	m.layers, ok = removeLayer(m.layers, l)
+	return ok
+}
+
+// Move one of the top level layers to the given position
+func (m *Map) MoveLayer(l Layer, index int) error {
+	if !m.RemoveLayer(l) {
+		return errors.New(fmt.Sprintf("Layer %s is not a top level layer", l.LayerName()))
+	}
+	m.layers = insertLayer(m.layers, index, l)
+	return nil
+}
+
+// Set the order of the top level layers, 'order' must hold exactly the current top level layers
+func (m *Map) ReorderLayers(order ...Layer) error {
+	err := checkLayerOrder(m.layers, order)
+	if err != nil {
+		return err
+	}
+	m.layers = append([]Layer{}, order...)
+	return nil
+}
+
+// Add a new tile layer to the top of the map
 func (m *Map) NewTileLayer(name string) *TileLayer {
-	tiles := make([]*Tile, m.Height * m.Height + m.Width)
-	layer := &TileLayer{
+	layer := newTileLayer(m, name)
+	m.layers = append(m.layers, layer)
+	return layer
+}
+
+// Add a new image layer to the top of the map
+func (m *Map) NewImageLayer(name, imageSource string) *ImageLayer {
+	layer := newImageLayer(m, name, imageSource)
+	m.layers = append(m.layers, layer)
+	return layer
+}
+
+// Add a new object layer to the top of the map
+func (m *Map) NewObjectLayer(name string) *ObjectLayer {
+	layer := newObjectLayer(m, name)
+	m.layers = append(m.layers, layer)
+	return layer
+}
+
+// Add a new group layer to the top of the map
+func (m *Map) NewGroupLayer(name string) *GroupLayer {
+	layer := newGroupLayer(m, name)
+	m.layers = append(m.layers, layer)
+	return layer
+}
+
+// All object layers in the map, including those nested in groups
+func (m *Map) allObjectLayers() []*ObjectLayer {
+	result := []*ObjectLayer{}
+	for _, layer := range m.AllLayers() {
+		if l, ok := layer.(*ObjectLayer); ok {
+			result = append(result, l)
+		}
+	}
+	return result
+}
+
+func newTileLayer(m *Map, name string) *TileLayer {
+	return &TileLayer{
 		parent: m,
 		Name: name,
-		tiles: tiles,
+		cells: m.newCellStore(),
+		Opacity: 1,
 		Visible: true,
+		Encoding: DefaultDataEncoding,
 		properties: make(map[string]*Property),
 	}
-	m.tileLayers = append(m.tileLayers, layer)
-	return layer
 }
 
-func (m *Map) NewImageLayer(name, imageSource string) *ImageLayer {
-	layer := &ImageLayer{
+func newImageLayer(m *Map, name, imageSource string) *ImageLayer {
+	return &ImageLayer{
 		parent: m,
 		Name: name,
+		Opacity: 1,
 		Visible: true,
 		ImageSource: imageSource,
 		properties: make(map[string]*Property),
 	}
-	m.imageLayers = append(m.imageLayers, layer)
-	return layer
+}
+
+func newObjectLayer(m *Map, name string) *ObjectLayer {
+	return &ObjectLayer{
+		parent: m,
+		Name: name,
+		Opacity: 1,
+		Visible: true,
+		DrawOrder: DefaultDrawOrder,
+		properties: make(map[string]*Property),
+	}
 }
 
 func NewMap(opts ...MapOption) *Map {
 	mp := &Map{
 		tilesets: []*Tileset{},
-		tileLayers: []*TileLayer{},
-		imageLayers: []*ImageLayer{},
+		layers: []Layer{},
+		nextObjectId: 1,
 		properties: make(map[string]*Property),
 		Version: DefaultTmxVersion,
 	}
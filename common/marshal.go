@@ -0,0 +1,300 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"reflect"
+	"strings"
+)
+
+// PropertyHolder is anything with properties; maps, tilesets, tiles, terrain, layers, objects ..
+type PropertyHolder interface {
+	Properties() []*Property
+}
+
+// PropertyUpdater is a PropertyHolder whose properties can be set
+type PropertyUpdater interface {
+	PropertyHolder
+	UpdateProperties(props ...*Property)
+}
+
+var (
+	colourType    = reflect.TypeOf(color.RGBA{})
+	colourPtrType = reflect.TypeOf(&color.RGBA{})
+)
+
+// UnmarshalProperties sets the fields of the struct pointed to by v from the holder's properties.
+// Fields are matched to properties by their `tmx:"name"` tag, fields without a tag are ignored
+// (but the fields of untagged embedded structs are used). A field tagged `tmx:"name,optional"`
+// is left as it is if there's no such property, otherwise a missing property is an error.
+//
+// Each property must be of the type matching it's field:
+//   - string fields take string or file properties
+//   - int & uint fields take int or object properties
+//   - float fields take float properties & bool fields bool properties
+//   - color.RGBA & *color.RGBA fields take color properties
+//   - struct (& pointer to struct) fields take class properties, filled from their members
+func UnmarshalProperties(holder PropertyHolder, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New(fmt.Sprintf("Expected a pointer to a struct, got %T", v))
+	}
+	return unmarshalStruct(holder.Properties(), rv.Elem(), "")
+}
+
+// MarshalProperties sets properties of the holder from the fields of the struct v (or the struct
+// v points to), as tagged for UnmarshalProperties. Other properties of the holder are kept.
+//
+// Property types follow the field types, except that int fields written over an object property
+// & string fields written over a file property keep that type. Struct fields are written as class
+// properties, keeping the custom type (& any other members) of the existing property.
+// Nil pointer fields aren't written.
+func MarshalProperties(holder PropertyUpdater, v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return errors.New(fmt.Sprintf("Expected a struct or pointer to a struct, got %T", v))
+	}
+	props, err := marshalStruct(holder.Properties(), rv, "")
+	if err != nil {
+		return err
+	}
+	holder.UpdateProperties(props...)
+	return nil
+}
+
+// A struct field mapped to a property
+type taggedField struct {
+	name     string
+	index    []int
+	optional bool
+}
+
+// The tagged fields of the given struct type, including those of untagged embedded structs
+func taggedFields(t reflect.Type) []taggedField {
+	result := []taggedField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("tmx")
+		if tag == "-" {
+			continue
+		}
+		if tag == "" {
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				for _, embedded := range taggedFields(f.Type) {
+					embedded.index = append([]int{i}, embedded.index...)
+					result = append(result, embedded)
+				}
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+
+		bits := strings.Split(tag, ",")
+		field := taggedField{name: bits[0], index: []int{i}}
+		for _, opt := range bits[1:] {
+			if opt == "optional" {
+				field.optional = true
+			}
+		}
+		result = append(result, field)
+	}
+	return result
+}
+
+func propertiesByName(props []*Property) map[string]*Property {
+	result := make(map[string]*Property)
+	for _, p := range props {
+		result[p.Name()] = p
+	}
+	return result
+}
+
+func unmarshalStruct(props []*Property, rv reflect.Value, path string) error {
+	byName := propertiesByName(props)
+	for _, f := range taggedFields(rv.Type()) {
+		prop, ok := byName[f.name]
+		if !ok {
+			if f.optional {
+				continue
+			}
+			return errors.New(fmt.Sprintf("Missing property %s%s", path, f.name))
+		}
+		err := unmarshalValue(prop, rv.FieldByIndex(f.index), path + f.name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unmarshalValue(prop *Property, fv reflect.Value, name string) error {
+	mismatch := func(expect ...string) error {
+		return errors.New(fmt.Sprintf("Property %s is of type %s, expected %s for %s field", name, prop.Type(), strings.Join(expect, " or "), fv.Type()))
+	}
+
+	switch {
+	case fv.Type() == colourType || fv.Type() == colourPtrType:
+		if prop.Type() != PropertyTypeColour {
+			return mismatch(PropertyTypeColour)
+		}
+		col := colourOrZero(prop.AsColour())
+		if fv.Type() == colourType {
+			fv.Set(reflect.ValueOf(col))
+		} else if prop.AsColour() == nil {
+			fv.Set(reflect.Zero(colourPtrType))
+		} else {
+			fv.Set(reflect.ValueOf(&col))
+		}
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		if prop.Type() != PropertyTypeString && prop.Type() != PropertyTypeFile {
+			return mismatch(PropertyTypeString, PropertyTypeFile)
+		}
+		fv.SetString(prop.AsString())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if prop.Type() != PropertyTypeInt && prop.Type() != PropertyTypeObject {
+			return mismatch(PropertyTypeInt, PropertyTypeObject)
+		}
+		if fv.OverflowInt(int64(prop.AsInt())) {
+			return errors.New(fmt.Sprintf("Property %s value %d overflows %s field", name, prop.AsInt(), fv.Type()))
+		}
+		fv.SetInt(int64(prop.AsInt()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if prop.Type() != PropertyTypeInt && prop.Type() != PropertyTypeObject {
+			return mismatch(PropertyTypeInt, PropertyTypeObject)
+		}
+		if prop.AsInt() < 0 || fv.OverflowUint(uint64(prop.AsInt())) {
+			return errors.New(fmt.Sprintf("Property %s value %d overflows %s field", name, prop.AsInt(), fv.Type()))
+		}
+		fv.SetUint(uint64(prop.AsInt()))
+	case reflect.Float32, reflect.Float64:
+		if prop.Type() != PropertyTypeFloat {
+			return mismatch(PropertyTypeFloat)
+		}
+		fv.SetFloat(prop.AsFloat())
+	case reflect.Bool:
+		if prop.Type() != PropertyTypeBool {
+			return mismatch(PropertyTypeBool)
+		}
+		fv.SetBool(prop.AsBool())
+	case reflect.Struct:
+		if prop.Type() != PropertyTypeClass {
+			return mismatch(PropertyTypeClass)
+		}
+		return unmarshalStruct(prop.Members(), fv, name + ".")
+	case reflect.Ptr:
+		if fv.Type().Elem().Kind() != reflect.Struct {
+			return errors.New(fmt.Sprintf("Unsupported field type %s for property %s", fv.Type(), name))
+		}
+		if prop.Type() != PropertyTypeClass {
+			return mismatch(PropertyTypeClass)
+		}
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return unmarshalStruct(prop.Members(), fv.Elem(), name + ".")
+	default:
+		return errors.New(fmt.Sprintf("Unsupported field type %s for property %s", fv.Type(), name))
+	}
+	return nil
+}
+
+func marshalStruct(existing []*Property, rv reflect.Value, path string) ([]*Property, error) {
+	byName := propertiesByName(existing)
+	result := []*Property{}
+	for _, f := range taggedFields(rv.Type()) {
+		prop, err := marshalValue(f.name, byName[f.name], rv.FieldByIndex(f.index), path)
+		if err != nil {
+			return nil, err
+		}
+		if prop != nil {
+			result = append(result, prop)
+		}
+	}
+	return result, nil
+}
+
+// A property holding the given field value, keeping what it can of the old property (if any).
+// Nil pointers give no property.
+func marshalValue(name string, old *Property, fv reflect.Value, path string) (*Property, error) {
+	prop := NewProp(name)
+
+	switch {
+	case fv.Type() == colourType:
+		col := fv.Interface().(color.RGBA)
+		return keepPropertyType(prop.SetColour(&col), old), nil
+	case fv.Type() == colourPtrType:
+		if fv.IsNil() {
+			return nil, nil
+		}
+		col := *fv.Interface().(*color.RGBA)
+		return keepPropertyType(prop.SetColour(&col), old), nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		if old != nil && old.Type() == PropertyTypeFile {
+			prop.SetFilepath(fv.String())
+		} else {
+			prop.SetString(fv.String())
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if old != nil && old.Type() == PropertyTypeObject {
+			prop.SetObject(int(fv.Int()))
+		} else {
+			prop.SetInt(int(fv.Int()))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if old != nil && old.Type() == PropertyTypeObject {
+			prop.SetObject(int(fv.Uint()))
+		} else {
+			prop.SetInt(int(fv.Uint()))
+		}
+	case reflect.Float32, reflect.Float64:
+		prop.SetFloat(fv.Float())
+	case reflect.Bool:
+		prop.SetBool(fv.Bool())
+	case reflect.Ptr:
+		if fv.Type().Elem().Kind() != reflect.Struct {
+			return nil, errors.New(fmt.Sprintf("Unsupported field type %s for property %s%s", fv.Type(), path, name))
+		}
+		if fv.IsNil() {
+			return nil, nil
+		}
+		return marshalValue(name, old, fv.Elem(), path)
+	case reflect.Struct:
+		class := ""
+		members := []*Property{}
+		if old != nil && old.Type() == PropertyTypeClass {
+			class = old.PropertyType()
+			for _, m := range old.Members() {
+				members = append(members, m.Copy())
+			}
+		}
+		updated, err := marshalStruct(members, fv, path + name + ".")
+		if err != nil {
+			return nil, err
+		}
+		prop.SetClass(class, members...)
+		prop.UpdateMembers(updated...)
+		return prop, nil
+	default:
+		return nil, errors.New(fmt.Sprintf("Unsupported field type %s for property %s%s", fv.Type(), path, name))
+	}
+
+	return keepPropertyType(prop, old), nil
+}
+
+// Keep the custom type (eg. an enum) of the old property, if the new one is of the same type
+func keepPropertyType(prop, old *Property) *Property {
+	if old != nil && old.Type() == prop.Type() {
+		prop.SetPropertyType(old.PropertyType())
+	}
+	return prop
+}
@@ -0,0 +1,166 @@
+package common
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+type resistances struct {
+	Fire float64 `tmx:"fire"`
+	Ice  float64 `tmx:"ice,optional"`
+}
+
+type spawn struct {
+	Respawn bool `tmx:"respawn"`
+}
+
+type monster struct {
+	spawn
+
+	Name    string       `tmx:"name"`
+	HP      int          `tmx:"hp"`
+	Level   uint8        `tmx:"level"`
+	Speed   float32      `tmx:"speed"`
+	Tint    color.RGBA   `tmx:"tint"`
+	Glow    *color.RGBA  `tmx:"glow,optional"`
+	Sprite  string       `tmx:"sprite"`
+	Target  int          `tmx:"target"`
+	Resist  resistances  `tmx:"resist"`
+	Loot    *resistances `tmx:"loot,optional"`
+	Ignored string
+	Skipped string `tmx:"-"`
+}
+
+func monsterObject() *Object {
+	obj := &Object{properties: make(map[string]*Property)}
+	obj.UpdateProperties(
+		NewProp("name").SetString("orc"),
+		NewProp("hp").SetInt(30),
+		NewProp("level").SetInt(4),
+		NewProp("speed").SetFloat(1.5),
+		NewProp("tint").SetColour(&color.RGBA{255, 0, 0, 255}),
+		NewProp("sprite").SetFilepath("orc.png"),
+		NewProp("target").SetObject(7),
+		NewProp("respawn").SetBool(true),
+		NewProp("resist").SetClass("Resistances", NewProp("fire").SetFloat(0.5), NewProp("poison").SetFloat(1)),
+		NewProp("Ignored").SetString("not read"),
+	)
+	return obj
+}
+
+func TestUnmarshalProperties(t *testing.T) {
+	var m monster
+	err := UnmarshalProperties(monsterObject(), &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Name != "orc" || m.HP != 30 || m.Level != 4 || m.Speed != 1.5 || m.Sprite != "orc.png" || m.Target != 7 {
+		t.Error("unexpected values", m)
+	}
+	if m.Tint != (color.RGBA{255, 0, 0, 255}) || m.Glow != nil {
+		t.Error("unexpected colours", m.Tint, m.Glow)
+	}
+	if !m.Respawn {
+		t.Error("expected embedded struct field to be set")
+	}
+	if m.Resist.Fire != 0.5 || m.Resist.Ice != 0 || m.Loot != nil {
+		t.Error("unexpected class values", m.Resist, m.Loot)
+	}
+	if m.Ignored != "" || m.Skipped != "" {
+		t.Error("expected untagged fields to be left alone")
+	}
+}
+
+func TestUnmarshalPropertiesErrors(t *testing.T) {
+	cases := map[string]struct {
+		Prop   *Property
+		Expect string
+	}{
+		"missing": {nil, "Missing property hp"},
+		"mismatch": {NewProp("hp").SetString("lots"), "Property hp is of type string"},
+		"nested-mismatch": {NewProp("resist").SetClass("", NewProp("fire").SetInt(1)), "Property resist.fire is of type int"},
+		"overflow": {NewProp("level").SetInt(300), "overflows"},
+		"not-class": {NewProp("resist").SetFloat(1), "expected class"},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			obj := monsterObject()
+			if tt.Prop == nil {
+				delete(obj.properties, "hp")
+			} else {
+				obj.UpdateProperties(tt.Prop)
+			}
+
+			var m monster
+			err := UnmarshalProperties(obj, &m)
+			if err == nil || !strings.Contains(err.Error(), tt.Expect) {
+				t.Errorf("expected error containing %q got %v", tt.Expect, err)
+			}
+		})
+	}
+
+	if err := UnmarshalProperties(monsterObject(), monster{}); err == nil {
+		t.Error("expected an error unmarshalling into a non pointer")
+	}
+}
+
+func TestMarshalProperties(t *testing.T) {
+	obj := monsterObject()
+	var m monster
+	err := UnmarshalProperties(obj, &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.HP = 12
+	m.Target = 9
+	m.Sprite = "orc2.png"
+	m.Resist.Fire = 0.75
+	m.Glow = &color.RGBA{0, 0, 255, 255}
+	err = MarshalProperties(obj, &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hp, _ := obj.Property("hp"); hp.Type() != PropertyTypeInt || hp.AsInt() != 12 {
+		t.Error("unexpected hp", hp)
+	}
+	if target, _ := obj.Property("target"); target.Type() != PropertyTypeObject || target.AsObject() != 9 {
+		t.Error("expected target to stay an object property, got", target)
+	}
+	if sprite, _ := obj.Property("sprite"); sprite.Type() != PropertyTypeFile || sprite.AsFilepath() != "orc2.png" {
+		t.Error("expected sprite to stay a file property, got", sprite)
+	}
+	if glow, ok := obj.Property("glow"); !ok || glow.AsColour().B != 255 {
+		t.Error("expected glow colour, got", glow)
+	}
+	if _, ok := obj.Property("loot"); ok {
+		t.Error("expected nil pointer not to be written")
+	}
+	if ignored, _ := obj.Property("Ignored"); ignored.AsString() != "not read" {
+		t.Error("expected other properties to be kept")
+	}
+
+	resist, _ := obj.Property("resist")
+	if resist.PropertyType() != "Resistances" {
+		t.Error("expected class name to be kept, got", resist.PropertyType())
+	}
+	if fire, _ := resist.Member("fire"); fire.AsFloat() != 0.75 {
+		t.Error("unexpected fire member", fire)
+	}
+	if poison, ok := resist.Member("poison"); !ok || poison.AsFloat() != 1 {
+		t.Error("expected untagged class member to be kept")
+	}
+
+	var again monster
+	err = UnmarshalProperties(obj, &again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.HP != 12 || again.Resist.Fire != 0.75 || again.Glow == nil || *again.Glow != *m.Glow {
+		t.Error("unexpected values after round trip", again)
+	}
+}
@@ -0,0 +1,297 @@
+package common
+
+import (
+	"image/color"
+)
+
+// ObjectLayer is a layer of free-standing objects (in Tiled an 'objectgroup')
+type ObjectLayer struct {
+	parent     *Map
+	group      *GroupLayer
+	objects    []*Object
+	properties map[string]*Property
+
+	Name       string
+	Colour     *color.RGBA
+	Opacity    float64
+	Visible    bool
+	OffsetX    int
+	OffsetY    int
+	DrawOrder  string
+	TintColour *color.RGBA
+}
+
+// The group this layer is in (nil if it's at the top level of the map)
+func (o *ObjectLayer) Group() *GroupLayer {
+	return o.group
+}
+
+// Opacity of this layer, combined with that of all groups it's in
+func (o *ObjectLayer) EffectiveOpacity() float64 {
+	return o.Opacity * o.group.EffectiveOpacity()
+}
+
+// Whether this layer, and all groups it's in, are visible
+func (o *ObjectLayer) EffectiveVisible() bool {
+	return o.Visible && o.group.EffectiveVisible()
+}
+
+// Offset of this layer, added to that of all groups it's in
+func (o *ObjectLayer) EffectiveOffset() (int, int) {
+	x, y := o.group.EffectiveOffset()
+	return o.OffsetX + x, o.OffsetY + y
+}
+
+// Tint of this layer, multiplied by that of all groups it's in (nil if there's no tint)
+func (o *ObjectLayer) EffectiveTint() *color.RGBA {
+	return multiplyTint(o.TintColour, o.group.EffectiveTint())
+}
+
+func (o *ObjectLayer) UpdateProperties(props ...*Property) {
+	for _, prop := range props {
+		o.properties[prop.Name()] = prop
+	}
+}
+
+// Remove the named properties (if present)
+func (o *ObjectLayer) RemoveProperties(names ...string) {
+	for _, name := range names {
+		delete(o.properties, name)
+	}
+}
+
+func (o *ObjectLayer) Property(name string) (*Property, bool) {
+	prop, ok := o.properties[name]
+	return prop, ok
+}
+
+func (o *ObjectLayer) Properties() []*Property {
+	results := []*Property{}
+	for _, p := range o.properties {
+		results = append(results, p)
+	}
+	return results
+}
+
+// Objects in this layer. The slice is a copy, use AddObjects & RemoveObject to change the objects.
+func (o *ObjectLayer) Objects() []*Object {
+	return append([]*Object{}, o.objects...)
+}
+
+// Add objects to this layer. Objects are given an Id when the map IDs are finalized
+// if they don't already have one.
+func (o *ObjectLayer) AddObjects(objs ...*Object) {
+	for _, obj := range objs {
+		obj.layer = o
+		o.objects = append(o.objects, obj)
+	}
+}
+
+// Remove the given object from this layer, if present.
+func (o *ObjectLayer) RemoveObject(obj *Object) {
+	for i, existing := range o.objects {
+		if existing == obj {
+			objects := append([]*Object{}, o.objects[:i]...)
+			o.objects = append(objects, o.objects[i+1:]...)
+			obj.layer = nil
+			return
+		}
+	}
+}
+
+// Find an object in this layer by its Id
+func (o *ObjectLayer) Object(id int) (*Object, bool) {
+	for _, obj := range o.objects {
+		if obj.Id == id {
+			return obj, true
+		}
+	}
+	return nil, false
+}
+
+// Object is a single object placed in an ObjectLayer.
+//
+// An object is exactly one of a rectangle (the default), point, ellipse, polygon, polyline,
+// text or tile object; see Kind() and the matching Set* functions.
+type Object struct {
+	layer      *ObjectLayer
+	properties map[string]*Property
+
+	// Rather than an interface per object type we keep the shape data for each kind here
+	// and switch on 'kind' (much like Property does for values).
+	kind   string
+	points []Point
+	text   *Text
+	cell   Cell
+
+	template *Template // see NewObjectFromTemplate
+
+	Id       int
+	Name     string
+	Type     string
+	X        float64 // in pixels, Tiled allows fractions of a pixel
+	Y        float64
+	Width    float64
+	Height   float64
+	Rotation float64 // in degrees, clockwise
+	Visible  bool
+}
+
+// Point of a polygon or polyline, relative to the object X,Y
+type Point struct {
+	X float64
+	Y float64
+}
+
+func Pt(x, y float64) Point {
+	return Point{X: x, Y: y}
+}
+
+func NewObject(name string) *Object {
+	return &Object{
+		Name:       name,
+		Visible:    true,
+		kind:       ObjectTypeRectangle,
+		properties: make(map[string]*Property),
+	}
+}
+
+// The layer this object belongs to (if any)
+func (o *Object) Layer() *ObjectLayer {
+	return o.layer
+}
+
+// One of the ObjectType* constants
+func (o *Object) Kind() string {
+	return o.kind
+}
+
+func (o *Object) clear() {
+	o.points = nil
+	o.text = nil
+	o.cell = Cell{}
+}
+
+// Points of a polygon or polyline, relative to the object X,Y
+func (o *Object) Points() []Point {
+	return o.points
+}
+
+// Text settings of a text object
+func (o *Object) Text() *Text {
+	return o.text
+}
+
+// Tile drawn by a tile object
+func (o *Object) Tile() *Tile {
+	return o.cell.Tile
+}
+
+// Tile drawn by a tile object, along with how it's flipped
+func (o *Object) TileCell() Cell {
+	return o.cell
+}
+
+func (o *Object) SetRectangle() *Object {
+	o.clear()
+	o.kind = ObjectTypeRectangle
+	return o
+}
+
+func (o *Object) SetPoint() *Object {
+	o.clear()
+	o.kind = ObjectTypePoint
+	return o
+}
+
+func (o *Object) SetEllipse() *Object {
+	o.clear()
+	o.kind = ObjectTypeEllipse
+	return o
+}
+
+func (o *Object) SetPolygon(points ...Point) *Object {
+	o.clear()
+	o.kind = ObjectTypePolygon
+	o.points = points
+	return o
+}
+
+func (o *Object) SetPolyline(points ...Point) *Object {
+	o.clear()
+	o.kind = ObjectTypePolyline
+	o.points = points
+	return o
+}
+
+func (o *Object) SetText(in *Text) *Object {
+	o.clear()
+	o.kind = ObjectTypeText
+	o.text = in
+	return o
+}
+
+func (o *Object) SetTile(in *Tile) *Object {
+	return o.SetTileCell(Cell{Tile: in})
+}
+
+func (o *Object) SetTileCell(in Cell) *Object {
+	o.clear()
+	o.kind = ObjectTypeTile
+	o.cell = in
+	return o
+}
+
+func (o *Object) UpdateProperties(props ...*Property) {
+	for _, prop := range props {
+		o.properties[prop.Name()] = prop
+	}
+}
+
+// Remove the named properties (if present)
+func (o *Object) RemoveProperties(names ...string) {
+	for _, name := range names {
+		delete(o.properties, name)
+	}
+}
+
+func (o *Object) Property(name string) (*Property, bool) {
+	prop, ok := o.properties[name]
+	return prop, ok
+}
+
+func (o *Object) Properties() []*Property {
+	results := []*Property{}
+	for _, p := range o.properties {
+		results = append(results, p)
+	}
+	return results
+}
+
+// Text held by a text object
+type Text struct {
+	Value      string
+	FontFamily string
+	PixelSize  int
+	Colour     *color.RGBA
+	HAlign     string
+	VAlign     string
+	Bold       bool
+	Italic     bool
+	Underline  bool
+	Strikeout  bool
+	Kerning    bool
+	Wrap       bool
+}
+
+func NewText(value string) *Text {
+	return &Text{
+		Value:      value,
+		FontFamily: DefaultFontFamily,
+		PixelSize:  DefaultPixelSize,
+		Colour:     &color.RGBA{0, 0, 0, 255},
+		HAlign:     DefaultHAlign,
+		VAlign:     DefaultVAlign,
+		Kerning:    true,
+	}
+}
@@ -49,6 +49,13 @@ func Background(in *color.RGBA) MapOption {
 	}
 }
 
+// Set the map to be infinite, see Map.SetInfinite
+func Infinite() MapOption {
+	return func(m *Map) {
+		m.infinite = true
+	}
+}
+
 func RenderOrderRightDown() MapOption {
 	return func(m *Map) {
 		m.renderOrder = MapRenderOrderRightDown
@@ -88,16 +95,29 @@ func OrientationIsometric() MapOption {
 func OrientationStaggered(staggerAxis, staggerIndex string) MapOption {
 	return func(m *Map) {
 		m.orientation = MapOrientationStaggered
+		m.setStagger(staggerAxis, staggerIndex)
+	}
+}
 
-		// defaults
-		m.staggerAxis = DefaultStaggerAxis
-		m.staggerIndex = DefaultStaggerIndex
+// Hexagonal maps are staggered like OrientationStaggered, hexSideLength is the length in pixels
+// of the flat side of each hexagon (along the stagger axis, see Map.HexSideLength)
+func OrientationHexagonal(hexSideLength int, staggerAxis, staggerIndex string) MapOption {
+	return func(m *Map) {
+		m.orientation = MapOrientationHexagonal
+		m.HexSideLength = hexSideLength
+		m.setStagger(staggerAxis, staggerIndex)
+	}
+}
 
-		if staggerAxis == MapStaggerAxisX || staggerAxis == MapStaggerAxisY {
-			m.staggerAxis = staggerAxis
-		}
-		if staggerIndex == MapStaggerIndexEven || staggerIndex == MapStaggerIndexOdd {
-			m.staggerIndex = staggerIndex
-		}
+// Set the stagger axis & index, falling back to the defaults for unknown values
+func (m *Map) setStagger(staggerAxis, staggerIndex string) {
+	m.staggerAxis = DefaultStaggerAxis
+	m.staggerIndex = DefaultStaggerIndex
+
+	if staggerAxis == MapStaggerAxisX || staggerAxis == MapStaggerAxisY {
+		m.staggerAxis = staggerAxis
+	}
+	if staggerIndex == MapStaggerIndexEven || staggerIndex == MapStaggerIndexOdd {
+		m.staggerIndex = staggerIndex
 	}
 }
@@ -17,6 +17,14 @@ type Property struct {
 	valueBool bool
 	valueColour *color.RGBA
 	valueFloat float64
+	members map[string]*Property // class properties only (object properties use valueInt)
+
+	// the name of the custom type (a class or enum declared in the Tiled project) if any
+	propertyType string
+
+	// set on class members filled in from the class' defaults (see PropertyTypes.FillDefaults),
+	// rather than set in the data
+	isDefault bool
 }
 
 func (p *Property) Name() string {
@@ -40,6 +48,9 @@ func (p *Property) clear() {
 	p.valueBool = false
 	p.valueColour = &color.RGBA{0, 0, 0, 0}
 	p.valueFloat = 0
+	p.members = nil
+	p.propertyType = ""
+	p.isDefault = false
 }
 
 func (p *Property) AsInt() int {
@@ -107,3 +118,143 @@ func (p *Property) SetBool(in bool) *Property {
 	p.valueType = PropertyTypeBool
 	return p
 }
+
+// The id of the object an object property refers to (0 is no object)
+func (p *Property) AsObject() int {
+	return p.valueInt
+}
+
+// Set the id of the object this property refers to (0 is no object)
+func (p *Property) SetObject(id int) *Property {
+	p.clear()
+	p.valueInt = id
+	p.valueType = PropertyTypeObject
+	return p
+}
+
+// Make this a class property, that is one holding the given member properties, of the
+// named custom class.
+func (p *Property) SetClass(propertyType string, members ...*Property) *Property {
+	p.clear()
+	p.valueType = PropertyTypeClass
+	p.propertyType = propertyType
+	p.members = make(map[string]*Property)
+	p.UpdateMembers(members...)
+	return p
+}
+
+// The name of the custom type (class or enum) of this property, if any
+func (p *Property) PropertyType() string {
+	return p.propertyType
+}
+
+// Set the name of the custom type of this property. For an enum the property is a string
+// or int holding the enum value. Nb. this should be set after the value.
+func (p *Property) SetPropertyType(name string) *Property {
+	p.propertyType = name
+	return p
+}
+
+// Add or replace members of a class property
+func (p *Property) UpdateMembers(props ...*Property) {
+	if p.members == nil {
+		p.members = make(map[string]*Property)
+	}
+	for _, prop := range props {
+		p.members[prop.Name()] = prop
+	}
+}
+
+func (p *Property) Member(name string) (*Property, bool) {
+	prop, ok := p.members[name]
+	return prop, ok
+}
+
+// Members of a class property
+func (p *Property) Members() []*Property {
+	results := []*Property{}
+	for _, m := range p.members {
+		results = append(results, m)
+	}
+	return results
+}
+
+// Members of a class property that were set, rather than filled in from the class' defaults (see
+// PropertyTypes.FillDefaults). Setting the value of a default member makes it one of these. Only
+// these need be written out, as Tiled does.
+func (p *Property) ExplicitMembers() []*Property {
+	results := []*Property{}
+	for _, m := range p.members {
+		if !m.isDefault || len(m.ExplicitMembers()) > 0 {
+			results = append(results, m)
+		}
+	}
+	return results
+}
+
+// Whether this is a class member filled in from the class' defaults, rather than set
+func (p *Property) IsDefault() bool {
+	return p.isDefault
+}
+
+// Mark this property & any members as filled in from a class' defaults
+func (p *Property) markDefault() {
+	p.isDefault = true
+	for _, m := range p.members {
+		m.markDefault()
+	}
+}
+
+// Whether both properties have the same name, type & value
+func (p *Property) Equal(other *Property) bool {
+	if p == nil || other == nil {
+		return p == other
+	}
+	if p.name != other.name || p.valueType != other.valueType || p.propertyType != other.propertyType {
+		return false
+	}
+	switch p.valueType {
+	case PropertyTypeInt, PropertyTypeObject:
+		return p.valueInt == other.valueInt
+	case PropertyTypeFloat:
+		return p.valueFloat == other.valueFloat
+	case PropertyTypeBool:
+		return p.valueBool == other.valueBool
+	case PropertyTypeColour:
+		return colourOrZero(p.valueColour) == colourOrZero(other.valueColour)
+	case PropertyTypeClass:
+		if len(p.members) != len(other.members) {
+			return false
+		}
+		for name, m := range p.members {
+			if !m.Equal(other.members[name]) {
+				return false
+			}
+		}
+		return true
+	}
+	return p.valueString == other.valueString
+}
+
+// A copy of this property, that may be changed without changing this property
+func (p *Property) Copy() *Property {
+	cp := *p
+	if p.valueColour != nil {
+		col := *p.valueColour
+		cp.valueColour = &col
+	}
+	if p.members != nil {
+		cp.members = make(map[string]*Property)
+		for name, m := range p.members {
+			cp.members[name] = m.Copy()
+		}
+	}
+	return &cp
+}
+
+func colourOrZero(in *color.RGBA) color.RGBA {
+	if in == nil {
+		return color.RGBA{}
+	}
+	return *in
+}
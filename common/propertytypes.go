@@ -0,0 +1,97 @@
+package common
+
+// PropertyTypes holds the custom classes declared in a Tiled project (see the "Custom Types
+// Editor"). Maps only store the members of class properties that differ from the class'
+// defaults, so these are needed to know the full value of a class property.
+type PropertyTypes struct {
+	classes map[string]*CustomClass
+}
+
+// CustomClass is a custom class of properties, each member property holds it's default value
+type CustomClass struct {
+	Name string
+	UseAs []string // what the class may be used for (eg. "property", "object", "map" ..)
+
+	members map[string]*Property
+}
+
+func NewPropertyTypes(classes ...*CustomClass) *PropertyTypes {
+	t := &PropertyTypes{classes: make(map[string]*CustomClass)}
+	t.AddClasses(classes...)
+	return t
+}
+
+func (t *PropertyTypes) AddClasses(classes ...*CustomClass) {
+	for _, c := range classes {
+		t.classes[c.Name] = c
+	}
+}
+
+// Find a custom class by name
+func (t *PropertyTypes) Class(name string) (*CustomClass, bool) {
+	if t == nil {
+		return nil, false
+	}
+	c, ok := t.classes[name]
+	return c, ok
+}
+
+func (t *PropertyTypes) Classes() []*CustomClass {
+	results := []*CustomClass{}
+	for _, c := range t.classes {
+		results = append(results, c)
+	}
+	return results
+}
+
+// Add copies of the default values of any members missing from the given class properties
+// (and the class properties nested in them). Properties of classes that aren't known are
+// left as they are. Members added are marked as defaults (see Property.ExplicitMembers), so
+// they aren't written out with the property.
+func (t *PropertyTypes) FillDefaults(props ...*Property) {
+	for _, prop := range props {
+		if prop.Type() != PropertyTypeClass {
+			continue
+		}
+		if class, ok := t.Class(prop.PropertyType()); ok {
+			for _, member := range class.Members() {
+				if _, ok := prop.Member(member.Name()); !ok {
+					def := member.Copy()
+					def.markDefault()
+					prop.UpdateMembers(def)
+				}
+			}
+		}
+		t.FillDefaults(prop.Members()...)
+	}
+}
+
+// Create a new custom class, with members giving the name, type & default value of each member
+func NewCustomClass(name string, members ...*Property) *CustomClass {
+	c := &CustomClass{
+		Name: name,
+		UseAs: []string{},
+		members: make(map[string]*Property),
+	}
+	c.UpdateMembers(members...)
+	return c
+}
+
+func (c *CustomClass) UpdateMembers(props ...*Property) {
+	for _, prop := range props {
+		c.members[prop.Name()] = prop
+	}
+}
+
+func (c *CustomClass) Member(name string) (*Property, bool) {
+	prop, ok := c.members[name]
+	return prop, ok
+}
+
+func (c *CustomClass) Members() []*Property {
+	results := []*Property{}
+	for _, m := range c.members {
+		results = append(results, m)
+	}
+	return results
+}
@@ -0,0 +1,129 @@
+package common
+
+// Template is an object kept in it's own file (in Tiled a .tx file) that many objects may be
+// based on. Objects using a template take it's values for anything they don't override.
+type Template struct {
+	Source string  // path to the template file
+	Object *Object // the object others are based on (it's Id, X & Y are unused)
+}
+
+func NewTemplate(source string, obj *Object) *Template {
+	return &Template{Source: source, Object: obj}
+}
+
+// Create a new object based on the given template, that is with a copy of the template
+// object's values & linked to the template (see Object.SetTemplate)
+func NewObjectFromTemplate(t *Template) *Object {
+	obj := NewObject("")
+	if t.Object != nil {
+		src := t.Object
+		obj.Name = src.Name
+		obj.Type = src.Type
+		obj.Width = src.Width
+		obj.Height = src.Height
+		obj.Rotation = src.Rotation
+		obj.Visible = src.Visible
+		obj.copyShape(src)
+		for _, prop := range src.properties {
+			obj.properties[prop.Name()] = prop.Copy()
+		}
+	}
+	obj.template = t
+	return obj
+}
+
+// The template this object is based on (nil if it isn't)
+func (o *Object) Template() *Template {
+	return o.template
+}
+
+// Link (or given nil, unlink) this object to a template. The object keeps it's own values, but
+// when written out only those that differ from the template are kept (see TemplateOverrides).
+func (o *Object) SetTemplate(t *Template) *Object {
+	o.template = t
+	return o
+}
+
+// ObjectOverrides says which values of an object differ from those of it's template
+type ObjectOverrides struct {
+	Name     bool
+	Type     bool
+	Width    bool
+	Height   bool
+	Rotation bool
+	Visible  bool
+	Shape    bool // the kind of object along with it's points, text or tile
+
+	// properties the template doesn't have, or has with another type or value
+	Properties []*Property
+}
+
+// Which values of this object differ from those of it's template. An object without
+// a template overrides everything.
+func (o *Object) TemplateOverrides() ObjectOverrides {
+	if o.template == nil || o.template.Object == nil {
+		return ObjectOverrides{
+			Name: true, Type: true, Width: true, Height: true, Rotation: true, Visible: true, Shape: true,
+			Properties: o.Properties(),
+		}
+	}
+
+	t := o.template.Object
+	result := ObjectOverrides{
+		Name: o.Name != t.Name,
+		Type: o.Type != t.Type,
+		Width: o.Width != t.Width,
+		Height: o.Height != t.Height,
+		Rotation: o.Rotation != t.Rotation,
+		Visible: o.Visible != t.Visible,
+		Shape: !o.sameShape(t),
+		Properties: []*Property{},
+	}
+	for name, prop := range o.properties {
+		if !prop.Equal(t.properties[name]) {
+			result.Properties = append(result.Properties, prop)
+		}
+	}
+	return result
+}
+
+// Copy the kind of object & it's points, text or tile from another object
+func (o *Object) copyShape(src *Object) {
+	o.clear()
+	o.kind = src.kind
+	o.cell = src.cell
+	if src.points != nil {
+		o.points = append(o.points, src.points...)
+	}
+	if src.text != nil {
+		txt := *src.text
+		if src.text.Colour != nil {
+			col := *src.text.Colour
+			txt.Colour = &col
+		}
+		o.text = &txt
+	}
+}
+
+// Whether both objects are the same kind of object with the same points, text or tile
+func (o *Object) sameShape(other *Object) bool {
+	if o.kind != other.kind || o.cell != other.cell || len(o.points) != len(other.points) {
+		return false
+	}
+	for i := range o.points {
+		if o.points[i] != other.points[i] {
+			return false
+		}
+	}
+	if (o.text == nil) != (other.text == nil) {
+		return false
+	}
+	if o.text != nil {
+		a, b := *o.text, *other.text
+		a.Colour, b.Colour = nil, nil
+		if a != b || colourOrZero(o.text.Colour) != colourOrZero(other.text.Colour) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,10 +1,18 @@
 package common
 
-
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// Tileset is either a single image (atlas or spritesheet) cut into tiles, or a collection of
+// tiles that each have their own image.
 type Tileset struct {
 	parent *Map
 
 	FirstGID int // the global id (across all tilesets) for the first tile in this set
+	Source string // path to the external tileset file (eg. .tsx) this set is kept in, if any
 	Name string
 	OffsetX int
 	OffsetY int
@@ -13,7 +21,15 @@ type Tileset struct {
 	Spacing int
 	Margin int
 
+	// Atlas tilesets only, see SetImage
+	ImageSource       string
+	ImageWidth        int
+	ImageHeight       int
+	TransparentColour *color.RGBA
+	Columns           int
+
 	terrain []*Terrain
+	wangsets []*WangSet
 	properties  map[string]*Property
 	tiles   []*Tile
 }
@@ -39,6 +55,13 @@ func (t *Tileset) UpdateProperties(props ...*Property) {
 	}
 }
 
+// Remove the named properties (if present)
+func (t *Tileset) RemoveProperties(names ...string) {
+	for _, name := range names {
+		delete(t.properties, name)
+	}
+}
+
 func (t *Tileset) Property(name string) (*Property, bool) {
 	prop, ok := t.properties[name]
 	return prop, ok
@@ -56,29 +79,136 @@ func (t *Tileset) TileCount() int {
 	return len(t.tiles)
 }
 
+// The Id the next tile added to the set is given; one past the highest Id in the set. Tiled
+// leaves gaps in the Ids of removed tiles, so this (rather than TileCount) is the number of
+// global ids the set takes in a map.
+func (t *Tileset) NextTileID() int {
+	next := 0
+	for _, tile := range t.tiles {
+		if tile.Id >= next {
+			next = tile.Id + 1
+		}
+	}
+	return next
+}
+
+// Find a tile by it's local Id within the set
+func (t *Tileset) Tile(id int) (*Tile, bool) {
+	if id >= 0 && id < len(t.tiles) && t.tiles[id].Id == id {
+		return t.tiles[id], true // in the usual case a tile's Id is it's index
+	}
+	for _, tile := range t.tiles {
+		if tile.Id == id {
+			return tile, true
+		}
+	}
+	return nil, false
+}
+
+// Add tiles to the set. Tiles in an atlas tileset have no Source of their own.
+// Tiles without an Id (see NewTile) are given the next free one.
 func (t *Tileset) AddTiles(tiles ...*Tile) {
 	for _, tile := range tiles {
-		if tile.Source == "" {
-			continue
+		if tile.Id < 0 {
+			tile.Id = t.NextTileID()
 		}
-
 		tile.parent = t
 		t.tiles = append(t.tiles, tile)
 	}
 }
 
+// Whether this tileset is a single image cut into tiles, rather than a collection of images
+func (t *Tileset) IsAtlas() bool {
+	return t.ImageSource != ""
+}
+
+// Make this an atlas tileset, that is one cut from a single image of the given size.
+// TileWidth, TileHeight, Margin & Spacing should be set first, as they decide the number
+// of Columns and tiles. Tiles are added so every tile in the image is addressable.
+func (t *Tileset) SetImage(source string, width, height int) {
+	t.ImageSource = source
+	t.ImageWidth = width
+	t.ImageHeight = height
+	t.Columns = t.fit(width, t.TileWidth)
+	t.FillTiles(t.Columns * t.fit(height, t.TileHeight))
+}
+
+// Add a tile for every Id below count the set doesn't yet have, keeping tiles in order of Id.
+// Tiles already in the set keep their Ids.
+func (t *Tileset) FillTiles(count int) {
+	have := map[int]bool{}
+	for _, tile := range t.tiles {
+		have[tile.Id] = true
+	}
+	added := false
+	for id := 0; id < count; id++ {
+		if !have[id] {
+			tile := NewTile("")
+			tile.Id = id
+			t.AddTiles(tile)
+			added = true
+		}
+	}
+	if added {
+		sort.SliceStable(t.tiles, func(i, j int) bool { return t.tiles[i].Id < t.tiles[j].Id })
+	}
+}
+
+// Number of tiles of the given size that fit in the given length of the atlas image
+func (t *Tileset) fit(length, tileLength int) int {
+	if tileLength + t.Spacing < 1 {
+		return 0
+	}
+	n := (length - 2 * t.Margin + t.Spacing) / (tileLength + t.Spacing)
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// Set Ids on child Tiles & Terrain (called before a Tileset is written out). Tiles keep any Id
+// they already have (as maps refer to tiles by it), tiles without one are given the next free Id.
+func (t *Tileset) FinalizeIDs() {
+	for i, terrain := range t.terrain {
+		terrain.Id = i
+	}
+	for _, tile := range t.tiles {
+		if tile.Id < 0 {
+			tile.Id = t.NextTileID()
+		}
+		tile.finalizeCollisionIDs()
+	}
+}
+
+// The map this tileset belongs to (if any)
+func (t *Tileset) Map() *Map {
+	return t.parent
+}
+
+// Create a new tileset & add it to this map
 func (m *Map) NewTileset(name string, tiles ...*Tile) *Tileset {
+	tset := NewTileset(name, tiles...)
+	tset.TileWidth = m.TileWidth
+	tset.TileHeight = m.TileHeight
+	m.AddTileset(tset)
+	return tset
+}
+
+// Add an existing tileset (eg. one loaded from a file shared by many maps) to this map
+func (m *Map) AddTileset(in *Tileset) {
+	in.parent = m
+	m.tilesets = append(m.tilesets, in)
+}
+
+// Create a new standalone tileset, that isn't (yet) part of any map
+func NewTileset(name string, tiles ...*Tile) *Tileset {
 	tset := &Tileset{
-		parent: m,
 		Name: name,
 		properties: make(map[string]*Property),
 		terrain: []*Terrain{},
+		wangsets: []*WangSet{},
 		tiles: []*Tile{},
-		TileWidth: m.TileWidth,
-		TileHeight: m.TileHeight,
 	}
-
-	m.tilesets = append(m.tilesets, tset)
 	tset.AddTiles(tiles...)
 	return tset
 }
@@ -93,13 +223,19 @@ type Tile struct {
 	Source string
 	Width int
 	Height int
-	Probability float64
+	Probability float64 // relative chance of picking this tile where there's a choice (default 1, 0 is never)
 	Animation *Animation
+
+	collision []*Object
 }
 
+// Create a new tile, source is the tile's own image (empty for tiles in an atlas tileset).
+// The tile has no Id (-1) until it's added to a tileset.
 func NewTile(source string) *Tile {
 	return &Tile{
+		Id: -1,
 		Source: source,
+		Probability: 1,
 		properties: make(map[string]*Property),
 		terrain: make([]*Terrain, 4),
 	}
@@ -109,6 +245,60 @@ func (t *Tile) GlobalID() int {
 	return t.Id + t.parent.FirstGID
 }
 
+// The tileset this tile belongs to (if any)
+func (t *Tile) Tileset() *Tileset {
+	return t.parent
+}
+
+// The image this tile is drawn from; it's own image or, for an atlas tile, the tileset image
+func (t *Tile) ImageSource() string {
+	if t.Source == "" && t.parent != nil {
+		return t.parent.ImageSource
+	}
+	return t.Source
+}
+
+// Region of ImageSource() holding this tile. For a tile with it's own image this is
+// the whole image.
+func (t *Tile) SourceRect() image.Rectangle {
+	if t.Source != "" || t.parent == nil || !t.parent.IsAtlas() {
+		return image.Rect(0, 0, t.Width, t.Height)
+	}
+
+	ts := t.parent
+	columns := ts.Columns
+	if columns < 1 {
+		columns = ts.fit(ts.ImageWidth, ts.TileWidth)
+	}
+	if columns < 1 {
+		return image.Rectangle{}
+	}
+
+	x := ts.Margin + (t.Id % columns) * (ts.TileWidth + ts.Spacing)
+	y := ts.Margin + (t.Id / columns) * (ts.TileHeight + ts.Spacing)
+	return image.Rect(x, y, x + ts.TileWidth, y + ts.TileHeight)
+}
+
+// Whether the tile has anything set beyond it's image, that is a type, properties, terrain,
+// an animation, collision shapes or a probability. Atlas tiles without data needn't be written out.
+func (t *Tile) HasData() bool {
+	if t.Type != "" || t.Probability != 1 || len(t.properties) > 0 {
+		return true
+	}
+	if t.Animation != nil && len(t.Animation.Frames) > 0 {
+		return true
+	}
+	if len(t.collision) > 0 {
+		return true
+	}
+	for _, terr := range t.terrain {
+		if terr != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func (t *Tile) TopLeftTerrain() *Terrain {
 	return t.terrain[0]
 }
@@ -147,6 +337,13 @@ func (t *Tile) UpdateProperties(props ...*Property) {
 	}
 }
 
+// Remove the named properties (if present)
+func (t *Tile) RemoveProperties(names ...string) {
+	for _, name := range names {
+		delete(t.properties, name)
+	}
+}
+
 func (t *Tile) Terrain() []*Terrain {
 	return t.terrain
 }
@@ -170,6 +367,33 @@ func (t *Tile) SetAnimation(frames ...*Frame) {
 	animation.parent = t
 }
 
+// Collision shapes of the tile (as drawn in Tiled's collision editor), each one a rectangle,
+// ellipse, polygon etc. object positioned relative to the top left of the tile.
+func (t *Tile) CollisionShapes() []*Object {
+	return t.collision
+}
+
+// Set the collision shapes of the tile, replacing any it already has
+func (t *Tile) SetCollisionShapes(shapes ...*Object) {
+	t.collision = shapes
+}
+
+// Give collision shapes without an Id the next free one (Ids are only unique within a tile)
+func (t *Tile) finalizeCollisionIDs() {
+	next := 1
+	for _, shape := range t.collision {
+		if shape.Id >= next {
+			next = shape.Id + 1
+		}
+	}
+	for _, shape := range t.collision {
+		if shape.Id < 1 {
+			shape.Id = next
+			next += 1
+		}
+	}
+}
+
 type Animation struct {
 	parent *Tile
 	Frames []*Frame
@@ -212,6 +436,13 @@ func (t *Terrain) UpdateProperties(props ...*Property) {
 	}
 }
 
+// Remove the named properties (if present)
+func (t *Terrain) RemoveProperties(names ...string) {
+	for _, name := range names {
+		delete(t.properties, name)
+	}
+}
+
 func (t *Terrain) Property(name string) (*Property, bool) {
 	prop, ok := t.properties[name]
 	return prop, ok
@@ -0,0 +1,32 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestSetImageSparseTiles(t *testing.T) {
+	tset := NewTileset("sheet")
+	tset.TileWidth, tset.TileHeight = 16, 16
+	wall := NewTile("")
+	wall.Id = 5
+	wall.Type = "wall"
+	tset.AddTiles(wall)
+
+	tset.SetImage("sheet.png", 64, 32)
+	if tset.TileCount() != 8 {
+		t.Fatal("expected 8 tiles, got", tset.TileCount())
+	}
+	for i, tile := range tset.Tiles() {
+		if tile.Id != i {
+			t.Errorf("expected tile %d to have id %d, got %d", i, i, tile.Id)
+		}
+	}
+	if tile, _ := tset.Tile(5); tile != wall {
+		t.Error("expected the existing tile to keep it's id")
+	}
+
+	tset.FillTiles(10)
+	if tset.TileCount() != 10 || tset.NextTileID() != 10 {
+		t.Error("expected 10 tiles, got", tset.TileCount())
+	}
+}
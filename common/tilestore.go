@@ -0,0 +1,154 @@
+package common
+
+import (
+	"image"
+	"sort"
+)
+
+// cellStore holds the cells of a TileLayer
+type cellStore interface {
+	get(x, y int) Cell
+	put(x, y int, cell Cell)
+
+	// The region of the layer holding cells; for a fixed size layer this is the whole layer,
+	// for a chunked layer it covers all chunks that hold at least one tile.
+	bounds() image.Rectangle
+
+	// Regions of the layer, covering all tiles, that can be written out separately
+	chunks() []image.Rectangle
+}
+
+// fixedStore holds the cells of a layer in a fixed size map, with 0,0 as the top left
+type fixedStore struct {
+	width  int
+	height int
+	cells  []Cell
+}
+
+func newFixedStore(width, height int) *fixedStore {
+	return &fixedStore{
+		width:  width,
+		height: height,
+		cells:  make([]Cell, width*height),
+	}
+}
+
+func (s *fixedStore) inBounds(x, y int) bool {
+	return x >= 0 && x < s.width && y >= 0 && y < s.height
+}
+
+func (s *fixedStore) get(x, y int) Cell {
+	if !s.inBounds(x, y) {
+		return Cell{}
+	}
+	return s.cells[y*s.width+x]
+}
+
+func (s *fixedStore) put(x, y int, cell Cell) {
+	if !s.inBounds(x, y) {
+		return
+	}
+	s.cells[y*s.width+x] = cell
+}
+
+func (s *fixedStore) bounds() image.Rectangle {
+	return image.Rect(0, 0, s.width, s.height)
+}
+
+func (s *fixedStore) chunks() []image.Rectangle {
+	return []image.Rectangle{s.bounds()}
+}
+
+// chunkedStore holds the cells of a layer in an infinite map. Cells are kept in square chunks
+// that are created as tiles are placed, so coordinates may be negative & regions sparse.
+type chunkedStore struct {
+	size int
+	data map[image.Point][]Cell // chunk coordinate (cell coordinate / size) -> cells
+}
+
+func newChunkedStore(size int) *chunkedStore {
+	if size < 1 {
+		size = DefaultChunkSize
+	}
+	return &chunkedStore{
+		size: size,
+		data: make(map[image.Point][]Cell),
+	}
+}
+
+// Chunk coordinate for the given cell, and the index of the cell within the chunk
+func (s *chunkedStore) locate(x, y int) (image.Point, int) {
+	cx, cy := floorDiv(x, s.size), floorDiv(y, s.size)
+	return image.Pt(cx, cy), (y-cy*s.size)*s.size + (x - cx*s.size)
+}
+
+func (s *chunkedStore) get(x, y int) Cell {
+	key, index := s.locate(x, y)
+	chunk, ok := s.data[key]
+	if !ok {
+		return Cell{}
+	}
+	return chunk[index]
+}
+
+func (s *chunkedStore) put(x, y int, cell Cell) {
+	key, index := s.locate(x, y)
+	chunk, ok := s.data[key]
+	if !ok {
+		if cell.IsEmpty() {
+			return // no need to create a chunk to hold nothing
+		}
+		chunk = make([]Cell, s.size*s.size)
+		s.data[key] = chunk
+	}
+	chunk[index] = cell
+
+	if cell.IsEmpty() && chunkIsEmpty(chunk) {
+		delete(s.data, key)
+	}
+}
+
+func (s *chunkedStore) bounds() image.Rectangle {
+	result := image.Rectangle{}
+	for _, rect := range s.chunks() {
+		result = result.Union(rect)
+	}
+	return result
+}
+
+func (s *chunkedStore) chunks() []image.Rectangle {
+	keys := []image.Point{}
+	for key := range s.data {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Y == keys[j].Y {
+			return keys[i].X < keys[j].X
+		}
+		return keys[i].Y < keys[j].Y
+	})
+
+	result := []image.Rectangle{}
+	for _, key := range keys {
+		result = append(result, image.Rect(key.X*s.size, key.Y*s.size, (key.X+1)*s.size, (key.Y+1)*s.size))
+	}
+	return result
+}
+
+func chunkIsEmpty(chunk []Cell) bool {
+	for _, cell := range chunk {
+		if !cell.IsEmpty() {
+			return false
+		}
+	}
+	return true
+}
+
+// Integer division rounding towards negative infinity (so -1 / 16 = -1)
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,267 @@
+package common
+
+import (
+	"image/color"
+)
+
+// Positions of the edges & corners of a tile in a WangID, clockwise from the top edge
+// (as Tiled orders them)
+const (
+	WangTop = iota
+	WangTopRight
+	WangRight
+	WangBottomRight
+	WangBottom
+	WangBottomLeft
+	WangLeft
+	WangTopLeft
+)
+
+// WangID gives the colour of each edge & corner of a tile within a WangSet, ordered as
+// WangTop, WangTopRight .. WangTopLeft. Colours are 1 based indexes into the set's
+// Colours(), 0 means no colour.
+type WangID [8]int
+
+// The colours of the four corners, in order top right, bottom right, bottom left, top left
+func (w WangID) Corners() [4]int {
+	return [4]int{w[WangTopRight], w[WangBottomRight], w[WangBottomLeft], w[WangTopLeft]}
+}
+
+// The colours of the four edges, in order top, right, bottom, left
+func (w WangID) Edges() [4]int {
+	return [4]int{w[WangTop], w[WangRight], w[WangBottom], w[WangLeft]}
+}
+
+// WangSet is a set of tiles whose edges and / or corners are labelled with colours
+// (terrains, in the old sense) so tools can pick tiles that join together.
+// Tiled 1.5+ uses these in place of Terrain.
+type WangSet struct {
+	parent *Tileset
+
+	Name string
+	Type string // one of WangSetTypeCorner, WangSetTypeEdge or WangSetTypeMixed
+	Tile *Tile  // tile representing the set (if any)
+
+	colours    []*WangColor
+	tiles      []*Tile
+	wangIDs    map[*Tile]WangID
+	properties map[string]*Property
+}
+
+// Create a new wang set of the given type (WangSetTypeCorner, WangSetTypeEdge or WangSetTypeMixed)
+func NewWangSet(name, kind string) *WangSet {
+	return &WangSet{
+		Name: name,
+		Type: kind,
+		colours: []*WangColor{},
+		tiles: []*Tile{},
+		wangIDs: make(map[*Tile]WangID),
+		properties: make(map[string]*Property),
+	}
+}
+
+// The tileset this set belongs to (if any)
+func (w *WangSet) Tileset() *Tileset {
+	return w.parent
+}
+
+// Add colours to the set, the first colour in a set is colour 1 in a WangID
+func (w *WangSet) AddColours(in ...*WangColor) {
+	w.colours = append(w.colours, in...)
+}
+
+func (w *WangSet) Colours() []*WangColor {
+	return w.colours
+}
+
+// The colour with the given 1 based index (as used in a WangID)
+func (w *WangSet) Colour(index int) (*WangColor, bool) {
+	if index < 1 || index > len(w.colours) {
+		return nil, false
+	}
+	return w.colours[index - 1], true
+}
+
+// The 1 based index of the given colour (as used in a WangID), or 0 if it isn't in the set
+func (w *WangSet) ColourIndex(in *WangColor) int {
+	for i, c := range w.colours {
+		if c == in {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Set the colours of the given tile's edges & corners, adding the tile to the set
+func (w *WangSet) SetWangID(tile *Tile, id WangID) {
+	if _, ok := w.wangIDs[tile]; !ok {
+		w.tiles = append(w.tiles, tile)
+	}
+	w.wangIDs[tile] = id
+}
+
+// The colours of the given tile's edges & corners, if the tile is in the set
+func (w *WangSet) WangID(tile *Tile) (WangID, bool) {
+	id, ok := w.wangIDs[tile]
+	return id, ok
+}
+
+// Remove the given tile from the set
+func (w *WangSet) RemoveTile(tile *Tile) {
+	if _, ok := w.wangIDs[tile]; !ok {
+		return
+	}
+	delete(w.wangIDs, tile)
+	for i, t := range w.tiles {
+		if t == tile {
+			w.tiles = append(w.tiles[:i], w.tiles[i + 1:]...)
+			break
+		}
+	}
+}
+
+// Tiles in the set, in the order they were added
+func (w *WangSet) Tiles() []*Tile {
+	return w.tiles
+}
+
+func (w *WangSet) UpdateProperties(props ...*Property) {
+	for _, prop := range props {
+		w.properties[prop.Name()] = prop
+	}
+}
+
+// Remove the named properties (if present)
+func (w *WangSet) RemoveProperties(names ...string) {
+	for _, name := range names {
+		delete(w.properties, name)
+	}
+}
+
+func (w *WangSet) Property(name string) (*Property, bool) {
+	prop, ok := w.properties[name]
+	return prop, ok
+}
+
+func (w *WangSet) Properties() []*Property {
+	results := []*Property{}
+	for _, p := range w.properties {
+		results = append(results, p)
+	}
+	return results
+}
+
+// WangColor is a colour (a terrain type) edges & corners of tiles in a WangSet can have
+type WangColor struct {
+	Name        string
+	Colour      *color.RGBA // the colour Tiled shows for this in the editor
+	Tile        *Tile       // tile representing the colour (if any)
+	Probability float64     // relative chance of picking this colour where there's a choice
+
+	properties map[string]*Property
+}
+
+func NewWangColor(name string) *WangColor {
+	return &WangColor{
+		Name: name,
+		Probability: 1,
+		properties: make(map[string]*Property),
+	}
+}
+
+func (w *WangColor) UpdateProperties(props ...*Property) {
+	for _, prop := range props {
+		w.properties[prop.Name()] = prop
+	}
+}
+
+// Remove the named properties (if present)
+func (w *WangColor) RemoveProperties(names ...string) {
+	for _, name := range names {
+		delete(w.properties, name)
+	}
+}
+
+func (w *WangColor) Property(name string) (*Property, bool) {
+	prop, ok := w.properties[name]
+	return prop, ok
+}
+
+func (w *WangColor) Properties() []*Property {
+	results := []*Property{}
+	for _, p := range w.properties {
+		results = append(results, p)
+	}
+	return results
+}
+
+func (t *Tileset) AddWangSets(in ...*WangSet) {
+	for _, set := range in {
+		set.parent = t
+		t.wangsets = append(t.wangsets, set)
+	}
+}
+
+func (t *Tileset) WangSets() []*WangSet {
+	return t.wangsets
+}
+
+// Find a wang set by name
+func (t *Tileset) WangSet(name string) (*WangSet, bool) {
+	for _, set := range t.wangsets {
+		if set.Name == name {
+			return set, true
+		}
+	}
+	return nil, false
+}
+
+// Convert the tileset's (legacy) Terrain into a corner WangSet with one colour per terrain,
+// giving each tile with terrain a WangID with the same corners. The new set is added to the
+// tileset & returned, the terrain itself is left as it is.
+// Nb. there is no conversion back; wang sets can describe tiles terrain cannot.
+func (t *Tileset) MigrateTerrain(name string) *WangSet {
+	set := t.TerrainWangSet(name)
+	t.AddWangSets(set)
+	return set
+}
+
+// As MigrateTerrain but the set isn't added to the tileset. Colour i of the set is
+// Terrain()[i-1].
+func (t *Tileset) TerrainWangSet(name string) *WangSet {
+	set := NewWangSet(name, WangSetTypeCorner)
+
+	colours := make(map[*Terrain]int)
+	for _, terr := range t.terrain {
+		colour := NewWangColor(terr.Name)
+		colour.Tile = terr.Tile
+		for _, prop := range terr.Properties() {
+			colour.UpdateProperties(prop.Copy())
+		}
+		set.AddColours(colour)
+		colours[terr] = len(set.colours)
+	}
+
+	for _, tile := range t.tiles {
+		id := WangID{}
+		found := false
+		corners := map[int]*Terrain{
+			WangTopLeft: tile.TopLeftTerrain(),
+			WangTopRight: tile.TopRightTerrain(),
+			WangBottomLeft: tile.BottomLeftTerrain(),
+			WangBottomRight: tile.BottomRightTerrain(),
+		}
+		for index, terr := range corners {
+			if terr == nil {
+				continue
+			}
+			id[index] = colours[terr]
+			found = found || id[index] > 0
+		}
+		if found {
+			set.SetWangID(tile, id)
+		}
+	}
+
+	return set
+}
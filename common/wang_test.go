@@ -0,0 +1,45 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestMigrateTerrain(t *testing.T) {
+	grass, sand := NewTerrain("grass"), NewTerrain("sand")
+	sand.UpdateProperties(NewProp("slow").SetBool(true))
+
+	full, half, plain := NewTile("full.png"), NewTile("half.png"), NewTile("plain.png")
+	tset := NewTileset("ground", full, half, plain)
+	tset.AddTerrain(grass, sand)
+	sand.Tile = half
+
+	for _, set := range []func(*Terrain){full.SetTopLeftTerrain, full.SetTopRightTerrain, full.SetBottomLeftTerrain, full.SetBottomRightTerrain} {
+		set(grass)
+	}
+	half.SetTopLeftTerrain(sand)
+	half.SetTopRightTerrain(sand)
+	half.SetBottomRightTerrain(grass) // bottom left is left empty
+
+	set := tset.MigrateTerrain("terrain")
+	if found, ok := tset.WangSet("terrain"); !ok || found != set || set.Type != WangSetTypeCorner {
+		t.Fatal("expected migrated corner set to be added to the tileset")
+	}
+
+	colours := set.Colours()
+	if len(colours) != 2 || colours[0].Name != "grass" || colours[1].Name != "sand" || colours[1].Tile != half {
+		t.Fatal("expected a colour per terrain, got", colours)
+	}
+	if slow, ok := colours[1].Property("slow"); !ok || !slow.AsBool() {
+		t.Error("expected terrain properties to be copied")
+	}
+
+	if id, ok := set.WangID(full); !ok || id != (WangID{0, 1, 0, 1, 0, 1, 0, 1}) {
+		t.Error("unexpected wang id for full tile", id)
+	}
+	if id, ok := set.WangID(half); !ok || id != (WangID{0, 2, 0, 1, 0, 0, 0, 2}) {
+		t.Error("unexpected wang id for half tile", id)
+	}
+	if _, ok := set.WangID(plain); ok || len(set.Tiles()) != 2 {
+		t.Error("expected tiles without terrain to be left out")
+	}
+}
@@ -0,0 +1,80 @@
+package diff
+
+import (
+	"image"
+	"sort"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+// Note the cells of the given tile layers that differ, as rectangles
+func (d *differ) compareCells(path string, before, after *common.TileLayer) {
+	bounds := image.Rectangle{}
+	for _, rect := range append(before.Chunks(), after.Chunks()...) {
+		bounds = bounds.Union(rect)
+	}
+
+	changed := map[image.Point]bool{}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if Describe(before.GetCell(x, y)) != Describe(after.GetCell(x, y)) {
+				changed[image.Pt(x, y)] = true
+			}
+		}
+	}
+	if len(changed) == 0 {
+		return
+	}
+	d.report.Cells = append(d.report.Cells, CellChanges{Path: path, Cells: len(changed), Rects: Rectangles(changed)})
+}
+
+// Rectangles covering the given cells (& no others). Starting from the top left most cell not
+// yet covered each rectangle is grown as far right as it'll go, then down.
+func Rectangles(cells map[image.Point]bool) []Rect {
+	points := []image.Point{}
+	for p := range cells {
+		points = append(points, p)
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].Y != points[j].Y {
+			return points[i].Y < points[j].Y
+		}
+		return points[i].X < points[j].X
+	})
+
+	covered := map[image.Point]bool{}
+	free := func(x, y int) bool {
+		p := image.Pt(x, y)
+		return cells[p] && !covered[p]
+	}
+
+	result := []Rect{}
+	for _, p := range points {
+		if covered[p] {
+			continue
+		}
+		width := 1
+		for free(p.X+width, p.Y) {
+			width++
+		}
+		height := 1
+		for {
+			row := true
+			for x := p.X; x < p.X+width && row; x++ {
+				row = free(x, p.Y+height)
+			}
+			if !row {
+				break
+			}
+			height++
+		}
+
+		for y := p.Y; y < p.Y+height; y++ {
+			for x := p.X; x < p.X+width; x++ {
+				covered[image.Pt(x, y)] = true
+			}
+		}
+		result = append(result, Rect{X: p.X, Y: p.Y, Width: width, Height: height})
+	}
+	return result
+}
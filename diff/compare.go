@@ -0,0 +1,500 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+func (d *differ) compareMap(before, after *common.Map) {
+	path := "map"
+	d.field(path, "width", before.Width, after.Width)
+	d.field(path, "height", before.Height, after.Height)
+	d.field(path, "tilewidth", before.TileWidth, after.TileWidth)
+	d.field(path, "tileheight", before.TileHeight, after.TileHeight)
+	d.field(path, "orientation", before.Orientation(), after.Orientation())
+	d.field(path, "renderorder", before.RenderOrder(), after.RenderOrder())
+	d.field(path, "staggeraxis", before.StaggerAxis(), after.StaggerAxis())
+	d.field(path, "staggerindex", before.StaggerIndex(), after.StaggerIndex())
+	d.field(path, "hexsidelength", before.HexSideLength, after.HexSideLength)
+	d.field(path, "infinite", before.Infinite(), after.Infinite())
+	d.field(path, "backgroundcolor", before.BackgroundColor, after.BackgroundColor)
+	d.compareProperties(path, before, after)
+
+	d.compareTilesets(path, before.Tilesets(), after.Tilesets())
+	d.compareLayers(path, before.Layers(), after.Layers())
+	d.compareObjects(path, before.Layers(), after.Layers())
+}
+
+func (d *differ) compareTilesets(path string, before, after []*common.Tileset) {
+	beforeByName := map[string]*common.Tileset{}
+	for _, tset := range before {
+		beforeByName[tset.Name] = tset
+	}
+	afterByName := map[string]*common.Tileset{}
+	for _, tset := range after {
+		afterByName[tset.Name] = tset
+	}
+
+	for _, tset := range before {
+		if other, ok := afterByName[tset.Name]; ok {
+			d.compareTileset(join(path, "tileset", tset.Name), tset, other)
+		} else {
+			d.removed(join(path, "tileset", tset.Name))
+		}
+	}
+	for _, tset := range after {
+		if _, ok := beforeByName[tset.Name]; !ok {
+			d.added(join(path, "tileset", tset.Name))
+		}
+	}
+}
+
+func (d *differ) compareTileset(path string, before, after *common.Tileset) {
+	d.field(path, "firstgid", before.FirstGID, after.FirstGID)
+	d.field(path, "source", before.Source, after.Source)
+	d.field(path, "tilewidth", before.TileWidth, after.TileWidth)
+	d.field(path, "tileheight", before.TileHeight, after.TileHeight)
+	d.field(path, "spacing", before.Spacing, after.Spacing)
+	d.field(path, "margin", before.Margin, after.Margin)
+	d.field(path, "offsetx", before.OffsetX, after.OffsetX)
+	d.field(path, "offsety", before.OffsetY, after.OffsetY)
+	d.field(path, "image", before.ImageSource, after.ImageSource)
+	d.field(path, "imagewidth", before.ImageWidth, after.ImageWidth)
+	d.field(path, "imageheight", before.ImageHeight, after.ImageHeight)
+	d.field(path, "trans", before.TransparentColour, after.TransparentColour)
+	d.field(path, "columns", before.Columns, after.Columns)
+	d.compareProperties(path, before, after)
+
+	// terrain, by name
+	afterTerrain := map[string]*common.Terrain{}
+	for _, ter := range after.Terrain() {
+		afterTerrain[ter.Name] = ter
+	}
+	beforeTerrain := map[string]bool{}
+	for _, ter := range before.Terrain() {
+		beforeTerrain[ter.Name] = true
+		terPath := join(path, "terrain", ter.Name)
+		other, ok := afterTerrain[ter.Name]
+		if !ok {
+			d.removed(terPath)
+			continue
+		}
+		d.field(terPath, "tile", ter.Tile, other.Tile)
+		d.compareProperties(terPath, ter, other)
+	}
+	for _, ter := range after.Terrain() {
+		if !beforeTerrain[ter.Name] {
+			d.added(join(path, "terrain", ter.Name))
+		}
+	}
+
+	// wang sets, by name
+	for _, set := range before.WangSets() {
+		setPath := join(path, "wangset", set.Name)
+		other, ok := after.WangSet(set.Name)
+		if !ok {
+			d.removed(setPath)
+			continue
+		}
+		d.compareWangSet(setPath, set, other)
+	}
+	for _, set := range after.WangSets() {
+		if _, ok := before.WangSet(set.Name); !ok {
+			d.added(join(path, "wangset", set.Name))
+		}
+	}
+
+	// tiles, by id
+	for _, tile := range before.Tiles() {
+		tilePath := join(path, "tile", tile.Id)
+		other, ok := after.Tile(tile.Id)
+		if !ok {
+			d.removed(tilePath)
+			continue
+		}
+		d.compareTile(tilePath, tile, other)
+	}
+	for _, tile := range after.Tiles() {
+		if _, ok := before.Tile(tile.Id); !ok {
+			d.added(join(path, "tile", tile.Id))
+		}
+	}
+}
+
+func (d *differ) compareWangSet(path string, before, after *common.WangSet) {
+	d.field(path, "type", before.Type, after.Type)
+	d.field(path, "tile", before.Tile, after.Tile)
+	d.compareProperties(path, before, after)
+
+	afterColours := map[string]*common.WangColor{}
+	for _, colour := range after.Colours() {
+		afterColours[colour.Name] = colour
+	}
+	beforeColours := map[string]bool{}
+	for _, colour := range before.Colours() {
+		beforeColours[colour.Name] = true
+		colourPath := join(path, "wangcolor", colour.Name)
+		other, ok := afterColours[colour.Name]
+		if !ok {
+			d.removed(colourPath)
+			continue
+		}
+		d.field(colourPath, "color", colour.Colour, other.Colour)
+		d.field(colourPath, "tile", colour.Tile, other.Tile)
+		d.field(colourPath, "probability", colour.Probability, other.Probability)
+		d.compareProperties(colourPath, colour, other)
+	}
+	for _, colour := range after.Colours() {
+		if !beforeColours[colour.Name] {
+			d.added(join(path, "wangcolor", colour.Name))
+		}
+	}
+
+	// wang tiles, by tile id
+	afterIDs := map[int]common.WangID{}
+	for _, tile := range after.Tiles() {
+		afterIDs[tile.Id], _ = after.WangID(tile)
+	}
+	beforeIDs := map[int]bool{}
+	for _, tile := range before.Tiles() {
+		beforeIDs[tile.Id] = true
+		tilePath := join(path, "wangtile", tile.Id)
+		id, _ := before.WangID(tile)
+		other, ok := afterIDs[tile.Id]
+		if !ok {
+			d.removed(tilePath)
+			continue
+		}
+		d.field(tilePath, "wangid", id, other)
+	}
+	for _, tile := range after.Tiles() {
+		if !beforeIDs[tile.Id] {
+			d.added(join(path, "wangtile", tile.Id))
+		}
+	}
+}
+
+func (d *differ) compareTile(path string, before, after *common.Tile) {
+	d.field(path, "type", before.Type, after.Type)
+	d.field(path, "image", before.Source, after.Source)
+	d.field(path, "width", before.Width, after.Width)
+	d.field(path, "height", before.Height, after.Height)
+	d.field(path, "probability", before.Probability, after.Probability)
+	d.changed(path, "terrain", describeTerrain(before), describeTerrain(after))
+	d.changed(path, "animation", describeAnimation(before.Animation), describeAnimation(after.Animation))
+	d.compareProperties(path, before, after)
+
+	afterShapes := map[int]*common.Object{}
+	for _, shape := range after.CollisionShapes() {
+		afterShapes[shape.Id] = shape
+	}
+	beforeShapes := map[int]bool{}
+	for _, shape := range before.CollisionShapes() {
+		beforeShapes[shape.Id] = true
+		other, ok := afterShapes[shape.Id]
+		if !ok {
+			d.removed(join(path, "object", shape.Id))
+			continue
+		}
+		d.compareObject(join(path, "object", shape.Id), shape, other)
+	}
+	for _, shape := range after.CollisionShapes() {
+		if !beforeShapes[shape.Id] {
+			d.added(join(path, "object", shape.Id))
+		}
+	}
+}
+
+// The terrain of each corner of a tile, by name
+func describeTerrain(tile *common.Tile) string {
+	names := []string{}
+	for _, ter := range tile.Terrain() {
+		if ter == nil {
+			names = append(names, "")
+		} else {
+			names = append(names, ter.Name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// The tile & duration of each frame of an animation
+func describeAnimation(in *common.Animation) string {
+	if in == nil || len(in.Frames) == 0 {
+		return "none"
+	}
+	frames := []string{}
+	for _, frame := range in.Frames {
+		id := -1
+		if frame.Tile != nil {
+			id = frame.Tile.Id
+		}
+		frames = append(frames, fmt.Sprintf("%d:%dms", id, frame.Duration))
+	}
+	return strings.Join(frames, " ")
+}
+
+// The element name of each kind of layer
+func layerKind(layer common.Layer) string {
+	switch layer.(type) {
+	case *common.TileLayer:
+		return "layer"
+	case *common.ImageLayer:
+		return "imagelayer"
+	case *common.ObjectLayer:
+		return "objectgroup"
+	case *common.GroupLayer:
+		return "group"
+	}
+	return fmt.Sprintf("%T", layer)
+}
+
+// LayerKeys gives the name each layer is known by within it's parent in a Report's paths
+// (eg. layer[ground]); layers that would share a name are numbered in order (eg. layer[ground]#2).
+// Returns the names in order along with the layers by name.
+func LayerKeys(layers []common.Layer) ([]string, map[string]common.Layer) {
+	keys := []string{}
+	byKey := map[string]common.Layer{}
+	count := map[string]int{}
+	for _, layer := range layers {
+		key := fmt.Sprintf("%s[%s]", layerKind(layer), layer.LayerName())
+		count[key]++
+		if n := count[key]; n > 1 {
+			key = fmt.Sprintf("%s#%d", key, n)
+		}
+		keys = append(keys, key)
+		byKey[key] = layer
+	}
+	return keys, byKey
+}
+
+func (d *differ) compareLayers(path string, before, after []common.Layer) {
+	beforeKeys, beforeByKey := LayerKeys(before)
+	afterKeys, afterByKey := LayerKeys(after)
+
+	beforeOrder := []string{}
+	for _, key := range beforeKeys {
+		other, ok := afterByKey[key]
+		if !ok {
+			d.removed(path + "/" + key)
+			continue
+		}
+		beforeOrder = append(beforeOrder, key)
+		d.compareLayer(path+"/"+key, beforeByKey[key], other)
+	}
+	afterOrder := []string{}
+	for _, key := range afterKeys {
+		if _, ok := beforeByKey[key]; !ok {
+			d.added(path + "/" + key)
+			continue
+		}
+		afterOrder = append(afterOrder, key)
+	}
+	d.changed(path, "layer order", strings.Join(beforeOrder, ", "), strings.Join(afterOrder, ", "))
+}
+
+// Compare layers of the same kind & name, objects are compared separately (see compareObjects)
+func (d *differ) compareLayer(path string, before, after common.Layer) {
+	switch b := before.(type) {
+	case *common.TileLayer:
+		a := after.(*common.TileLayer)
+		d.field(path, "opacity", b.Opacity, a.Opacity)
+		d.field(path, "visible", b.Visible, a.Visible)
+		d.field(path, "offsetx", b.OffsetX, a.OffsetX)
+		d.field(path, "offsety", b.OffsetY, a.OffsetY)
+		d.field(path, "tintcolor", b.TintColour, a.TintColour)
+		d.field(path, "width", b.Width(), a.Width())
+		d.field(path, "height", b.Height(), a.Height())
+		d.field(path, "encoding", b.Encoding, a.Encoding)
+		d.field(path, "compression", b.Compression, a.Compression)
+		d.compareProperties(path, b, a)
+		d.compareCells(path, b, a)
+	case *common.ImageLayer:
+		a := after.(*common.ImageLayer)
+		d.field(path, "opacity", b.Opacity, a.Opacity)
+		d.field(path, "visible", b.Visible, a.Visible)
+		d.field(path, "offsetx", b.OffsetX, a.OffsetX)
+		d.field(path, "offsety", b.OffsetY, a.OffsetY)
+		d.field(path, "tintcolor", b.TintColour, a.TintColour)
+		d.field(path, "image", b.ImageSource, a.ImageSource)
+		d.field(path, "width", b.Width, a.Width)
+		d.field(path, "height", b.Height, a.Height)
+		d.field(path, "trans", b.TransparentColour, a.TransparentColour)
+		d.compareProperties(path, b, a)
+	case *common.ObjectLayer:
+		a := after.(*common.ObjectLayer)
+		d.field(path, "color", b.Colour, a.Colour)
+		d.field(path, "opacity", b.Opacity, a.Opacity)
+		d.field(path, "visible", b.Visible, a.Visible)
+		d.field(path, "offsetx", b.OffsetX, a.OffsetX)
+		d.field(path, "offsety", b.OffsetY, a.OffsetY)
+		d.field(path, "draworder", b.DrawOrder, a.DrawOrder)
+		d.field(path, "tintcolor", b.TintColour, a.TintColour)
+		d.compareProperties(path, b, a)
+	case *common.GroupLayer:
+		a := after.(*common.GroupLayer)
+		d.field(path, "opacity", b.Opacity, a.Opacity)
+		d.field(path, "visible", b.Visible, a.Visible)
+		d.field(path, "offsetx", b.OffsetX, a.OffsetX)
+		d.field(path, "offsety", b.OffsetY, a.OffsetY)
+		d.field(path, "tintcolor", b.TintColour, a.TintColour)
+		d.compareProperties(path, b, a)
+		d.compareLayers(path, b.Layers(), a.Layers())
+	}
+}
+
+// An object along with the path of the layer it's in
+type placedObject struct {
+	layer  string
+	object *common.Object
+}
+
+// Every object in the given layers (& groups within them) by id
+func objectsByID(path string, layers []common.Layer, into map[int]placedObject) {
+	keys, byKey := LayerKeys(layers)
+	for _, key := range keys {
+		switch layer := byKey[key].(type) {
+		case *common.ObjectLayer:
+			for _, obj := range layer.Objects() {
+				into[obj.Id] = placedObject{layer: path + "/" + key, object: obj}
+			}
+		case *common.GroupLayer:
+			objectsByID(path+"/"+key, layer.Layers(), into)
+		}
+	}
+}
+
+// Compare the objects of the given layers by id, as objects may move between layers
+func (d *differ) compareObjects(path string, before, after []common.Layer) {
+	beforeByID, afterByID := map[int]placedObject{}, map[int]placedObject{}
+	objectsByID(path, before, beforeByID)
+	objectsByID(path, after, afterByID)
+
+	ids := []int{}
+	for id := range beforeByID {
+		ids = append(ids, id)
+	}
+	for id := range afterByID {
+		if _, ok := beforeByID[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		b, inBefore := beforeByID[id]
+		a, inAfter := afterByID[id]
+		if !inAfter {
+			d.removed(join(b.layer, "object", id))
+		} else if !inBefore {
+			d.added(join(a.layer, "object", id))
+		} else {
+			objPath := join(a.layer, "object", id)
+			d.changed(objPath, "layer", b.layer, a.layer)
+			d.compareObject(objPath, b.object, a.object)
+		}
+	}
+}
+
+func (d *differ) compareObject(path string, before, after *common.Object) {
+	d.field(path, "name", before.Name, after.Name)
+	d.field(path, "type", before.Type, after.Type)
+	d.field(path, "x", before.X, after.X)
+	d.field(path, "y", before.Y, after.Y)
+	d.field(path, "width", before.Width, after.Width)
+	d.field(path, "height", before.Height, after.Height)
+	d.field(path, "rotation", before.Rotation, after.Rotation)
+	d.field(path, "visible", before.Visible, after.Visible)
+	d.changed(path, "shape", shapeNames[before.Kind()], shapeNames[after.Kind()])
+	d.field(path, "points", before.Points(), after.Points())
+	d.changed(path, "text", describeText(before.Text()), describeText(after.Text()))
+	d.field(path, "tile", before.TileCell(), after.TileCell())
+	d.changed(path, "template", describeTemplate(before.Template()), describeTemplate(after.Template()))
+	d.compareProperties(path, before, after)
+}
+
+// The name of each kind of object, as it's shape
+var shapeNames = map[string]string{
+	common.ObjectTypeRectangle: "rectangle",
+	common.ObjectTypePoint:     "point",
+	common.ObjectTypeEllipse:   "ellipse",
+	common.ObjectTypePolygon:   "polygon",
+	common.ObjectTypePolyline:  "polyline",
+	common.ObjectTypeText:      "text",
+	common.ObjectTypeTile:      "tile",
+}
+
+func describeText(in *common.Text) string {
+	if in == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%q (%s %dpx %s, %s/%s bold:%v italic:%v underline:%v strikeout:%v kerning:%v wrap:%v)",
+		in.Value, in.FontFamily, in.PixelSize, Describe(in.Colour), in.HAlign, in.VAlign,
+		in.Bold, in.Italic, in.Underline, in.Strikeout, in.Kerning, in.Wrap)
+}
+
+func describeTemplate(in *common.Template) string {
+	if in == nil {
+		return "none"
+	}
+	return in.Source
+}
+
+func (d *differ) compareProperties(path string, before, after common.PropertyHolder) {
+	beforeNames, beforeProps := propertiesByName(before)
+	afterNames, afterProps := propertiesByName(after)
+
+	for _, name := range beforeNames {
+		propPath := join(path, "property", name)
+		other, ok := afterProps[name]
+		if !ok {
+			d.removed(propPath)
+			continue
+		}
+		if !beforeProps[name].Equal(other) {
+			d.changed(propPath, "value", describeProperty(beforeProps[name]), describeProperty(other))
+		}
+	}
+	for _, name := range afterNames {
+		if _, ok := beforeProps[name]; !ok {
+			d.added(join(path, "property", name))
+		}
+	}
+}
+
+// A property's type & value, eg. int 3 or class Stats {hp=int 10}
+func describeProperty(p *common.Property) string {
+	kind := p.Type()
+	if p.PropertyType() != "" {
+		kind = fmt.Sprintf("%s %s", kind, p.PropertyType())
+	}
+
+	var value interface{}
+	switch p.Type() {
+	case common.PropertyTypeInt:
+		value = p.AsInt()
+	case common.PropertyTypeObject:
+		value = p.AsObject()
+	case common.PropertyTypeFloat:
+		value = p.AsFloat()
+	case common.PropertyTypeBool:
+		value = p.AsBool()
+	case common.PropertyTypeColour:
+		value = p.AsColour()
+	case common.PropertyTypeFile:
+		value = p.AsFilepath()
+	case common.PropertyTypeClass:
+		members := []string{}
+		for _, member := range p.Members() {
+			members = append(members, member.Name()+"="+describeProperty(member))
+		}
+		sort.Strings(members)
+		return fmt.Sprintf("%s {%s}", kind, strings.Join(members, ", "))
+	default:
+		value = p.AsString()
+	}
+	return fmt.Sprintf("%s %s", kind, Describe(value))
+}
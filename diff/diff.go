@@ -0,0 +1,189 @@
+// Package diff compares two common.Maps, reporting the layers, tilesets, tiles, properties and
+// objects added, removed or changed between them, along with the cells of each tile layer that
+// changed (as a few rectangles, rather than cell by cell).
+//
+// Paths in a Report are in the same form as those of v1.DecodeError & validate.Issue
+// (eg. map/group[level]/layer[ground]/property[speed]). Layers & tilesets are matched by name,
+// objects by id & properties by name.
+package diff
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+// Kind of change
+type Kind string
+
+const (
+	Added   Kind = "added"
+	Removed Kind = "removed"
+	Changed Kind = "changed"
+)
+
+// Change is a single difference between two maps; something added or removed (in which case
+// Field is empty) or a field of something that's changed.
+type Change struct {
+	Kind   Kind   `json:"kind"`
+	Path   string `json:"path"`
+	Field  string `json:"field,omitempty"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("+ %s", c.Path)
+	case Removed:
+		return fmt.Sprintf("- %s", c.Path)
+	}
+	return fmt.Sprintf("~ %s: %s %s -> %s", c.Path, c.Field, c.Before, c.After)
+}
+
+// Rect is an area of cells Width by Height, with it's top left cell at X,Y
+type Rect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func (r Rect) Rectangle() image.Rectangle {
+	return image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height)
+}
+
+func (r Rect) String() string {
+	return fmt.Sprintf("%d,%d %dx%d", r.X, r.Y, r.Width, r.Height)
+}
+
+// CellChanges are the cells of a tile layer (in both maps) that hold a different tile, or are
+// flipped differently. Every changed cell is in exactly one of Rects.
+type CellChanges struct {
+	Path  string `json:"path"`
+	Cells int    `json:"cells"`
+	Rects []Rect `json:"rects"`
+}
+
+func (c CellChanges) String() string {
+	return fmt.Sprintf("~ %s: %d cells in %v", c.Path, c.Cells, c.Rects)
+}
+
+// Report of the differences between two maps
+type Report struct {
+	Changes []Change      `json:"changes"`
+	Cells   []CellChanges `json:"cells"`
+}
+
+// Whether the maps compared were the same
+func (r *Report) Empty() bool {
+	return len(r.Changes) == 0 && len(r.Cells) == 0
+}
+
+// Compare the given maps, reporting how 'after' differs from 'before'. Changes come in the order
+// of the map's settings, properties, tilesets, layers & then objects (by id).
+func Maps(before, after *common.Map) *Report {
+	d := &differ{report: &Report{Changes: []Change{}, Cells: []CellChanges{}}}
+	d.compareMap(before, after)
+	return d.report
+}
+
+// Compare the given tilesets (which may be from different maps), reporting how 'after' differs
+// from 'before'. Paths are as in a map's Report, whatever the names of the tilesets.
+func Tilesets(before, after *common.Tileset) *Report {
+	d := &differ{report: &Report{Changes: []Change{}, Cells: []CellChanges{}}}
+	d.compareTileset(join("map", "tileset", before.Name), before, after)
+	return d.report
+}
+
+// Holds the state of a single Maps call
+type differ struct {
+	report *Report
+}
+
+func (d *differ) added(path string) {
+	d.report.Changes = append(d.report.Changes, Change{Kind: Added, Path: path})
+}
+
+func (d *differ) removed(path string) {
+	d.report.Changes = append(d.report.Changes, Change{Kind: Removed, Path: path})
+}
+
+// Note a change to the given field if the before & after values differ
+func (d *differ) field(path, field string, before, after interface{}) {
+	d.changed(path, field, Describe(before), Describe(after))
+}
+
+// As field, for values already described
+func (d *differ) changed(path, field, before, after string) {
+	if before != after {
+		d.report.Changes = append(d.report.Changes, Change{Kind: Changed, Path: path, Field: field, Before: before, After: after})
+	}
+}
+
+// Describe a value as it's shown in a Report; values that describe the same are the same (tiles
+// are the same if they have the same id in a tileset of the same name, even in different maps)
+func Describe(v interface{}) string {
+	switch value := v.(type) {
+	case *color.RGBA:
+		if value == nil {
+			return "none"
+		}
+		return fmt.Sprintf("#%02x%02x%02x%02x", value.A, value.R, value.G, value.B)
+	case *common.Tile:
+		if value == nil {
+			return "none"
+		}
+		if value.Tileset() == nil {
+			return fmt.Sprintf("tile %d", value.Id)
+		}
+		return fmt.Sprintf("tile %d of %s", value.Id, value.Tileset().Name)
+	case common.Cell:
+		tile := Describe(value.Tile)
+		for _, flip := range []struct {
+			set  bool
+			name string
+		}{{value.FlipH, "h"}, {value.FlipV, "v"}, {value.FlipD, "d"}, {value.FlipHex, "hex"}} {
+			if flip.set && value.Tile != nil {
+				tile += " flipped " + flip.name
+			}
+		}
+		return tile
+	case *common.Text:
+		return describeText(value)
+	case *common.Property:
+		if value == nil {
+			return "none"
+		}
+		return describeProperty(value)
+	case string:
+		return fmt.Sprintf("%q", value)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// The path of an element within the element at the given path, named by it's kind & (if given)
+// it's name or id. eg. join("map", "layer", "ground") is map/layer[ground]
+func join(path, kind string, id interface{}) string {
+	name := kind
+	if s := fmt.Sprintf("%v", id); s != "" {
+		name = fmt.Sprintf("%s[%s]", kind, s)
+	}
+	return path + "/" + name
+}
+
+// Properties sorted by name, keyed by name
+func propertiesByName(holder common.PropertyHolder) ([]string, map[string]*common.Property) {
+	byName := map[string]*common.Property{}
+	names := []string{}
+	for _, prop := range holder.Properties() {
+		byName[prop.Name()] = prop
+		names = append(names, prop.Name())
+	}
+	sort.Strings(names)
+	return names, byName
+}
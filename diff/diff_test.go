@@ -0,0 +1,215 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+// A small map; a 4x3 layer of tiles from a single tileset, with an object layer in a group
+func baseMap() *common.Map {
+	m := common.NewMap(common.Width(4), common.Height(3), common.TileWidth(16), common.TileHeight(16))
+	m.UpdateProperties(common.NewProp("music").SetString("calm.ogg"))
+
+	tset := m.NewTileset("ground", common.NewTile("grass.png"), common.NewTile("sand.png"))
+	tiles := tset.Tiles()
+
+	layer := m.NewTileLayer("ground")
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 4; x++ {
+			layer.Put(x, y, tiles[0])
+		}
+	}
+
+	group := m.NewGroupLayer("level")
+	objects := group.NewObjectLayer("objects")
+	chest := common.NewObject("chest")
+	chest.UpdateProperties(common.NewProp("gold").SetInt(10))
+	door := common.NewObject("door")
+	objects.AddObjects(chest, door)
+	m.FinalizeIDs()
+	return m
+}
+
+func summarise(r *Report) []string {
+	result := []string{}
+	for _, c := range r.Changes {
+		result = append(result, c.String())
+	}
+	for _, c := range r.Cells {
+		result = append(result, c.String())
+	}
+	return result
+}
+
+func expectChanges(t *testing.T, r *Report, expect ...string) {
+	t.Helper()
+	got := summarise(r)
+	if strings.Join(got, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("expected changes\n%s\ngot\n%s", strings.Join(expect, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestSameMaps(t *testing.T) {
+	r := Maps(baseMap(), baseMap())
+	if !r.Empty() {
+		t.Error("expected no changes, got", summarise(r))
+	}
+}
+
+func TestMapChanges(t *testing.T) {
+	after := baseMap()
+	after.Width = 5
+	after.UpdateProperties(common.NewProp("music").SetString("tense.ogg"), common.NewProp("dark").SetBool(true))
+	after.NewTileset("water", common.NewTile("water.png"))
+	after.TileLayers()[0].Opacity = 0.5
+	after.NewImageLayer("sky", "sky.png")
+
+	r := Maps(baseMap(), after)
+	expectChanges(t, r,
+		"~ map: width 4 -> 5",
+		`~ map/property[music]: value string "calm.ogg" -> string "tense.ogg"`,
+		"+ map/property[dark]",
+		"+ map/tileset[water]",
+		"~ map/layer[ground]: opacity 1 -> 0.5",
+		"+ map/imagelayer[sky]",
+	)
+}
+
+func TestRemovedAndReordered(t *testing.T) {
+	before := baseMap()
+	before.NewTileLayer("decor")
+
+	after := baseMap()
+	after.NewTileLayer("decor")
+	if err := after.MoveLayer(after.TileLayers()[1], 0); err != nil {
+		t.Fatal(err)
+	}
+	after.RemoveLayer(after.TileLayers()[1])
+
+	r := Maps(before, after)
+	expectChanges(t, r,
+		"- map/layer[ground]",
+		"~ map: layer order group[level], layer[decor] -> layer[decor], group[level]",
+	)
+
+	after = baseMap()
+	after.NewTileLayer("decor")
+	if err := after.MoveLayer(after.TileLayers()[1], 0); err != nil {
+		t.Fatal(err)
+	}
+	r = Maps(before, after)
+	expectChanges(t, r,
+		"~ map: layer order layer[ground], group[level], layer[decor] -> layer[decor], layer[ground], group[level]",
+	)
+}
+
+func TestObjectChanges(t *testing.T) {
+	after := baseMap()
+	objects := after.GroupLayers()[0].Layers()[0].(*common.ObjectLayer)
+	chest, _ := objects.Object(1)
+	chest.X = 32
+	chest.UpdateProperties(common.NewProp("gold").SetInt(20))
+	door, _ := objects.Object(2)
+	objects.RemoveObject(door)
+	moved := after.NewObjectLayer("doors")
+	moved.AddObjects(door)
+	door.SetPoint()
+	objects.AddObjects(common.NewObject("key"))
+	after.FinalizeIDs()
+
+	r := Maps(baseMap(), after)
+	expectChanges(t, r,
+		"+ map/objectgroup[doors]",
+		"~ map/group[level]/objectgroup[objects]/object[1]: x 0 -> 32",
+		"~ map/group[level]/objectgroup[objects]/object[1]/property[gold]: value int 10 -> int 20",
+		"~ map/objectgroup[doors]/object[2]: layer map/group[level]/objectgroup[objects] -> map/objectgroup[doors]",
+		"~ map/objectgroup[doors]/object[2]: shape rectangle -> point",
+		"+ map/group[level]/objectgroup[objects]/object[3]",
+	)
+}
+
+func TestCellChanges(t *testing.T) {
+	after := baseMap()
+	sand, _ := after.Tilesets()[0].Tile(1)
+	layer := after.TileLayers()[0]
+	layer.Put(1, 0, sand)
+	layer.Put(2, 0, sand)
+	layer.Put(1, 1, sand)
+	layer.Put(2, 1, sand)
+	layer.PutCell(3, 2, common.Cell{Tile: layer.Get(3, 2), FlipH: true})
+
+	r := Maps(baseMap(), after)
+	expectChanges(t, r, "~ map/layer[ground]: 5 cells in [1,0 2x2 3,2 1x1]")
+}
+
+func TestTilesets(t *testing.T) {
+	before := common.NewTileset("things", common.NewTile("barrel.png"))
+	after := common.NewTileset("things", common.NewTile("crate.png"))
+	after.FirstGID = 3
+	expectChanges(t, Tilesets(before, after),
+		"~ map/tileset[things]: firstgid 0 -> 3",
+		`~ map/tileset[things]/tile[0]: image "barrel.png" -> "crate.png"`,
+	)
+}
+
+func TestRectangles(t *testing.T) {
+	cases := []struct {
+		Cells  []image.Point
+		Expect []Rect
+	}{
+		{[]image.Point{{0, 0}}, []Rect{{0, 0, 1, 1}}},
+		{[]image.Point{{0, 0}, {1, 0}, {2, 0}, {0, 1}, {1, 1}, {2, 1}}, []Rect{{0, 0, 3, 2}}},
+		// an L shape
+		{[]image.Point{{0, 0}, {0, 1}, {1, 1}}, []Rect{{0, 0, 1, 2}, {1, 1, 1, 1}}},
+		{[]image.Point{{-2, -2}, {5, 5}}, []Rect{{-2, -2, 1, 1}, {5, 5, 1, 1}}},
+	}
+
+	for _, tc := range cases {
+		cells := map[image.Point]bool{}
+		for _, p := range tc.Cells {
+			cells[p] = true
+		}
+		got := Rectangles(cells)
+		if len(got) != len(tc.Expect) {
+			t.Errorf("expected %v got %v", tc.Expect, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.Expect[i] {
+				t.Errorf("expected %v got %v", tc.Expect, got)
+				break
+			}
+		}
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	after := baseMap()
+	after.Height = 4
+	r := Maps(baseMap(), after)
+
+	buf := &bytes.Buffer{}
+	if err := r.WriteText(buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "~ map: height 3 -> 4\n" {
+		t.Errorf("unexpected text %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := r.WriteJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	read := &Report{}
+	if err := json.Unmarshal(buf.Bytes(), read); err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Changes) != 1 || read.Changes[0] != r.Changes[0] {
+		t.Errorf("expected %v got %v", r.Changes, read.Changes)
+	}
+}
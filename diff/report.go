@@ -0,0 +1,39 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Write the report as text, a line per change, eg.
+//
+//	~ map/layer[ground]: opacity 1 -> 0.5
+//	+ map/layer[decor]
+//	- map/tileset[old]
+//	~ map/layer[ground]: 5 cells in [0,0 4x1 2,3 1x1]
+func (r *Report) WriteText(w io.Writer) error {
+	for _, change := range r.Changes {
+		_, err := fmt.Fprintln(w, change)
+		if err != nil {
+			return err
+		}
+	}
+	for _, cells := range r.Cells {
+		_, err := fmt.Fprintln(w, cells)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write the report as (indented) JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
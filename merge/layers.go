@@ -0,0 +1,217 @@
+package merge
+
+import (
+	"image"
+
+	"github.com/voidshard/libtmx/common"
+	"github.com/voidshard/libtmx/diff"
+)
+
+// Somewhere layers are kept, ie. a map or a group
+type layerParent interface {
+	Layers() []common.Layer
+	NewTileLayer(name string) *common.TileLayer
+	NewImageLayer(name, imageSource string) *common.ImageLayer
+	NewObjectLayer(name string) *common.ObjectLayer
+	NewGroupLayer(name string) *common.GroupLayer
+	MoveLayer(l common.Layer, index int) error
+	RemoveLayer(l common.Layer) bool
+}
+
+// Merge the layers of a parent, matched by kind & name (see diff.LayerKeys). Layers theirs added
+// are copied into ours, near where theirs put them.
+func (m *merger) mergeLayers(path string, parent layerParent, base, ours, theirs []common.Layer) {
+	baseKeys, baseByKey := diff.LayerKeys(base)
+	_, oursByKey := diff.LayerKeys(ours)
+	theirsKeys, theirsByKey := diff.LayerKeys(theirs)
+
+	for _, key := range baseKeys {
+		layerPath := path + "/" + key
+		o, inOurs := oursByKey[key]
+		t, inTheirs := theirsByKey[key]
+		switch {
+		case inOurs && inTheirs:
+			m.mergeLayer(layerPath, baseByKey[key], o, t)
+		case inOurs:
+			if changedUnder(m.oursChanges, layerPath) {
+				m.conflict(Conflict{Reason: RemovedByTheirs, Path: layerPath})
+			} else {
+				parent.RemoveLayer(o)
+			}
+		case inTheirs:
+			if changedUnder(m.theirsChanges, layerPath) {
+				m.conflict(Conflict{Reason: RemovedByOurs, Path: layerPath})
+			}
+		}
+	}
+
+	for i, key := range theirsKeys {
+		if _, ok := baseByKey[key]; ok {
+			continue
+		}
+		layerPath := path + "/" + key
+		if _, ok := oursByKey[key]; ok {
+			m.conflict(Conflict{Reason: AddedByBoth, Path: layerPath})
+			continue
+		}
+		m.copyLayer(layerPath, parent, i, theirsByKey[key])
+	}
+}
+
+// Merge layers of the same kind & name, objects are merged separately (see mergeObjects)
+func (m *merger) mergeLayer(path string, base, ours, theirs common.Layer) {
+	switch o := ours.(type) {
+	case *common.TileLayer:
+		b, t := base.(*common.TileLayer), theirs.(*common.TileLayer)
+		m.mergeFields(path,
+			field{"opacity", b.Opacity, o.Opacity, t.Opacity, func() { o.Opacity = t.Opacity }},
+			field{"visible", b.Visible, o.Visible, t.Visible, func() { o.Visible = t.Visible }},
+			field{"offsetx", b.OffsetX, o.OffsetX, t.OffsetX, func() { o.OffsetX = t.OffsetX }},
+			field{"offsety", b.OffsetY, o.OffsetY, t.OffsetY, func() { o.OffsetY = t.OffsetY }},
+			field{"tintcolor", b.TintColour, o.TintColour, t.TintColour, func() { o.TintColour = t.TintColour }},
+			field{"encoding", b.Encoding, o.Encoding, t.Encoding, func() { o.Encoding = t.Encoding }},
+			field{"compression", b.Compression, o.Compression, t.Compression, func() { o.Compression = t.Compression }},
+		)
+		m.mergeProperties(path, b, o, t)
+		m.mergeCells(path, b, o, t)
+	case *common.ImageLayer:
+		b, t := base.(*common.ImageLayer), theirs.(*common.ImageLayer)
+		m.mergeFields(path,
+			field{"opacity", b.Opacity, o.Opacity, t.Opacity, func() { o.Opacity = t.Opacity }},
+			field{"visible", b.Visible, o.Visible, t.Visible, func() { o.Visible = t.Visible }},
+			field{"offsetx", b.OffsetX, o.OffsetX, t.OffsetX, func() { o.OffsetX = t.OffsetX }},
+			field{"offsety", b.OffsetY, o.OffsetY, t.OffsetY, func() { o.OffsetY = t.OffsetY }},
+			field{"tintcolor", b.TintColour, o.TintColour, t.TintColour, func() { o.TintColour = t.TintColour }},
+			field{"image", b.ImageSource, o.ImageSource, t.ImageSource, func() {
+				o.ImageSource, o.ImageFormat, o.Format = t.ImageSource, t.ImageFormat, t.Format
+				o.Width, o.Height = t.Width, t.Height
+			}},
+			field{"trans", b.TransparentColour, o.TransparentColour, t.TransparentColour, func() { o.TransparentColour = t.TransparentColour }},
+		)
+		m.mergeProperties(path, b, o, t)
+	case *common.ObjectLayer:
+		b, t := base.(*common.ObjectLayer), theirs.(*common.ObjectLayer)
+		m.mergeFields(path,
+			field{"color", b.Colour, o.Colour, t.Colour, func() { o.Colour = t.Colour }},
+			field{"opacity", b.Opacity, o.Opacity, t.Opacity, func() { o.Opacity = t.Opacity }},
+			field{"visible", b.Visible, o.Visible, t.Visible, func() { o.Visible = t.Visible }},
+			field{"offsetx", b.OffsetX, o.OffsetX, t.OffsetX, func() { o.OffsetX = t.OffsetX }},
+			field{"offsety", b.OffsetY, o.OffsetY, t.OffsetY, func() { o.OffsetY = t.OffsetY }},
+			field{"draworder", b.DrawOrder, o.DrawOrder, t.DrawOrder, func() { o.DrawOrder = t.DrawOrder }},
+			field{"tintcolor", b.TintColour, o.TintColour, t.TintColour, func() { o.TintColour = t.TintColour }},
+		)
+		m.mergeProperties(path, b, o, t)
+	case *common.GroupLayer:
+		b, t := base.(*common.GroupLayer), theirs.(*common.GroupLayer)
+		m.mergeFields(path,
+			field{"opacity", b.Opacity, o.Opacity, t.Opacity, func() { o.Opacity = t.Opacity }},
+			field{"visible", b.Visible, o.Visible, t.Visible, func() { o.Visible = t.Visible }},
+			field{"offsetx", b.OffsetX, o.OffsetX, t.OffsetX, func() { o.OffsetX = t.OffsetX }},
+			field{"offsety", b.OffsetY, o.OffsetY, t.OffsetY, func() { o.OffsetY = t.OffsetY }},
+			field{"tintcolor", b.TintColour, o.TintColour, t.TintColour, func() { o.TintColour = t.TintColour }},
+		)
+		m.mergeProperties(path, b, o, t)
+		m.mergeLayers(path, o, b.Layers(), o.Layers(), t.Layers())
+	}
+}
+
+// Every cell in any of the given layers
+func cellBounds(layers ...*common.TileLayer) image.Rectangle {
+	bounds := image.Rectangle{}
+	for _, layer := range layers {
+		for _, rect := range layer.Chunks() {
+			bounds = bounds.Union(rect)
+		}
+	}
+	return bounds
+}
+
+// Merge cell by cell, cells both changed differently are kept as ours had them
+func (m *merger) mergeCells(path string, base, ours, theirs *common.TileLayer) {
+	conflicted := map[image.Point]bool{}
+	missing := map[image.Point]bool{}
+
+	bounds := cellBounds(base, ours, theirs)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			b := diff.Describe(base.GetCell(x, y))
+			o := diff.Describe(ours.GetCell(x, y))
+			t := diff.Describe(theirs.GetCell(x, y))
+			if t == b || t == o {
+				continue
+			}
+			if o != b {
+				conflicted[image.Pt(x, y)] = true
+				continue
+			}
+			cell, ok := m.cell(theirs.GetCell(x, y))
+			if !ok {
+				missing[image.Pt(x, y)] = true
+				continue
+			}
+			ours.PutCell(x, y, cell)
+		}
+	}
+
+	if len(conflicted) > 0 {
+		m.conflict(Conflict{Reason: ChangedByBoth, Path: path, Field: "cells", Rects: diff.Rectangles(conflicted)})
+	}
+	if len(missing) > 0 {
+		m.conflict(Conflict{Reason: Missing, Path: path, Field: "cells", Rects: diff.Rectangles(missing)})
+	}
+}
+
+// Copy a layer theirs added into ours (without it's objects, see mergeObjects), placing it at
+// the given position in the parent
+func (m *merger) copyLayer(path string, parent layerParent, index int, layer common.Layer) {
+	var added common.Layer
+	switch l := layer.(type) {
+	case *common.TileLayer:
+		n := parent.NewTileLayer(l.Name)
+		n.Opacity, n.Visible, n.OffsetX, n.OffsetY, n.TintColour = l.Opacity, l.Visible, l.OffsetX, l.OffsetY, l.TintColour
+		n.Encoding, n.Compression = l.Encoding, l.Compression
+		n.UpdateProperties(l.Properties()...)
+
+		missing := map[image.Point]bool{}
+		bounds := cellBounds(l)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				cell, ok := m.cell(l.GetCell(x, y))
+				if !ok {
+					missing[image.Pt(x, y)] = true
+				} else if !cell.IsEmpty() {
+					n.PutCell(x, y, cell)
+				}
+			}
+		}
+		if len(missing) > 0 {
+			m.conflict(Conflict{Reason: Missing, Path: path, Field: "cells", Rects: diff.Rectangles(missing)})
+		}
+		added = n
+	case *common.ImageLayer:
+		n := parent.NewImageLayer(l.Name, l.ImageSource)
+		n.Opacity, n.Visible, n.OffsetX, n.OffsetY, n.TintColour = l.Opacity, l.Visible, l.OffsetX, l.OffsetY, l.TintColour
+		n.ImageFormat, n.Format, n.Width, n.Height = l.ImageFormat, l.Format, l.Width, l.Height
+		n.TransparentColour = l.TransparentColour
+		n.UpdateProperties(l.Properties()...)
+		added = n
+	case *common.ObjectLayer:
+		n := parent.NewObjectLayer(l.Name)
+		n.Opacity, n.Visible, n.OffsetX, n.OffsetY, n.TintColour = l.Opacity, l.Visible, l.OffsetX, l.OffsetY, l.TintColour
+		n.Colour, n.DrawOrder = l.Colour, l.DrawOrder
+		n.UpdateProperties(l.Properties()...)
+		added = n
+	case *common.GroupLayer:
+		n := parent.NewGroupLayer(l.Name)
+		n.Opacity, n.Visible, n.OffsetX, n.OffsetY, n.TintColour = l.Opacity, l.Visible, l.OffsetX, l.OffsetY, l.TintColour
+		n.UpdateProperties(l.Properties()...)
+		keys, byKey := diff.LayerKeys(l.Layers())
+		for i, key := range keys {
+			m.copyLayer(path+"/"+key, n, i, byKey[key])
+		}
+		added = n
+	default:
+		return
+	}
+	parent.MoveLayer(added, index)
+}
@@ -0,0 +1,293 @@
+// Package merge does a three way merge of maps: given a common base & two maps changed from it
+// (ours & theirs), the changes theirs made are applied to ours. Tile layers are merged cell by
+// cell, properties name by name & objects by id, along with the layers & tilesets either side
+// added or removed.
+//
+// Where both sides changed the same thing differently ours is kept & a Conflict is noted. Paths
+// in conflicts are in the same form as those of a diff.Report
+// (eg. map/group[level]/layer[ground]/property[speed]).
+package merge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/voidshard/libtmx/common"
+	"github.com/voidshard/libtmx/diff"
+)
+
+// Reasons for a conflict
+const (
+	// both changed the same value, to different values
+	ChangedByBoth = "changed by both"
+
+	// both added something by the same name
+	AddedByBoth = "added by both"
+
+	// ours changed something theirs removed
+	RemovedByTheirs = "changed by ours, removed by theirs"
+
+	// theirs changed something ours removed
+	RemovedByOurs = "removed by ours, changed by theirs"
+
+	// theirs placed a tile or object somewhere ours no longer has (eg. a tile from a tileset
+	// ours removed, or an object in a layer ours removed)
+	Missing = "missing from ours"
+
+	// theirs changed something that isn't merged (eg. map settings like the size or orientation,
+	// or the tiles of a tileset) which ours didn't change the same way
+	NotMerged = "not merged"
+)
+
+// Conflict is a change theirs made that couldn't be applied to ours
+type Conflict struct {
+	Reason string `json:"reason"`
+	Path   string `json:"path"`
+	Field  string `json:"field,omitempty"`
+
+	// values (as described by diff.Describe) of the field, if any
+	Base   string `json:"base,omitempty"`
+	Ours   string `json:"ours,omitempty"`
+	Theirs string `json:"theirs,omitempty"`
+
+	// cells in conflict, for conflicts of a tile layer's cells
+	Rects []diff.Rect `json:"rects,omitempty"`
+}
+
+func (c Conflict) String() string {
+	where := c.Path
+	if c.Field != "" {
+		where = fmt.Sprintf("%s: %s", c.Path, c.Field)
+	}
+	if len(c.Rects) > 0 {
+		return fmt.Sprintf("%s %s in %v", where, c.Reason, c.Rects)
+	}
+	if c.Field == "" {
+		return fmt.Sprintf("%s %s", where, c.Reason)
+	}
+	return fmt.Sprintf("%s %s (base %s, ours %s, theirs %s)", where, c.Reason, c.Base, c.Ours, c.Theirs)
+}
+
+// Maps merges the changes theirs made to base into ours, returning ours (updated in place) along
+// with any conflicts. Tilesets & objects theirs added are moved from theirs to ours, so theirs
+// shouldn't be used afterwards.
+//
+// Objects both sides added with the same id are kept, those theirs added are given new ids.
+func Maps(base, ours, theirs *common.Map) (*common.Map, []Conflict) {
+	m := &merger{
+		oursChanges:   diff.Maps(base, ours),
+		theirsChanges: diff.Maps(base, theirs),
+		tilesets:      map[*common.Tileset]*common.Tileset{},
+		conflicts:     []Conflict{},
+	}
+
+	m.mergeSettings()
+	m.mergeProperties("map", base, ours, theirs)
+	m.mergeTilesets(base, ours, theirs)
+	m.mergeLayers("map", ours, base.Layers(), ours.Layers(), theirs.Layers())
+	m.mergeObjects(base, ours, theirs)
+
+	if theirs.NextObjectID() > ours.NextObjectID() {
+		ours.SetNextObjectID(theirs.NextObjectID())
+	}
+	ours.FinalizeIDs()
+	return ours, m.conflicts
+}
+
+// Holds the state of a single Maps call
+type merger struct {
+	oursChanges, theirsChanges *diff.Report
+	conflicts                  []Conflict
+
+	// the tileset of ours each tileset of theirs matches, see mergeTilesets
+	tilesets map[*common.Tileset]*common.Tileset
+}
+
+func (m *merger) conflict(c Conflict) {
+	m.conflicts = append(m.conflicts, c)
+}
+
+// Three way merge of a single value (as described by diff.Describe). Returns true if ours should
+// take the value of theirs, noting a conflict if both changed it differently.
+func (m *merger) merge(path, field, base, ours, theirs string) bool {
+	if theirs == base || theirs == ours {
+		return false
+	}
+	if ours == base {
+		return true
+	}
+	m.conflict(Conflict{Reason: ChangedByBoth, Path: path, Field: field, Base: base, Ours: ours, Theirs: theirs})
+	return false
+}
+
+// A field both sides may have changed, along with how ours takes the value of theirs
+type field struct {
+	name               string
+	base, ours, theirs interface{}
+	take               func()
+}
+
+func (m *merger) mergeFields(path string, fields ...field) {
+	for _, f := range fields {
+		if m.merge(path, f.name, diff.Describe(f.base), diff.Describe(f.ours), diff.Describe(f.theirs)) {
+			f.take()
+		}
+	}
+}
+
+// Whether the given report has changes to what's at the given path (or anything within it)
+func changedUnder(report *diff.Report, path string) bool {
+	for _, c := range report.Changes {
+		if c.Path == path || strings.HasPrefix(c.Path, path+"/") {
+			return true
+		}
+	}
+	for _, c := range report.Cells {
+		if c.Path == path || strings.HasPrefix(c.Path, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Map settings (size, orientation ..) & the tiles of tilesets aren't merged, any change theirs
+// made to them that ours didn't also make is a conflict
+func (m *merger) mergeSettings() {
+	for _, c := range m.theirsChanges.Changes {
+		if !notMerged(c) {
+			continue
+		}
+		ours := c.Before
+		for _, o := range m.oursChanges.Changes {
+			if o.Kind == c.Kind && o.Path == c.Path && o.Field == c.Field {
+				ours = o.After
+			}
+		}
+		if ours != c.After || c.Kind == diff.Removed {
+			m.conflict(Conflict{Reason: NotMerged, Path: c.Path, Field: c.Field, Base: c.Before, Ours: ours, Theirs: c.After})
+		}
+	}
+}
+
+// Whether the given change is one that isn't merged; a change to the map's settings or to a
+// tileset other than it's properties (or it's firstgid, which follows from the tilesets before it)
+func notMerged(c diff.Change) bool {
+	if c.Path == "map" {
+		return c.Field != "layer order" // layers keep the order ours gave them
+	}
+	if !strings.HasPrefix(c.Path, "map/tileset[") {
+		return false
+	}
+	parts := strings.Split(c.Path, "/")
+	switch len(parts) {
+	case 2:
+		return c.Kind != diff.Added && c.Field != "firstgid"
+	case 3:
+		return !strings.HasPrefix(parts[2], "property[")
+	}
+	return true
+}
+
+// Something with properties that can be changed
+type propertyHolder interface {
+	Properties() []*common.Property
+	UpdateProperties(props ...*common.Property)
+	RemoveProperties(names ...string)
+}
+
+func (m *merger) mergeProperties(path string, base, ours, theirs propertyHolder) {
+	all := map[string]bool{}
+	byName := func(holder propertyHolder) map[string]*common.Property {
+		result := map[string]*common.Property{}
+		for _, prop := range holder.Properties() {
+			result[prop.Name()] = prop
+			all[prop.Name()] = true
+		}
+		return result
+	}
+	baseProps, oursProps, theirsProps := byName(base), byName(ours), byName(theirs)
+
+	names := []string{}
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		b, o, t := baseProps[name], oursProps[name], theirsProps[name]
+		if !m.merge(path+"/property["+name+"]", "value", diff.Describe(b), diff.Describe(o), diff.Describe(t)) {
+			continue
+		}
+		if t == nil {
+			ours.RemoveProperties(name)
+		} else {
+			ours.UpdateProperties(t)
+		}
+	}
+}
+
+// Tilesets theirs added are added to ours, others (by name) have their properties merged. A
+// tileset both added by the same name is only matched if both added the same set.
+func (m *merger) mergeTilesets(base, ours, theirs *common.Map) {
+	byName := func(in *common.Map) map[string]*common.Tileset {
+		result := map[string]*common.Tileset{}
+		for _, tset := range in.Tilesets() {
+			result[tset.Name] = tset
+		}
+		return result
+	}
+	baseSets, oursSets := byName(base), byName(ours)
+
+	for _, tset := range theirs.Tilesets() {
+		path := "map/tileset[" + tset.Name + "]"
+		b, inBase := baseSets[tset.Name]
+		o, inOurs := oursSets[tset.Name]
+		switch {
+		case inBase && inOurs:
+			m.mergeProperties(path, b, o, tset)
+			m.tilesets[tset] = o
+		case inBase:
+			if changedUnder(m.theirsChanges, path) {
+				m.conflict(Conflict{Reason: RemovedByOurs, Path: path})
+			}
+		case inOurs:
+			if sameTileset(o, tset) {
+				m.tilesets[tset] = o
+			} else {
+				m.conflict(Conflict{Reason: AddedByBoth, Path: path})
+			}
+		default:
+			ours.AddTileset(tset)
+			m.tilesets[tset] = tset
+		}
+	}
+}
+
+// Whether two tilesets are the same set, other than where their global ids start
+func sameTileset(ours, theirs *common.Tileset) bool {
+	for _, c := range diff.Tilesets(ours, theirs).Changes {
+		if c.Field != "firstgid" {
+			return false
+		}
+	}
+	return true
+}
+
+// The tile in ours matching the given tile of theirs (same id, in the tileset of ours matched to
+// the tileset of theirs by mergeTilesets)
+func (m *merger) cell(in common.Cell) (common.Cell, bool) {
+	if in.Tile == nil || in.Tile.Tileset() == nil {
+		return in, true
+	}
+	tset, ok := m.tilesets[in.Tile.Tileset()]
+	if !ok {
+		return in, false
+	}
+	tile, ok := tset.Tile(in.Tile.Id)
+	if !ok {
+		return in, false
+	}
+	in.Tile = tile
+	return in, true
+}
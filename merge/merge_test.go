@@ -0,0 +1,270 @@
+package merge
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/voidshard/libtmx/common"
+	"github.com/voidshard/libtmx/diff"
+)
+
+// A small map; a 4x4 layer of grass, with an object layer in a group
+func baseMap() *common.Map {
+	m := common.NewMap(common.Width(4), common.Height(4), common.TileWidth(16), common.TileHeight(16))
+	m.UpdateProperties(
+		common.NewProp("music").SetString("calm.ogg"),
+		common.NewProp("dark").SetBool(false),
+		common.NewProp("weather").SetString("rain"),
+	)
+
+	tset := m.NewTileset("ground", common.NewTile("grass.png"), common.NewTile("sand.png"), common.NewTile("water.png"))
+	grass := tset.Tiles()[0]
+
+	layer := m.NewTileLayer("ground")
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			layer.Put(x, y, grass)
+		}
+	}
+
+	group := m.NewGroupLayer("level")
+	objects := group.NewObjectLayer("objects")
+	objects.AddObjects(common.NewObject("chest"), common.NewObject("door"))
+	m.NewTileLayer("decor")
+	m.FinalizeIDs()
+	return m
+}
+
+func tile(m *common.Map, id int) *common.Tile {
+	t, _ := m.Tilesets()[0].Tile(id)
+	return t
+}
+
+func objects(m *common.Map) *common.ObjectLayer {
+	return m.GroupLayers()[0].Layers()[0].(*common.ObjectLayer)
+}
+
+func expectConflicts(t *testing.T, conflicts []Conflict, expect ...string) {
+	t.Helper()
+	got := []string{}
+	for _, c := range conflicts {
+		got = append(got, c.String())
+	}
+	if strings.Join(got, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("expected conflicts\n%s\ngot\n%s", strings.Join(expect, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+// Check the merged map differs from the base as expected
+func expectChanges(t *testing.T, merged *common.Map, expect ...string) {
+	t.Helper()
+	got := []string{}
+	r := diff.Maps(baseMap(), merged)
+	for _, c := range r.Changes {
+		got = append(got, c.String())
+	}
+	for _, c := range r.Cells {
+		got = append(got, c.String())
+	}
+	if strings.Join(got, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("expected changes\n%s\ngot\n%s", strings.Join(expect, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestMergeUnchanged(t *testing.T) {
+	merged, conflicts := Maps(baseMap(), baseMap(), baseMap())
+	expectConflicts(t, conflicts)
+	expectChanges(t, merged)
+}
+
+func TestMergeCells(t *testing.T) {
+	ours := baseMap()
+	ours.TileLayers()[0].Put(0, 0, tile(ours, 1))
+	ours.TileLayers()[0].Put(1, 0, tile(ours, 1))
+	ours.TileLayers()[0].Put(3, 3, tile(ours, 1))
+
+	theirs := baseMap()
+	theirs.TileLayers()[0].Put(0, 2, tile(theirs, 2))
+	theirs.TileLayers()[0].Put(0, 3, tile(theirs, 2))
+	theirs.TileLayers()[0].Put(3, 3, tile(theirs, 2))
+	theirs.TileLayers()[0].PutCell(1, 0, common.Cell{Tile: tile(theirs, 1)})
+
+	merged, conflicts := Maps(baseMap(), ours, theirs)
+	expectConflicts(t, conflicts, "map/layer[ground]: cells changed by both in [3,3 1x1]")
+	expectChanges(t, merged, "~ map/layer[ground]: 5 cells in [0,0 2x1 0,2 1x2 3,3 1x1]")
+
+	layer := merged.TileLayers()[0]
+	if layer.Get(0, 2) != tile(merged, 2) || layer.Get(3, 3) != tile(merged, 1) {
+		t.Error("expected theirs cells to use our tiles & conflicting cells to be ours")
+	}
+}
+
+func TestMergeProperties(t *testing.T) {
+	ours := baseMap()
+	ours.UpdateProperties(common.NewProp("music").SetString("tense.ogg"), common.NewProp("weather").SetString("snow"))
+
+	theirs := baseMap()
+	theirs.UpdateProperties(common.NewProp("dark").SetBool(true), common.NewProp("weather").SetString("fog"))
+	theirs.RemoveProperties("music")
+	theirs.TileLayers()[0].UpdateProperties(common.NewProp("speed").SetFloat(0.5))
+
+	merged, conflicts := Maps(baseMap(), ours, theirs)
+	expectConflicts(t, conflicts,
+		`map/property[music]: value changed by both (base string "calm.ogg", ours string "tense.ogg", theirs none)`,
+		`map/property[weather]: value changed by both (base string "rain", ours string "snow", theirs string "fog")`,
+	)
+	expectChanges(t, merged,
+		"~ map/property[dark]: value bool false -> bool true",
+		`~ map/property[music]: value string "calm.ogg" -> string "tense.ogg"`,
+		`~ map/property[weather]: value string "rain" -> string "snow"`,
+		"+ map/layer[ground]/property[speed]",
+	)
+}
+
+func TestMergeObjects(t *testing.T) {
+	ours := baseMap()
+	chest, _ := objects(ours).Object(1)
+	chest.Name = "big chest"
+	objects(ours).AddObjects(common.NewObject("key"))
+	ours.FinalizeIDs()
+
+	theirs := baseMap()
+	chest, _ = objects(theirs).Object(1)
+	chest.X = 48
+	chest.UpdateProperties(common.NewProp("gold").SetInt(5))
+	door, _ := objects(theirs).Object(2)
+	objects(theirs).RemoveObject(door)
+	objects(theirs).AddObjects(common.NewObject("lamp").SetPoint())
+	theirs.FinalizeIDs()
+
+	merged, conflicts := Maps(baseMap(), ours, theirs)
+	expectConflicts(t, conflicts)
+	expectChanges(t, merged,
+		`~ map/group[level]/objectgroup[objects]/object[1]: name "chest" -> "big chest"`,
+		"~ map/group[level]/objectgroup[objects]/object[1]: x 0 -> 48",
+		"+ map/group[level]/objectgroup[objects]/object[1]/property[gold]",
+		"- map/group[level]/objectgroup[objects]/object[2]",
+		"+ map/group[level]/objectgroup[objects]/object[3]",
+		"+ map/group[level]/objectgroup[objects]/object[4]",
+	)
+	lamp, _ := objects(merged).Object(4)
+	if lamp == nil || lamp.Name != "lamp" {
+		t.Error("expected theirs new object to be given a new id, got", lamp)
+	}
+	if merged.NextObjectID() != 5 {
+		t.Error("expected next object id 5, got", merged.NextObjectID())
+	}
+}
+
+func TestMergeObjectConflicts(t *testing.T) {
+	ours := baseMap()
+	chest, _ := objects(ours).Object(1)
+	chest.X = 16
+	door, _ := objects(ours).Object(2)
+	door.UpdateProperties(common.NewProp("locked").SetBool(true))
+
+	theirs := baseMap()
+	chest, _ = objects(theirs).Object(1)
+	chest.X = 32
+	chest.SetEllipse()
+	door, _ = objects(theirs).Object(2)
+	objects(theirs).RemoveObject(door)
+
+	merged, conflicts := Maps(baseMap(), ours, theirs)
+	expectConflicts(t, conflicts,
+		"map/group[level]/objectgroup[objects]/object[1]: x changed by both (base 0, ours 16, theirs 32)",
+		"map/group[level]/objectgroup[objects]/object[2] changed by ours, removed by theirs",
+	)
+	chest, _ = objects(merged).Object(1)
+	if chest.X != 16 || chest.Kind() != common.ObjectTypeEllipse {
+		t.Error("expected ours x & theirs shape, got", chest.X, chest.Kind())
+	}
+}
+
+func TestMergeLayers(t *testing.T) {
+	ours := baseMap()
+	ours.RemoveLayer(ours.TileLayers()[1])
+	ours.GroupLayers()[0].NewTileLayer("shadows")
+
+	theirs := baseMap()
+	theirs.TileLayers()[1].Put(1, 1, tile(theirs, 2))
+	theirs.TileLayers()[0].Opacity = 0.5
+	props := theirs.NewTileset("props", common.NewTile("barrel.png"))
+	decor := theirs.GroupLayers()[0].NewTileLayer("props")
+	decor.Put(2, 2, props.Tiles()[0])
+	if err := theirs.GroupLayers()[0].MoveLayer(decor, 0); err != nil {
+		t.Fatal(err)
+	}
+	theirs.GroupLayers()[0].NewTileLayer("shadows")
+
+	merged, conflicts := Maps(baseMap(), ours, theirs)
+	expectConflicts(t, conflicts,
+		"map/group[level]/layer[shadows] added by both",
+		"map/layer[decor] removed by ours, changed by theirs",
+	)
+	expectChanges(t, merged,
+		"+ map/tileset[props]",
+		"~ map/layer[ground]: opacity 1 -> 0.5",
+		"+ map/group[level]/layer[props]",
+		"+ map/group[level]/layer[shadows]",
+		"- map/layer[decor]",
+	)
+
+	layer := merged.GroupLayers()[0].Layers()[0].(*common.TileLayer)
+	if layer.Name != "props" || layer.Get(2, 2) == nil || layer.Get(2, 2).Tileset().Name != "props" {
+		t.Error("expected layer theirs added first in group with their tiles, got", layer.Name)
+	}
+}
+
+func TestMergeNotMerged(t *testing.T) {
+	theirs := baseMap()
+	theirs.Width = 8
+	theirs.Tilesets()[0].Spacing = 2
+
+	ours := baseMap()
+	ours.Tilesets()[0].Spacing = 2
+
+	_, conflicts := Maps(baseMap(), ours, theirs)
+	expectConflicts(t, conflicts, "map: width not merged (base 4, ours 4, theirs 8)")
+}
+
+func TestMergeTilesetConflicts(t *testing.T) {
+	withProps := func() *common.Map {
+		m := baseMap()
+		m.NewTileset("props", common.NewTile("barrel.png"))
+		m.FinalizeIDs()
+		return m
+	}
+	base := withProps()
+
+	ours := baseMap() // removed props
+	ours.NewTileset("trees", common.NewTile("oak.png"), common.NewTile("pine.png"))
+	ours.NewTileset("items", common.NewTile("sword.png"))
+	ours.FinalizeIDs()
+
+	theirs := withProps()
+	theirs.Tilesets()[1].UpdateProperties(common.NewProp("breakable").SetBool(true))
+	trees := theirs.NewTileset("trees", common.NewTile("oak.png"), common.NewTile("pine.png"))
+	items := theirs.NewTileset("items", common.NewTile("shield.png"))
+	theirs.TileLayers()[1].Put(0, 0, trees.Tiles()[1])
+	theirs.TileLayers()[1].Put(1, 0, items.Tiles()[0])
+	theirs.FinalizeIDs()
+
+	merged, conflicts := Maps(base, ours, theirs)
+	expectConflicts(t, conflicts,
+		"map/tileset[props] removed by ours, changed by theirs",
+		"map/tileset[items] added by both",
+		"map/layer[decor]: cells missing from ours in [1,0 1x1]",
+	)
+
+	layer := merged.TileLayers()[1]
+	if layer.Get(0, 0) == nil || layer.Get(0, 0).Source != "pine.png" || layer.Get(0, 0).Tileset() != merged.Tilesets()[1] {
+		t.Error("expected theirs tile from a tileset both added to use ours, got", layer.Get(0, 0))
+	}
+	if layer.Get(1, 0) != nil {
+		t.Error("expected theirs tile from a different tileset of the same name not to be merged, got", layer.Get(1, 0))
+	}
+	if len(merged.Tilesets()) != 3 {
+		t.Error("expected ours tilesets only, got", len(merged.Tilesets()))
+	}
+}
@@ -0,0 +1,197 @@
+package merge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/voidshard/libtmx/common"
+	"github.com/voidshard/libtmx/diff"
+)
+
+// An object along with the path of the layer it's in
+type placedObject struct {
+	layer  string
+	object *common.Object
+}
+
+// Every object in the given layers (& groups within them) by id
+func objectsByID(path string, layers []common.Layer, into map[int]placedObject) map[int]placedObject {
+	keys, byKey := diff.LayerKeys(layers)
+	for _, key := range keys {
+		switch layer := byKey[key].(type) {
+		case *common.ObjectLayer:
+			for _, obj := range layer.Objects() {
+				into[obj.Id] = placedObject{layer: path + "/" + key, object: obj}
+			}
+		case *common.GroupLayer:
+			objectsByID(path+"/"+key, layer.Layers(), into)
+		}
+	}
+	return into
+}
+
+// Every object layer in the given layers (& groups within them) by path
+func objectLayers(path string, layers []common.Layer, into map[string]*common.ObjectLayer) map[string]*common.ObjectLayer {
+	keys, byKey := diff.LayerKeys(layers)
+	for _, key := range keys {
+		switch layer := byKey[key].(type) {
+		case *common.ObjectLayer:
+			into[path+"/"+key] = layer
+		case *common.GroupLayer:
+			objectLayers(path+"/"+key, layer.Layers(), into)
+		}
+	}
+	return into
+}
+
+// Whether the given report has changes to the object with the given id (objects in tiles, ie.
+// collision shapes, aren't counted)
+func objectChanged(report *diff.Report, id int) bool {
+	name := fmt.Sprintf("/object[%d]", id)
+	for _, c := range report.Changes {
+		if strings.HasPrefix(c.Path, "map/tileset[") {
+			continue
+		}
+		if strings.HasSuffix(c.Path, name) || strings.Contains(c.Path, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Merge objects by id, after layers have been merged (as objects may move between layers)
+func (m *merger) mergeObjects(base, ours, theirs *common.Map) {
+	baseByID := objectsByID("map", base.Layers(), map[int]placedObject{})
+	oursByID := objectsByID("map", ours.Layers(), map[int]placedObject{})
+	theirsByID := objectsByID("map", theirs.Layers(), map[int]placedObject{})
+	layers := objectLayers("map", ours.Layers(), map[string]*common.ObjectLayer{})
+
+	all := map[int]bool{}
+	for _, byID := range []map[int]placedObject{baseByID, oursByID, theirsByID} {
+		for id := range byID {
+			all[id] = true
+		}
+	}
+	ids := []int{}
+	for id := range all {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		b, inBase := baseByID[id]
+		o, inOurs := oursByID[id]
+		t, inTheirs := theirsByID[id]
+
+		switch {
+		case inBase && inOurs && inTheirs:
+			m.mergeObject(b, o, t, layers)
+		case inBase && inOurs:
+			path := fmt.Sprintf("%s/object[%d]", o.layer, id)
+			if objectChanged(m.oursChanges, id) {
+				m.conflict(Conflict{Reason: RemovedByTheirs, Path: path})
+			} else if o.object.Layer() != nil {
+				o.object.Layer().RemoveObject(o.object)
+			}
+		case inBase && inTheirs:
+			if objectChanged(m.theirsChanges, id) {
+				m.conflict(Conflict{Reason: RemovedByOurs, Path: fmt.Sprintf("%s/object[%d]", t.layer, id)})
+			}
+		case inTheirs && !inBase:
+			if inOurs {
+				t.object.Id = 0 // both added an object with this id, keep ours & give theirs a new id
+			}
+			m.addObject(id, t, layers)
+		}
+	}
+}
+
+// Move an object theirs added into ours
+func (m *merger) addObject(id int, t placedObject, layers map[string]*common.ObjectLayer) {
+	path := fmt.Sprintf("%s/object[%d]", t.layer, id)
+	layer, ok := layers[t.layer]
+	if !ok {
+		m.conflict(Conflict{Reason: Missing, Path: path, Field: "layer", Theirs: t.layer})
+		return
+	}
+	if t.object.Kind() == common.ObjectTypeTile {
+		cell, ok := m.cell(t.object.TileCell())
+		if !ok {
+			m.conflict(Conflict{Reason: Missing, Path: path, Field: "tile", Theirs: diff.Describe(t.object.TileCell())})
+			return
+		}
+		t.object.SetTileCell(cell)
+	}
+	layer.AddObjects(t.object)
+}
+
+// The kind of an object along with it's points, text or tile
+func describeShape(obj *common.Object) string {
+	return fmt.Sprintf("%s %v %s %s", obj.Kind(), obj.Points(), diff.Describe(obj.Text()), diff.Describe(obj.TileCell()))
+}
+
+// The template an object is based on
+func describeTemplate(obj *common.Object) string {
+	if obj.Template() == nil {
+		return "none"
+	}
+	return obj.Template().Source
+}
+
+func (m *merger) mergeObject(base, ours, theirs placedObject, layers map[string]*common.ObjectLayer) {
+	b, o, t := base.object, ours.object, theirs.object
+	path := fmt.Sprintf("%s/object[%d]", ours.layer, o.Id)
+
+	if m.merge(path, "layer", base.layer, ours.layer, theirs.layer) {
+		if layer, ok := layers[theirs.layer]; ok {
+			o.Layer().RemoveObject(o)
+			layer.AddObjects(o)
+		} else {
+			m.conflict(Conflict{Reason: Missing, Path: path, Field: "layer", Base: base.layer, Ours: ours.layer, Theirs: theirs.layer})
+		}
+	}
+
+	m.mergeFields(path,
+		field{"name", b.Name, o.Name, t.Name, func() { o.Name = t.Name }},
+		field{"type", b.Type, o.Type, t.Type, func() { o.Type = t.Type }},
+		field{"x", b.X, o.X, t.X, func() { o.X = t.X }},
+		field{"y", b.Y, o.Y, t.Y, func() { o.Y = t.Y }},
+		field{"width", b.Width, o.Width, t.Width, func() { o.Width = t.Width }},
+		field{"height", b.Height, o.Height, t.Height, func() { o.Height = t.Height }},
+		field{"rotation", b.Rotation, o.Rotation, t.Rotation, func() { o.Rotation = t.Rotation }},
+		field{"visible", b.Visible, o.Visible, t.Visible, func() { o.Visible = t.Visible }},
+	)
+	if m.merge(path, "template", describeTemplate(b), describeTemplate(o), describeTemplate(t)) {
+		o.SetTemplate(t.Template())
+	}
+	if m.merge(path, "shape", describeShape(b), describeShape(o), describeShape(t)) {
+		m.copyShape(path, o, t)
+	}
+	m.mergeProperties(path, b, o, t)
+}
+
+// Make ours the same kind of object as theirs, with the same points, text or tile
+func (m *merger) copyShape(path string, ours, theirs *common.Object) {
+	switch theirs.Kind() {
+	case common.ObjectTypeRectangle:
+		ours.SetRectangle()
+	case common.ObjectTypePoint:
+		ours.SetPoint()
+	case common.ObjectTypeEllipse:
+		ours.SetEllipse()
+	case common.ObjectTypePolygon:
+		ours.SetPolygon(theirs.Points()...)
+	case common.ObjectTypePolyline:
+		ours.SetPolyline(theirs.Points()...)
+	case common.ObjectTypeText:
+		ours.SetText(theirs.Text())
+	case common.ObjectTypeTile:
+		cell, ok := m.cell(theirs.TileCell())
+		if !ok {
+			m.conflict(Conflict{Reason: Missing, Path: path, Field: "tile", Theirs: diff.Describe(theirs.TileCell())})
+			return
+		}
+		ours.SetTileCell(cell)
+	}
+}
@@ -2,6 +2,7 @@ package libtmx
 
 import (
 	"github.com/voidshard/libtmx/common"
+	"github.com/voidshard/libtmx/codecs/tmj"
 	"github.com/voidshard/libtmx/codecs/v1"
 )
 
@@ -10,6 +11,42 @@ type TmxCodec interface {
 	Marshal(*common.Map) ([]byte, error)
 }
 
-func CodecV1() TmxCodec {
-	return &v1.CodecV1{}
+type TilesetCodec interface {
+	UnmarshalTileset([]byte) (*common.Tileset, error)
+	MarshalTileset(*common.Tileset) ([]byte, error)
+}
+
+type TemplateCodec interface {
+	UnmarshalTemplate([]byte) (*common.Template, error)
+	MarshalTemplate(*common.Template) ([]byte, error)
+}
+
+// CodecV1 reads & writes Tiled's XML .tmx format
+func CodecV1(opts ...v1.Option) TmxCodec {
+	return v1.NewCodecV1(opts...)
+}
+
+// CodecTSX reads & writes Tiled's XML .tsx (external tileset) format
+func CodecTSX(opts ...v1.Option) TilesetCodec {
+	return v1.NewCodecV1(opts...)
+}
+
+// CodecTX reads & writes Tiled's XML .tx object template format
+func CodecTX(opts ...v1.Option) TemplateCodec {
+	return v1.NewCodecV1(opts...)
+}
+
+// CodecJSON reads & writes Tiled's JSON .tmj format
+func CodecJSON(opts ...tmj.Option) TmxCodec {
+	return tmj.NewCodecTMJ(opts...)
+}
+
+// CodecTSJ reads & writes Tiled's JSON .tsj (external tileset) format
+func CodecTSJ(opts ...tmj.Option) TilesetCodec {
+	return tmj.NewCodecTMJ(opts...)
+}
+
+// CodecTJ reads & writes Tiled's JSON .tj object template format
+func CodecTJ(opts ...tmj.Option) TemplateCodec {
+	return tmj.NewCodecTMJ(opts...)
 }
@@ -0,0 +1,93 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+// Draw src over dst with it's top left at the given point, faded by opacity (0-1)
+func drawWithOpacity(dst *image.RGBA, src *image.RGBA, at image.Point, opacity float64) {
+	rect := image.Rectangle{Min: at, Max: at.Add(src.Bounds().Size())}
+	if opacity >= 1 {
+		draw.Draw(dst, rect, src, src.Bounds().Min, draw.Over)
+		return
+	}
+	mask := image.NewUniform(color.Alpha{A: uint8(opacity*255 + 0.5)})
+	draw.DrawMask(dst, rect, src, src.Bounds().Min, mask, image.Point{}, draw.Over)
+}
+
+// Copy an image into an RGBA image with bounds starting at 0,0
+func toRGBA(in image.Image) *image.RGBA {
+	out := image.NewRGBA(image.Rectangle{Max: in.Bounds().Size()})
+	draw.Draw(out, out.Bounds(), in, in.Bounds().Min, draw.Src)
+	return out
+}
+
+// Make every pixel of the given colour (ignoring alpha) fully transparent
+func keyOut(img *image.RGBA, key color.RGBA) {
+	for i := 0; i+3 < len(img.Pix); i += 4 {
+		if img.Pix[i] == key.R && img.Pix[i+1] == key.G && img.Pix[i+2] == key.B {
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = 0, 0, 0, 0
+		}
+	}
+}
+
+// Copy the given region of src into a new image, applying the cell's flip flags & the tint.
+//
+// As in Tiled the diagonal flip (a transpose) applies first, then the horizontal & vertical flips.
+func transform(src *image.RGBA, region image.Rectangle, cell common.Cell, tint *color.RGBA) *image.RGBA {
+	w, h := region.Dx(), region.Dy()
+	if cell.FlipD {
+		w, h = h, w
+	}
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			// work backwards from the output pixel to the source pixel
+			sx, sy := x, y
+			if cell.FlipV {
+				sy = h - 1 - sy
+			}
+			if cell.FlipH {
+				sx = w - 1 - sx
+			}
+			if cell.FlipD {
+				sx, sy = sy, sx
+			}
+
+			c := src.RGBAAt(region.Min.X+sx, region.Min.Y+sy)
+			if tint != nil {
+				c = multiply(c, *tint)
+			}
+			out.SetRGBA(x, y, c)
+		}
+	}
+	return out
+}
+
+// Multiply a (premultiplied) colour by a tint
+func multiply(c, tint color.RGBA) color.RGBA {
+	a := uint32(tint.A)
+	return color.RGBA{
+		R: uint8(uint32(c.R) * uint32(tint.R) * a / (255 * 255)),
+		G: uint8(uint32(c.G) * uint32(tint.G) * a / (255 * 255)),
+		B: uint8(uint32(c.B) * uint32(tint.B) * a / (255 * 255)),
+		A: uint8(uint32(c.A) * a / 255),
+	}
+}
+
+// Nearest neighbour scale the image to the given size
+func scale(src *image.RGBA, width, height int) *image.RGBA {
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			out.SetRGBA(x, y, src.RGBAAt(src.Bounds().Min.X+x*sw/width, src.Bounds().Min.Y+y*sh/height))
+		}
+	}
+	return out
+}
@@ -0,0 +1,45 @@
+package render
+
+import (
+	"bytes"
+	"image"
+
+	// image formats Tiled maps commonly use
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// ImageLoader loads the images a map refers to (tileset, tile & image layer sources).
+//
+// Sources are slash separated paths relative to the map; images of tiles in external tilesets
+// are joined onto the directory of the tileset file before being handed to the loader.
+// Each image is loaded at most once per Render, loaders may cache images across calls.
+type ImageLoader interface {
+	LoadImage(source string) (image.Image, error)
+}
+
+// Resolver reads files relative to a map, any codec Resolver (eg. v1.FSResolver) will do.
+type Resolver interface {
+	ReadFile(name string) ([]byte, error)
+}
+
+// ResolverLoader loads images by reading them with a Resolver & decoding them
+// (png, jpeg & gif are supported).
+type ResolverLoader struct {
+	Resolver Resolver
+}
+
+// Create a new loader reading images with the given Resolver
+func NewResolverLoader(r Resolver) *ResolverLoader {
+	return &ResolverLoader{Resolver: r}
+}
+
+func (l *ResolverLoader) LoadImage(source string) (image.Image, error) {
+	data, err := l.Resolver.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
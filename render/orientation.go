@@ -0,0 +1,197 @@
+package render
+
+import (
+	"image"
+	"math"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+// projection places tiles & objects of a map onto the output image.
+//
+// Each works over an 'area' of the map in tiles (the whole map, or for infinite maps the region
+// holding tiles) which is drawn with it's top left at 0,0 of the output.
+type projection interface {
+	// size of the output image in pixels
+	size() image.Point
+
+	// pixel region of the output the tile at x,y fills (it's footprint, tiles taller than the
+	// map's tiles extend above this)
+	cellRect(x, y int) image.Rectangle
+
+	// position in the output of the given object pixel coordinates
+	pixelToScreen(x, y int) image.Point
+
+	// position in the output of the map's top left corner, image layers are drawn from here
+	origin() image.Point
+}
+
+// Pick the projection for the map's orientation, covering the given area of tiles
+func projectionFor(m *common.Map, area image.Rectangle) projection {
+	switch m.Orientation() {
+	case common.MapOrientationIsometric:
+		return &isometric{tw: m.TileWidth, th: m.TileHeight, area: area}
+	case common.MapOrientationHexagonal:
+		grid := m.HexGrid()
+		// as for staggered maps, the area must start on an even row (or column)
+		if m.StaggerAxis() == common.MapStaggerAxisX {
+			area.Min.X &^= 1
+		} else {
+			area.Min.Y &^= 1
+		}
+		// tile 0,0 & the area's first tile are both on even rows, so are equally shifted
+		base := grid.TileToPixel(area.Min.X, area.Min.Y).Sub(grid.TileToPixel(0, 0))
+		return &hexagonal{grid: grid, area: area, base: base}
+	case common.MapOrientationStaggered:
+		s := &staggered{
+			tw:    m.TileWidth,
+			th:    m.TileHeight,
+			axisX: m.StaggerAxis() == common.MapStaggerAxisX,
+			odd:   m.StaggerIndex() == common.MapStaggerIndexOdd,
+		}
+		// the area must start on an even row (or column) so staggered rows stay staggered
+		if s.axisX {
+			area.Min.X &^= 1
+		} else {
+			area.Min.Y &^= 1
+		}
+		s.area = area
+		return s
+	}
+	return &orthogonal{tw: m.TileWidth, th: m.TileHeight, area: area}
+}
+
+// Tiles in a plain grid
+type orthogonal struct {
+	tw, th int
+	area   image.Rectangle
+}
+
+func (o *orthogonal) size() image.Point {
+	return image.Pt(o.area.Dx()*o.tw, o.area.Dy()*o.th)
+}
+
+func (o *orthogonal) cellRect(x, y int) image.Rectangle {
+	px, py := (x-o.area.Min.X)*o.tw, (y-o.area.Min.Y)*o.th
+	return image.Rect(px, py, px+o.tw, py+o.th)
+}
+
+func (o *orthogonal) pixelToScreen(x, y int) image.Point {
+	return image.Pt(x-o.area.Min.X*o.tw, y-o.area.Min.Y*o.th)
+}
+
+func (o *orthogonal) origin() image.Point {
+	return o.pixelToScreen(0, 0)
+}
+
+// Diamond shaped tiles with the map's 0,0 tile at the top centre of the output
+type isometric struct {
+	tw, th int
+	area   image.Rectangle
+}
+
+func (i *isometric) size() image.Point {
+	n := i.area.Dx() + i.area.Dy()
+	return image.Pt(n*i.tw/2, n*i.th/2)
+}
+
+// x position of the top corner of the area's top tile
+func (i *isometric) originX() int {
+	return i.area.Dy() * i.tw / 2
+}
+
+func (i *isometric) cellRect(x, y int) image.Rectangle {
+	x, y = x-i.area.Min.X, y-i.area.Min.Y
+	px := (x-y)*i.tw/2 + i.originX() - i.tw/2
+	py := (x + y) * i.th / 2
+	return image.Rect(px, py, px+i.tw, py+i.th)
+}
+
+// Object positions in isometric maps are measured in tile heights along both tile axes
+func (i *isometric) pixelToScreen(x, y int) image.Point {
+	tx := float64(x)/float64(i.th) - float64(i.area.Min.X)
+	ty := float64(y)/float64(i.th) - float64(i.area.Min.Y)
+	return image.Pt(
+		int(math.Round((tx-ty)*float64(i.tw)/2))+i.originX(),
+		int(math.Round((tx+ty)*float64(i.th)/2)),
+	)
+}
+
+// The map's bounding box has the 0,0 tile's top corner at it's top centre
+func (i *isometric) origin() image.Point {
+	top := i.cellRect(0, 0)
+	return image.Pt(top.Min.X+i.tw/2-i.area.Dy()*i.tw/2, top.Min.Y)
+}
+
+// Diamond shaped tiles in rows (or columns) where every other row is shifted by half a tile
+type staggered struct {
+	tw, th int
+	area   image.Rectangle
+	axisX  bool // columns are staggered rather than rows
+	odd    bool // odd rows (or columns) are shifted rather than even ones
+}
+
+func (s *staggered) size() image.Point {
+	if s.axisX {
+		return image.Pt((s.area.Dx()+1)*s.tw/2, s.area.Dy()*s.th+s.th/2)
+	}
+	return image.Pt(s.area.Dx()*s.tw+s.tw/2, (s.area.Dy()+1)*s.th/2)
+}
+
+// Whether the given row (or column) is shifted
+func (s *staggered) shifted(index int) bool {
+	return (index&1 == 1) == s.odd
+}
+
+func (s *staggered) cellRect(x, y int) image.Rectangle {
+	var px, py int
+	if s.axisX {
+		px = (x - s.area.Min.X) * s.tw / 2
+		py = (y - s.area.Min.Y) * s.th
+		if s.shifted(x) {
+			py += s.th / 2
+		}
+	} else {
+		px = (x - s.area.Min.X) * s.tw
+		py = (y - s.area.Min.Y) * s.th / 2
+		if s.shifted(y) {
+			px += s.tw / 2
+		}
+	}
+	return image.Rect(px, py, px+s.tw, py+s.th)
+}
+
+func (s *staggered) pixelToScreen(x, y int) image.Point {
+	if s.axisX {
+		return image.Pt(x-s.area.Min.X*s.tw/2, y-s.area.Min.Y*s.th)
+	}
+	return image.Pt(x-s.area.Min.X*s.tw, y-s.area.Min.Y*s.th/2)
+}
+
+func (s *staggered) origin() image.Point {
+	return s.pixelToScreen(0, 0)
+}
+
+// Hexagons in staggered rows (or columns), see common.HexGrid
+type hexagonal struct {
+	grid *common.HexGrid
+	area image.Rectangle
+	base image.Point // pixel position of the area's top left
+}
+
+func (h *hexagonal) size() image.Point {
+	return h.grid.Size(h.area.Dx(), h.area.Dy())
+}
+
+func (h *hexagonal) cellRect(x, y int) image.Rectangle {
+	p := h.grid.TileToPixel(x, y).Sub(h.base)
+	return image.Rect(p.X, p.Y, p.X+h.grid.TileWidth, p.Y+h.grid.TileHeight)
+}
+
+func (h *hexagonal) pixelToScreen(x, y int) image.Point {
+	return image.Pt(x, y).Sub(h.base)
+}
+
+func (h *hexagonal) origin() image.Point {
+	return h.pixelToScreen(0, 0)
+}
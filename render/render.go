@@ -0,0 +1,283 @@
+// Package render draws a common.Map into an image.RGBA.
+//
+// Orthogonal, isometric, staggered and hexagonal maps are supported. Tile layers, image layers and tile
+// objects are drawn; other objects (shapes, text) are editor-only and are skipped.
+package render
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"path"
+	"sort"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+// Render draws the given map into a new image, loading the images it uses with the given loader.
+//
+// Layers are drawn in order, respecting their visibility, opacity, offset & tint (along with
+// those of the groups they're in). Tile layers are drawn in the map's RenderOrder() with each
+// tile's flip flags and tileset offset applied. Fixed size maps fill the image, infinite maps
+// are cropped to the region holding tiles.
+func Render(m *common.Map, loader ImageLoader) (*image.RGBA, error) {
+	r := &renderer{
+		m:      m,
+		loader: loader,
+		proj:   projectionFor(m, renderArea(m)),
+		images: map[imageKey]*image.RGBA{},
+		tiles:  map[tileKey]*image.RGBA{},
+	}
+	return r.render()
+}
+
+// The region of the map (in tiles) that is drawn
+func renderArea(m *common.Map) image.Rectangle {
+	if !m.Infinite() {
+		return image.Rect(0, 0, m.Width, m.Height)
+	}
+	area := image.Rectangle{}
+	for _, l := range m.AllLayers() {
+		if tl, ok := l.(*common.TileLayer); ok {
+			area = area.Union(tl.Bounds())
+		}
+	}
+	return area
+}
+
+type imageKey struct {
+	source      string
+	transparent color.RGBA
+	keyed       bool
+}
+
+type tileKey struct {
+	cell common.Cell
+	tint color.RGBA
+}
+
+// renderer holds state for a single call to Render, chiefly images already loaded
+type renderer struct {
+	m      *common.Map
+	loader ImageLoader
+	proj   projection
+
+	images map[imageKey]*image.RGBA
+	tiles  map[tileKey]*image.RGBA
+}
+
+func (r *renderer) render() (*image.RGBA, error) {
+	out := image.NewRGBA(image.Rectangle{Max: r.proj.size()})
+
+	if r.m.BackgroundColor != nil {
+		bg := *r.m.BackgroundColor
+		if bg.A == 0 {
+			bg.A = 255 // colours written as #RRGGBB are read with no alpha, but are opaque
+		}
+		draw.Draw(out, out.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	}
+
+	for _, l := range r.m.AllLayers() {
+		var err error
+		switch layer := l.(type) {
+		case *common.TileLayer:
+			err = r.drawTileLayer(out, layer)
+		case *common.ImageLayer:
+			err = r.drawImageLayer(out, layer)
+		case *common.ObjectLayer:
+			err = r.drawObjectLayer(out, layer)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+func (r *renderer) drawTileLayer(out *image.RGBA, layer *common.TileLayer) error {
+	opacity := layer.EffectiveOpacity()
+	if !layer.EffectiveVisible() || opacity <= 0 {
+		return nil
+	}
+	ox, oy := layer.EffectiveOffset()
+	tint := layer.EffectiveTint()
+
+	area := layer.Bounds()
+	xs := cellOrder(area.Min.X, area.Max.X, r.m.RenderOrder() == common.MapRenderOrderLeftDown || r.m.RenderOrder() == common.MapRenderOrderLeftUp)
+	ys := cellOrder(area.Min.Y, area.Max.Y, r.m.RenderOrder() == common.MapRenderOrderRightUp || r.m.RenderOrder() == common.MapRenderOrderLeftUp)
+
+	for _, y := range ys {
+		for _, x := range xs {
+			cell := layer.GetCell(x, y)
+			if cell.IsEmpty() {
+				continue
+			}
+			img, err := r.tileImage(cell, tint)
+			if err != nil {
+				return err
+			}
+			if img == nil {
+				continue
+			}
+
+			// tiles sit on the bottom left of their cell, tall tiles reach up into the cells above
+			rect := r.proj.cellRect(x, y)
+			ts := cell.Tile.Tileset()
+			at := image.Pt(rect.Min.X+ox, rect.Max.Y-img.Bounds().Dy()+oy)
+			if ts != nil {
+				at = at.Add(image.Pt(ts.OffsetX, ts.OffsetY))
+			}
+			drawWithOpacity(out, img, at, opacity)
+		}
+	}
+	return nil
+}
+
+func (r *renderer) drawImageLayer(out *image.RGBA, layer *common.ImageLayer) error {
+	opacity := layer.EffectiveOpacity()
+	if !layer.EffectiveVisible() || opacity <= 0 || layer.ImageSource == "" {
+		return nil
+	}
+
+	img, err := r.loadImage(layer.ImageSource, layer.TransparentColour)
+	if err != nil {
+		return err
+	}
+	if tint := layer.EffectiveTint(); tint != nil {
+		img = transform(img, img.Bounds(), common.Cell{}, tint)
+	}
+
+	ox, oy := layer.EffectiveOffset()
+	drawWithOpacity(out, img, r.proj.origin().Add(image.Pt(ox, oy)), opacity)
+	return nil
+}
+
+func (r *renderer) drawObjectLayer(out *image.RGBA, layer *common.ObjectLayer) error {
+	opacity := layer.EffectiveOpacity()
+	if !layer.EffectiveVisible() || opacity <= 0 {
+		return nil
+	}
+	ox, oy := layer.EffectiveOffset()
+	tint := layer.EffectiveTint()
+
+	objects := append([]*common.Object{}, layer.Objects()...)
+	if layer.DrawOrder != common.ObjectGroupDrawOrderIndex {
+		sort.SliceStable(objects, func(i, j int) bool {
+			return objects[i].Y < objects[j].Y
+		})
+	}
+
+	for _, obj := range objects {
+		if !obj.Visible || obj.Kind() != common.ObjectTypeTile || obj.TileCell().IsEmpty() {
+			continue
+		}
+		cell := obj.TileCell()
+		img, err := r.tileImage(cell, tint)
+		if err != nil {
+			return err
+		}
+		if img == nil {
+			continue
+		}
+		width, height := int(math.Round(obj.Width)), int(math.Round(obj.Height))
+		if width > 0 && height > 0 && (width != img.Bounds().Dx() || height != img.Bounds().Dy()) {
+			img = scale(img, width, height)
+		}
+
+		// tile objects hang from their bottom left corner (bottom centre in isometric maps)
+		at := r.proj.pixelToScreen(int(math.Round(obj.X)), int(math.Round(obj.Y))).Add(image.Pt(ox, oy-img.Bounds().Dy()))
+		if r.m.Orientation() == common.MapOrientationIsometric {
+			at.X -= img.Bounds().Dx() / 2
+		}
+		if ts := cell.Tile.Tileset(); ts != nil {
+			at = at.Add(image.Pt(ts.OffsetX, ts.OffsetY))
+		}
+		drawWithOpacity(out, img, at, opacity)
+	}
+	return nil
+}
+
+// The image of the tile in the given cell, flipped & tinted. Returns nil if the tile
+// has no image.
+func (r *renderer) tileImage(cell common.Cell, tint *color.RGBA) (*image.RGBA, error) {
+	key := tileKey{cell: cell}
+	if tint != nil {
+		key.tint = *tint
+	}
+	if img, ok := r.tiles[key]; ok {
+		return img, nil
+	}
+
+	source := cell.Tile.ImageSource()
+	if source == "" {
+		r.tiles[key] = nil
+		return nil, nil
+	}
+
+	// images of tiles in external tilesets are relative to the tileset file
+	ts := cell.Tile.Tileset()
+	var transparent *color.RGBA
+	if ts != nil {
+		if ts.Source != "" {
+			source = path.Join(path.Dir(ts.Source), source)
+		}
+		if cell.Tile.Source == "" {
+			transparent = ts.TransparentColour
+		}
+	}
+
+	src, err := r.loadImage(source, transparent)
+	if err != nil {
+		return nil, err
+	}
+
+	rect := cell.Tile.SourceRect().Add(src.Bounds().Min)
+	if rect.Empty() {
+		rect = src.Bounds() // the tile didn't state it's size, so it's the whole image
+	}
+
+	img := transform(src, rect.Intersect(src.Bounds()), cell, tint)
+	r.tiles[key] = img
+	return img, nil
+}
+
+// Load an image, pixels of the transparent colour (if given) are made fully transparent
+func (r *renderer) loadImage(source string, transparent *color.RGBA) (*image.RGBA, error) {
+	key := imageKey{source: source}
+	if transparent != nil {
+		key.transparent = *transparent
+		key.keyed = true
+	}
+	if img, ok := r.images[key]; ok {
+		return img, nil
+	}
+
+	loaded, err := r.loader.LoadImage(source)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to load image %s: %v", source, err))
+	}
+
+	img := toRGBA(loaded)
+	if transparent != nil {
+		keyOut(img, *transparent)
+	}
+	r.images[key] = img
+	return img, nil
+}
+
+// Indexes from min to max (exclusive), backwards if reverse is set
+func cellOrder(min, max int, reverse bool) []int {
+	result := make([]int, 0, max-min)
+	for i := min; i < max; i++ {
+		if reverse {
+			result = append(result, max-1-(i-min))
+		} else {
+			result = append(result, i)
+		}
+	}
+	return result
+}
@@ -0,0 +1,389 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+	"testing/fstest"
+
+	"github.com/voidshard/libtmx/codecs/v1"
+	"github.com/voidshard/libtmx/common"
+)
+
+var (
+	red   = color.RGBA{255, 0, 0, 255}
+	green = color.RGBA{0, 255, 0, 255}
+	blue  = color.RGBA{0, 0, 255, 255}
+	white = color.RGBA{255, 255, 255, 255}
+	clear = color.RGBA{}
+)
+
+type memLoader map[string]image.Image
+
+func (m memLoader) LoadImage(source string) (image.Image, error) {
+	img, ok := m[source]
+	if !ok {
+		return nil, errors.New("no such image " + source)
+	}
+	return img, nil
+}
+
+// A solid image of the given size
+func solid(w, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// A 4x2 atlas of two 2x2 tiles: the first blue with a red top left corner, the second white
+func atlas() *image.RGBA {
+	img := solid(4, 2, white)
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			img.SetRGBA(x, y, blue)
+		}
+	}
+	img.SetRGBA(0, 0, red)
+	return img
+}
+
+// Map of the given size & orientation with an atlas tileset of 2x2 tiles (see atlas())
+func atlasMap(t *testing.T, opts ...common.MapOption) (*common.Map, *common.Tileset) {
+	m := common.NewMap(append([]common.MapOption{common.TileWidth(2), common.TileHeight(2)}, opts...)...)
+	ts := m.NewTileset("atlas")
+	ts.FirstGID = 1
+	ts.SetImage("atlas.png", 4, 2)
+	if ts.TileCount() != 2 {
+		t.Fatalf("expected 2 tiles in atlas, got %d", ts.TileCount())
+	}
+	return m, ts
+}
+
+func tile(ts *common.Tileset, id int) *common.Tile {
+	tile, _ := ts.Tile(id)
+	return tile
+}
+
+func expectPixels(t *testing.T, img *image.RGBA, expect map[image.Point]color.RGBA) {
+	t.Helper()
+	for p, c := range expect {
+		got := img.RGBAAt(p.X, p.Y)
+		if got != c {
+			t.Errorf("pixel %v: expected %v got %v", p, c, got)
+		}
+	}
+}
+
+func TestRenderOrthogonalFlips(t *testing.T) {
+	m, ts := atlasMap(t, common.Width(2), common.Height(2))
+	layer := m.NewTileLayer("ground")
+	layer.PutCell(0, 0, common.Cell{Tile: tile(ts, 0)})
+	layer.PutCell(1, 0, common.Cell{Tile: tile(ts, 0), FlipH: true})
+	layer.PutCell(0, 1, common.Cell{Tile: tile(ts, 0), FlipV: true})
+	layer.PutCell(1, 1, common.Cell{Tile: tile(ts, 0), FlipH: true, FlipD: true}) // rotated 90 degrees clockwise
+
+	img, err := Render(m, memLoader{"atlas.png": atlas()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img.Bounds() != image.Rect(0, 0, 4, 4) {
+		t.Fatalf("expected 4x4 image, got %v", img.Bounds())
+	}
+	expectPixels(t, img, map[image.Point]color.RGBA{
+		{0, 0}: red, {1, 0}: blue, {0, 1}: blue,
+		{3, 0}: red, {2, 0}: blue,
+		{0, 3}: red, {0, 2}: blue,
+		{3, 2}: red, {2, 2}: blue, {3, 3}: blue,
+	})
+}
+
+func TestRenderOrthogonalLayers(t *testing.T) {
+	cases := []struct {
+		Name   string
+		Setup  func(m *common.Map, ts *common.Tileset)
+		Expect map[image.Point]color.RGBA
+	}{
+		{
+			Name: "background",
+			Setup: func(m *common.Map, ts *common.Tileset) {
+				m.BackgroundColor = &color.RGBA{0, 255, 0, 0} // as read from #00ff00
+				m.NewTileLayer("ground").Put(0, 0, tile(ts, 1))
+			},
+			Expect: map[image.Point]color.RGBA{{0, 0}: white, {2, 0}: green, {3, 3}: green},
+		},
+		{
+			Name: "invisible",
+			Setup: func(m *common.Map, ts *common.Tileset) {
+				l := m.NewTileLayer("ground")
+				l.Put(0, 0, tile(ts, 1))
+				l.Visible = false
+			},
+			Expect: map[image.Point]color.RGBA{{0, 0}: clear},
+		},
+		{
+			Name: "invisible-group",
+			Setup: func(m *common.Map, ts *common.Tileset) {
+				g := m.NewGroupLayer("group")
+				g.Visible = false
+				inner := g.NewTileLayer("ground")
+				inner.Put(0, 0, tile(ts, 1))
+			},
+			Expect: map[image.Point]color.RGBA{{0, 0}: clear},
+		},
+		{
+			Name: "opacity",
+			Setup: func(m *common.Map, ts *common.Tileset) {
+				m.BackgroundColor = &color.RGBA{0, 0, 0, 255}
+				l := m.NewTileLayer("ground")
+				l.Put(0, 0, tile(ts, 1))
+				l.Opacity = 0.5
+			},
+			Expect: map[image.Point]color.RGBA{{0, 0}: {128, 128, 128, 255}},
+		},
+		{
+			Name: "layer-offset",
+			Setup: func(m *common.Map, ts *common.Tileset) {
+				l := m.NewTileLayer("ground")
+				l.Put(0, 0, tile(ts, 1))
+				l.OffsetX, l.OffsetY = 1, 2
+			},
+			Expect: map[image.Point]color.RGBA{{0, 0}: clear, {1, 2}: white, {2, 3}: white, {3, 3}: clear},
+		},
+		{
+			Name: "tileset-offset",
+			Setup: func(m *common.Map, ts *common.Tileset) {
+				ts.OffsetX = 2
+				m.NewTileLayer("ground").Put(0, 0, tile(ts, 1))
+			},
+			Expect: map[image.Point]color.RGBA{{0, 0}: clear, {2, 0}: white, {3, 1}: white},
+		},
+		{
+			Name: "tint",
+			Setup: func(m *common.Map, ts *common.Tileset) {
+				l := m.NewTileLayer("ground")
+				l.Put(0, 0, tile(ts, 1))
+				l.TintColour = &color.RGBA{255, 0, 0, 255}
+			},
+			Expect: map[image.Point]color.RGBA{{0, 0}: red},
+		},
+		{
+			Name: "transparent-colour",
+			Setup: func(m *common.Map, ts *common.Tileset) {
+				ts.TransparentColour = &color.RGBA{0, 0, 255, 0}
+				m.NewTileLayer("ground").Put(0, 0, tile(ts, 0))
+			},
+			Expect: map[image.Point]color.RGBA{{0, 0}: red, {1, 1}: clear},
+		},
+		{
+			Name: "image-layer",
+			Setup: func(m *common.Map, ts *common.Tileset) {
+				l := m.NewImageLayer("picture", "picture.png")
+				l.OffsetX = 1
+				l.TransparentColour = &color.RGBA{0, 0, 255, 0}
+			},
+			Expect: map[image.Point]color.RGBA{{0, 0}: clear, {1, 0}: red, {2, 0}: clear, {3, 0}: white},
+		},
+		{
+			Name: "layer-order",
+			Setup: func(m *common.Map, ts *common.Tileset) {
+				m.NewTileLayer("under").Put(0, 0, tile(ts, 0))
+				m.NewTileLayer("over").Put(0, 0, tile(ts, 1))
+			},
+			Expect: map[image.Point]color.RGBA{{0, 0}: white, {1, 1}: white},
+		},
+		{
+			Name: "tile-object",
+			Setup: func(m *common.Map, ts *common.Tileset) {
+				obj := common.NewObject("thing")
+				obj.X, obj.Y = 1, 4 // objects hang from their bottom left corner
+				obj.SetTileCell(common.Cell{Tile: tile(ts, 0), FlipH: true})
+				m.NewObjectLayer("objects").AddObjects(obj)
+			},
+			Expect: map[image.Point]color.RGBA{{1, 2}: blue, {2, 2}: red, {2, 3}: blue, {0, 2}: clear},
+		},
+	}
+
+	loader := memLoader{"atlas.png": atlas(), "picture.png": atlas()}
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			m, ts := atlasMap(t, common.Width(2), common.Height(2))
+			tt.Setup(m, ts)
+
+			img, err := Render(m, loader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectPixels(t, img, tt.Expect)
+		})
+	}
+}
+
+func TestRenderOrder(t *testing.T) {
+	// a 2x4 tile overlaps the cell above it, which tile wins depends on the render order
+	cases := []struct {
+		Option common.MapOption
+		Expect color.RGBA
+	}{
+		{common.RenderOrderRightDown(), blue},
+		{common.RenderOrderRightUp(), white},
+	}
+
+	for _, tt := range cases {
+		m, ts := atlasMap(t, common.Width(1), common.Height(2), tt.Option)
+		tall := common.NewTile("tall.png")
+		tall.Id = 2
+		tall.Width, tall.Height = 2, 4
+		ts.AddTiles(tall)
+
+		l := m.NewTileLayer("ground")
+		l.Put(0, 0, tile(ts, 1))
+		l.Put(0, 1, tall)
+
+		img, err := Render(m, memLoader{"atlas.png": atlas(), "tall.png": solid(2, 4, blue)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectPixels(t, img, map[image.Point]color.RGBA{{0, 0}: tt.Expect, {0, 3}: blue})
+	}
+}
+
+func TestRenderIsometric(t *testing.T) {
+	m := common.NewMap(common.Width(2), common.Height(2), common.TileWidth(4), common.TileHeight(2), common.OrientationIsometric())
+	ts := m.NewTileset("tiles")
+	ts.FirstGID = 1
+	ts.SetImage("tiles.png", 4, 2)
+
+	l := m.NewTileLayer("ground")
+	l.Put(1, 0, tile(ts, 0))
+
+	img, err := Render(m, memLoader{"tiles.png": solid(4, 2, green)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img.Bounds() != image.Rect(0, 0, 8, 4) {
+		t.Fatalf("expected 8x4 image, got %v", img.Bounds())
+	}
+	// tile 1,0 is to the lower right of the top (0,0) tile
+	expectPixels(t, img, map[image.Point]color.RGBA{
+		{4, 1}: green, {7, 2}: green,
+		{2, 0}: clear, {3, 3}: clear,
+	})
+}
+
+func TestRenderStaggered(t *testing.T) {
+	cases := []struct {
+		Name   string
+		Option common.MapOption
+		Size   image.Rectangle
+		Expect map[image.Point]color.RGBA
+	}{
+		{
+			Name:   "y-odd",
+			Option: common.OrientationStaggered(common.MapStaggerAxisY, common.MapStaggerIndexOdd),
+			Size:   image.Rect(0, 0, 10, 3),
+			Expect: map[image.Point]color.RGBA{{9, 2}: green, {6, 1}: green, {5, 1}: clear, {1, 1}: clear},
+		},
+		{
+			Name:   "y-even",
+			Option: common.OrientationStaggered(common.MapStaggerAxisY, common.MapStaggerIndexEven),
+			Size:   image.Rect(0, 0, 10, 3),
+			Expect: map[image.Point]color.RGBA{{4, 1}: green, {7, 2}: green, {9, 2}: clear},
+		},
+		{
+			Name:   "x-odd",
+			Option: common.OrientationStaggered(common.MapStaggerAxisX, common.MapStaggerIndexOdd),
+			Size:   image.Rect(0, 0, 6, 5),
+			Expect: map[image.Point]color.RGBA{{2, 3}: green, {5, 4}: green, {2, 2}: clear},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			m := common.NewMap(common.Width(2), common.Height(2), common.TileWidth(4), common.TileHeight(2), tt.Option)
+			ts := m.NewTileset("tiles")
+			ts.FirstGID = 1
+			ts.SetImage("tiles.png", 4, 2)
+			m.NewTileLayer("ground").Put(1, 1, tile(ts, 0))
+
+			img, err := Render(m, memLoader{"tiles.png": solid(4, 2, green)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if img.Bounds() != tt.Size {
+				t.Fatalf("expected image %v, got %v", tt.Size, img.Bounds())
+			}
+			expectPixels(t, img, tt.Expect)
+		})
+	}
+}
+
+func TestRenderInfinite(t *testing.T) {
+	m, ts := atlasMap(t, common.Infinite())
+	l := m.NewTileLayer("ground")
+	l.Put(-1, -1, tile(ts, 1))
+	l.Put(17, 0, tile(ts, 0))
+
+	img, err := Render(m, memLoader{"atlas.png": atlas()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// chunks -16,-16 to 32,16 hold tiles
+	if img.Bounds() != image.Rect(0, 0, 96, 64) {
+		t.Fatalf("expected 96x64 image, got %v", img.Bounds())
+	}
+	expectPixels(t, img, map[image.Point]color.RGBA{{30, 30}: white, {66, 32}: red})
+}
+
+func TestRenderResolverLoader(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	if err := png.Encode(buf, atlas()); err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{"tilesets/atlas.png": {Data: buf.Bytes()}}
+
+	m, ts := atlasMap(t, common.Width(1), common.Height(1))
+	ts.Source = "tilesets/atlas.tsx" // the tileset image is relative to the tileset file
+	m.NewTileLayer("ground").Put(0, 0, tile(ts, 0))
+
+	img, err := Render(m, NewResolverLoader(v1.NewFSResolver(fsys, ".")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectPixels(t, img, map[image.Point]color.RGBA{{0, 0}: red, {1, 1}: blue})
+
+	ts.ImageSource = "missing.png"
+	_, err = Render(m, NewResolverLoader(v1.NewFSResolver(fsys, ".")))
+	if err == nil {
+		t.Error("expected an error for a missing image")
+	}
+}
+
+func TestRenderHexagonal(t *testing.T) {
+	m := common.NewMap(common.Width(2), common.Height(2), common.TileWidth(14), common.TileHeight(12),
+		common.OrientationHexagonal(6, common.MapStaggerAxisY, common.MapStaggerIndexOdd))
+	ts := m.NewTileset("tiles")
+	ts.FirstGID = 1
+	ts.SetImage("tiles.png", 14, 12)
+	m.NewTileLayer("ground").Put(1, 1, tile(ts, 0))
+
+	img, err := Render(m, memLoader{"tiles.png": solid(14, 12, green)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if img.Bounds() != image.Rect(0, 0, 35, 21) {
+		t.Fatalf("expected 35x21 image, got %v", img.Bounds())
+	}
+	// odd rows are shifted right by half a tile
+	expectPixels(t, img, map[image.Point]color.RGBA{{21, 9}: green, {34, 20}: green, {20, 9}: clear})
+}
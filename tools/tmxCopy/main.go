@@ -2,19 +2,44 @@ package main
 
 import (
 	"github.com/voidshard/libtmx"
+	"github.com/voidshard/libtmx/codecs/tmj"
+	"github.com/voidshard/libtmx/codecs/v1"
 	"gopkg.in/alecthomas/kingpin.v2"
 	"os"
 	"io/ioutil"
 	"fmt"
+	"path/filepath"
+	"strings"
 )
 
 var (
-	inFile = kingpin.Arg("file", "Input .tmx file").String()
-	outFile = kingpin.Arg("output", "Output .tmx file").String()
+	inFile = kingpin.Arg("file", "Input .tmx or .tmj file").String()
+	outFile = kingpin.Arg("output", "Output .tmx or .tmj file (the format may differ from the input)").String()
+	encoding = kingpin.Flag("encoding", "Tile data encoding to write (csv, base64), defaults to that of each layer").String()
+	compression = kingpin.Flag("compression", "Tile data compression to write with base64 encoding (gzip, zlib, zstd)").String()
+	external = kingpin.Flag("external-tilesets", "Write external tilesets as references rather than inlining them").Bool()
 )
 
+// Pick the codec for the given file by it's extension, JSON for .tmj & .json files, XML otherwise
+func codecFor(filename string, resolver v1.Resolver) libtmx.TmxCodec {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".tmj", ".json":
+		opts := []tmj.Option{tmj.DataEncoding(*encoding, *compression), tmj.WithResolver(resolver)}
+		if *external {
+			opts = append(opts, tmj.ExternalTilesets())
+		}
+		return libtmx.CodecJSON(opts...)
+	}
+
+	opts := []v1.Option{v1.DataEncoding(*encoding, *compression), v1.WithResolver(resolver)}
+	if *external {
+		opts = append(opts, v1.ExternalTilesets())
+	}
+	return libtmx.CodecV1(opts...)
+}
+
 // Test of our marshal / unmarshal functions
-//  - read in tmx file, parse to internal format & write out copy.
+//  - read in tmx (or tmj) file, parse to internal format & write out copy.
 func main() {
 	kingpin.Parse()
 
@@ -27,15 +52,19 @@ func main() {
 		panic(err)
 	}
 
-	parser := libtmx.CodecV1()
-	xmap, err := parser.Unmarshal(data)
+	resolver, err := v1.NewFileResolver(*inFile)
+	if err != nil {
+		panic(err)
+	}
+
+	xmap, err := codecFor(*inFile, resolver).Unmarshal(data)
 	if err != nil {
 		panic(err)
 	}
 
 	fmt.Println(xmap.BackgroundColor)
 
-	rawdata, err := parser.Marshal(xmap)
+	rawdata, err := codecFor(*outFile, resolver).Marshal(xmap)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"github.com/voidshard/libtmx"
+	"github.com/voidshard/libtmx/codecs/tmj"
+	"github.com/voidshard/libtmx/codecs/v1"
+	"github.com/voidshard/libtmx/common"
+	"github.com/voidshard/libtmx/diff"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	files = kingpin.Arg("files", "Before & after .tmx or .tmj files (or the seven arguments git passes a GIT_EXTERNAL_DIFF program)").Required().Strings()
+	format = kingpin.Flag("format", "Output format (text, json)").Default("text").Enum("text", "json")
+	relativeTo = kingpin.Flag("relative-to", "Directory to find external tilesets & templates in, rather than that of each file (eg. when git hands us temporary copies)").String()
+	exitCode = kingpin.Flag("exit-code", "Exit with 1 if the maps differ").Bool()
+)
+
+// The file git uses to stand for a file that doesn't exist (ie. one added or deleted)
+const devNull = "/dev/null"
+
+// Pick the codec for the given file by it's extension, JSON for .tmj & .json files, XML otherwise
+func codecFor(filename string, resolver v1.Resolver) libtmx.TmxCodec {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".tmj", ".json":
+		return libtmx.CodecJSON(tmj.WithResolver(resolver))
+	}
+	return libtmx.CodecV1(v1.WithResolver(resolver))
+}
+
+// Read the given map, finding the files it refers to in 'dir' if given
+func readMap(filename, dir string) (*common.Map, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	relative := filename
+	if dir != "" {
+		relative = filepath.Join(dir, filepath.Base(filename))
+	}
+	resolver, err := v1.NewFileResolver(relative)
+	if err != nil {
+		return nil, err
+	}
+
+	return codecFor(filename, resolver).Unmarshal(data)
+}
+
+// Compare two maps, printing what changed between them.
+//
+// Works as a git difftool
+//   git difftool -x tmxDiff -- level.tmx
+// and as a git diff driver (given the seven arguments of GIT_EXTERNAL_DIFF), eg. in .gitattributes
+//   *.tmx diff=tmx
+// with .git/config
+//   [diff "tmx"]
+//     command = tmxDiff
+// In the latter case external tilesets are found relative to the map in the work tree, as git
+// passes in temporary copies of the map.
+func main() {
+	kingpin.Parse()
+
+	name := ""
+	before, after := "", ""
+	dir := *relativeTo
+	switch len(*files) {
+	case 2:
+		before, after = (*files)[0], (*files)[1]
+	case 7:
+		// path old-file old-hex old-mode new-file new-hex new-mode
+		name = (*files)[0]
+		before, after = (*files)[1], (*files)[4]
+		if dir == "" {
+			dir = filepath.Dir(name)
+		}
+		fmt.Printf("tmxDiff a/%s b/%s\n", name, name)
+	default:
+		kingpin.Fatalf("expected 2 files (or the 7 arguments of GIT_EXTERNAL_DIFF), got %d", len(*files))
+	}
+
+	if before == devNull || after == devNull {
+		if before == devNull {
+			fmt.Println("+ map")
+		} else {
+			fmt.Println("- map")
+		}
+		if *exitCode {
+			os.Exit(1)
+		}
+		return
+	}
+
+	beforeMap, err := readMap(before, dir)
+	kingpin.FatalIfError(err, "reading %s", before)
+	afterMap, err := readMap(after, dir)
+	kingpin.FatalIfError(err, "reading %s", after)
+
+	report := diff.Maps(beforeMap, afterMap)
+	if *format == "json" {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	kingpin.FatalIfError(err, "writing report")
+
+	if *exitCode && !report.Empty() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/voidshard/libtmx"
+	"github.com/voidshard/libtmx/codecs/tmj"
+	"github.com/voidshard/libtmx/codecs/v1"
+	"github.com/voidshard/libtmx/common"
+	"github.com/voidshard/libtmx/merge"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	baseFile = kingpin.Arg("base", "The common ancestor .tmx or .tmj file").Required().String()
+	oursFile = kingpin.Arg("ours", "Our .tmx or .tmj file, the merged map is written here unless --output is given").Required().String()
+	theirsFile = kingpin.Arg("theirs", "Their .tmx or .tmj file").Required().String()
+	outFile = kingpin.Flag("output", "File to write the merged map to (defaults to ours)").String()
+	path = kingpin.Flag("path", "Path of the map in the work tree; external tilesets & templates are found relative to it & it's extension picks the format (eg. when git hands us temporary copies)").String()
+	format = kingpin.Flag("format", "Conflict output format (text, json)").Default("text").Enum("text", "json")
+)
+
+// Pick the codec for the given file by it's extension, JSON for .tmj & .json files, XML otherwise.
+// Tilesets & templates read from their own files are written back as references.
+func codecFor(filename string, resolver v1.Resolver) libtmx.TmxCodec {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".tmj", ".json":
+		return libtmx.CodecJSON(tmj.WithResolver(resolver), tmj.ExternalTilesets())
+	}
+	return libtmx.CodecV1(v1.WithResolver(resolver), v1.ExternalTilesets())
+}
+
+// Read the given map, finding the files it refers to relative to 'relative'
+func readMap(filename, relative string) (*common.Map, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	resolver, err := v1.NewFileResolver(relative)
+	if err != nil {
+		return nil, err
+	}
+	return codecFor(relative, resolver).Unmarshal(data)
+}
+
+// Three way merge of maps, exiting with 1 if there are conflicts (in which case where both sides
+// changed something differently ours is kept).
+//
+// Works as a git merge driver, eg. in .gitattributes
+//   *.tmx merge=tmx
+// with .git/config
+//   [merge "tmx"]
+//     name = TMX map merge
+//     driver = tmxMerge --path %P %O %A %B
+func main() {
+	kingpin.Parse()
+
+	relative := *path
+	if relative == "" {
+		relative = *oursFile
+	}
+	output := *outFile
+	if output == "" {
+		output = *oursFile
+	}
+
+	base, err := readMap(*baseFile, relative)
+	kingpin.FatalIfError(err, "reading %s", *baseFile)
+	ours, err := readMap(*oursFile, relative)
+	kingpin.FatalIfError(err, "reading %s", *oursFile)
+	theirs, err := readMap(*theirsFile, relative)
+	kingpin.FatalIfError(err, "reading %s", *theirsFile)
+
+	merged, conflicts := merge.Maps(base, ours, theirs)
+
+	resolver, err := v1.NewFileResolver(relative)
+	kingpin.FatalIfError(err, "writing %s", output)
+	data, err := codecFor(relative, resolver).Marshal(merged)
+	kingpin.FatalIfError(err, "writing %s", output)
+	err = ioutil.WriteFile(output, data, 0644)
+	kingpin.FatalIfError(err, "writing %s", output)
+
+	if *format == "json" {
+		data, err := json.MarshalIndent(conflicts, "", "  ")
+		kingpin.FatalIfError(err, "writing conflicts")
+		fmt.Println(string(data))
+	} else {
+		for _, c := range conflicts {
+			fmt.Println(c)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		os.Exit(1)
+	}
+}
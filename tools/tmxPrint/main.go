@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"github.com/voidshard/libtmx"
+	"github.com/voidshard/libtmx/codecs/v1"
 	"gopkg.in/alecthomas/kingpin.v2"
 	"os"
 	"io/ioutil"
@@ -26,7 +27,12 @@ func main() {
 		panic(err)
 	}
 
-	parser := libtmx.CodecV1()
+	resolver, err := v1.NewFileResolver(*inFile)
+	if err != nil {
+		panic(err)
+	}
+
+	parser := libtmx.CodecV1(v1.WithResolver(resolver))
 	xmap, err := parser.Unmarshal(data)
 	if err != nil {
 		panic(err)
@@ -38,7 +44,7 @@ func main() {
 	}
 
 	for _, tileset := range xmap.Tilesets() {
-		fmt.Println("> Tileset:", tileset.Name, "Tiles:", len(tileset.Tiles()))
+		fmt.Println("> Tileset:", tileset.Name, "Tiles:", len(tileset.Tiles()), "Source:", tileset.Source, "Image:", tileset.ImageSource)
 
 		for _, prop := range tileset.Properties() {
 			fmt.Println("   ", prop.Name(), prop.Type())
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/voidshard/libtmx/codecs/tmj"
+	"github.com/voidshard/libtmx/codecs/v1"
+	"github.com/voidshard/libtmx/common"
+	"github.com/voidshard/libtmx/validate"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	paths = kingpin.Arg("paths", "Input .tmx & .tsx files, or directories to search for them").Required().ExistingFilesOrDirs()
+	format = kingpin.Flag("format", "Output format (text, json)").Default("text").Enum("text", "json")
+	configFile = kingpin.Flag("config", "JSON rule config, turning rules off or changing their severity (see validate.Config)").ExistingFile()
+	typesFile = kingpin.Flag("property-types", "Tiled project (.tiled-project) or exported custom types, to check class properties against").ExistingFile()
+)
+
+// An issue found in a file
+type fileIssue struct {
+	File   string `json:"file"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+	validate.Issue
+}
+
+func (i fileIssue) String() string {
+	where := i.File
+	if i.Line > 0 {
+		where = fmt.Sprintf("%s:%d:%d", i.File, i.Line, i.Column)
+	}
+	return fmt.Sprintf("%s: %s", where, i.Issue)
+}
+
+// The .tmx & .tsx files at the given paths, searching directories
+func findFiles(in []string) ([]string, error) {
+	result := []string{}
+	for _, root := range in {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			ext := strings.ToLower(filepath.Ext(path))
+			if !info.IsDir() && (ext == ".tmx" || ext == ".tsx") {
+				result = append(result, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Turn problems found decoding the given file into issues. Problems in other files the file
+// refers to (eg. external tilesets) are reported against those files.
+func decodeIssues(filename string, errs ...error) []fileIssue {
+	result := []fileIssue{}
+	for _, err := range errs {
+		issue := fileIssue{
+			File: filename,
+			Issue: validate.Issue{Rule: validate.RuleDecode, Severity: validate.SeverityError, Message: err.Error()},
+		}
+		var derr *v1.DecodeError
+		if errors.As(err, &derr) {
+			if derr.File != "" {
+				issue.File = filepath.Join(filepath.Dir(filename), derr.File)
+			}
+			issue.Line = derr.Line
+			issue.Column = derr.Column
+			issue.Path = derr.Path
+			issue.Message = derr.Err.Error()
+		}
+		result = append(result, issue)
+	}
+	return result
+}
+
+// Decode the given file, noting every problem (rather than stopping at the first), then
+// validate what was decoded
+func validateFile(filename string, types *common.PropertyTypes) []fileIssue {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return decodeIssues(filename, err)
+	}
+	resolver, err := v1.NewFileResolver(filename)
+	if err != nil {
+		return decodeIssues(filename, err)
+	}
+
+	opts := []validate.Option{}
+	codecOpts := []v1.Option{v1.WithResolver(resolver), v1.Lenient()}
+	if types != nil {
+		opts = append(opts, validate.WithPropertyTypes(types))
+		codecOpts = append(codecOpts, v1.WithPropertyTypes(types))
+	}
+	codec := v1.NewCodecV1(codecOpts...)
+
+	var issues []validate.Issue
+	var warnings []*v1.DecodeError
+	if strings.ToLower(filepath.Ext(filename)) == ".tsx" {
+		var tset *common.Tileset
+		tset, warnings, err = codec.UnmarshalTilesetWithWarnings(data)
+		if err == nil {
+			issues = validate.ValidateTileset(tset, opts...)
+		}
+	} else {
+		var m *common.Map
+		m, warnings, err = codec.UnmarshalWithWarnings(data)
+		if err == nil {
+			issues = validate.Validate(m, opts...)
+		}
+	}
+
+	errs := []error{}
+	for _, w := range warnings {
+		errs = append(errs, w)
+	}
+	if err != nil {
+		errs = append(errs, err)
+	}
+	result := decodeIssues(filename, errs...)
+	for _, issue := range issues {
+		result = append(result, fileIssue{File: filename, Issue: issue})
+	}
+	return result
+}
+
+// Check maps & tilesets for problems, exiting with 1 if any errors are found.
+//  - every file is decoded, reporting all decode problems, then checked with the validate package
+func main() {
+	kingpin.Parse()
+
+	var config *validate.Config
+	if *configFile != "" {
+		data, err := ioutil.ReadFile(*configFile)
+		kingpin.FatalIfError(err, "reading config")
+		config, err = validate.ParseConfig(data)
+		kingpin.FatalIfError(err, "reading config")
+	}
+
+	var types *common.PropertyTypes
+	if *typesFile != "" {
+		data, err := ioutil.ReadFile(*typesFile)
+		kingpin.FatalIfError(err, "reading property types")
+		types, err = tmj.NewCodecTMJ().UnmarshalPropertyTypes(data)
+		kingpin.FatalIfError(err, "reading property types")
+	}
+
+	files, err := findFiles(*paths)
+	kingpin.FatalIfError(err, "finding files")
+
+	report := []fileIssue{}
+	failed := false
+	for _, filename := range files {
+		for _, issue := range validateFile(filename, types) {
+			applied := config.Apply([]validate.Issue{issue.Issue})
+			if len(applied) == 0 {
+				continue
+			}
+			issue.Issue = applied[0]
+			if issue.Severity == validate.SeverityError {
+				failed = true
+			}
+			report = append(report, issue)
+		}
+	}
+
+	if *format == "json" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		kingpin.FatalIfError(err, "writing report")
+		fmt.Println(string(data))
+	} else {
+		for _, issue := range report {
+			fmt.Println(issue)
+		}
+		fmt.Printf("%d files checked, %d issues\n", len(files), len(report))
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
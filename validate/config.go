@@ -0,0 +1,80 @@
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// RuleDecode is for problems found decoding a file (eg. v1.DecodeError), for tools that
+// report these alongside the issues found by Validate
+const RuleDecode = "decode"
+
+// Settings for a rule in a Config
+const (
+	RuleOff     = "off"
+	RuleWarning = string(SeverityWarning)
+	RuleError   = string(SeverityError)
+)
+
+// Config turns rules off or changes the severity of their issues. It's read from JSON
+// mapping rule names to "off", "warning" or "error", eg.
+//
+//	{"rules": {"object-reference": "off", "layer-size": "warning"}}
+//
+// Rules that aren't mentioned are left as they are.
+type Config struct {
+	Rules map[string]string `json:"rules"`
+}
+
+// ParseConfig reads a Config from JSON, checking it only mentions known rules (those in Rules,
+// & RuleDecode) and settings.
+func ParseConfig(data []byte) (*Config, error) {
+	cfg := &Config{}
+	err := json.Unmarshal(data, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[string]bool{RuleDecode: true}
+	for _, rule := range Rules {
+		known[rule] = true
+	}
+
+	names := []string{}
+	for rule := range cfg.Rules {
+		names = append(names, rule)
+	}
+	sort.Strings(names)
+	for _, rule := range names {
+		if !known[rule] {
+			return nil, errors.New(fmt.Sprintf("Unknown rule %s", rule))
+		}
+		switch cfg.Rules[rule] {
+		case RuleOff, RuleWarning, RuleError:
+		default:
+			return nil, errors.New(fmt.Sprintf("Rule %s has unknown setting %s, expected %s, %s or %s", rule, cfg.Rules[rule], RuleOff, RuleWarning, RuleError))
+		}
+	}
+	return cfg, nil
+}
+
+// Apply the config to the given issues, dropping those of rules that are off & setting the
+// severity of the others. A nil Config leaves the issues as they are.
+func (c *Config) Apply(issues []Issue) []Issue {
+	if c == nil {
+		return issues
+	}
+	var result []Issue
+	for _, issue := range issues {
+		switch setting := c.Rules[issue.Rule]; setting {
+		case RuleOff:
+			continue
+		case RuleWarning, RuleError:
+			issue.Severity = Severity(setting)
+		}
+		result = append(result, issue)
+	}
+	return result
+}
@@ -0,0 +1,60 @@
+package validate
+
+import (
+	"testing"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`{"rules": {"object-reference": "off", "layer-size": "warning", "decode": "error"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Rules[RuleObjectReference] != RuleOff || cfg.Rules[RuleLayerSize] != RuleWarning {
+		t.Error("unexpected rules", cfg.Rules)
+	}
+
+	for _, bad := range []string{
+		`{"rules": {"no-such-rule": "off"}}`,
+		`{"rules": {"layer-size": "loud"}}`,
+		`{"rules": `,
+	} {
+		_, err := ParseConfig([]byte(bad))
+		if err == nil {
+			t.Error("expected an error for", bad)
+		}
+	}
+}
+
+func TestConfigApply(t *testing.T) {
+	issues := []Issue{
+		{Rule: RuleLayerSize, Severity: SeverityError, Path: "map/layer[a]"},
+		{Rule: RuleObjectReference, Severity: SeverityWarning, Path: "map/layer[a]/property[b]"},
+		{Rule: RuleTerrain, Severity: SeverityError, Path: "map/tileset[c]/tile[0]"},
+	}
+	cfg := &Config{Rules: map[string]string{RuleObjectReference: RuleOff, RuleLayerSize: RuleWarning}}
+
+	got := cfg.Apply(issues)
+	expectIssues(t, got, "layer-size map/layer[a]", "terrain map/tileset[c]/tile[0]")
+	if got[0].Severity != SeverityWarning || got[1].Severity != SeverityError {
+		t.Error("unexpected severities", got)
+	}
+	if issues[0].Severity != SeverityError {
+		t.Error("expected the given issues to be left as they were")
+	}
+
+	var none *Config
+	if len(none.Apply(issues)) != len(issues) {
+		t.Error("expected a nil config to keep every issue")
+	}
+}
+
+func TestValidateTileset(t *testing.T) {
+	tset := common.NewTileset("ground", common.NewTile("grass.png"))
+	tset.Tiles()[0].UpdateProperties(common.NewProp("target").SetObject(99))
+	tset.Tiles()[0].SetTopLeftTerrain(common.NewTerrain("lava"))
+
+	// object references can't be checked without a map
+	expectIssues(t, ValidateTileset(tset), "terrain tileset[ground]/tile[0]")
+}
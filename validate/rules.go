@@ -0,0 +1,275 @@
+package validate
+
+import (
+	"fmt"
+	"image"
+	"sort"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+// Corners of a tile, in the order of common.Tile.Terrain()
+var cornerNames = []string{"top left", "top right", "bottom left", "bottom right"}
+
+func (v *validator) checkOrientation() {
+	m := v.m
+
+	stagger := func() {
+		if m.StaggerAxis() != common.MapStaggerAxisX && m.StaggerAxis() != common.MapStaggerAxisY {
+			v.add(RuleOrientation, SeverityError, "map", "%s maps need a stagger axis of x or y, got '%s'", m.Orientation(), m.StaggerAxis())
+		}
+		if m.StaggerIndex() != common.MapStaggerIndexOdd && m.StaggerIndex() != common.MapStaggerIndexEven {
+			v.add(RuleOrientation, SeverityError, "map", "%s maps need a stagger index of odd or even, got '%s'", m.Orientation(), m.StaggerIndex())
+		}
+	}
+	noStagger := func() {
+		if m.StaggerAxis() != "" || m.StaggerIndex() != "" {
+			v.add(RuleOrientation, SeverityWarning, "map", "Stagger axis & index are ignored by %s maps", m.Orientation())
+		}
+	}
+	noHexSide := func() {
+		if m.HexSideLength != 0 {
+			v.add(RuleOrientation, SeverityWarning, "map", "Hex side length is ignored by %s maps", m.Orientation())
+		}
+	}
+
+	switch m.Orientation() {
+	case common.MapOrientationOrthogonal, common.MapOrientationIsometric:
+		noStagger()
+		noHexSide()
+	case common.MapOrientationStaggered:
+		stagger()
+		noHexSide()
+	case common.MapOrientationHexagonal:
+		stagger()
+		size := m.TileHeight
+		if m.StaggerAxis() == common.MapStaggerAxisX {
+			size = m.TileWidth
+		}
+		if m.HexSideLength < 0 || m.HexSideLength > size {
+			v.add(RuleOrientation, SeverityError, "map", "Hex side length %d must be between 0 and the tile size along the stagger axis (%d)", m.HexSideLength, size)
+		}
+	default:
+		v.add(RuleOrientation, SeverityError, "map", "Unknown orientation '%s'", m.Orientation())
+	}
+
+	if m.TileWidth < 1 || m.TileHeight < 1 {
+		v.add(RuleOrientation, SeverityError, "map", "Tiles must be at least 1x1 pixels, got %dx%d", m.TileWidth, m.TileHeight)
+	}
+
+	switch m.RenderOrder() {
+	case "", common.MapRenderOrderRightDown, common.MapRenderOrderRightUp, common.MapRenderOrderLeftDown, common.MapRenderOrderLeftUp:
+	default:
+		v.add(RuleOrientation, SeverityError, "map", "Unknown render order '%s'", m.RenderOrder())
+	}
+}
+
+// Each tileset takes the global ids from it's FirstGID to that of it's last tile, these
+// mustn't overlap (or start at 0, which means no tile)
+func (v *validator) checkTilesetGIDs() {
+	type gidRange struct {
+		tset     *common.Tileset
+		min, max int
+	}
+	ranges := []gidRange{}
+	for _, tset := range v.m.Tilesets() {
+		path := join("map", "tileset", tset.Name)
+		if tset.FirstGID < 1 {
+			v.add(RuleTilesetGIDs, SeverityError, path, "First global id %d must be at least 1", tset.FirstGID)
+			continue
+		}
+		last := -1
+		for _, tile := range tset.Tiles() {
+			if tile.Id > last {
+				last = tile.Id
+			}
+		}
+		ranges = append(ranges, gidRange{tset: tset, min: tset.FirstGID, max: tset.FirstGID + last})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].min < ranges[j].min })
+	for i := 1; i < len(ranges); i++ {
+		prev, r := ranges[i-1], ranges[i]
+		if r.min <= prev.max {
+			v.add(RuleTilesetGIDs, SeverityError, join("map", "tileset", r.tset.Name),
+				"Global ids %d-%d overlap those of tileset %s (%d-%d)", r.min, r.max, prev.tset.Name, prev.min, prev.max)
+		}
+	}
+}
+
+func (v *validator) checkTileset(path string, tset *common.Tileset) {
+	v.checkProperties(path, tset)
+	for _, ter := range tset.Terrain() {
+		v.checkProperties(join(path, "terrain", ter.Name), ter)
+	}
+	for _, set := range tset.WangSets() {
+		setPath := join(path, "wangset", set.Name)
+		v.checkProperties(setPath, set)
+		for _, colour := range set.Colours() {
+			v.checkProperties(join(setPath, "wangcolor", colour.Name), colour)
+		}
+	}
+
+	for _, tile := range tset.Tiles() {
+		tilePath := join(path, "tile", tile.Id)
+		v.checkProperties(tilePath, tile)
+		v.checkTerrain(tilePath, tset, tile)
+		v.checkAnimation(tilePath, tset, tile)
+		for _, shape := range tile.CollisionShapes() {
+			v.checkProperties(join(tilePath, "object", shape.Id), shape)
+		}
+	}
+}
+
+func (v *validator) checkTerrain(path string, tset *common.Tileset, tile *common.Tile) {
+	terrain := tset.Terrain()
+	for i, ter := range tile.Terrain() {
+		if ter == nil {
+			continue
+		}
+		if ter.Id < 0 || ter.Id >= len(terrain) || terrain[ter.Id] != ter {
+			v.add(RuleTerrain, SeverityError, path, "Terrain %s (id %d) of the %s corner isn't terrain of the tileset", ter.Name, ter.Id, cornerNames[i])
+		}
+	}
+}
+
+func (v *validator) checkAnimation(path string, tset *common.Tileset, tile *common.Tile) {
+	if tile.Animation == nil {
+		return
+	}
+	for i, frame := range tile.Animation.Frames {
+		if frame.Tile == nil {
+			v.add(RuleAnimationFrame, SeverityError, path, "Animation frame %d has no tile", i)
+			continue
+		}
+		if found, ok := tset.Tile(frame.Tile.Id); !ok || found != frame.Tile {
+			v.add(RuleAnimationFrame, SeverityError, path, "Animation frame %d refers to tile %d, which isn't in the tileset", i, frame.Tile.Id)
+		}
+	}
+}
+
+func (v *validator) checkLayers(path string, layers []common.Layer) {
+	for _, layer := range layers {
+		switch l := layer.(type) {
+		case *common.TileLayer:
+			layerPath := join(path, "layer", l.Name)
+			v.checkProperties(layerPath, l)
+			v.checkTileLayer(layerPath, l)
+		case *common.ImageLayer:
+			v.checkProperties(join(path, "imagelayer", l.Name), l)
+		case *common.ObjectLayer:
+			layerPath := join(path, "objectgroup", l.Name)
+			v.checkProperties(layerPath, l)
+			for _, obj := range l.Objects() {
+				objPath := join(layerPath, "object", obj.Id)
+				v.checkProperties(objPath, obj)
+				if obj.Kind() == common.ObjectTypeTile {
+					v.checkTile(objPath, obj.Tile(), "Object's tile", 1)
+				}
+			}
+		case *common.GroupLayer:
+			groupPath := join(path, "group", l.Name)
+			v.checkProperties(groupPath, l)
+			v.checkLayers(groupPath, l.Layers())
+		}
+	}
+}
+
+func (v *validator) checkTileLayer(path string, layer *common.TileLayer) {
+	if !v.m.Infinite() && (layer.Width() != v.m.Width || layer.Height() != v.m.Height) {
+		v.add(RuleLayerSize, SeverityError, path, "Layer is %dx%d tiles, the map is %dx%d", layer.Width(), layer.Height(), v.m.Width, v.m.Height)
+	}
+
+	// tiles that aren't in the map's tilesets, with where each is first used & how often
+	first := map[*common.Tile]image.Point{}
+	count := map[*common.Tile]int{}
+	order := []*common.Tile{}
+	for _, rect := range layer.Chunks() {
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				tile := layer.Get(x, y)
+				if tile == nil || v.tilesets[tile.Tileset()] {
+					continue
+				}
+				if _, ok := first[tile]; !ok {
+					first[tile] = image.Pt(x, y)
+					order = append(order, tile)
+				}
+				count[tile]++
+			}
+		}
+	}
+	for _, tile := range order {
+		at := first[tile]
+		v.checkTile(path, tile, fmt.Sprintf("Tile at %d,%d", at.X, at.Y), count[tile])
+	}
+}
+
+// Check the given tile is in one of the map's tilesets, what & count describe where it's used
+func (v *validator) checkTile(path string, tile *common.Tile, what string, count int) {
+	if tile == nil || v.tilesets[tile.Tileset()] {
+		return
+	}
+	also := ""
+	if count > 1 {
+		also = fmt.Sprintf(" (as are %d other cells)", count-1)
+	}
+	if tile.Tileset() == nil {
+		v.add(RuleUnknownTile, SeverityError, path, "%s has no tileset%s", what, also)
+		return
+	}
+	v.add(RuleUnknownTile, SeverityError, path, "%s is tile %d of tileset %s, which isn't in the map%s", what, tile.Id, tile.Tileset().Name, also)
+}
+
+func (v *validator) checkProperties(path string, holder common.PropertyHolder) {
+	for _, prop := range sortedProperties(holder) {
+		v.checkProperty(join(path, "property", prop.Name()), prop)
+	}
+}
+
+func (v *validator) checkProperty(path string, prop *common.Property) {
+	if prop.Type() == common.PropertyTypeObject && v.objects != nil && prop.AsObject() != 0 && !v.objects[prop.AsObject()] {
+		v.add(RuleObjectReference, SeverityWarning, path, "Refers to object %d, which isn't in the map", prop.AsObject())
+	}
+	v.checkClass(path, prop)
+	for _, member := range sortedMembers(prop) {
+		v.checkProperty(join(path, "property", member.Name()), member)
+	}
+}
+
+// Check a property with a custom type matches it's class, if it's a class we know
+func (v *validator) checkClass(path string, prop *common.Property) {
+	if v.propertyTypes == nil || prop.PropertyType() == "" {
+		return
+	}
+	class, known := v.propertyTypes.Class(prop.PropertyType())
+	if !known {
+		if prop.Type() == common.PropertyTypeClass {
+			v.add(RulePropertyType, SeverityWarning, path, "Unknown class %s", prop.PropertyType())
+		}
+		return // enums aren't kept, so any other custom type may be one
+	}
+	if prop.Type() != common.PropertyTypeClass {
+		v.add(RulePropertyType, SeverityError, path, "Property of class %s is of type %s", class.Name, prop.Type())
+		return
+	}
+
+	for _, member := range sortedMembers(prop) {
+		memberPath := join(path, "property", member.Name())
+		def, ok := class.Member(member.Name())
+		if !ok {
+			v.add(RulePropertyType, SeverityWarning, memberPath, "Not a member of class %s", class.Name)
+			continue
+		}
+		if def.Type() != member.Type() {
+			v.add(RulePropertyType, SeverityError, memberPath, "Member is of type %s, class %s says %s", member.Type(), class.Name, def.Type())
+		}
+	}
+}
+
+// Members of a class property sorted by name, so issues come out in a stable order
+func sortedMembers(prop *common.Property) []*common.Property {
+	members := append([]*common.Property{}, prop.Members()...)
+	sort.Slice(members, func(i, j int) bool { return members[i].Name() < members[j].Name() })
+	return members
+}
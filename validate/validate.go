@@ -0,0 +1,173 @@
+// Package validate checks a common.Map for problems that would otherwise only show up at
+// runtime (or when the map is opened in Tiled); tiles that belong to no tileset of the map,
+// tile layers that don't match the size of the map, tilesets whose global ids overlap and so on.
+//
+// Validate returns every Issue found, each with the Rule that found it, a Severity and the path
+// to the offending element, in the same form as the paths of v1.DecodeError
+// (eg. map/tileset[terrain]/tile[3]/property[speed]).
+package validate
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+// Severity of an issue
+type Severity string
+
+const (
+	// The map is broken; it won't load, or loads with tiles or values missing
+	SeverityError Severity = "error"
+
+	// The map loads, but has something that's probably a mistake
+	SeverityWarning Severity = "warning"
+)
+
+// Rules, that is the kinds of issue Validate looks for
+const (
+	// Tile layers of a fixed size map must be the size of the map
+	RuleLayerSize = "layer-size"
+
+	// Tiles placed in layers (or used by tile objects) must belong to a tileset of the map
+	RuleUnknownTile = "unknown-tile"
+
+	// Animation frames must refer to tiles of the animated tile's tileset
+	RuleAnimationFrame = "animation-frame"
+
+	// Tile terrain must be terrain of the tile's tileset
+	RuleTerrain = "terrain"
+
+	// The global tile ids of tilesets mustn't overlap
+	RuleTilesetGIDs = "tileset-gids"
+
+	// The orientation, stagger & render order settings must make sense together
+	RuleOrientation = "orientation"
+
+	// Properties of custom classes must match their class
+	RulePropertyType = "property-type"
+
+	// Object properties must refer to objects in the map
+	RuleObjectReference = "object-reference"
+)
+
+// Rules lists every rule Validate checks
+var Rules = []string{
+	RuleLayerSize,
+	RuleUnknownTile,
+	RuleAnimationFrame,
+	RuleTerrain,
+	RuleTilesetGIDs,
+	RuleOrientation,
+	RulePropertyType,
+	RuleObjectReference,
+}
+
+// Issue is a single problem found in a map
+type Issue struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Path     string   `json:"path"`
+	Message  string   `json:"message"`
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s: %s [%s]", i.Severity, i.Path, i.Message, i.Rule)
+}
+
+// Option configures Validate
+type Option func(*validator)
+
+// Check class properties against the given custom classes (eg. those of a Tiled project,
+// loaded with tmj.CodecTMJ.UnmarshalPropertyTypes). Without these only object properties
+// are checked.
+func WithPropertyTypes(types *common.PropertyTypes) Option {
+	return func(v *validator) {
+		v.propertyTypes = types
+	}
+}
+
+// Validate the given map, returning every issue found (nil if there are none). Issues with the
+// map's settings come first, then those of it's properties, tilesets & layers in order.
+func Validate(m *common.Map, opts ...Option) []Issue {
+	v := &validator{m: m}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	v.checkOrientation()
+	v.checkTilesetGIDs()
+
+	v.tilesets = map[*common.Tileset]bool{}
+	for _, tset := range m.Tilesets() {
+		v.tilesets[tset] = true
+	}
+	v.objects = map[int]bool{}
+	for _, layer := range m.AllLayers() {
+		if l, ok := layer.(*common.ObjectLayer); ok {
+			for _, obj := range l.Objects() {
+				v.objects[obj.Id] = true
+			}
+		}
+	}
+
+	v.checkProperties("map", m)
+	for _, tset := range m.Tilesets() {
+		v.checkTileset(join("map", "tileset", tset.Name), tset)
+	}
+	v.checkLayers("map", m.Layers())
+	return v.issues
+}
+
+// Validate the given standalone tileset (eg. one loaded from a .tsx file), returning every
+// issue found (nil if there are none). Object properties aren't checked, as the objects they
+// refer to are in the maps using the tileset.
+func ValidateTileset(tset *common.Tileset, opts ...Option) []Issue {
+	v := &validator{}
+	for _, opt := range opts {
+		opt(v)
+	}
+	v.checkTileset(join("", "tileset", tset.Name), tset)
+	return v.issues
+}
+
+// Holds the state of a single Validate (or ValidateTileset) call
+type validator struct {
+	m             *common.Map
+	propertyTypes *common.PropertyTypes
+
+	tilesets map[*common.Tileset]bool // tilesets of the map
+	objects  map[int]bool             // ids of objects in the map (nil if there's no map)
+
+	issues []Issue
+}
+
+func (v *validator) add(rule string, severity Severity, path, format string, args ...interface{}) {
+	v.issues = append(v.issues, Issue{
+		Rule:     rule,
+		Severity: severity,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// The path of an element within the element at the given path ("" for a root element), named
+// by it's kind & (if given) it's name or id. eg. join("map", "layer", "ground") is map/layer[ground]
+func join(path, kind string, id interface{}) string {
+	name := kind
+	if s := fmt.Sprintf("%v", id); s != "" {
+		name = fmt.Sprintf("%s[%s]", kind, s)
+	}
+	if path == "" {
+		return name
+	}
+	return path + "/" + name
+}
+
+// Properties sorted by name, so issues come out in a stable order
+func sortedProperties(holder common.PropertyHolder) []*common.Property {
+	props := holder.Properties()
+	sort.Slice(props, func(i, j int) bool { return props[i].Name() < props[j].Name() })
+	return props
+}
@@ -0,0 +1,135 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/voidshard/libtmx/common"
+)
+
+// A small valid map; a 3x2 layer of tiles from a single tileset, with an object layer
+func validMap() *common.Map {
+	m := common.NewMap(common.Width(3), common.Height(2), common.TileWidth(16), common.TileHeight(16))
+
+	tset := m.NewTileset("ground", common.NewTile("grass.png"), common.NewTile("sand.png"))
+	grass := common.NewTerrain("grass")
+	tset.AddTerrain(grass)
+	tiles := tset.Tiles()
+	tiles[0].SetTopLeftTerrain(grass)
+	tiles[0].SetAnimation(&common.Frame{Tile: tiles[1], Duration: 100})
+
+	layer := m.NewTileLayer("ground")
+	layer.Put(0, 0, tiles[0])
+	layer.Put(2, 1, tiles[1])
+
+	objects := m.NewObjectLayer("objects")
+	chest := common.NewObject("chest")
+	objects.AddObjects(chest)
+	m.FinalizeIDs()
+
+	door := common.NewObject("door")
+	door.UpdateProperties(common.NewProp("opens").SetObject(chest.Id))
+	objects.AddObjects(door)
+	m.FinalizeIDs()
+	return m
+}
+
+// The rule & path of each issue
+func summarise(issues []Issue) []string {
+	result := []string{}
+	for _, i := range issues {
+		result = append(result, i.Rule+" "+i.Path)
+	}
+	return result
+}
+
+func expectIssues(t *testing.T, issues []Issue, expect ...string) {
+	t.Helper()
+	got := summarise(issues)
+	if strings.Join(got, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("expected issues\n%s\ngot\n%s", strings.Join(expect, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestValidMap(t *testing.T) {
+	issues := Validate(validMap())
+	if issues != nil {
+		t.Error("expected no issues, got", issues)
+	}
+}
+
+func TestValidateTiles(t *testing.T) {
+	m := validMap()
+	tset := m.Tilesets()[0]
+	layer := m.TileLayers()[0]
+
+	other := common.NewTileset("other", common.NewTile("lava.png"))
+	lava := other.Tiles()[0]
+	layer.Put(1, 0, lava)
+	layer.Put(1, 1, lava)
+	tset.Tiles()[1].SetAnimation(&common.Frame{Tile: lava, Duration: 100})
+	tset.Tiles()[1].SetBottomRightTerrain(common.NewTerrain("lava"))
+
+	moved := m.NewTileset("moved", common.NewTile("a.png"))
+	moved.FirstGID = 2 // overlaps the ground tileset's 1-2
+
+	m.Width = 4
+
+	issues := Validate(m)
+	expectIssues(t, issues,
+		"tileset-gids map/tileset[moved]",
+		"terrain map/tileset[ground]/tile[1]",
+		"animation-frame map/tileset[ground]/tile[1]",
+		"layer-size map/layer[ground]",
+		"unknown-tile map/layer[ground]",
+	)
+	for _, i := range issues {
+		if i.Severity != SeverityError {
+			t.Error("expected an error, got", i)
+		}
+	}
+	if !strings.Contains(issues[4].Message, "Tile at 1,0 is tile 0 of tileset other") || !strings.Contains(issues[4].Message, "1 other cells") {
+		t.Error("unexpected message", issues[4].Message)
+	}
+}
+
+func TestValidateOrientation(t *testing.T) {
+	m := common.NewMap(common.TileWidth(32), common.TileHeight(16), common.OrientationHexagonal(20, "y", "odd"))
+	expectIssues(t, Validate(m), "orientation map")
+
+	m = common.NewMap(common.OrientationStaggered("x", "odd"), common.OrientationOrthogonal())
+	issues := Validate(m)
+	expectIssues(t, issues, "orientation map")
+	if issues[0].Severity != SeverityWarning {
+		t.Error("expected a warning, got", issues[0])
+	}
+}
+
+func TestValidateProperties(t *testing.T) {
+	m := validMap()
+	types := common.NewPropertyTypes()
+	types.AddClasses(common.NewCustomClass("Stats",
+		common.NewProp("hp").SetInt(10),
+		common.NewProp("speed").SetFloat(1),
+	))
+
+	layer := m.TileLayers()[0]
+	layer.UpdateProperties(
+		common.NewProp("stats").SetClass("Stats",
+			common.NewProp("hp").SetFloat(2.5),
+			common.NewProp("mana").SetInt(3),
+		),
+		common.NewProp("boss").SetString("x").SetPropertyType("Stats"),
+		common.NewProp("target").SetObject(99),
+	)
+
+	expectIssues(t, Validate(m, WithPropertyTypes(types)),
+		"property-type map/layer[ground]/property[boss]",
+		"property-type map/layer[ground]/property[stats]/property[hp]",
+		"property-type map/layer[ground]/property[stats]/property[mana]",
+		"object-reference map/layer[ground]/property[target]",
+	)
+
+	// without types only object references are checked
+	expectIssues(t, Validate(m), "object-reference map/layer[ground]/property[target]")
+}